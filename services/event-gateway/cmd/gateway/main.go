@@ -11,7 +11,11 @@ import (
 	grpcserver "github.com/distributed-event-processor/services/event-gateway/internal/api/grpc/server"
 	httpserver "github.com/distributed-event-processor/services/event-gateway/internal/api/http/server"
 	"github.com/distributed-event-processor/services/event-gateway/internal/config"
+	"github.com/distributed-event-processor/services/event-gateway/internal/dedupe"
+	"github.com/distributed-event-processor/services/event-gateway/internal/ingest"
 	"github.com/distributed-event-processor/services/event-gateway/internal/kafka"
+	"github.com/distributed-event-processor/services/event-gateway/internal/registry"
+	"github.com/distributed-event-processor/services/event-gateway/internal/schema"
 	"go.uber.org/zap"
 )
 
@@ -39,15 +43,46 @@ func main() {
 		zap.String("environment", cfg.Environment),
 		zap.String("version", "1.0.0"))
 
-	// Initialize Kafka producer
-	kafkaProducer, err := kafka.NewProducer(cfg.Kafka, logger)
+	// Initialize Kafka producer, or the in-memory broker in memory mode
+	kafkaProducer, err := kafka.NewPublisher(cfg.Kafka, logger)
 	if err != nil {
 		logger.Fatal("Failed to initialize Kafka producer", zap.Error(err))
 	}
+
+	// Deduplicate retried events by idempotency key
+	if cfg.Dedupe.Enabled {
+		deduped, err := dedupe.New(cfg.Dedupe, kafkaProducer, logger)
+		if err != nil {
+			logger.Fatal("Failed to initialize idempotency store", zap.Error(err))
+		}
+		kafkaProducer = deduped
+	}
 	defer kafkaProducer.Close()
 
+	// Keep versioned schemas by event type in the embedded registry
+	var schemaRegistry *registry.Registry
+	if cfg.Registry.Enabled {
+		schemaRegistry, err = registry.New(cfg.Registry)
+		if err != nil {
+			logger.Fatal("Failed to open schema registry", zap.Error(err))
+		}
+	}
+
+	// Check event data against JSON Schemas
+	var schemas *schema.Validator
+	if cfg.Schemas.Enabled {
+		schemas, err = schema.New(cfg.Schemas, schemaRegistry, logger)
+		if err != nil {
+			logger.Fatal("Failed to load schemas", zap.Error(err))
+		}
+		defer schemas.Close()
+	}
+
+	// Both transports convert events with the same client field limits
+	converter := ingest.NewConverter(cfg.Ingest, schemas)
+
 	// Initialize HTTP server
-	httpSrv := httpserver.New(cfg, kafkaProducer, logger)
+	httpSrv := httpserver.New(cfg, kafkaProducer, converter, schemaRegistry, logger)
 
 	// Start HTTP server
 	httpServer := &http.Server{
@@ -67,7 +102,7 @@ func main() {
 	}()
 
 	// Initialize and start gRPC server
-	grpcSrv := grpcserver.New(cfg.GRPC, kafkaProducer, logger)
+	grpcSrv := grpcserver.New(cfg.GRPC, kafkaProducer, converter, logger)
 
 	// Start gRPC server in goroutine
 	grpcErrChan := make(chan error, 1)
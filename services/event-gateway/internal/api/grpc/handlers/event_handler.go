@@ -2,33 +2,54 @@ package handlers
 
 import (
 	"context"
-	"fmt"
+	"errors"
 	"io"
+	"strconv"
+	"sync"
 	"time"
 
+	"github.com/distributed-event-processor/services/event-gateway/internal/config"
+	"github.com/distributed-event-processor/services/event-gateway/internal/dedupe"
+	"github.com/distributed-event-processor/services/event-gateway/internal/ingest"
 	"github.com/distributed-event-processor/services/event-gateway/internal/kafka"
 	"github.com/distributed-event-processor/services/event-gateway/internal/models"
+	"github.com/distributed-event-processor/services/event-gateway/internal/validation"
 	pb "github.com/distributed-event-processor/shared/proto/events/v1"
 	"github.com/google/uuid"
 	"go.uber.org/zap"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
 	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
+// errTransactionRejected is reported for valid events that were not produced
+// because another event in the same transactional batch failed validation
+const errTransactionRejected = "batch rejected: transactional delivery requires every event in the batch to be valid"
+
+// idempotencyKeyMetadata is the call metadata carrying the idempotency key
+const idempotencyKeyMetadata = "idempotency-key"
+
 // EventHandler implements the EventGateway gRPC service
 type EventHandler struct {
 	pb.UnimplementedEventGatewayServer
-	producer *kafka.Producer
-	logger   *zap.Logger
+	producer  kafka.EventPublisher
+	converter *ingest.Converter
+	logger    *zap.Logger
 }
 
-// NewEventHandler creates a new gRPC event handler
-func NewEventHandler(producer *kafka.Producer, logger *zap.Logger) *EventHandler {
+// NewEventHandler creates a new gRPC event handler. A nil converter applies
+// the default ingestion limits.
+func NewEventHandler(producer kafka.EventPublisher, converter *ingest.Converter, logger *zap.Logger) *EventHandler {
+	if converter == nil {
+		converter = ingest.NewConverter(config.IngestConfig{}, nil)
+	}
 	return &EventHandler{
-		producer: producer,
-		logger:   logger,
+		producer:  producer,
+		converter: converter,
+		logger:    logger,
 	}
 }
 
@@ -43,55 +64,79 @@ func (h *EventHandler) IngestEvent(ctx context.Context, req *pb.IngestEventReque
 		zap.String("tenant_id", req.Event.TenantId),
 	)
 
-	// Validate event
-	if err := validateEvent(req.Event); err != nil {
+	// Validate event and convert it to the internal model
+	event, result := h.converter.FromProto(req.Event)
+	if !result.Valid() {
 		h.logger.Warn("Event validation failed",
 			zap.String("request_id", requestID),
-			zap.Error(err),
+			zap.Error(result.Err()),
 		)
-		return nil, status.Error(codes.InvalidArgument, err.Error())
+		return nil, validationStatus(result)
 	}
 
-	// Generate event ID if not provided
-	if req.Event.Id == "" {
-		req.Event.Id = uuid.New().String()
-	}
-
-	// Set timestamp if not provided
-	if req.Event.Timestamp == nil {
-		req.Event.Timestamp = timestamppb.Now()
+	if err := checkIdempotencyKey(ctx); err != nil {
+		return nil, err
 	}
 
-	// Convert to internal model
-	event := protoToModel(req.Event)
+	setRequestMetadata(ctx, event, requestID)
+	setIdempotencyKey(event, getIdempotencyKey(ctx, req.Event, -1))
 
 	// Produce to Kafka
-	partition, offset, err := h.producer.ProduceEvent(ctx, event)
-	if err != nil {
+	delivery := h.producer.Produce(ctx, event)
+	if err := delivery.Err; err != nil {
+		if rejected := idempotencyStatus(err); rejected != nil {
+			h.logger.Warn("Idempotency key rejected",
+				zap.String("request_id", requestID),
+				zap.Error(err),
+			)
+			return nil, rejected
+		}
+
 		h.logger.Error("Failed to produce event to Kafka",
 			zap.String("request_id", requestID),
 			zap.Error(err),
 		)
+		if retryable := retryableStatus(err); retryable != nil {
+			return nil, retryable
+		}
 		return nil, status.Error(codes.Internal, "failed to process event")
 	}
 
 	h.logger.Info("Event successfully ingested",
 		zap.String("request_id", requestID),
-		zap.String("event_id", req.Event.Id),
-		zap.Int32("partition", partition),
-		zap.Int64("offset", offset),
+		zap.String("event_id", event.ID),
+		zap.String("topic", delivery.Topic),
+		zap.Int32("partition", delivery.Partition),
+		zap.Int64("offset", delivery.Offset),
+		zap.Bool("duplicate", delivery.Duplicate),
 	)
 
 	return &pb.IngestEventResponse{
-		EventId:    req.Event.Id,
-		RequestId:  requestID,
-		AcceptedAt: timestamppb.Now(),
-		Partition:  partition,
-		Offset:     offset,
-		Status:     pb.IngestionStatus_INGESTION_STATUS_ACCEPTED,
+		EventId:     event.ID,
+		RequestId:   requestID,
+		AcceptedAt:  timestamppb.Now(),
+		Partition:   delivery.Partition,
+		Offset:      delivery.Offset,
+		Status:      ingestionStatus(delivery),
+		Topic:       delivery.Topic,
+		RoutingRule: delivery.Rule,
+		Cluster:     delivery.Cluster,
+		Duplicate:   delivery.Duplicate,
 	}, nil
 }
 
+// ingestionStatus reports events that were spooled while Kafka was unavailable
+// as queued rather than accepted, and events dropped by load shedding as rejected
+func ingestionStatus(delivery kafka.DeliveryResult) pb.IngestionStatus {
+	if delivery.Dropped {
+		return pb.IngestionStatus_INGESTION_STATUS_REJECTED
+	}
+	if delivery.Queued {
+		return pb.IngestionStatus_INGESTION_STATUS_QUEUED
+	}
+	return pb.IngestionStatus_INGESTION_STATUS_ACCEPTED
+}
+
 // IngestEventBatch handles batch event ingestion
 func (h *EventHandler) IngestEventBatch(ctx context.Context, req *pb.IngestEventBatchRequest) (*pb.IngestEventBatchResponse, error) {
 	requestID := getRequestID(ctx)
@@ -102,22 +147,30 @@ func (h *EventHandler) IngestEventBatch(ctx context.Context, req *pb.IngestEvent
 		zap.Int("batch_size", len(req.Events)),
 	)
 
-	if len(req.Events) == 0 {
-		return nil, status.Error(codes.InvalidArgument, "batch cannot be empty")
+	if sizeResult := validation.ValidateBatchSize(len(req.Events)); !sizeResult.Valid() {
+		return nil, validationStatus(sizeResult)
+	}
+	if err := checkIdempotencyKey(ctx); err != nil {
+		return nil, err
 	}
 
 	results := make([]*pb.IngestEventResponse, 0, len(req.Events))
 	successCount := int32(0)
 	failureCount := int32(0)
 
+	// Validate every event first; valid ones are produced together as one batch
+	events := make([]*models.Event, 0, len(req.Events))
+	resultIndices := make([]int, 0, len(req.Events))
+
 	for i, event := range req.Events {
-		// Validate event
-		if err := validateEvent(event); err != nil {
+		// Validate event and convert it to the internal model
+		internalEvent, validationResult := h.converter.FromProto(event)
+		if !validationResult.Valid() {
 			result := &pb.IngestEventResponse{
-				EventId:      event.Id,
+				EventId:      event.GetId(),
 				RequestId:    requestID,
 				Status:       pb.IngestionStatus_INGESTION_STATUS_REJECTED,
-				ErrorMessage: err.Error(),
+				ErrorMessage: validationResult.Err().Error(),
 			}
 			results = append(results, result)
 			failureCount++
@@ -133,47 +186,97 @@ func (h *EventHandler) IngestEventBatch(ctx context.Context, req *pb.IngestEvent
 			continue
 		}
 
-		// Generate event ID if not provided
-		if event.Id == "" {
-			event.Id = uuid.New().String()
-		}
-
-		// Set timestamp if not provided
-		if event.Timestamp == nil {
-			event.Timestamp = timestamppb.Now()
-		}
-
-		// Convert to internal model
-		internalEvent := protoToModel(event)
+		// Reserve the event's slot in the results
+		setRequestMetadata(ctx, internalEvent, requestID)
+		setIdempotencyKey(internalEvent, getIdempotencyKey(ctx, event, i))
+		events = append(events, internalEvent)
+		resultIndices = append(resultIndices, len(results))
+		results = append(results, nil)
+	}
 
-		// Produce to Kafka
-		partition, offset, err := h.producer.ProduceEvent(ctx, internalEvent)
-		if err != nil {
-			result := &pb.IngestEventResponse{
-				EventId:      event.Id,
+	// Transactional batches are all-or-nothing, so one invalid event rejects the rest
+	if len(events) > 0 && failureCount > 0 && h.producer.Transactional() {
+		for j, event := range events {
+			results[resultIndices[j]] = &pb.IngestEventResponse{
+				EventId:      event.ID,
 				RequestId:    requestID,
-				Status:       pb.IngestionStatus_INGESTION_STATUS_FAILED,
-				ErrorMessage: "failed to produce to Kafka",
+				Status:       pb.IngestionStatus_INGESTION_STATUS_REJECTED,
+				ErrorMessage: errTransactionRejected,
 			}
-			results = append(results, result)
 			failureCount++
+		}
+		events = events[:0]
+	}
 
-			if req.FailFast {
-				break
+	// Produce to Kafka
+	var retryable, firstFailure error
+	if len(events) > 0 {
+		deliveries := h.producer.ProduceBatch(ctx, events)
+
+		for j, delivery := range deliveries {
+			if delivery.Err != nil {
+				if st := retryableStatus(delivery.Err); st != nil {
+					retryable = st
+				}
+				if firstFailure == nil {
+					firstFailure = delivery.Err
+				}
+				results[resultIndices[j]] = &pb.IngestEventResponse{
+					EventId:      events[j].ID,
+					RequestId:    requestID,
+					Status:       pb.IngestionStatus_INGESTION_STATUS_FAILED,
+					ErrorMessage: delivery.Err.Error(),
+					Topic:        delivery.Topic,
+					RoutingRule:  delivery.Rule,
+					Cluster:      delivery.Cluster,
+				}
+				failureCount++
+				continue
 			}
-			continue
-		}
 
-		result := &pb.IngestEventResponse{
-			EventId:    event.Id,
-			RequestId:  requestID,
-			AcceptedAt: timestamppb.Now(),
-			Partition:  partition,
-			Offset:     offset,
-			Status:     pb.IngestionStatus_INGESTION_STATUS_ACCEPTED,
+			results[resultIndices[j]] = &pb.IngestEventResponse{
+				EventId:     events[j].ID,
+				RequestId:   requestID,
+				AcceptedAt:  timestamppb.Now(),
+				Partition:   delivery.Partition,
+				Offset:      delivery.Offset,
+				Status:      ingestionStatus(delivery),
+				Topic:       delivery.Topic,
+				RoutingRule: delivery.Rule,
+				Cluster:     delivery.Cluster,
+				Duplicate:   delivery.Duplicate,
+			}
+			// A shed event never reaches Kafka, so it counts as failed
+			if delivery.Dropped {
+				failureCount++
+				continue
+			}
+			successCount++
 		}
-		results = append(results, result)
-		successCount++
+	}
+
+	// Nothing was ingested because the breaker or a priority lane rejected the
+	// sends, so the call fails just like a single event would and the client
+	// can retry the whole batch. Once any event got through, retrying it
+	// would send that event again, so the results are returned instead.
+	if successCount == 0 && retryable != nil {
+		h.logger.Warn("Batch rejected before reaching Kafka",
+			zap.String("request_id", requestID),
+			zap.Int("batch_size", len(events)),
+		)
+		return nil, retryable
+	}
+
+	// The events were sent together, so fail-fast cannot stop the ones after
+	// a failed delivery; the call fails with the first failure instead
+	if req.FailFast && firstFailure != nil {
+		h.logger.Warn("Batch failed due to fail-fast",
+			zap.String("request_id", requestID),
+			zap.Int32("success", successCount),
+			zap.Int32("failures", failureCount),
+			zap.Error(firstFailure),
+		)
+		return nil, deliveryStatus(firstFailure)
 	}
 
 	processingTime := time.Since(startTime).Milliseconds()
@@ -194,7 +297,9 @@ func (h *EventHandler) IngestEventBatch(ctx context.Context, req *pb.IngestEvent
 	}, nil
 }
 
-// StreamEvents handles bidirectional streaming for real-time event ingestion
+// StreamEvents handles bidirectional streaming for real-time event ingestion.
+// Events are submitted to Kafka as they arrive and acknowledged as their
+// deliveries complete, so acks may be sent out of order.
 func (h *EventHandler) StreamEvents(stream pb.EventGateway_StreamEventsServer) error {
 	requestID := uuid.New().String()
 	ctx := stream.Context()
@@ -203,7 +308,19 @@ func (h *EventHandler) StreamEvents(stream pb.EventGateway_StreamEventsServer) e
 		zap.String("request_id", requestID),
 	)
 
+	// Acks are sent from delivery goroutines, so serialize writes to the stream
+	// and wait for outstanding deliveries before the handler returns
+	var sendMu sync.Mutex
+	send := func(msg *pb.StreamEventResponse) error {
+		sendMu.Lock()
+		defer sendMu.Unlock()
+		return stream.Send(msg)
+	}
+
+	var pending sync.WaitGroup
+
 	defer func() {
+		pending.Wait()
 		h.logger.Info("Stream connection closed",
 			zap.String("request_id", requestID),
 		)
@@ -229,37 +346,60 @@ func (h *EventHandler) StreamEvents(stream pb.EventGateway_StreamEventsServer) e
 			switch msg := req.Message.(type) {
 			case *pb.StreamEventRequest_Event:
 				// Handle event ingestion
-				response, err := h.handleStreamEvent(ctx, requestID, msg.Event)
+				event, delivery, err := h.submitStreamEvent(ctx, msg.Event, requestID)
 				if err != nil {
-					h.logger.Error("Failed to handle stream event",
-						zap.String("request_id", requestID),
-						zap.Error(err),
-					)
-					// Send error response
-					statusMsg := &pb.StreamEventResponse{
-						Message: &pb.StreamEventResponse_Status{
-							Status: &pb.StreamStatus{
-								Code:      pb.StatusCode_STATUS_CODE_ERROR,
-								Message:   err.Error(),
-								Timestamp: timestamppb.Now(),
-							},
-						},
-					}
-					if err := stream.Send(statusMsg); err != nil {
+					if err := send(streamErrorStatus(err)); err != nil {
 						return err
 					}
 					continue
 				}
 
-				// Send acknowledgment
-				ackMsg := &pb.StreamEventResponse{
-					Message: &pb.StreamEventResponse_Ack{
-						Ack: response,
-					},
-				}
-				if err := stream.Send(ackMsg); err != nil {
-					return err
-				}
+				pending.Add(1)
+				go func() {
+					defer pending.Done()
+
+					result := <-delivery
+					if result.Err != nil {
+						h.logger.Error("Failed to handle stream event",
+							zap.String("request_id", requestID),
+							zap.String("event_id", event.ID),
+							zap.Error(result.Err),
+						)
+						// Send error response
+						if err := send(streamErrorStatus(result.Err)); err != nil {
+							h.logger.Warn("Failed to send stream error status",
+								zap.String("request_id", requestID),
+								zap.Error(err),
+							)
+						}
+						return
+					}
+
+					// Send acknowledgment
+					ackMsg := &pb.StreamEventResponse{
+						Message: &pb.StreamEventResponse_Ack{
+							Ack: &pb.IngestEventResponse{
+								EventId:     event.ID,
+								RequestId:   requestID,
+								AcceptedAt:  timestamppb.Now(),
+								Partition:   result.Partition,
+								Offset:      result.Offset,
+								Status:      ingestionStatus(result),
+								Topic:       result.Topic,
+								RoutingRule: result.Rule,
+								Cluster:     result.Cluster,
+								Duplicate:   result.Duplicate,
+							},
+						},
+					}
+					if err := send(ackMsg); err != nil {
+						h.logger.Warn("Failed to send stream ack",
+							zap.String("request_id", requestID),
+							zap.String("event_id", event.ID),
+							zap.Error(err),
+						)
+					}
+				}()
 
 			case *pb.StreamEventRequest_Ping:
 				// Handle ping
@@ -270,7 +410,7 @@ func (h *EventHandler) StreamEvents(stream pb.EventGateway_StreamEventsServer) e
 						},
 					},
 				}
-				if err := stream.Send(pongMsg); err != nil {
+				if err := send(pongMsg); err != nil {
 					return err
 				}
 
@@ -292,35 +432,20 @@ func (h *EventHandler) ValidateEvent(ctx context.Context, req *pb.ValidateEventR
 
 	h.logger.Info("Received validation request",
 		zap.String("request_id", requestID),
-		zap.String("event_type", req.Event.Type),
+		zap.String("event_type", req.Event.GetType()),
 	)
 
-	errors := make([]*pb.ValidationError, 0)
-
-	// Validate event
-	if err := validateEvent(req.Event); err != nil {
-		errors = append(errors, &pb.ValidationError{
-			Field:   "event",
-			Message: err.Error(),
-			Code:    "VALIDATION_FAILED",
-		})
-	}
+	// Only the checks run; nothing is produced. With validate_schema, data
+	// that does not match its schema is an error whatever the type's mode.
+	_, result := h.converter.ValidateProto(req.Event, req.ValidateSchema)
 
-	// Check required fields
-	if req.Event.Type == "" {
-		errors = append(errors, &pb.ValidationError{
-			Field:   "type",
-			Message: "event type is required",
-			Code:    "REQUIRED_FIELD",
-		})
-	}
-
-	if req.Event.Source == "" {
-		errors = append(errors, &pb.ValidationError{
-			Field:   "source",
-			Message: "event source is required",
-			Code:    "REQUIRED_FIELD",
-		})
+	errors := make([]*pb.ValidationError, len(result.Errors))
+	for i, violation := range result.Errors {
+		errors[i] = &pb.ValidationError{
+			Field:   violation.Field,
+			Message: violation.Message,
+			Code:    violation.Code,
+		}
 	}
 
 	isValid := len(errors) == 0
@@ -328,6 +453,7 @@ func (h *EventHandler) ValidateEvent(ctx context.Context, req *pb.ValidateEventR
 	return &pb.ValidateEventResponse{
 		IsValid:   isValid,
 		Errors:    errors,
+		Warnings:  result.Warnings,
 		RequestId: requestID,
 	}, nil
 }
@@ -343,14 +469,28 @@ func (h *EventHandler) HealthCheck(ctx context.Context, req *pb.HealthCheckReque
 	if req.Detailed {
 		components := make(map[string]*pb.ComponentHealth)
 
-		// Check Kafka connectivity
+		// Report the cached result of the background Kafka health check
 		kafkaHealth := &pb.ComponentHealth{
-			Status:    pb.HealthStatus_HEALTH_STATUS_UP,
-			Message:   "Kafka producer is healthy",
+			Status:    pb.HealthStatus_HEALTH_STATUS_DOWN,
+			Message:   "Kafka producer is not configured",
 			LastCheck: timestamppb.Now(),
 		}
+		if h.producer != nil {
+			status := h.producer.Health()
+			kafkaHealth.Message = status.Message
+			kafkaHealth.Details = status.Details
+			if !status.LastCheck.IsZero() {
+				kafkaHealth.LastCheck = timestamppb.New(status.LastCheck)
+			}
+			if status.Healthy {
+				kafkaHealth.Status = pb.HealthStatus_HEALTH_STATUS_UP
+			}
+		}
 		components["kafka"] = kafkaHealth
 
+		if kafkaHealth.Status != pb.HealthStatus_HEALTH_STATUS_UP {
+			response.Status = pb.ServiceStatus_SERVICE_STATUS_DEGRADED
+		}
 		response.Components = components
 	}
 
@@ -359,86 +499,162 @@ func (h *EventHandler) HealthCheck(ctx context.Context, req *pb.HealthCheckReque
 
 // Helper functions
 
-func (h *EventHandler) handleStreamEvent(ctx context.Context, requestID string, event *pb.Event) (*pb.IngestEventResponse, error) {
-	// Validate event
-	if err := validateEvent(event); err != nil {
-		return nil, err
+// submitStreamEvent validates a streamed event and hands it to the producer
+// without waiting for the broker acknowledgement
+func (h *EventHandler) submitStreamEvent(ctx context.Context, event *pb.Event, requestID string) (*models.Event, <-chan kafka.DeliveryResult, error) {
+	// Validate event and convert it to the internal model
+	internalEvent, result := h.converter.FromProto(event)
+	if !result.Valid() {
+		return nil, nil, result.Err()
 	}
 
-	// Generate event ID if not provided
-	if event.Id == "" {
-		event.Id = uuid.New().String()
-	}
+	setRequestMetadata(ctx, internalEvent, requestID)
+
+	// Streamed events are deduplicated by the IDs the client gives them
+	setIdempotencyKey(internalEvent, event.GetId())
 
-	// Set timestamp if not provided
-	if event.Timestamp == nil {
-		event.Timestamp = timestamppb.Now()
+	// Produce to Kafka
+	return internalEvent, h.producer.ProduceEventAsync(ctx, internalEvent), nil
+}
+
+// deliveryStatus converts a failed delivery into the status a single event
+// failing the same way gets
+func deliveryStatus(err error) error {
+	if st := idempotencyStatus(err); st != nil {
+		return st
 	}
+	if st := retryableStatus(err); st != nil {
+		return st
+	}
+	return status.Error(codes.Internal, "failed to process event")
+}
 
-	// Convert to internal model
-	internalEvent := protoToModel(event)
+// validationStatus converts the violations of an invalid event into an
+// InvalidArgument status carrying one field violation for each of them
+func validationStatus(result validation.Result) error {
+	st := status.New(codes.InvalidArgument, result.Err().Error())
 
-	// Produce to Kafka
-	partition, offset, err := h.producer.ProduceEvent(ctx, internalEvent)
-	if err != nil {
-		return nil, err
+	violations := make([]*errdetails.BadRequest_FieldViolation, len(result.Errors))
+	for i, violation := range result.Errors {
+		violations[i] = &errdetails.BadRequest_FieldViolation{
+			Field:       violation.Field,
+			Description: violation.Message,
+		}
 	}
+	if detailed, detailErr := st.WithDetails(&errdetails.BadRequest{
+		FieldViolations: violations,
+	}); detailErr == nil {
+		st = detailed
+	}
+	return st.Err()
+}
 
-	return &pb.IngestEventResponse{
-		EventId:    event.Id,
-		RequestId:  requestID,
-		AcceptedAt: timestamppb.Now(),
-		Partition:  partition,
-		Offset:     offset,
-		Status:     pb.IngestionStatus_INGESTION_STATUS_ACCEPTED,
-	}, nil
+// retryableStatus converts a send failed fast by the circuit breaker into an
+// Unavailable status, and one shed by a priority lane into ResourceExhausted,
+// both carrying the retry delay. It returns nil for other errors.
+func retryableStatus(err error) error {
+	var st *status.Status
+	var retryAfter time.Duration
+
+	var openErr *kafka.CircuitOpenError
+	var shedErr *kafka.LoadShedError
+	switch {
+	case errors.As(err, &openErr):
+		st = status.New(codes.Unavailable, "Kafka is unavailable, retry later")
+		retryAfter = openErr.RetryAfter
+	case errors.As(err, &shedErr):
+		st = status.New(codes.ResourceExhausted, "The gateway is shedding load, retry later")
+		retryAfter = shedErr.RetryAfter
+	default:
+		return nil
+	}
+
+	if detailed, detailErr := st.WithDetails(&errdetails.RetryInfo{
+		RetryDelay: durationpb.New(retryAfter),
+	}); detailErr == nil {
+		st = detailed
+	}
+	return st.Err()
 }
 
-func validateEvent(event *pb.Event) error {
-	if event == nil {
-		return fmt.Errorf("event cannot be nil")
+// idempotencyStatus converts a rejected idempotency key into Aborted while the
+// first request with the key is in flight, and into InvalidArgument when the
+// key was used for a different event. It returns nil for other errors.
+func idempotencyStatus(err error) error {
+	switch {
+	case errors.Is(err, dedupe.ErrInProgress):
+		return status.Error(codes.Aborted, err.Error())
+	case errors.Is(err, dedupe.ErrKeyReused):
+		return status.Error(codes.InvalidArgument, err.Error())
 	}
+	return nil
+}
 
-	if event.Type == "" {
-		return fmt.Errorf("event type is required")
+func streamErrorStatus(err error) *pb.StreamEventResponse {
+	return &pb.StreamEventResponse{
+		Message: &pb.StreamEventResponse_Status{
+			Status: &pb.StreamStatus{
+				Code:      pb.StatusCode_STATUS_CODE_ERROR,
+				Message:   err.Error(),
+				Timestamp: timestamppb.Now(),
+			},
+		},
 	}
+}
 
-	if event.Source == "" {
-		return fmt.Errorf("event source is required")
+// setRequestMetadata records the request ID and the caller's W3C trace context
+// on the event, so the producer can expose them as Kafka headers
+func setRequestMetadata(ctx context.Context, event *models.Event, requestID string) {
+	if event.Metadata == nil {
+		event.Metadata = make(map[string]string)
 	}
+	event.Metadata[models.MetadataRequestID] = requestID
 
-	if event.Data == nil {
-		return fmt.Errorf("event data is required")
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return
+	}
+	if traceParent := md.Get("traceparent"); len(traceParent) > 0 {
+		event.Metadata[models.MetadataTraceParent] = traceParent[0]
+		if traceState := md.Get("tracestate"); len(traceState) > 0 {
+			event.Metadata[models.MetadataTraceState] = traceState[0]
+		}
 	}
+}
 
+// checkIdempotencyKey refuses idempotency-key metadata that is too long to
+// store, as the HTTP API does for the Idempotency-Key header. Event IDs used as
+// keys are already limited by validation.
+func checkIdempotencyKey(ctx context.Context) error {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return nil
+	}
+	if keys := md.Get(idempotencyKeyMetadata); len(keys) > 0 && len(keys[0]) > dedupe.MaxKeyLength {
+		return status.Errorf(codes.InvalidArgument, "idempotency-key must be at most %d characters", dedupe.MaxKeyLength)
+	}
 	return nil
 }
 
-func protoToModel(event *pb.Event) *models.Event {
-	var timestamp time.Time
-	if event.Timestamp != nil {
-		timestamp = event.Timestamp.AsTime()
-	} else {
-		timestamp = time.Now()
-	}
-
-	// Convert protobuf Struct to map
-	data := make(map[string]interface{})
-	if event.Data != nil {
-		data = event.Data.AsMap()
-	}
-
-	return &models.Event{
-		ID:            event.Id,
-		Type:          event.Type,
-		Source:        event.Source,
-		TenantID:      event.TenantId,
-		Data:          data,
-		Timestamp:     timestamp,
-		SchemaVersion: event.SchemaVersion,
-		Metadata:      event.Metadata,
-		CorrelationID: event.CorrelationId,
-		Priority:      int(event.Priority),
+// getIdempotencyKey returns the key that deduplicates event: the call's
+// idempotency-key metadata, suffixed with the event's index within a batch
+// (index >= 0), or else the event ID chosen by the client
+func getIdempotencyKey(ctx context.Context, event *pb.Event, index int) string {
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if keys := md.Get(idempotencyKeyMetadata); len(keys) > 0 && keys[0] != "" {
+			if index >= 0 {
+				return keys[0] + ":" + strconv.Itoa(index)
+			}
+			return keys[0]
+		}
+	}
+	return event.GetId()
+}
+
+// setIdempotencyKey hands the idempotency key to the publisher, if there is one
+func setIdempotencyKey(event *models.Event, key string) {
+	if key != "" {
+		event.Metadata[models.MetadataIdempotencyKey] = key
 	}
 }
 
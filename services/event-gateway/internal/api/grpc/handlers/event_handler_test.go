@@ -2,80 +2,34 @@ package handlers
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
-
+	"time"
+
+	"github.com/distributed-event-processor/services/event-gateway/internal/config"
+	"github.com/distributed-event-processor/services/event-gateway/internal/dedupe"
+	"github.com/distributed-event-processor/services/event-gateway/internal/ingest"
+	"github.com/distributed-event-processor/services/event-gateway/internal/kafka"
+	"github.com/distributed-event-processor/services/event-gateway/internal/models"
+	"github.com/distributed-event-processor/services/event-gateway/internal/schema"
 	pb "github.com/distributed-event-processor/shared/proto/events/v1"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"go.uber.org/zap"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/types/known/structpb"
-	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
-func TestValidateEvent_Valid(t *testing.T) {
-	data, err := structpb.NewStruct(map[string]interface{}{
-		"key": "value",
-	})
-	require.NoError(t, err)
-
-	event := &pb.Event{
-		Type:   "test.event",
-		Source: "test-service",
-		Data:   data,
-	}
-
-	err = validateEvent(event)
-	assert.NoError(t, err)
-}
-
-func TestValidateEvent_NilEvent(t *testing.T) {
-	err := validateEvent(nil)
-	assert.Error(t, err)
-	assert.Contains(t, err.Error(), "cannot be nil")
-}
-
-func TestValidateEvent_MissingType(t *testing.T) {
-	data, _ := structpb.NewStruct(map[string]interface{}{"key": "value"})
-	event := &pb.Event{
-		Source: "test-service",
-		Data:   data,
-	}
-
-	err := validateEvent(event)
-	assert.Error(t, err)
-	assert.Contains(t, err.Error(), "type is required")
-}
-
-func TestValidateEvent_MissingSource(t *testing.T) {
-	data, _ := structpb.NewStruct(map[string]interface{}{"key": "value"})
-	event := &pb.Event{
-		Type: "test.event",
-		Data: data,
-	}
-
-	err := validateEvent(event)
-	assert.Error(t, err)
-	assert.Contains(t, err.Error(), "source is required")
-}
-
-func TestValidateEvent_MissingData(t *testing.T) {
-	event := &pb.Event{
-		Type:   "test.event",
-		Source: "test-service",
-		Data:   nil,
-	}
-
-	err := validateEvent(event)
-	assert.Error(t, err)
-	assert.Contains(t, err.Error(), "data is required")
-}
-
 func TestHealthCheck_Basic(t *testing.T) {
 	logger, _ := zap.NewDevelopment()
-	handler := NewEventHandler(nil, logger)
+	handler := NewEventHandler(nil, nil, logger)
 
 	req := &pb.HealthCheckRequest{
 		Detailed: false,
@@ -92,7 +46,7 @@ func TestHealthCheck_Basic(t *testing.T) {
 
 func TestHealthCheck_Detailed(t *testing.T) {
 	logger, _ := zap.NewDevelopment()
-	handler := NewEventHandler(nil, logger)
+	handler := NewEventHandler(nil, nil, logger)
 
 	req := &pb.HealthCheckRequest{
 		Detailed: true,
@@ -104,12 +58,15 @@ func TestHealthCheck_Detailed(t *testing.T) {
 	assert.NotNil(t, resp)
 	assert.NotNil(t, resp.Components)
 	assert.Contains(t, resp.Components, "kafka")
-	assert.Equal(t, pb.HealthStatus_HEALTH_STATUS_UP, resp.Components["kafka"].Status)
+
+	// Without a producer Kafka cannot be reached
+	assert.Equal(t, pb.HealthStatus_HEALTH_STATUS_DOWN, resp.Components["kafka"].Status)
+	assert.Equal(t, pb.ServiceStatus_SERVICE_STATUS_DEGRADED, resp.Status)
 }
 
 func TestValidateEventRPC_InvalidEvent(t *testing.T) {
 	logger, _ := zap.NewDevelopment()
-	handler := NewEventHandler(nil, logger)
+	handler := NewEventHandler(nil, nil, logger)
 
 	req := &pb.ValidateEventRequest{
 		Event: &pb.Event{
@@ -125,9 +82,51 @@ func TestValidateEventRPC_InvalidEvent(t *testing.T) {
 	assert.NotEmpty(t, resp.Errors)
 }
 
+func TestValidateEventRPC_ReportsEveryViolation(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+	handler := NewEventHandler(nil, nil, logger)
+
+	req := &pb.ValidateEventRequest{
+		Event: &pb.Event{
+			Id:       "not a valid id",
+			Priority: 11,
+		},
+	}
+
+	resp, err := handler.ValidateEvent(context.Background(), req)
+
+	require.NoError(t, err)
+	assert.False(t, resp.IsValid)
+
+	fields := make(map[string]string)
+	for _, violation := range resp.Errors {
+		fields[violation.Field] = violation.Code
+	}
+	assert.Equal(t, map[string]string{
+		"type":     "REQUIRED_FIELD",
+		"source":   "REQUIRED_FIELD",
+		"data":     "REQUIRED_FIELD",
+		"id":       "INVALID_FORMAT",
+		"priority": "OUT_OF_RANGE",
+	}, fields)
+}
+
+func TestValidateEventRPC_NilEvent(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+	handler := NewEventHandler(nil, nil, logger)
+
+	resp, err := handler.ValidateEvent(context.Background(), &pb.ValidateEventRequest{})
+
+	require.NoError(t, err)
+	assert.False(t, resp.IsValid)
+	require.Len(t, resp.Errors, 1)
+	assert.Equal(t, "event", resp.Errors[0].Field)
+	assert.Contains(t, resp.Errors[0].Message, "cannot be nil")
+}
+
 func TestValidateEventRPC_ValidEvent(t *testing.T) {
 	logger, _ := zap.NewDevelopment()
-	handler := NewEventHandler(nil, logger)
+	handler := NewEventHandler(nil, nil, logger)
 
 	data, _ := structpb.NewStruct(map[string]interface{}{"key": "value"})
 	req := &pb.ValidateEventRequest{
@@ -146,9 +145,32 @@ func TestValidateEventRPC_ValidEvent(t *testing.T) {
 	assert.Empty(t, resp.Errors)
 }
 
+func TestValidateEventRPC_Warnings(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+	handler := NewEventHandler(nil, nil, logger)
+
+	data, _ := structpb.NewStruct(map[string]interface{}{})
+	req := &pb.ValidateEventRequest{
+		Event: &pb.Event{
+			Type:     "test.event",
+			Source:   "test-service",
+			Data:     data,
+			Metadata: map[string]string{"request_id": "client-value"},
+		},
+	}
+
+	resp, err := handler.ValidateEvent(context.Background(), req)
+
+	require.NoError(t, err)
+	assert.True(t, resp.IsValid)
+	require.Len(t, resp.Warnings, 2)
+	assert.Contains(t, resp.Warnings[0], "data is empty")
+	assert.Contains(t, resp.Warnings[1], "request_id")
+}
+
 func TestIngestEvent_ValidationFailure(t *testing.T) {
 	logger, _ := zap.NewDevelopment()
-	handler := NewEventHandler(nil, logger)
+	handler := NewEventHandler(nil, nil, logger)
 
 	req := &pb.IngestEventRequest{
 		Event: &pb.Event{
@@ -162,11 +184,22 @@ func TestIngestEvent_ValidationFailure(t *testing.T) {
 	st, ok := status.FromError(err)
 	assert.True(t, ok)
 	assert.Equal(t, codes.InvalidArgument, st.Code())
+	assert.Equal(t, "event type is required; event source is required; event data is required", st.Message())
+
+	// Every violation is attached as a field violation
+	require.Len(t, st.Details(), 1)
+	badRequest, ok := st.Details()[0].(*errdetails.BadRequest)
+	require.True(t, ok)
+	fields := make([]string, 0, len(badRequest.FieldViolations))
+	for _, violation := range badRequest.FieldViolations {
+		fields = append(fields, violation.Field)
+	}
+	assert.Equal(t, []string{"type", "source", "data"}, fields)
 }
 
 func TestIngestEventBatch_EmptyBatch(t *testing.T) {
 	logger, _ := zap.NewDevelopment()
-	handler := NewEventHandler(nil, logger)
+	handler := NewEventHandler(nil, nil, logger)
 
 	req := &pb.IngestEventBatchRequest{
 		Events: []*pb.Event{},
@@ -181,71 +214,21 @@ func TestIngestEventBatch_EmptyBatch(t *testing.T) {
 	assert.Contains(t, st.Message(), "batch cannot be empty")
 }
 
-func TestProtoToModel(t *testing.T) {
-	data, _ := structpb.NewStruct(map[string]interface{}{
-		"key":    "value",
-		"number": float64(42),
-	})
-
-	timestamp := timestamppb.Now()
-	event := &pb.Event{
-		Id:            "event-123",
-		Type:          "user.created",
-		Source:        "user-service",
-		TenantId:      "tenant-456",
-		Data:          data,
-		Timestamp:     timestamp,
-		SchemaVersion: "1.0.0",
-		Metadata: map[string]string{
-			"env": "test",
-		},
-		CorrelationId: "corr-789",
-		Priority:      1,
-	}
-
-	model := protoToModel(event)
-
-	assert.Equal(t, "event-123", model.ID)
-	assert.Equal(t, "user.created", model.Type)
-	assert.Equal(t, "user-service", model.Source)
-	assert.Equal(t, "tenant-456", model.TenantID)
-	assert.Equal(t, "value", model.Data["key"])
-	assert.Equal(t, float64(42), model.Data["number"])
-	assert.Equal(t, timestamp.AsTime(), model.Timestamp)
-	assert.Equal(t, "1.0.0", model.SchemaVersion)
-	assert.Equal(t, "test", model.Metadata["env"])
-	assert.Equal(t, "corr-789", model.CorrelationID)
-	assert.Equal(t, 1, model.Priority)
-}
-
-func TestProtoToModel_NilTimestamp(t *testing.T) {
-	data, _ := structpb.NewStruct(map[string]interface{}{"key": "value"})
-	event := &pb.Event{
-		Id:        "event-123",
-		Type:      "test.event",
-		Source:    "test-service",
-		Data:      data,
-		Timestamp: nil,
-	}
-
-	model := protoToModel(event)
-
-	assert.NotNil(t, model.Timestamp)
-	assert.False(t, model.Timestamp.IsZero())
-}
+func TestIngestEventBatch_TooLarge(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+	handler := NewEventHandler(nil, nil, logger)
 
-func TestProtoToModel_NilData(t *testing.T) {
-	event := &pb.Event{
-		Id:     "event-123",
-		Type:   "test.event",
-		Source: "test-service",
-		Data:   nil,
+	req := &pb.IngestEventBatchRequest{
+		Events: make([]*pb.Event, 101),
 	}
 
-	model := protoToModel(event)
+	_, err := handler.IngestEventBatch(context.Background(), req)
 
-	assert.NotNil(t, model.Data)
-	assert.Empty(t, model.Data)
+	require.Error(t, err)
+	st, ok := status.FromError(err)
+	assert.True(t, ok)
+	assert.Equal(t, codes.InvalidArgument, st.Code())
+	assert.Contains(t, st.Message(), "more than 100 events")
 }
 
 func TestGetRequestID_FromMetadata(t *testing.T) {
@@ -281,6 +264,28 @@ func TestGetRequestID_NoRequestIDInMetadata(t *testing.T) {
 	assert.Len(t, requestID, 36) // UUID length
 }
 
+func TestSetRequestMetadata(t *testing.T) {
+	md := metadata.New(map[string]string{
+		"traceparent": "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"tracestate":  "vendor=value",
+	})
+	ctx := metadata.NewIncomingContext(context.Background(), md)
+
+	event := &models.Event{ID: "event-1"}
+	setRequestMetadata(ctx, event, "request-1")
+
+	assert.Equal(t, "request-1", event.Metadata[models.MetadataRequestID])
+	assert.Equal(t, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", event.Metadata[models.MetadataTraceParent])
+	assert.Equal(t, "vendor=value", event.Metadata[models.MetadataTraceState])
+}
+
+func TestSetRequestMetadata_NoTraceContext(t *testing.T) {
+	event := &models.Event{ID: "event-1", Metadata: map[string]string{"env": "test"}}
+	setRequestMetadata(context.Background(), event, "request-1")
+
+	assert.Equal(t, map[string]string{"env": "test", models.MetadataRequestID: "request-1"}, event.Metadata)
+}
+
 // Helper function to check gRPC error codes
 func assertGRPCError(t *testing.T, err error, expectedCode codes.Code) {
 	st, ok := status.FromError(err)
@@ -294,7 +299,7 @@ func assertGRPCError(t *testing.T, err error, expectedCode codes.Code) {
 
 func TestIngestEventBatch_AllInvalid(t *testing.T) {
 	logger, _ := zap.NewDevelopment()
-	handler := NewEventHandler(nil, logger)
+	handler := NewEventHandler(nil, nil, logger)
 
 	req := &pb.IngestEventBatchRequest{
 		Events: []*pb.Event{
@@ -322,7 +327,7 @@ func TestIngestEventBatch_AllInvalid(t *testing.T) {
 
 func TestHealthCheck_WithProducer(t *testing.T) {
 	logger, _ := zap.NewDevelopment()
-	handler := NewEventHandler(nil, logger)
+	handler := NewEventHandler(nil, nil, logger)
 
 	req := &pb.HealthCheckRequest{
 		Detailed: true,
@@ -338,3 +343,239 @@ func TestHealthCheck_WithProducer(t *testing.T) {
 	kafkaHealth := resp.Components["kafka"]
 	assert.NotNil(t, kafkaHealth)
 }
+
+func newTestBroker(t *testing.T) *kafka.MemoryBroker {
+	broker, err := kafka.NewMemoryBroker(config.KafkaConfig{Topic: "events"}, zap.NewNop())
+	require.NoError(t, err)
+	return broker
+}
+
+func testProtoEvent(t *testing.T) *pb.Event {
+	data, err := structpb.NewStruct(map[string]interface{}{"user_id": "123"})
+	require.NoError(t, err)
+	return &pb.Event{Type: "user.created", Source: "test-service", Data: data}
+}
+
+func TestIngestEvent_Success(t *testing.T) {
+	broker := newTestBroker(t)
+	handler := NewEventHandler(broker, nil, zap.NewNop())
+
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("x-request-id", "request-1"))
+	resp, err := handler.IngestEvent(ctx, &pb.IngestEventRequest{Event: testProtoEvent(t)})
+
+	require.NoError(t, err)
+	assert.NotEmpty(t, resp.EventId)
+	assert.Equal(t, "request-1", resp.RequestId)
+	assert.Equal(t, "events", resp.Topic)
+	assert.Equal(t, kafka.DefaultCluster, resp.Cluster)
+	assert.Equal(t, pb.IngestionStatus_INGESTION_STATUS_ACCEPTED, resp.Status)
+	assert.Equal(t, int64(0), resp.Offset)
+
+	messages := broker.Messages()
+	require.Len(t, messages, 1)
+	assert.Equal(t, resp.EventId, messages[0].Headers["event_id"])
+	assert.Equal(t, "request-1", messages[0].Headers["request_id"])
+}
+
+func TestIngestEvent_KafkaFailure(t *testing.T) {
+	broker := newTestBroker(t)
+	broker.SetError(errors.New("broker down"))
+	handler := NewEventHandler(broker, nil, zap.NewNop())
+
+	_, err := handler.IngestEvent(context.Background(), &pb.IngestEventRequest{Event: testProtoEvent(t)})
+
+	assertGRPCError(t, err, codes.Internal)
+}
+
+func TestIngestEventBatch_Success(t *testing.T) {
+	broker := newTestBroker(t)
+	handler := NewEventHandler(broker, nil, zap.NewNop())
+
+	resp, err := handler.IngestEventBatch(context.Background(), &pb.IngestEventBatchRequest{
+		Events: []*pb.Event{testProtoEvent(t), testProtoEvent(t)},
+	})
+
+	require.NoError(t, err)
+	assert.Equal(t, int32(2), resp.SuccessCount)
+	assert.Equal(t, int32(0), resp.FailureCount)
+	assert.Len(t, broker.Messages(), 2)
+}
+
+// batchResultsPublisher answers batches with fixed results
+type batchResultsPublisher struct {
+	*kafka.MemoryBroker
+	results []kafka.DeliveryResult
+}
+
+func (p *batchResultsPublisher) ProduceBatch(ctx context.Context, events []*models.Event) []kafka.DeliveryResult {
+	return p.results
+}
+
+func TestIngestEventBatch_Retryable(t *testing.T) {
+	shed := &kafka.LoadShedError{Lane: "bulk", RetryAfter: time.Second}
+	publisher := &batchResultsPublisher{MemoryBroker: newTestBroker(t)}
+	handler := NewEventHandler(publisher, nil, zap.NewNop())
+	req := &pb.IngestEventBatchRequest{Events: []*pb.Event{testProtoEvent(t), testProtoEvent(t)}}
+
+	// Nothing got through, so the whole call can be retried
+	publisher.results = []kafka.DeliveryResult{{Err: shed}, {Err: shed}}
+	_, err := handler.IngestEventBatch(context.Background(), req)
+	assertGRPCError(t, err, codes.ResourceExhausted)
+
+	// An accepted event must not be sent again, so each event gets its result
+	publisher.results = []kafka.DeliveryResult{{Err: shed}, {Topic: "events", Offset: 7}}
+	resp, err := handler.IngestEventBatch(context.Background(), req)
+	require.NoError(t, err)
+	assert.Equal(t, int32(1), resp.SuccessCount)
+	assert.Equal(t, int32(1), resp.FailureCount)
+	assert.Equal(t, pb.IngestionStatus_INGESTION_STATUS_FAILED, resp.Results[0].Status)
+	assert.Equal(t, pb.IngestionStatus_INGESTION_STATUS_ACCEPTED, resp.Results[1].Status)
+	assert.Equal(t, int64(7), resp.Results[1].Offset)
+}
+
+func TestIngestEventBatch_FailFastDelivery(t *testing.T) {
+	publisher := &batchResultsPublisher{MemoryBroker: newTestBroker(t)}
+	handler := NewEventHandler(publisher, nil, zap.NewNop())
+	publisher.results = []kafka.DeliveryResult{{Topic: "events", Offset: 7}, {Err: errors.New("leader not available")}}
+
+	// Without fail-fast, each event gets its result
+	req := &pb.IngestEventBatchRequest{Events: []*pb.Event{testProtoEvent(t), testProtoEvent(t)}}
+	resp, err := handler.IngestEventBatch(context.Background(), req)
+	require.NoError(t, err)
+	assert.Equal(t, int32(1), resp.SuccessCount)
+	assert.Equal(t, int32(1), resp.FailureCount)
+
+	// With it, a failed delivery fails the call
+	req.FailFast = true
+	_, err = handler.IngestEventBatch(context.Background(), req)
+	assertGRPCError(t, err, codes.Internal)
+
+	shed := &kafka.LoadShedError{Lane: "bulk", RetryAfter: time.Second}
+	publisher.results = []kafka.DeliveryResult{{Topic: "events", Offset: 8}, {Err: shed}}
+	_, err = handler.IngestEventBatch(context.Background(), req)
+	assertGRPCError(t, err, codes.ResourceExhausted)
+}
+
+func TestIngestEventBatch_Dropped(t *testing.T) {
+	publisher := &batchResultsPublisher{MemoryBroker: newTestBroker(t)}
+	handler := NewEventHandler(publisher, nil, zap.NewNop())
+	req := &pb.IngestEventBatchRequest{Events: []*pb.Event{testProtoEvent(t), testProtoEvent(t)}}
+
+	// Shed events never reach Kafka, so they count as failures
+	publisher.results = []kafka.DeliveryResult{{Dropped: true}, {Topic: "events", Offset: 7}}
+	resp, err := handler.IngestEventBatch(context.Background(), req)
+	require.NoError(t, err)
+	assert.Equal(t, int32(1), resp.SuccessCount)
+	assert.Equal(t, int32(1), resp.FailureCount)
+	assert.Equal(t, pb.IngestionStatus_INGESTION_STATUS_REJECTED, resp.Results[0].Status)
+	assert.Equal(t, pb.IngestionStatus_INGESTION_STATUS_ACCEPTED, resp.Results[1].Status)
+}
+
+func TestIngestionStatus(t *testing.T) {
+	assert.Equal(t, pb.IngestionStatus_INGESTION_STATUS_ACCEPTED, ingestionStatus(kafka.DeliveryResult{Topic: "events"}))
+	assert.Equal(t, pb.IngestionStatus_INGESTION_STATUS_QUEUED, ingestionStatus(kafka.DeliveryResult{Topic: "events", Queued: true}))
+	assert.Equal(t, pb.IngestionStatus_INGESTION_STATUS_REJECTED, ingestionStatus(kafka.DeliveryResult{Dropped: true}))
+}
+
+func TestRetryableStatus(t *testing.T) {
+	assert.NoError(t, retryableStatus(nil))
+	assert.NoError(t, retryableStatus(errors.New("kafka down")))
+
+	err := retryableStatus(&kafka.LoadShedError{Lane: "bulk", RetryAfter: time.Second})
+	assertGRPCError(t, err, codes.ResourceExhausted)
+
+	err = retryableStatus(fmt.Errorf("send: %w", &kafka.CircuitOpenError{RetryAfter: 5 * time.Second}))
+	assertGRPCError(t, err, codes.Unavailable)
+
+	st, _ := status.FromError(err)
+	require.Len(t, st.Details(), 1)
+	retryInfo, ok := st.Details()[0].(*errdetails.RetryInfo)
+	require.True(t, ok)
+	assert.Equal(t, 5*time.Second, retryInfo.RetryDelay.AsDuration())
+}
+
+func TestIngestEvent_Deduplicated(t *testing.T) {
+	broker := newTestBroker(t)
+	publisher, err := dedupe.New(config.DedupeConfig{
+		Backend:       dedupe.BackendMemory,
+		TTLMs:         60000,
+		LockTimeoutMs: 1000,
+		MaxEntries:    100,
+	}, broker, zap.NewNop())
+	require.NoError(t, err)
+	handler := NewEventHandler(publisher, nil, zap.NewNop())
+
+	// A client-supplied event ID deduplicates on its own
+	event := testProtoEvent(t)
+	event.Id = "event-1"
+	first, err := handler.IngestEvent(context.Background(), &pb.IngestEventRequest{Event: event})
+	require.NoError(t, err)
+	assert.False(t, first.Duplicate)
+
+	retry := testProtoEvent(t)
+	retry.Id = "event-1"
+	resp, err := handler.IngestEvent(context.Background(), &pb.IngestEventRequest{Event: retry})
+	require.NoError(t, err)
+	assert.True(t, resp.Duplicate)
+	assert.Equal(t, first.Offset, resp.Offset)
+
+	// The idempotency-key metadata deduplicates events without an ID
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("idempotency-key", "key-1"))
+	first, err = handler.IngestEvent(ctx, &pb.IngestEventRequest{Event: testProtoEvent(t)})
+	require.NoError(t, err)
+	resp, err = handler.IngestEvent(ctx, &pb.IngestEventRequest{Event: testProtoEvent(t)})
+	require.NoError(t, err)
+	assert.True(t, resp.Duplicate)
+	assert.Equal(t, first.EventId, resp.EventId)
+
+	assert.Len(t, broker.Messages(), 2)
+}
+
+func TestIngestEvent_IdempotencyKeyTooLong(t *testing.T) {
+	broker := newTestBroker(t)
+	handler := NewEventHandler(broker, nil, zap.NewNop())
+
+	key := strings.Repeat("k", dedupe.MaxKeyLength+1)
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("idempotency-key", key))
+
+	_, err := handler.IngestEvent(ctx, &pb.IngestEventRequest{Event: testProtoEvent(t)})
+	assertGRPCError(t, err, codes.InvalidArgument)
+
+	_, err = handler.IngestEventBatch(ctx, &pb.IngestEventBatchRequest{Events: []*pb.Event{testProtoEvent(t)}})
+	assertGRPCError(t, err, codes.InvalidArgument)
+
+	assert.Empty(t, broker.Messages())
+}
+
+func TestIdempotencyStatus(t *testing.T) {
+	assert.NoError(t, idempotencyStatus(errors.New("kafka down")))
+	assertGRPCError(t, idempotencyStatus(dedupe.ErrInProgress), codes.Aborted)
+	assertGRPCError(t, idempotencyStatus(dedupe.ErrKeyReused), codes.InvalidArgument)
+}
+
+func TestValidateEventRPC_ValidateSchema(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "test.event"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "test.event", "1.0.0.json"),
+		[]byte(`{"type": "object", "required": ["key"]}`), 0o644))
+	schemas, err := schema.New(config.SchemasConfig{Directory: dir, Mode: schema.ModeOff}, nil, zap.NewNop())
+	require.NoError(t, err)
+	defer schemas.Close()
+
+	handler := NewEventHandler(nil, ingest.NewConverter(config.IngestConfig{}, schemas), zap.NewNop())
+
+	data, _ := structpb.NewStruct(map[string]interface{}{"other": "value"})
+	event := &pb.Event{Type: "test.event", Source: "test-service", Data: data, SchemaVersion: "1.0.0"}
+
+	// Schemas are off for ingestion
+	resp, err := handler.ValidateEvent(context.Background(), &pb.ValidateEventRequest{Event: event})
+	require.NoError(t, err)
+	assert.True(t, resp.IsValid)
+
+	resp, err = handler.ValidateEvent(context.Background(), &pb.ValidateEventRequest{Event: event, ValidateSchema: true})
+	require.NoError(t, err)
+	assert.False(t, resp.IsValid)
+	require.Len(t, resp.Errors, 1)
+	assert.Equal(t, "data", resp.Errors[0].Field)
+	assert.Equal(t, schema.CodeSchemaViolation, resp.Errors[0].Code)
+}
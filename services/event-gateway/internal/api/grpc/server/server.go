@@ -7,6 +7,7 @@ import (
 
 	"github.com/distributed-event-processor/services/event-gateway/internal/api/grpc/handlers"
 	"github.com/distributed-event-processor/services/event-gateway/internal/config"
+	"github.com/distributed-event-processor/services/event-gateway/internal/ingest"
 	"github.com/distributed-event-processor/services/event-gateway/internal/kafka"
 	pb "github.com/distributed-event-processor/shared/proto/events/v1"
 	"go.uber.org/zap"
@@ -17,18 +18,20 @@ import (
 
 // Server represents the gRPC server
 type Server struct {
-	config   config.GRPCConfig
-	producer *kafka.Producer
-	logger   *zap.Logger
-	server   *grpc.Server
+	config    config.GRPCConfig
+	producer  kafka.EventPublisher
+	converter *ingest.Converter
+	logger    *zap.Logger
+	server    *grpc.Server
 }
 
 // New creates a new gRPC server instance
-func New(cfg config.GRPCConfig, producer *kafka.Producer, logger *zap.Logger) *Server {
+func New(cfg config.GRPCConfig, producer kafka.EventPublisher, converter *ingest.Converter, logger *zap.Logger) *Server {
 	return &Server{
-		config:   cfg,
-		producer: producer,
-		logger:   logger,
+		config:    cfg,
+		producer:  producer,
+		converter: converter,
+		logger:    logger,
 	}
 }
 
@@ -72,7 +75,7 @@ func (s *Server) Start() error {
 	s.server = grpc.NewServer(opts...)
 
 	// Register event handler
-	eventHandler := handlers.NewEventHandler(s.producer, s.logger)
+	eventHandler := handlers.NewEventHandler(s.producer, s.converter, s.logger)
 	pb.RegisterEventGatewayServer(s.server, eventHandler)
 
 	// Enable reflection for grpcurl and other tools
@@ -1,82 +1,158 @@
 package handlers
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
+	"reflect"
 	"strconv"
 
+	"github.com/distributed-event-processor/services/event-gateway/internal/api/http/middleware"
+	"github.com/distributed-event-processor/services/event-gateway/internal/cloudevents"
+	"github.com/distributed-event-processor/services/event-gateway/internal/config"
+	"github.com/distributed-event-processor/services/event-gateway/internal/dedupe"
+	"github.com/distributed-event-processor/services/event-gateway/internal/ingest"
 	"github.com/distributed-event-processor/services/event-gateway/internal/kafka"
 	"github.com/distributed-event-processor/services/event-gateway/internal/models"
+	"github.com/distributed-event-processor/services/event-gateway/internal/validation"
+	pb "github.com/distributed-event-processor/shared/proto/events/v1"
 	"github.com/gin-gonic/gin"
-	"github.com/go-playground/validator/v10"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/ugorji/go/codec"
 	"go.uber.org/zap"
+	"google.golang.org/protobuf/proto"
+)
+
+// errTransactionRejected is reported for valid events that were not produced
+// because another event in the same transactional batch failed validation
+const errTransactionRejected = "batch rejected: transactional delivery requires every event in the batch to be valid"
+
+// errEventDropped is reported for events that priority lanes shed in drop mode
+const errEventDropped = "event dropped by load shedding"
+
+// Idempotency-Key header handling
+const (
+	idempotencyKeyHeader = "Idempotency-Key"
+	replayedHeader       = "Idempotent-Replayed"
 )
 
 type EventHandler struct {
-	producer  *kafka.Producer
+	producer  kafka.EventPublisher
+	converter *ingest.Converter
 	logger    *zap.Logger
-	validator *validator.Validate
 }
 
-func NewEventHandler(producer *kafka.Producer, logger *zap.Logger) *EventHandler {
+// NewEventHandler creates the HTTP event handler. A nil converter applies the
+// default ingestion limits.
+func NewEventHandler(producer kafka.EventPublisher, converter *ingest.Converter, logger *zap.Logger) *EventHandler {
+	if converter == nil {
+		converter = ingest.NewConverter(config.IngestConfig{}, nil)
+	}
 	return &EventHandler{
 		producer:  producer,
+		converter: converter,
 		logger:    logger,
-		validator: validator.New(),
 	}
 }
 
-// IngestEvent handles single event ingestion
+// IngestEvent handles single event ingestion. The event is an EventRequest
+// or a CloudEvent, and a batch of CloudEvents is ingested as by IngestBatch.
 func (h *EventHandler) IngestEvent(c *gin.Context) {
-	var req models.EventRequest
+	if c.ContentType() == cloudevents.BatchContentType {
+		h.IngestBatch(c)
+		return
+	}
 
-	// Bind JSON request
-	if err := c.ShouldBindJSON(&req); err != nil {
-		h.logger.Warn("Invalid JSON in event request",
+	idempotencyKey, ok := idempotencyKey(c)
+	if !ok {
+		return
+	}
+
+	// Bind request
+	req, err := bindEvent(c)
+	if err != nil {
+		h.logger.Warn("Invalid event request",
 			zap.String("request_id", getRequestID(c)),
 			zap.Error(err))
 
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error":      "invalid_json",
-			"message":    "Invalid JSON format",
-			"details":    err.Error(),
-			"request_id": getRequestID(c),
-		})
+		c.JSON(bindFailure(c, err))
 		return
 	}
 
-	// Validate request
-	if err := h.validator.Struct(&req); err != nil {
+	// Validate and convert to event
+	event, result := h.converter.FromRequest(req)
+	if !result.Valid() {
 		h.logger.Warn("Event validation failed",
 			zap.String("request_id", getRequestID(c)),
-			zap.Error(err))
+			zap.Error(result.Err()))
 
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error":      "validation_failed",
-			"message":    "Event validation failed",
-			"details":    formatValidationErrors(err),
-			"request_id": getRequestID(c),
-		})
+		c.JSON(http.StatusBadRequest, validationFailure(c, "Event validation failed", result))
 		return
 	}
 
-	// Convert to event
-	event := req.ToEvent()
-
 	// Add request metadata
 	if event.Metadata == nil {
 		event.Metadata = make(map[string]string)
 	}
-	event.Metadata["request_id"] = getRequestID(c)
+	event.Metadata[models.MetadataRequestID] = getRequestID(c)
 	event.Metadata["client_ip"] = c.ClientIP()
 	event.Metadata["user_agent"] = c.GetHeader("User-Agent")
+	if idempotencyKey != "" {
+		event.Metadata[models.MetadataIdempotencyKey] = idempotencyKey
+	}
+	setTraceContext(c, event)
 
 	// Send to Kafka
-	if err := h.producer.SendEvent(event); err != nil {
+	delivery := h.producer.Produce(c.Request.Context(), event)
+	if err := delivery.Err; err != nil {
+		// The idempotency key is taken, so the event was not sent
+		if status, code, ok := idempotencyError(err); ok {
+			h.logger.Warn("Idempotency key rejected",
+				zap.String("request_id", getRequestID(c)),
+				zap.Error(err))
+
+			c.JSON(status, gin.H{
+				"error":      code,
+				"message":    err.Error(),
+				"request_id": getRequestID(c),
+			})
+			return
+		}
+
 		h.logger.Error("Failed to send event to Kafka",
 			zap.String("event_id", event.ID),
 			zap.String("request_id", getRequestID(c)),
 			zap.Error(err))
 
+		// The circuit breaker failed the send without trying Kafka
+		var openErr *kafka.CircuitOpenError
+		if errors.As(err, &openErr) {
+			c.Header("Retry-After", strconv.Itoa(openErr.RetryAfterSeconds()))
+			c.JSON(http.StatusServiceUnavailable, gin.H{
+				"error":       "kafka_unavailable",
+				"message":     "Kafka is unavailable, retry later",
+				"event_id":    event.ID,
+				"retry_after": openErr.RetryAfterSeconds(),
+				"request_id":  getRequestID(c),
+			})
+			return
+		}
+
+		// A priority lane shed the event under load
+		var shedErr *kafka.LoadShedError
+		if errors.As(err, &shedErr) {
+			c.Header("Retry-After", strconv.Itoa(shedErr.RetryAfterSeconds()))
+			c.JSON(http.StatusServiceUnavailable, gin.H{
+				"error":       "overloaded",
+				"message":     "The gateway is shedding load, retry later",
+				"event_id":    event.ID,
+				"retry_after": shedErr.RetryAfterSeconds(),
+				"request_id":  getRequestID(c),
+			})
+			return
+		}
+
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error":      "ingestion_failed",
 			"message":    "Failed to ingest event",
@@ -86,118 +162,228 @@ func (h *EventHandler) IngestEvent(c *gin.Context) {
 		return
 	}
 
+	// A priority lane shed the event under load and discarded it
+	if delivery.Dropped {
+		c.Header("X-Event-ID", event.ID)
+		c.JSON(http.StatusAccepted, models.EventResponse{
+			EventID:   event.ID,
+			Status:    "dropped",
+			Timestamp: event.Timestamp,
+			Message:   "Event dropped by load shedding",
+		})
+		return
+	}
+
+	// A retry of an event that was already ingested gets the original response
+	if delivery.Duplicate {
+		c.Header(replayedHeader, "true")
+	}
+
+	// Kafka was unavailable but the event is safe in the spool
+	if delivery.Queued {
+		c.Header("X-Event-ID", event.ID)
+		c.JSON(http.StatusAccepted, models.EventResponse{
+			EventID:     event.ID,
+			Status:      "queued",
+			Timestamp:   event.Timestamp,
+			Message:     "Event queued for delivery",
+			Topic:       delivery.Topic,
+			RoutingRule: delivery.Rule,
+			Cluster:     delivery.Cluster,
+			Duplicate:   delivery.Duplicate,
+		})
+		return
+	}
+
 	h.logger.Info("Event ingested successfully",
 		zap.String("event_id", event.ID),
 		zap.String("event_type", event.Type),
 		zap.String("source", event.Source),
+		zap.String("topic", delivery.Topic),
+		zap.Bool("duplicate", delivery.Duplicate),
 		zap.String("request_id", getRequestID(c)))
 
 	// Return success response
 	response := models.EventResponse{
-		EventID:   event.ID,
-		Status:    "accepted",
-		Timestamp: event.Timestamp,
-		Message:   "Event ingested successfully",
+		EventID:     event.ID,
+		Status:      "accepted",
+		Timestamp:   event.Timestamp,
+		Message:     "Event ingested successfully",
+		Topic:       delivery.Topic,
+		RoutingRule: delivery.Rule,
+		Cluster:     delivery.Cluster,
+		Partition:   &delivery.Partition,
+		Offset:      &delivery.Offset,
+		Duplicate:   delivery.Duplicate,
 	}
 
 	c.Header("X-Event-ID", event.ID)
 	c.JSON(http.StatusAccepted, response)
 }
 
-// IngestBatch handles batch event ingestion
+// IngestBatch handles batch event ingestion, of a BatchEventRequest or a
+// batch of CloudEvents
 func (h *EventHandler) IngestBatch(c *gin.Context) {
-	var req models.BatchEventRequest
+	idempotencyKey, ok := idempotencyKey(c)
+	if !ok {
+		return
+	}
 
-	// Bind JSON request
-	if err := c.ShouldBindJSON(&req); err != nil {
-		h.logger.Warn("Invalid JSON in batch request",
+	// Bind request
+	requests, err := bindBatch(c)
+	if err != nil {
+		h.logger.Warn("Invalid batch request",
 			zap.String("request_id", getRequestID(c)),
 			zap.Error(err))
 
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error":      "invalid_json",
-			"message":    "Invalid JSON format",
-			"details":    err.Error(),
-			"request_id": getRequestID(c),
-		})
+		c.JSON(bindFailure(c, err))
 		return
 	}
 
-	// Validate request
-	if err := h.validator.Struct(&req); err != nil {
+	// Validate batch size
+	if result := validation.ValidateBatchSize(len(requests)); !result.Valid() {
 		h.logger.Warn("Batch validation failed",
 			zap.String("request_id", getRequestID(c)),
-			zap.Error(err))
+			zap.Error(result.Err()))
 
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error":      "validation_failed",
-			"message":    "Batch validation failed",
-			"details":    formatValidationErrors(err),
-			"request_id": getRequestID(c),
-		})
+		c.JSON(http.StatusBadRequest, validationFailure(c, "Batch validation failed", result))
 		return
 	}
 
 	// Process events
 	response := models.BatchEventResponse{
-		Results: make([]models.BatchEventResult, len(req.Events)),
+		Results: make([]models.BatchEventResult, len(requests)),
 	}
 
-	events := make([]*models.Event, 0, len(req.Events))
+	events := make([]*models.Event, 0, len(requests))
+	indices := make([]int, 0, len(requests))
 
-	for i, eventReq := range req.Events {
-		// Validate individual event
-		if err := h.validator.Struct(&eventReq); err != nil {
+	for i, eventReq := range requests {
+		// Validate individual event and convert it
+		event, result := h.converter.FromRequest(&eventReq)
+		if !result.Valid() {
+			message := result.Err().Error()
 			response.FailedCount++
 			response.Results[i] = models.BatchEventResult{
-				Status: "failed",
-				Error:  formatValidationErrors(err),
+				EventID: eventReq.ID,
+				Status:  "failed",
+				Error:   message,
+				Errors:  result.Errors,
 			}
-			response.Errors = append(response.Errors,
-				formatValidationErrors(err))
+			response.Errors = append(response.Errors, message)
 			continue
 		}
 
-		// Convert to event
-		event := eventReq.ToEvent()
-
 		// Add request metadata
 		if event.Metadata == nil {
 			event.Metadata = make(map[string]string)
 		}
-		event.Metadata["request_id"] = getRequestID(c)
+		event.Metadata[models.MetadataRequestID] = getRequestID(c)
 		event.Metadata["client_ip"] = c.ClientIP()
 		event.Metadata["user_agent"] = c.GetHeader("User-Agent")
 		event.Metadata["batch_index"] = strconv.Itoa(i)
+		if idempotencyKey != "" {
+			// Each event of a batch is deduplicated on its own
+			event.Metadata[models.MetadataIdempotencyKey] = idempotencyKey + ":" + strconv.Itoa(i)
+		}
+		setTraceContext(c, event)
 
 		events = append(events, event)
-		response.Results[i] = models.BatchEventResult{
-			EventID: event.ID,
-			Status:  "accepted",
+		indices = append(indices, i)
+	}
+
+	// Transactional batches are all-or-nothing, so one invalid event rejects the rest
+	if len(events) > 0 && response.FailedCount > 0 && h.producer.Transactional() {
+		for j, event := range events {
+			response.Results[indices[j]] = models.BatchEventResult{
+				EventID: event.ID,
+				Status:  "failed",
+				Error:   errTransactionRejected,
+			}
+			response.FailedCount++
 		}
-		response.ProcessedCount++
+		events = events[:0]
 	}
 
-	// Send events to Kafka
+	// Send events to Kafka as a single batch and record each event's outcome
+	deliveryFailures := 0
+	retryAfter := 0
 	if len(events) > 0 {
-		if err := h.producer.SendBatchEvents(events); err != nil {
-			h.logger.Error("Failed to send batch events to Kafka",
-				zap.String("request_id", getRequestID(c)),
-				zap.Int("event_count", len(events)),
-				zap.Error(err))
+		deliveries := h.producer.ProduceBatch(c.Request.Context(), events)
+
+		for j, delivery := range deliveries {
+			event := events[j]
+			i := indices[j]
+
+			if delivery.Err != nil {
+				h.logger.Error("Failed to send batch event to Kafka",
+					zap.String("event_id", event.ID),
+					zap.String("request_id", getRequestID(c)),
+					zap.Int("batch_index", i),
+					zap.Error(delivery.Err))
+
+				// A taken idempotency key is the client's mistake, not a broker failure
+				if _, _, rejected := idempotencyError(delivery.Err); !rejected {
+					deliveryFailures++
+				}
+				if seconds, ok := retryAfterSeconds(delivery.Err); ok {
+					retryAfter = seconds
+				}
+				response.FailedCount++
+				response.Results[i] = models.BatchEventResult{
+					EventID:     event.ID,
+					Status:      "failed",
+					Topic:       delivery.Topic,
+					RoutingRule: delivery.Rule,
+					Cluster:     delivery.Cluster,
+					Error:       delivery.Err.Error(),
+				}
+				response.Errors = append(response.Errors, delivery.Err.Error())
+				continue
+			}
 
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"error":      "ingestion_failed",
-				"message":    "Failed to ingest batch events",
-				"request_id": getRequestID(c),
-			})
-			return
+			// A shed event never reaches Kafka, so it counts as failed
+			if delivery.Dropped {
+				response.Results[i] = models.BatchEventResult{
+					EventID: event.ID,
+					Status:  "dropped",
+					Error:   errEventDropped,
+				}
+				response.FailedCount++
+				continue
+			}
+
+			if delivery.Queued {
+				response.Results[i] = models.BatchEventResult{
+					EventID:     event.ID,
+					Status:      "queued",
+					Topic:       delivery.Topic,
+					RoutingRule: delivery.Rule,
+					Cluster:     delivery.Cluster,
+					Duplicate:   delivery.Duplicate,
+				}
+				response.ProcessedCount++
+				continue
+			}
+
+			partition, offset := delivery.Partition, delivery.Offset
+			response.Results[i] = models.BatchEventResult{
+				EventID:     event.ID,
+				Status:      "accepted",
+				Topic:       delivery.Topic,
+				RoutingRule: delivery.Rule,
+				Cluster:     delivery.Cluster,
+				Partition:   &partition,
+				Offset:      &offset,
+				Duplicate:   delivery.Duplicate,
+			}
+			response.ProcessedCount++
 		}
 	}
 
 	h.logger.Info("Batch events processed",
 		zap.String("request_id", getRequestID(c)),
-		zap.Int("total_events", len(req.Events)),
+		zap.Int("total_events", len(requests)),
 		zap.Int("processed", response.ProcessedCount),
 		zap.Int("failed", response.FailedCount))
 
@@ -206,51 +392,257 @@ func (h *EventHandler) IngestBatch(c *gin.Context) {
 		status = http.StatusMultiStatus
 	}
 
+	// Nothing reached Kafka because of broker failures, so let clients retry the batch
+	if response.ProcessedCount == 0 && deliveryFailures > 0 {
+		status = http.StatusInternalServerError
+		if retryAfter > 0 {
+			status = http.StatusServiceUnavailable
+			c.Header("Retry-After", strconv.Itoa(retryAfter))
+		}
+	}
+
 	c.JSON(status, response)
 }
 
 // ValidateEvent validates an event without ingesting it (dry-run)
 func (h *EventHandler) ValidateEvent(c *gin.Context) {
-	var req models.EventRequest
-
-	// Bind JSON request
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"valid":      false,
-			"error":      "invalid_json",
-			"message":    "Invalid JSON format",
-			"details":    err.Error(),
-			"request_id": getRequestID(c),
-		})
+	// Bind request
+	req, err := bindEvent(c)
+	if err != nil {
+		status, response := bindFailure(c, err)
+		response["valid"] = false
+		c.JSON(status, response)
 		return
 	}
 
-	// Validate request
-	if err := h.validator.Struct(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"valid":      false,
-			"error":      "validation_failed",
-			"message":    "Event validation failed",
-			"details":    formatValidationErrors(err),
-			"request_id": getRequestID(c),
-		})
+	// Validate and convert to event to test transformation. The outcome of a
+	// dry run is the answer, so an invalid event is still a successful request.
+	// With validate_schema=true, data that does not match its schema is an
+	// error even for types whose schemas are only warned about.
+	validateSchema := c.Query("validate_schema") == "true"
+	event, result := h.converter.ValidateRequest(req, validateSchema)
+	if !result.Valid() {
+		response := validationFailure(c, "Event validation failed", result)
+		response["valid"] = false
+		c.JSON(http.StatusOK, response)
 		return
 	}
 
-	// Convert to event to test transformation
-	event := req.ToEvent()
-
 	c.JSON(http.StatusOK, gin.H{
 		"valid":      true,
 		"message":    "Event is valid",
 		"event_id":   event.ID,
 		"timestamp":  event.Timestamp,
+		"warnings":   warnings(result),
 		"request_id": getRequestID(c),
 	})
 }
 
 // Helper functions
 
+// idempotencyKey returns the request's Idempotency-Key header, answering the
+// request itself if the key is unusable
+func idempotencyKey(c *gin.Context) (string, bool) {
+	key := c.GetHeader(idempotencyKeyHeader)
+	if len(key) > dedupe.MaxKeyLength {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":      "invalid_idempotency_key",
+			"message":    "Idempotency-Key must be at most " + strconv.Itoa(dedupe.MaxKeyLength) + " characters",
+			"request_id": getRequestID(c),
+		})
+		return "", false
+	}
+	return key, true
+}
+
+// idempotencyError maps a rejected idempotency key to its HTTP status and
+// error code
+func idempotencyError(err error) (int, string, bool) {
+	switch {
+	case errors.Is(err, dedupe.ErrInProgress):
+		return http.StatusConflict, "idempotency_key_in_use", true
+	case errors.Is(err, dedupe.ErrKeyReused):
+		return http.StatusUnprocessableEntity, "idempotency_key_reused", true
+	}
+	return 0, "", false
+}
+
+// retryAfterSeconds returns the Retry-After delay of a send failed fast by the
+// circuit breaker or shed by a priority lane
+func retryAfterSeconds(err error) (int, bool) {
+	var openErr *kafka.CircuitOpenError
+	if errors.As(err, &openErr) {
+		return openErr.RetryAfterSeconds(), true
+	}
+	var shedErr *kafka.LoadShedError
+	if errors.As(err, &shedErr) {
+		return shedErr.RetryAfterSeconds(), true
+	}
+	return 0, false
+}
+
+// bindEvent reads the event of a request: a CloudEvent in structured or
+// binary content mode, an IngestEventRequest in protobuf, or an EventRequest
+// in msgpack or JSON
+func bindEvent(c *gin.Context) (*models.EventRequest, error) {
+	switch contentType := c.ContentType(); {
+	case contentType == cloudevents.ContentType:
+		body, err := c.GetRawData()
+		if err != nil {
+			return nil, err
+		}
+		return cloudevents.Decode(body)
+	case cloudevents.IsBinary(c.Request.Header):
+		body, err := c.GetRawData()
+		if err != nil {
+			return nil, err
+		}
+		return cloudevents.DecodeBinary(c.Request.Header, body)
+	case contentType == binding.MIMEPROTOBUF:
+		var msg pb.IngestEventRequest
+		if err := bindProtobuf(c, &msg); err != nil {
+			return nil, err
+		}
+		return ingest.ProtoToRequest(msg.GetEvent()), nil
+	case isMsgpack(contentType):
+		var req models.EventRequest
+		if err := bindMsgpack(c, &req); err != nil {
+			return nil, err
+		}
+		return &req, nil
+	}
+
+	var req models.EventRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		return nil, err
+	}
+	return &req, nil
+}
+
+// bindBatch reads the events of a batch request: CloudEvents in batched
+// content mode, an IngestEventBatchRequest in protobuf, or a
+// BatchEventRequest in msgpack or JSON
+func bindBatch(c *gin.Context) ([]models.EventRequest, error) {
+	switch contentType := c.ContentType(); {
+	case contentType == cloudevents.BatchContentType:
+		body, err := c.GetRawData()
+		if err != nil {
+			return nil, err
+		}
+		return cloudevents.DecodeBatch(body)
+	case contentType == binding.MIMEPROTOBUF:
+		var msg pb.IngestEventBatchRequest
+		if err := bindProtobuf(c, &msg); err != nil {
+			return nil, err
+		}
+		requests := make([]models.EventRequest, len(msg.GetEvents()))
+		for i, event := range msg.GetEvents() {
+			requests[i] = *ingest.ProtoToRequest(event)
+		}
+		return requests, nil
+	case isMsgpack(contentType):
+		var req models.BatchEventRequest
+		if err := bindMsgpack(c, &req); err != nil {
+			return nil, err
+		}
+		return req.Events, nil
+	}
+
+	var req models.BatchEventRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		return nil, err
+	}
+	return req.Events, nil
+}
+
+// errInvalidProtobuf and errInvalidMsgpack are wrapped by the errors of
+// bodies that do not decode in their content type
+var (
+	errInvalidProtobuf = errors.New("invalid protobuf")
+	errInvalidMsgpack  = errors.New("invalid msgpack")
+)
+
+// msgpackHandle decodes msgpack maps with string keys and strings as text,
+// the way JSON reads them
+var msgpackHandle = &codec.MsgpackHandle{
+	BasicHandle: codec.BasicHandle{
+		DecodeOptions: codec.DecodeOptions{
+			MapType:     reflect.TypeOf(map[string]interface{}(nil)),
+			RawToString: true,
+		},
+	},
+}
+
+func isMsgpack(contentType string) bool {
+	return contentType == binding.MIMEMSGPACK2 || contentType == binding.MIMEMSGPACK
+}
+
+// bindProtobuf reads a protobuf request body into msg
+func bindProtobuf(c *gin.Context, msg proto.Message) error {
+	body, err := c.GetRawData()
+	if err != nil {
+		return err
+	}
+	if err := proto.Unmarshal(body, msg); err != nil {
+		return fmt.Errorf("%w: %v", errInvalidProtobuf, err)
+	}
+	return nil
+}
+
+// bindMsgpack reads a msgpack request body into obj. The body is decoded as
+// the JSON document it stands for, so numbers, timestamps and event data read
+// exactly as they do in JSON requests.
+func bindMsgpack(c *gin.Context, obj interface{}) error {
+	body, err := c.GetRawData()
+	if err != nil {
+		return err
+	}
+
+	var document interface{}
+	if err := codec.NewDecoderBytes(body, msgpackHandle).Decode(&document); err != nil {
+		return fmt.Errorf("%w: %v", errInvalidMsgpack, err)
+	}
+	encoded, err := json.Marshal(document)
+	if err != nil {
+		return fmt.Errorf("%w: %v", errInvalidMsgpack, err)
+	}
+	if err := json.Unmarshal(encoded, obj); err != nil {
+		return fmt.Errorf("%w: %v", errInvalidMsgpack, err)
+	}
+	return nil
+}
+
+// bindFailure is the status and response for a request whose events could
+// not be read
+func bindFailure(c *gin.Context, err error) (int, gin.H) {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return http.StatusRequestEntityTooLarge, gin.H{
+			"error":      "request_too_large",
+			"message":    fmt.Sprintf("Request body too large. Maximum allowed: %d bytes", tooLarge.Limit),
+			"request_id": getRequestID(c),
+		}
+	}
+
+	code, message := "invalid_json", "Invalid JSON format"
+	switch {
+	case errors.Is(err, middleware.ErrInvalidEncoding):
+		code, message = "invalid_encoding", "Invalid compressed request body"
+	case errors.Is(err, cloudevents.ErrInvalid):
+		code, message = "invalid_cloudevent", "Invalid CloudEvent"
+	case errors.Is(err, errInvalidProtobuf):
+		code, message = "invalid_protobuf", "Invalid protobuf message"
+	case errors.Is(err, errInvalidMsgpack):
+		code, message = "invalid_msgpack", "Invalid msgpack format"
+	}
+	return http.StatusBadRequest, gin.H{
+		"error":      code,
+		"message":    message,
+		"details":    err.Error(),
+		"request_id": getRequestID(c),
+	}
+}
+
 func getRequestID(c *gin.Context) string {
 	if id, exists := c.Get("request_id"); exists {
 		return id.(string)
@@ -258,16 +650,35 @@ func getRequestID(c *gin.Context) string {
 	return "unknown"
 }
 
-func formatValidationErrors(err error) string {
-	if validationErrors, ok := err.(validator.ValidationErrors); ok {
-		var errors []string
-		for _, e := range validationErrors {
-			errors = append(errors,
-				"Field '"+e.Field()+"' failed validation: "+e.Tag())
-		}
-		if len(errors) > 0 {
-			return errors[0] // Return first error for simplicity
+// setTraceContext records the caller's W3C trace context on the event, so the
+// producer can continue the trace in the Kafka headers
+func setTraceContext(c *gin.Context, event *models.Event) {
+	if traceParent := c.GetHeader("traceparent"); traceParent != "" {
+		event.Metadata[models.MetadataTraceParent] = traceParent
+		if traceState := c.GetHeader("tracestate"); traceState != "" {
+			event.Metadata[models.MetadataTraceState] = traceState
 		}
 	}
-	return err.Error()
+}
+
+// validationFailure is the response to an event or batch that broke the
+// ingestion rules. Details joins the messages of all violations, which are
+// listed in errors.
+func validationFailure(c *gin.Context, message string, result validation.Result) gin.H {
+	return gin.H{
+		"error":      "validation_failed",
+		"message":    message,
+		"details":    result.Err().Error(),
+		"errors":     result.Errors,
+		"warnings":   warnings(result),
+		"request_id": getRequestID(c),
+	}
+}
+
+// warnings returns the result's warnings, as an empty list rather than null
+func warnings(result validation.Result) []string {
+	if result.Warnings == nil {
+		return []string{}
+	}
+	return result.Warnings
 }
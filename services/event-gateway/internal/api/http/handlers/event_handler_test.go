@@ -2,15 +2,35 @@ package handlers
 
 import (
 	"bytes"
+	"compress/gzip"
+	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
-
+	"time"
+
+	"github.com/distributed-event-processor/services/event-gateway/internal/api/http/middleware"
+	"github.com/distributed-event-processor/services/event-gateway/internal/config"
+	"github.com/distributed-event-processor/services/event-gateway/internal/dedupe"
+	"github.com/distributed-event-processor/services/event-gateway/internal/ingest"
+	"github.com/distributed-event-processor/services/event-gateway/internal/kafka"
+	"github.com/distributed-event-processor/services/event-gateway/internal/models"
+	"github.com/distributed-event-processor/services/event-gateway/internal/schema"
+	pb "github.com/distributed-event-processor/shared/proto/events/v1"
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"github.com/ugorji/go/codec"
 	"go.uber.org/zap"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
 func init() {
@@ -31,7 +51,7 @@ func setupTestRouter(handler *EventHandler) *gin.Engine {
 
 func TestIngestEvent_InvalidJSON(t *testing.T) {
 	logger, _ := zap.NewDevelopment()
-	handler := NewEventHandler(nil, logger)
+	handler := NewEventHandler(nil, nil, logger)
 	router := setupTestRouter(handler)
 
 	req := httptest.NewRequest(http.MethodPost, "/events", bytes.NewBufferString("invalid json"))
@@ -50,7 +70,7 @@ func TestIngestEvent_InvalidJSON(t *testing.T) {
 
 func TestIngestEvent_ValidationFailed(t *testing.T) {
 	logger, _ := zap.NewDevelopment()
-	handler := NewEventHandler(nil, logger)
+	handler := NewEventHandler(nil, nil, logger)
 	router := setupTestRouter(handler)
 
 	// Missing required fields
@@ -76,7 +96,7 @@ func TestIngestEvent_ValidationFailed(t *testing.T) {
 
 func TestValidateEvent_Valid(t *testing.T) {
 	logger, _ := zap.NewDevelopment()
-	handler := NewEventHandler(nil, logger)
+	handler := NewEventHandler(nil, nil, logger)
 	router := setupTestRouter(handler)
 
 	payload := map[string]interface{}{
@@ -105,7 +125,7 @@ func TestValidateEvent_Valid(t *testing.T) {
 
 func TestValidateEvent_InvalidJSON(t *testing.T) {
 	logger, _ := zap.NewDevelopment()
-	handler := NewEventHandler(nil, logger)
+	handler := NewEventHandler(nil, nil, logger)
 	router := setupTestRouter(handler)
 
 	req := httptest.NewRequest(http.MethodPost, "/events/validate", bytes.NewBufferString("not json"))
@@ -125,7 +145,7 @@ func TestValidateEvent_InvalidJSON(t *testing.T) {
 
 func TestValidateEvent_ValidationFailed(t *testing.T) {
 	logger, _ := zap.NewDevelopment()
-	handler := NewEventHandler(nil, logger)
+	handler := NewEventHandler(nil, nil, logger)
 	router := setupTestRouter(handler)
 
 	// Missing required fields
@@ -151,7 +171,7 @@ func TestValidateEvent_ValidationFailed(t *testing.T) {
 
 func TestIngestBatch_InvalidJSON(t *testing.T) {
 	logger, _ := zap.NewDevelopment()
-	handler := NewEventHandler(nil, logger)
+	handler := NewEventHandler(nil, nil, logger)
 	router := setupTestRouter(handler)
 
 	req := httptest.NewRequest(http.MethodPost, "/events/batch", bytes.NewBufferString("invalid"))
@@ -170,7 +190,7 @@ func TestIngestBatch_InvalidJSON(t *testing.T) {
 
 func TestIngestBatch_EmptyEvents(t *testing.T) {
 	logger, _ := zap.NewDevelopment()
-	handler := NewEventHandler(nil, logger)
+	handler := NewEventHandler(nil, nil, logger)
 	router := setupTestRouter(handler)
 
 	payload := map[string]interface{}{
@@ -213,13 +233,685 @@ func TestGetRequestID(t *testing.T) {
 	})
 }
 
-func TestFormatValidationErrors(t *testing.T) {
-	// Test with non-validation error
-	err := assert.AnError
-	result := formatValidationErrors(err)
-	assert.Equal(t, err.Error(), result)
+func TestIngestEvent_ReportsEveryViolation(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+	router := setupTestRouter(NewEventHandler(nil, nil, logger))
+
+	w := postJSON(router, "/events", map[string]interface{}{
+		"id":       "not a valid id",
+		"priority": 11,
+	})
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	var response struct {
+		Error  string                   `json:"error"`
+		Errors []models.ValidationError `json:"errors"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, "validation_failed", response.Error)
+
+	fields := make([]string, len(response.Errors))
+	for i, violation := range response.Errors {
+		fields[i] = violation.Field
+	}
+	assert.Equal(t, []string{"type", "source", "data", "id", "priority"}, fields)
+	assert.Equal(t, "REQUIRED_FIELD", response.Errors[0].Code)
+}
+
+func TestValidateEvent_Warnings(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+	router := setupTestRouter(NewEventHandler(nil, nil, logger))
+
+	payload := testEventPayload()
+	payload["data"] = map[string]interface{}{}
+	w := postJSON(router, "/events/validate", payload)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response map[string]interface{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, true, response["valid"])
+	assert.Equal(t, []interface{}{"event data is empty"}, response["warnings"])
+}
+
+func TestIngestBatch_TooLarge(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+	router := setupTestRouter(NewEventHandler(nil, nil, logger))
+
+	events := make([]interface{}, 101)
+	for i := range events {
+		events[i] = testEventPayload()
+	}
+	w := postJSON(router, "/events/batch", map[string]interface{}{"events": events})
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	var response map[string]interface{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, "validation_failed", response["error"])
+}
+
+func newTestBroker(t *testing.T) *kafka.MemoryBroker {
+	broker, err := kafka.NewMemoryBroker(config.KafkaConfig{
+		Topic:   "events",
+		Headers: config.HeadersConfig{TraceContext: true},
+		Memory:  config.MemoryBrokerConfig{Partitions: 3},
+	}, zap.NewNop())
+	require.NoError(t, err)
+	return broker
+}
+
+func postJSON(router *gin.Engine, path string, payload interface{}) *httptest.ResponseRecorder {
+	body, _ := json.Marshal(payload)
+	req := httptest.NewRequest(http.MethodPost, path, bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func testEventPayload() map[string]interface{} {
+	return map[string]interface{}{
+		"type":   "user.created",
+		"source": "test-service",
+		"data":   map[string]interface{}{"user_id": "123"},
+	}
+}
+
+func TestIngestEvent_Success(t *testing.T) {
+	broker := newTestBroker(t)
+	router := setupTestRouter(NewEventHandler(broker, nil, zap.NewNop()))
+
+	w := postJSON(router, "/events", testEventPayload())
+
+	assert.Equal(t, http.StatusAccepted, w.Code)
+
+	var response models.EventResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, "accepted", response.Status)
+	assert.Equal(t, "events", response.Topic)
+	assert.Equal(t, kafka.DefaultCluster, response.Cluster)
+	require.NotNil(t, response.Partition)
+	require.NotNil(t, response.Offset)
+	assert.Equal(t, int64(0), *response.Offset)
+	assert.Equal(t, response.EventID, w.Header().Get("X-Event-ID"))
+
+	messages := broker.Messages()
+	require.Len(t, messages, 1)
+	assert.Equal(t, *response.Partition, messages[0].Partition)
+	assert.Equal(t, response.EventID, messages[0].Headers["event_id"])
+	assert.Equal(t, "test-request-id", messages[0].Headers["request_id"])
+	assert.Contains(t, messages[0].Headers["traceparent"], "4bf92f3577b34da6a3ce929d0e0e4736")
+}
+
+func TestIngestEvent_ClientFields(t *testing.T) {
+	broker := newTestBroker(t)
+	router := setupTestRouter(NewEventHandler(broker, nil, zap.NewNop()))
+
+	payload := testEventPayload()
+	payload["id"] = "order-42"
+	payload["timestamp"] = "2024-05-01T12:00:00Z"
+	payload["tenant_id"] = "tenant-1"
+	payload["priority"] = 8
+	w := postJSON(router, "/events", payload)
+
+	require.Equal(t, http.StatusAccepted, w.Code)
+	var response models.EventResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, "order-42", response.EventID)
+	assert.Equal(t, time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC), response.Timestamp)
+
+	messages := broker.Messages()
+	require.Len(t, messages, 1)
+	assert.Equal(t, "tenant-1", messages[0].Event.TenantID)
+	assert.Equal(t, 8, messages[0].Event.Priority)
+
+	payload["timestamp"] = time.Now().Add(time.Hour).Format(time.RFC3339)
+	w = postJSON(router, "/events", payload)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestIngestEvent_KafkaFailure(t *testing.T) {
+	broker := newTestBroker(t)
+	broker.SetError(errors.New("broker down"))
+	router := setupTestRouter(NewEventHandler(broker, nil, zap.NewNop()))
+
+	w := postJSON(router, "/events", testEventPayload())
+
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+
+	var response map[string]interface{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, "ingestion_failed", response["error"])
+	assert.Empty(t, broker.Messages())
+}
+
+func TestIngestEvent_LoadShed(t *testing.T) {
+	broker := newTestBroker(t)
+	broker.SetError(&kafka.LoadShedError{Lane: "bulk", RetryAfter: time.Second})
+	router := setupTestRouter(NewEventHandler(broker, nil, zap.NewNop()))
+
+	w := postJSON(router, "/events", testEventPayload())
+
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Equal(t, "1", w.Header().Get("Retry-After"))
+
+	var response map[string]interface{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, "overloaded", response["error"])
+}
+
+func TestIngestBatch_Success(t *testing.T) {
+	broker := newTestBroker(t)
+	router := setupTestRouter(NewEventHandler(broker, nil, zap.NewNop()))
+
+	w := postJSON(router, "/events/batch", map[string]interface{}{
+		"events": []interface{}{testEventPayload(), testEventPayload()},
+	})
+
+	assert.Equal(t, http.StatusAccepted, w.Code)
+
+	var response models.BatchEventResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, 2, response.ProcessedCount)
+	assert.Equal(t, 0, response.FailedCount)
+	for i, result := range response.Results {
+		assert.Equal(t, "accepted", result.Status)
+		require.NotNil(t, result.Offset)
+		assert.Equal(t, int64(i), *result.Offset)
+	}
+	assert.Len(t, broker.Messages(), 2)
+}
+
+func TestIngestBatch_PartialFailure(t *testing.T) {
+	broker := newTestBroker(t)
+	broker.FailNext(errors.New("leader not available"))
+	router := setupTestRouter(NewEventHandler(broker, nil, zap.NewNop()))
+
+	w := postJSON(router, "/events/batch", map[string]interface{}{
+		"events": []interface{}{testEventPayload(), testEventPayload()},
+	})
+
+	assert.Equal(t, http.StatusMultiStatus, w.Code)
+
+	var response models.BatchEventResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, 1, response.ProcessedCount)
+	assert.Equal(t, 1, response.FailedCount)
+	assert.Equal(t, "failed", response.Results[0].Status)
+	assert.Equal(t, "accepted", response.Results[1].Status)
+}
+
+// droppingPublisher sheds every event in drop mode
+type droppingPublisher struct {
+	*kafka.MemoryBroker
+}
+
+func (p droppingPublisher) ProduceBatch(ctx context.Context, events []*models.Event) []kafka.DeliveryResult {
+	results := make([]kafka.DeliveryResult, len(events))
+	for i := range results {
+		results[i] = kafka.DeliveryResult{Dropped: true}
+	}
+	return results
+}
+
+func TestIngestBatch_Dropped(t *testing.T) {
+	router := setupTestRouter(NewEventHandler(droppingPublisher{newTestBroker(t)}, nil, zap.NewNop()))
+
+	w := postJSON(router, "/events/batch", map[string]interface{}{
+		"events": []interface{}{testEventPayload(), testEventPayload()},
+	})
+
+	// Nothing reached Kafka, so the batch is not reported as a success
+	assert.Equal(t, http.StatusMultiStatus, w.Code)
+
+	var response models.BatchEventResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, 0, response.ProcessedCount)
+	assert.Equal(t, 2, response.FailedCount)
+	for _, result := range response.Results {
+		assert.Equal(t, "dropped", result.Status)
+	}
+}
+
+func TestIngestBatch_InvalidEvent(t *testing.T) {
+	broker := newTestBroker(t)
+	router := setupTestRouter(NewEventHandler(broker, nil, zap.NewNop()))
+
+	w := postJSON(router, "/events/batch", map[string]interface{}{
+		"events": []interface{}{
+			testEventPayload(),
+			map[string]interface{}{"type": "test.event"},
+		},
+	})
+
+	assert.Equal(t, http.StatusMultiStatus, w.Code)
+
+	var response models.BatchEventResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, "accepted", response.Results[0].Status)
+	assert.Equal(t, "failed", response.Results[1].Status)
+	assert.Equal(t, "event source is required; event data is required", response.Results[1].Error)
+	require.Len(t, response.Results[1].Errors, 2)
+	assert.Equal(t, "source", response.Results[1].Errors[0].Field)
+	assert.Len(t, broker.Messages(), 1)
+}
+
+func newTestDedupe(t *testing.T, broker *kafka.MemoryBroker) *dedupe.Publisher {
+	publisher, err := dedupe.New(config.DedupeConfig{
+		Backend:       dedupe.BackendMemory,
+		TTLMs:         60000,
+		LockTimeoutMs: 1000,
+		MaxEntries:    100,
+	}, broker, zap.NewNop())
+	require.NoError(t, err)
+	return publisher
+}
+
+func postWithIdempotencyKey(router *gin.Engine, path, key string, payload interface{}) *httptest.ResponseRecorder {
+	body, _ := json.Marshal(payload)
+	req := httptest.NewRequest(http.MethodPost, path, bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Idempotency-Key", key)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestIngestEvent_IdempotencyKey(t *testing.T) {
+	broker := newTestBroker(t)
+	router := setupTestRouter(NewEventHandler(newTestDedupe(t, broker), nil, zap.NewNop()))
+
+	first := postWithIdempotencyKey(router, "/events", "key-1", testEventPayload())
+	require.Equal(t, http.StatusAccepted, first.Code)
+	assert.Empty(t, first.Header().Get("Idempotent-Replayed"))
+
+	retry := postWithIdempotencyKey(router, "/events", "key-1", testEventPayload())
+	require.Equal(t, http.StatusAccepted, retry.Code)
+	assert.Equal(t, "true", retry.Header().Get("Idempotent-Replayed"))
+
+	var original, replayed models.EventResponse
+	require.NoError(t, json.Unmarshal(first.Body.Bytes(), &original))
+	require.NoError(t, json.Unmarshal(retry.Body.Bytes(), &replayed))
+	assert.True(t, replayed.Duplicate)
+	assert.Equal(t, original.EventID, replayed.EventID)
+	assert.Equal(t, *original.Offset, *replayed.Offset)
+	assert.Len(t, broker.Messages(), 1)
+
+	// The same key with a different event is refused
+	other := testEventPayload()
+	other["subject"] = "user/456"
+	w := postWithIdempotencyKey(router, "/events", "key-1", other)
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+
+	w = postWithIdempotencyKey(router, "/events", string(bytes.Repeat([]byte("k"), 256)), testEventPayload())
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestIngestBatch_IdempotencyKey(t *testing.T) {
+	broker := newTestBroker(t)
+	router := setupTestRouter(NewEventHandler(newTestDedupe(t, broker), nil, zap.NewNop()))
+	payload := map[string]interface{}{
+		"events": []interface{}{testEventPayload(), testEventPayload()},
+	}
+
+	require.Equal(t, http.StatusAccepted, postWithIdempotencyKey(router, "/events/batch", "batch-1", payload).Code)
+	w := postWithIdempotencyKey(router, "/events/batch", "batch-1", payload)
+	require.Equal(t, http.StatusAccepted, w.Code)
+
+	var response models.BatchEventResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, 2, response.ProcessedCount)
+	for _, result := range response.Results {
+		assert.True(t, result.Duplicate)
+	}
+	assert.Len(t, broker.Messages(), 2)
+}
+
+// newSchemaConverter checks the data of user.created events, which must have a
+// string user_id, in the given mode
+func newSchemaConverter(t *testing.T, mode string) *ingest.Converter {
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "user.created"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "user.created", "default.json"),
+		[]byte(`{"type": "object", "required": ["user_id"], "properties": {"user_id": {"type": "string"}}}`), 0o644))
+
+	schemas, err := schema.New(config.SchemasConfig{Directory: dir, Mode: mode}, nil, zap.NewNop())
+	require.NoError(t, err)
+	t.Cleanup(schemas.Close)
+	return ingest.NewConverter(config.IngestConfig{}, schemas)
+}
+
+func TestIngestEvent_SchemaViolation(t *testing.T) {
+	broker := newTestBroker(t)
+	router := setupTestRouter(NewEventHandler(broker, newSchemaConverter(t, schema.ModeEnforce), zap.NewNop()))
+
+	payload := testEventPayload()
+	payload["data"] = map[string]interface{}{"user_id": 123}
+	w := postJSON(router, "/events", payload)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	var response struct {
+		Errors []models.ValidationError `json:"errors"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	require.Len(t, response.Errors, 1)
+	assert.Equal(t, "data.user_id", response.Errors[0].Field)
+	assert.Equal(t, schema.CodeSchemaViolation, response.Errors[0].Code)
+	assert.Empty(t, broker.Messages())
+}
+
+func TestValidateEvent_ValidateSchema(t *testing.T) {
+	router := setupTestRouter(NewEventHandler(nil, newSchemaConverter(t, schema.ModeWarn), zap.NewNop()))
+
+	payload := testEventPayload()
+	payload["data"] = map[string]interface{}{}
+
+	// In warn mode, the violation is only a warning
+	w := postJSON(router, "/events/validate", payload)
+	var response map[string]interface{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, true, response["valid"])
+	assert.Len(t, response["warnings"], 2)
+
+	// validate_schema makes it an error
+	w = postJSON(router, "/events/validate?validate_schema=true", payload)
+	assert.Equal(t, http.StatusOK, w.Code)
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, false, response["valid"])
+	assert.Len(t, response["errors"], 1)
+}
+
+func postCloudEvent(router *gin.Engine, path, contentType, body string, header map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, path, bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", contentType)
+	for name, value := range header {
+		req.Header.Set(name, value)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestIngestEvent_CloudEventStructured(t *testing.T) {
+	broker, err := kafka.NewMemoryBroker(config.KafkaConfig{
+		Topic:         "events",
+		Serialization: config.SerializationConfig{Format: kafka.FormatCloudEvents},
+	}, zap.NewNop())
+	require.NoError(t, err)
+	router := setupTestRouter(NewEventHandler(broker, nil, zap.NewNop()))
+
+	w := postCloudEvent(router, "/events", "application/cloudevents+json; charset=utf-8", `{
+		"specversion": "1.0",
+		"id": "evt-1",
+		"source": "/users",
+		"type": "user.created",
+		"time": "2024-05-01T12:00:00Z",
+		"dataschema": "https://example.com/user.json",
+		"tenantid": "tenant-1",
+		"region": "eu-west-1",
+		"data": {"user_id": "123"}
+	}`, nil)
+
+	require.Equal(t, http.StatusAccepted, w.Code, w.Body.String())
+	var response models.EventResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, "evt-1", response.EventID)
+
+	messages := broker.Messages()
+	require.Len(t, messages, 1)
+	event := messages[0].Event
+	assert.Equal(t, "tenant-1", event.TenantID)
+	assert.Equal(t, "https://example.com/user.json", event.DataSchema)
+	assert.Equal(t, "eu-west-1", event.Metadata["region"])
+
+	// The record is a CloudEvent as well
+	var attributes map[string]interface{}
+	require.NoError(t, json.Unmarshal(messages[0].Value, &attributes))
+	assert.Equal(t, "1.0", attributes["specversion"])
+	assert.Equal(t, "evt-1", attributes["id"])
+	assert.Equal(t, "eu-west-1", attributes["region"])
+	assert.Equal(t, "test-request-id", attributes["requestid"])
+	assert.Equal(t, kafka.ContentTypeCloudEvents, messages[0].Headers["content-type"])
+}
+
+func TestIngestEvent_CloudEventBinary(t *testing.T) {
+	broker := newTestBroker(t)
+	router := setupTestRouter(NewEventHandler(broker, nil, zap.NewNop()))
+
+	w := postCloudEvent(router, "/events", "application/json", `{"user_id": "123"}`, map[string]string{
+		"ce-specversion": "1.0",
+		"ce-id":          "evt-2",
+		"ce-source":      "/users",
+		"ce-type":        "user.created",
+		"ce-priority":    "8",
+	})
+
+	require.Equal(t, http.StatusAccepted, w.Code, w.Body.String())
+	messages := broker.Messages()
+	require.Len(t, messages, 1)
+	assert.Equal(t, "evt-2", messages[0].Event.ID)
+	assert.Equal(t, 8, messages[0].Event.Priority)
+	assert.Equal(t, map[string]interface{}{"user_id": "123"}, messages[0].Event.Data)
+}
+
+func TestIngestEvent_CloudEventID(t *testing.T) {
+	broker, err := kafka.NewMemoryBroker(config.KafkaConfig{
+		Topic:         "events",
+		Serialization: config.SerializationConfig{Format: kafka.FormatCloudEvents},
+	}, zap.NewNop())
+	require.NoError(t, err)
+	router := setupTestRouter(NewEventHandler(broker, nil, zap.NewNop()))
+
+	// The specification allows any id, not only those valid as event IDs
+	w := postCloudEvent(router, "/events", "application/cloudevents+json",
+		`{"specversion": "1.0", "id": "a/b", "source": "/users", "type": "user.created", "data": {}}`, nil)
+	require.Equal(t, http.StatusAccepted, w.Code, w.Body.String())
+
+	messages := broker.Messages()
+	require.Len(t, messages, 1)
+	assert.NotEqual(t, "a/b", messages[0].Event.ID)
+	var attributes map[string]interface{}
+	require.NoError(t, json.Unmarshal(messages[0].Value, &attributes))
+	assert.Equal(t, "a/b", attributes["id"])
+	assert.NotContains(t, attributes, "cloudeventsid")
+}
+
+func TestIngestBatch_CloudEvents(t *testing.T) {
+	broker := newTestBroker(t)
+	router := setupTestRouter(NewEventHandler(broker, nil, zap.NewNop()))
+	batch := `[
+		{"specversion": "1.0", "id": "evt-1", "source": "/users", "type": "user.created", "data": {"user_id": "1"}},
+		{"specversion": "1.0", "id": "evt-2", "source": "/users", "type": "user.created"}
+	]`
+
+	// Batches are accepted by both endpoints
+	for _, path := range []string{"/events/batch", "/events"} {
+		w := postCloudEvent(router, path, "application/cloudevents-batch+json", batch, nil)
+		require.Equal(t, http.StatusMultiStatus, w.Code, path)
+
+		var response models.BatchEventResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.Equal(t, 1, response.ProcessedCount)
+		assert.Equal(t, "accepted", response.Results[0].Status)
+		assert.Equal(t, "failed", response.Results[1].Status)
+		assert.Equal(t, "data", response.Results[1].Errors[0].Field)
+	}
+	assert.Len(t, broker.Messages(), 2)
+}
+
+func TestIngestEvent_InvalidCloudEvent(t *testing.T) {
+	broker := newTestBroker(t)
+	router := setupTestRouter(NewEventHandler(broker, nil, zap.NewNop()))
+
+	w := postCloudEvent(router, "/events", "application/cloudevents+json",
+		`{"specversion": "0.3", "id": "evt-1", "source": "/users", "type": "user.created", "data": {}}`, nil)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	var response map[string]interface{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, "invalid_cloudevent", response["error"])
+	assert.Contains(t, response["details"], "specversion")
+
+	w = postCloudEvent(router, "/events/validate", "application/cloudevents+json", `{"specversion": `, nil)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, "invalid_json", response["error"])
+	assert.Equal(t, false, response["valid"])
+	assert.Empty(t, broker.Messages())
+}
+
+func postBody(router *gin.Engine, path, contentType string, body []byte) *httptest.ResponseRecorder {
+	return postCloudEvent(router, path, contentType, string(body), nil)
+}
+
+func TestIngestEvent_Protobuf(t *testing.T) {
+	broker := newTestBroker(t)
+	router := setupTestRouter(NewEventHandler(broker, nil, zap.NewNop()))
+
+	data, err := structpb.NewStruct(map[string]interface{}{"user_id": "123"})
+	require.NoError(t, err)
+	event := &pb.Event{
+		Id:        "evt-1",
+		Type:      "user.created",
+		Source:    "user-service",
+		Data:      data,
+		Timestamp: timestamppb.New(time.Now().Add(-time.Minute)),
+		Priority:  4,
+	}
+	body, err := proto.Marshal(&pb.IngestEventRequest{Event: event})
+	require.NoError(t, err)
+
+	w := postBody(router, "/events", binding.MIMEPROTOBUF, body)
+	require.Equal(t, http.StatusAccepted, w.Code, w.Body.String())
+
+	messages := broker.Messages()
+	require.Len(t, messages, 1)
+	assert.Equal(t, "evt-1", messages[0].Event.ID)
+	assert.Equal(t, 4, messages[0].Event.Priority)
+	assert.Equal(t, event.Timestamp.AsTime(), messages[0].Event.Timestamp)
+	assert.Equal(t, map[string]interface{}{"user_id": "123"}, messages[0].Event.Data)
+
+	// Batches are IngestEventBatchRequests
+	body, err = proto.Marshal(&pb.IngestEventBatchRequest{Events: []*pb.Event{
+		{Type: "user.created", Source: "user-service", Data: data},
+		{Type: "user.created", Data: data},
+	}})
+	require.NoError(t, err)
+
+	w = postBody(router, "/events/batch", binding.MIMEPROTOBUF, body)
+	require.Equal(t, http.StatusMultiStatus, w.Code, w.Body.String())
+	var response models.BatchEventResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, 1, response.ProcessedCount)
+	assert.Equal(t, "source", response.Results[1].Errors[0].Field)
+}
+
+func TestIngestEvent_Msgpack(t *testing.T) {
+	broker := newTestBroker(t)
+	router := setupTestRouter(NewEventHandler(broker, nil, zap.NewNop()))
+
+	timestamp := time.Now().Add(-time.Minute).UTC().Truncate(time.Second)
+	encode := func(v interface{}) []byte {
+		var body []byte
+		require.NoError(t, codec.NewEncoderBytes(&body, &codec.MsgpackHandle{WriteExt: true}).Encode(v))
+		return body
+	}
+
+	w := postBody(router, "/events", binding.MIMEMSGPACK2, encode(map[string]interface{}{
+		"id":        "evt-1",
+		"type":      "user.created",
+		"source":    "user-service",
+		"timestamp": timestamp,
+		"priority":  2,
+		"data":      map[string]interface{}{"user_id": "123", "profile": map[string]interface{}{"age": 30}},
+	}))
+	require.Equal(t, http.StatusAccepted, w.Code, w.Body.String())
+
+	messages := broker.Messages()
+	require.Len(t, messages, 1)
+	event := messages[0].Event
+	assert.Equal(t, "evt-1", event.ID)
+	assert.Equal(t, 2, event.Priority)
+	assert.True(t, timestamp.Equal(event.Timestamp), event.Timestamp)
+	// Data reads as it would from JSON
+	assert.Equal(t, map[string]interface{}{
+		"user_id": "123",
+		"profile": map[string]interface{}{"age": float64(30)},
+	}, event.Data)
+
+	w = postBody(router, "/events/batch", binding.MIMEMSGPACK, encode(map[string]interface{}{
+		"events": []interface{}{
+			map[string]interface{}{"type": "user.created", "source": "user-service", "data": map[string]interface{}{}},
+		},
+	}))
+	require.Equal(t, http.StatusAccepted, w.Code, w.Body.String())
+	assert.Len(t, broker.Messages(), 2)
+}
+
+func TestIngestEvent_InvalidEncodedBody(t *testing.T) {
+	router := setupTestRouter(NewEventHandler(newTestBroker(t), nil, zap.NewNop()))
+
+	tests := []struct {
+		contentType string
+		body        []byte
+		code        string
+	}{
+		{binding.MIMEPROTOBUF, []byte{0x0a, 0xff, 0x01}, "invalid_protobuf"},
+		{binding.MIMEMSGPACK2, []byte{0xc1}, "invalid_msgpack"},
+		{binding.MIMEMSGPACK2, []byte{0x93, 0x01, 0x02, 0x03}, "invalid_msgpack"},
+	}
+
+	for _, tt := range tests {
+		for _, path := range []string{"/events", "/events/batch", "/events/validate"} {
+			w := postBody(router, path, tt.contentType, tt.body)
+			assert.Equal(t, http.StatusBadRequest, w.Code, path)
+
+			var response map[string]interface{}
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+			assert.Equal(t, tt.code, response["error"], path)
+		}
+	}
 }
 
-// Note: Tests for successful event ingestion are omitted as they would require
-// a real Kafka producer or complex mocking. The validation tests above provide
-// adequate coverage of request handling, parsing, and validation logic.
+func TestIngestEvent_Compressed(t *testing.T) {
+	broker := newTestBroker(t)
+	router := gin.New()
+	router.Use(middleware.RequestSizeLimit(config.BodyLimitConfig{MaxBytes: 1024, MaxDecompressedBytes: 4096}))
+	handler := NewEventHandler(broker, nil, zap.NewNop())
+	router.POST("/events", handler.IngestEvent)
+
+	post := func(payload interface{}) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(payload)
+		var compressed bytes.Buffer
+		gz := gzip.NewWriter(&compressed)
+		_, _ = gz.Write(body)
+		_ = gz.Close()
+
+		req := httptest.NewRequest(http.MethodPost, "/events", &compressed)
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Content-Encoding", "gzip")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := post(testEventPayload())
+	require.Equal(t, http.StatusAccepted, w.Code, w.Body.String())
+	assert.Len(t, broker.Messages(), 1)
+
+	// Compressed well under the limit, but too large once decompressed
+	payload := testEventPayload()
+	payload["data"] = map[string]interface{}{"blob": strings.Repeat("x", 8192)}
+	w = post(payload)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+
+	var response map[string]interface{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, "request_too_large", response["error"])
+	assert.Contains(t, response["message"], "4096")
+	assert.Len(t, broker.Messages(), 1)
+}
@@ -13,11 +13,11 @@ import (
 
 type HealthHandler struct {
 	logger    *zap.Logger
-	producer  *kafka.Producer
+	producer  kafka.EventPublisher
 	startTime time.Time
 }
 
-func NewHealthHandler(logger *zap.Logger, producer *kafka.Producer) *HealthHandler {
+func NewHealthHandler(logger *zap.Logger, producer kafka.EventPublisher) *HealthHandler {
 	return &HealthHandler{
 		logger:    logger,
 		producer:  producer,
@@ -48,12 +48,21 @@ func (h *HealthHandler) DetailedHealth(c *gin.Context) {
 
 	// Check Kafka connectivity
 	kafkaStatus := "healthy"
+	var kafkaDetails gin.H
 	if h.producer == nil {
 		kafkaStatus = "unavailable"
 		health.Status = "degraded"
-	} else if !h.producer.IsHealthy() {
-		kafkaStatus = "unhealthy"
-		health.Status = "degraded"
+	} else {
+		kafkaHealth := h.producer.Health()
+		if !kafkaHealth.Healthy {
+			kafkaStatus = "unhealthy"
+			health.Status = "degraded"
+		}
+		kafkaDetails = gin.H{
+			"message":    kafkaHealth.Message,
+			"last_check": kafkaHealth.LastCheck,
+			"details":    kafkaHealth.Details,
+		}
 	}
 	health.Services["kafka"] = kafkaStatus
 
@@ -69,6 +78,7 @@ func (h *HealthHandler) DetailedHealth(c *gin.Context) {
 		"timestamp": health.Timestamp,
 		"version":   health.Version,
 		"services":  health.Services,
+		"kafka":     kafkaDetails,
 		"system": gin.H{
 			"uptime_seconds": int(uptime.Seconds()),
 			"uptime_human":   uptime.String(),
@@ -0,0 +1,219 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/distributed-event-processor/services/event-gateway/internal/registry"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// RegistryHandler serves the REST API of the embedded schema registry
+type RegistryHandler struct {
+	registry *registry.Registry
+	logger   *zap.Logger
+}
+
+func NewRegistryHandler(schemas *registry.Registry, logger *zap.Logger) *RegistryHandler {
+	return &RegistryHandler{
+		registry: schemas,
+		logger:   logger,
+	}
+}
+
+// schemaRequest carries a schema to register or check. The schema is a
+// string, or for JSON Schemas also the schema document itself.
+type schemaRequest struct {
+	SchemaType string          `json:"schema_type"`
+	Schema     json.RawMessage `json:"schema" binding:"required"`
+}
+
+func (r *schemaRequest) definition() string {
+	var definition string
+	if err := json.Unmarshal(r.Schema, &definition); err == nil {
+		return definition
+	}
+	return string(r.Schema)
+}
+
+// ListSubjects lists the subjects with registered schemas
+func (h *RegistryHandler) ListSubjects(c *gin.Context) {
+	c.JSON(http.StatusOK, h.registry.Subjects())
+}
+
+// ListVersions lists the versions of a subject
+func (h *RegistryHandler) ListVersions(c *gin.Context) {
+	versions, err := h.registry.Versions(c.Param("subject"))
+	if err != nil {
+		h.registryError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, versions)
+}
+
+// GetVersion returns a version of a subject, a number or "latest"
+func (h *RegistryHandler) GetVersion(c *gin.Context) {
+	version := registry.LatestVersion
+	if param := c.Param("version"); param != "latest" {
+		number, err := strconv.Atoi(param)
+		if err != nil || number < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":      "invalid_version",
+				"message":    "Version must be a positive number or latest",
+				"request_id": getRequestID(c),
+			})
+			return
+		}
+		version = number
+	}
+
+	schema, err := h.registry.Get(c.Param("subject"), version)
+	if err != nil {
+		h.registryError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, schema)
+}
+
+// RegisterSchema registers a schema as the next version of a subject
+func (h *RegistryHandler) RegisterSchema(c *gin.Context) {
+	var req schemaRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	schema, created, err := h.registry.Register(c.Param("subject"), req.SchemaType, req.definition())
+	if err != nil {
+		h.registryError(c, err)
+		return
+	}
+
+	status := http.StatusOK
+	if created {
+		status = http.StatusCreated
+		h.logger.Info("Schema registered",
+			zap.String("request_id", getRequestID(c)),
+			zap.String("subject", schema.Subject),
+			zap.Int("version", schema.Version),
+			zap.Int("id", schema.ID),
+		)
+	}
+	c.JSON(status, schema)
+}
+
+// CheckCompatibility reports whether a schema could be registered as the next
+// version of a subject, without registering it
+func (h *RegistryHandler) CheckCompatibility(c *gin.Context) {
+	var req schemaRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	subject := c.Param("subject")
+	err := h.registry.CheckCompatibility(subject, req.SchemaType, req.definition())
+
+	var incompatible *registry.IncompatibleError
+	switch {
+	case err == nil:
+		c.JSON(http.StatusOK, gin.H{
+			"compatible":    true,
+			"compatibility": h.registry.Compatibility(subject),
+			"reasons":       []string{},
+		})
+	case errors.As(err, &incompatible):
+		c.JSON(http.StatusOK, gin.H{
+			"compatible":    false,
+			"compatibility": incompatible.Compatibility,
+			"version":       incompatible.Version,
+			"reasons":       incompatible.Reasons,
+		})
+	default:
+		h.registryError(c, err)
+	}
+}
+
+// GetCompatibility returns the compatibility level of a subject
+func (h *RegistryHandler) GetCompatibility(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"compatibility": h.registry.Compatibility(c.Param("subject"))})
+}
+
+// SetCompatibility sets the compatibility level of a subject
+func (h *RegistryHandler) SetCompatibility(c *gin.Context) {
+	var req struct {
+		Compatibility string `json:"compatibility" binding:"required"`
+	}
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	if err := h.registry.SetCompatibility(c.Param("subject"), req.Compatibility); err != nil {
+		h.registryError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"compatibility": req.Compatibility})
+}
+
+// bindJSON binds the request body, answering the request itself if it is not
+// valid JSON
+func bindJSON(c *gin.Context, req interface{}) bool {
+	if err := c.ShouldBindJSON(req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":      "invalid_json",
+			"message":    "Invalid JSON format",
+			"details":    err.Error(),
+			"request_id": getRequestID(c),
+		})
+		return false
+	}
+	return true
+}
+
+// registryError answers with the HTTP status and error code of a registry error
+func (h *RegistryHandler) registryError(c *gin.Context, err error) {
+	var invalid *registry.InvalidSchemaError
+	var incompatible *registry.IncompatibleError
+
+	switch {
+	case errors.Is(err, registry.ErrSubjectNotFound):
+		c.JSON(http.StatusNotFound, gin.H{
+			"error":      "subject_not_found",
+			"message":    "Subject not found",
+			"request_id": getRequestID(c),
+		})
+	case errors.Is(err, registry.ErrVersionNotFound):
+		c.JSON(http.StatusNotFound, gin.H{
+			"error":      "version_not_found",
+			"message":    "Version not found",
+			"request_id": getRequestID(c),
+		})
+	case errors.As(err, &invalid):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"error":      "invalid_schema",
+			"message":    "Invalid schema",
+			"details":    invalid.Err.Error(),
+			"request_id": getRequestID(c),
+		})
+	case errors.As(err, &incompatible):
+		c.JSON(http.StatusConflict, gin.H{
+			"error":         "incompatible_schema",
+			"message":       "Schema is incompatible with the latest version",
+			"compatibility": incompatible.Compatibility,
+			"version":       incompatible.Version,
+			"reasons":       incompatible.Reasons,
+			"request_id":    getRequestID(c),
+		})
+	default:
+		h.logger.Error("Schema registry operation failed",
+			zap.String("request_id", getRequestID(c)),
+			zap.Error(err),
+		)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":      "registry_error",
+			"message":    "Failed to update the schema registry",
+			"request_id": getRequestID(c),
+		})
+	}
+}
@@ -0,0 +1,146 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/distributed-event-processor/services/event-gateway/internal/config"
+	"github.com/distributed-event-processor/services/event-gateway/internal/registry"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func setupRegistryRouter(t *testing.T) *gin.Engine {
+	schemas, err := registry.New(config.RegistryConfig{Compatibility: registry.CompatibilityBackward})
+	require.NoError(t, err)
+	handler := NewRegistryHandler(schemas, zap.NewNop())
+
+	router := gin.New()
+	router.GET("/subjects", handler.ListSubjects)
+	router.GET("/subjects/:subject/versions", handler.ListVersions)
+	router.POST("/subjects/:subject/versions", handler.RegisterSchema)
+	router.GET("/subjects/:subject/versions/:version", handler.GetVersion)
+	router.POST("/compatibility/subjects/:subject", handler.CheckCompatibility)
+	router.GET("/config/:subject", handler.GetCompatibility)
+	router.PUT("/config/:subject", handler.SetCompatibility)
+	return router
+}
+
+func registryRequest(router *gin.Engine, method, path string, payload interface{}) (*httptest.ResponseRecorder, map[string]interface{}) {
+	var body bytes.Buffer
+	if payload != nil {
+		_ = json.NewEncoder(&body).Encode(payload)
+	}
+	req := httptest.NewRequest(method, path, &body)
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	var response map[string]interface{}
+	_ = json.Unmarshal(w.Body.Bytes(), &response)
+	return w, response
+}
+
+func TestRegistry_RegisterAndGet(t *testing.T) {
+	router := setupRegistryRouter(t)
+
+	// The schema can be the JSON Schema document itself
+	w, response := registryRequest(router, http.MethodPost, "/subjects/user.created/versions", map[string]interface{}{
+		"schema": map[string]interface{}{"type": "object", "properties": map[string]interface{}{"user_id": map[string]interface{}{"type": "string"}}},
+	})
+	require.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, float64(1), response["version"])
+	assert.Equal(t, registry.SchemaTypeJSON, response["schema_type"])
+
+	// Or a string; registering it again returns the existing version
+	w, _ = registryRequest(router, http.MethodPost, "/subjects/user.created/versions", map[string]interface{}{
+		"schema_type": "JSON",
+		"schema":      response["schema"],
+	})
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w, response = registryRequest(router, http.MethodGet, "/subjects/user.created/versions/latest", nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "user.created", response["subject"])
+
+	w, _ = registryRequest(router, http.MethodGet, "/subjects/user.created/versions/1", nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w, _ = registryRequest(router, http.MethodGet, "/subjects", nil)
+	assert.JSONEq(t, `["user.created"]`, w.Body.String())
+	w, _ = registryRequest(router, http.MethodGet, "/subjects/user.created/versions", nil)
+	assert.JSONEq(t, `[1]`, w.Body.String())
+}
+
+func TestRegistry_Errors(t *testing.T) {
+	router := setupRegistryRouter(t)
+
+	w, response := registryRequest(router, http.MethodGet, "/subjects/user.created/versions/latest", nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, "subject_not_found", response["error"])
+
+	w, response = registryRequest(router, http.MethodGet, "/subjects/user.created/versions/first", nil)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, "invalid_version", response["error"])
+
+	w, response = registryRequest(router, http.MethodPost, "/subjects/user.created/versions", map[string]interface{}{
+		"schema": map[string]interface{}{"type": 42},
+	})
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	assert.Equal(t, "invalid_schema", response["error"])
+
+	w, response = registryRequest(router, http.MethodPost, "/subjects/user.created/versions", map[string]interface{}{})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, "invalid_json", response["error"])
+
+	w, _ = registryRequest(router, http.MethodPost, "/subjects/user.created/versions", map[string]interface{}{
+		"schema": map[string]interface{}{"type": "object"},
+	})
+	require.Equal(t, http.StatusCreated, w.Code)
+	w, response = registryRequest(router, http.MethodGet, "/subjects/user.created/versions/2", nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, "version_not_found", response["error"])
+}
+
+func TestRegistry_Compatibility(t *testing.T) {
+	router := setupRegistryRouter(t)
+	w, _ := registryRequest(router, http.MethodPost, "/subjects/user.created/versions", map[string]interface{}{
+		"schema": map[string]interface{}{"type": "object"},
+	})
+	require.Equal(t, http.StatusCreated, w.Code)
+
+	breaking := map[string]interface{}{
+		"schema": map[string]interface{}{"type": "object", "required": []string{"user_id"}},
+	}
+
+	w, response := registryRequest(router, http.MethodPost, "/compatibility/subjects/user.created", breaking)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, false, response["compatible"])
+	assert.Equal(t, registry.CompatibilityBackward, response["compatibility"])
+	assert.Equal(t, []interface{}{"schema: property user_id is now required"}, response["reasons"])
+
+	w, response = registryRequest(router, http.MethodPost, "/subjects/user.created/versions", breaking)
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Equal(t, "incompatible_schema", response["error"])
+	assert.NotEmpty(t, response["reasons"])
+
+	// Lowering the subject's level lets the schema through
+	w, _ = registryRequest(router, http.MethodPut, "/config/user.created", map[string]string{"compatibility": "FORWARD"})
+	assert.Equal(t, http.StatusOK, w.Code)
+	_, response = registryRequest(router, http.MethodGet, "/config/user.created", nil)
+	assert.Equal(t, registry.CompatibilityForward, response["compatibility"])
+
+	_, response = registryRequest(router, http.MethodPost, "/compatibility/subjects/user.created", breaking)
+	assert.Equal(t, true, response["compatible"])
+	w, _ = registryRequest(router, http.MethodPost, "/subjects/user.created/versions", breaking)
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	w, response = registryRequest(router, http.MethodPut, "/config/user.created", map[string]string{"compatibility": "SOMETIMES"})
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	assert.Equal(t, "invalid_schema", response["error"])
+}
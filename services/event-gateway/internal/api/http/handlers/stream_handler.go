@@ -0,0 +1,389 @@
+package handlers
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/distributed-event-processor/services/event-gateway/internal/api/http/middleware"
+	"github.com/distributed-event-processor/services/event-gateway/internal/config"
+	"github.com/distributed-event-processor/services/event-gateway/internal/ingest"
+	"github.com/distributed-event-processor/services/event-gateway/internal/kafka"
+	"github.com/distributed-event-processor/services/event-gateway/internal/models"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// ContentTypeNDJSON is the content type of event streams, one JSON event per
+// line
+const ContentTypeNDJSON = "application/x-ndjson"
+
+// Stream limits applied when the configuration leaves them unset
+const (
+	defaultStreamBatchSize     = 100
+	defaultStreamFlushInterval = 1000
+	defaultStreamMaxLineBytes  = 1 << 20
+	defaultStreamMaxErrors     = 1000
+)
+
+// streamReadBufferSize is the buffer lines are read through; longer lines are
+// assembled from several reads
+const streamReadBufferSize = 64 * 1024
+
+// StreamHandler ingests NDJSON streams of events too large for a batch. Lines
+// are parsed as they arrive and produced in chunks, so memory stays bounded
+// by the chunk size whatever the length of the stream. A chunk that does not
+// fill within the flush interval is produced as it is.
+type StreamHandler struct {
+	cfg       config.StreamConfig
+	producer  kafka.EventPublisher
+	converter *ingest.Converter
+	logger    *zap.Logger
+}
+
+// NewStreamHandler creates the stream handler. A nil converter applies the
+// default ingestion limits.
+func NewStreamHandler(cfg config.StreamConfig, producer kafka.EventPublisher, converter *ingest.Converter, logger *zap.Logger) *StreamHandler {
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = defaultStreamBatchSize
+	}
+	if cfg.FlushIntervalMs <= 0 {
+		cfg.FlushIntervalMs = defaultStreamFlushInterval
+	}
+	if cfg.MaxLineBytes <= 0 {
+		cfg.MaxLineBytes = defaultStreamMaxLineBytes
+	}
+	if cfg.MaxErrors <= 0 {
+		cfg.MaxErrors = defaultStreamMaxErrors
+	}
+	if converter == nil {
+		converter = ingest.NewConverter(config.IngestConfig{}, nil)
+	}
+	return &StreamHandler{
+		cfg:       cfg,
+		producer:  producer,
+		converter: converter,
+		logger:    logger,
+	}
+}
+
+// streamState tracks one stream: the chunk of events waiting to be produced
+// and the summary so far
+type streamState struct {
+	response         models.StreamEventResponse
+	events           []*models.Event
+	lines            []int
+	deliveryFailures int
+	retryAfter       int
+}
+
+// streamLine is a line read from a stream, or the error that ended it
+type streamLine struct {
+	data    []byte
+	tooLong bool
+	err     error
+}
+
+// IngestStream handles NDJSON stream ingestion, optionally gzip or zstd
+// compressed.
+// Every line is an EventRequest; the response summarizes the stream with the
+// errors of the lines that failed.
+func (h *StreamHandler) IngestStream(c *gin.Context) {
+	if c.ContentType() != ContentTypeNDJSON {
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{
+			"error":      "unsupported_media_type",
+			"message":    "Content-Type must be " + ContentTypeNDJSON,
+			"request_id": getRequestID(c),
+		})
+		return
+	}
+
+	idempotencyKey, ok := idempotencyKey(c)
+	if !ok {
+		return
+	}
+
+	body := c.Request.Body
+	if h.cfg.MaxBodyBytes > 0 {
+		body = http.MaxBytesReader(c.Writer, body, h.cfg.MaxBodyBytes)
+	}
+
+	// The decompressor is released by the line reader once it stops reading
+	var release func()
+	if encoding := strings.ToLower(c.GetHeader("Content-Encoding")); encoding != "" && encoding != "identity" {
+		decompressed, closeDecoder, err := middleware.NewDecoder(encoding, body, 0)
+		if errors.Is(err, middleware.ErrUnsupportedEncoding) {
+			c.JSON(http.StatusUnsupportedMediaType, gin.H{
+				"error":      "unsupported_encoding",
+				"message":    "Content-Encoding must be gzip, zstd or identity, not " + encoding,
+				"request_id": getRequestID(c),
+			})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":      "invalid_encoding",
+				"message":    "Request body is not valid " + encoding,
+				"details":    err.Error(),
+				"request_id": getRequestID(c),
+			})
+			return
+		}
+		release = closeDecoder
+		body = io.NopCloser(decompressed)
+	}
+
+	state := &streamState{
+		response: models.StreamEventResponse{Errors: []models.StreamLineError{}},
+		events:   make([]*models.Event, 0, h.cfg.BatchSize),
+		lines:    make([]int, 0, h.cfg.BatchSize),
+	}
+
+	// Lines are read in the background, so that a partial chunk can be
+	// produced while the client is not sending. A client that stops sending
+	// blocks the reader in a read, so the handler does not wait for it: the
+	// read is cut short by a deadline where the connection supports one, and
+	// otherwise ends with the connection.
+	lines := make(chan streamLine)
+	done := make(chan struct{})
+	var finished bool
+	go readLines(bufio.NewReaderSize(body, streamReadBufferSize), h.cfg.MaxLineBytes, lines, done, release)
+	defer func() {
+		close(done)
+		if !finished {
+			_ = http.NewResponseController(c.Writer).SetReadDeadline(time.Now())
+		}
+	}()
+
+	flushInterval := time.Duration(h.cfg.FlushIntervalMs) * time.Millisecond
+	flushTimer := time.NewTimer(flushInterval)
+	flushTimer.Stop()
+	defer flushTimer.Stop()
+
+	var readErr error
+	for number := 0; readErr == nil; {
+		var next streamLine
+		select {
+		case next = <-lines:
+		case <-flushTimer.C:
+			h.flush(c, state)
+			readErr = c.Request.Context().Err()
+			continue
+		case <-c.Request.Context().Done():
+			readErr = c.Request.Context().Err()
+			continue
+		}
+		if next.err != nil {
+			finished = true
+			if !errors.Is(next.err, io.EOF) {
+				readErr = next.err
+			}
+			break
+		}
+		number++
+		line, tooLong := next.data, next.tooLong
+
+		if tooLong {
+			state.response.LineCount++
+			h.fail(state, models.StreamLineError{
+				Line:  number,
+				Error: fmt.Sprintf("line exceeds %d bytes", h.cfg.MaxLineBytes),
+			})
+			continue
+		}
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		state.response.LineCount++
+
+		var req models.EventRequest
+		if err := json.Unmarshal(line, &req); err != nil {
+			h.fail(state, models.StreamLineError{Line: number, Error: "invalid JSON: " + err.Error()})
+			continue
+		}
+
+		event, result := h.converter.FromRequest(&req)
+		if !result.Valid() {
+			h.fail(state, models.StreamLineError{
+				Line:    number,
+				EventID: req.ID,
+				Error:   result.Err().Error(),
+				Errors:  result.Errors,
+			})
+			continue
+		}
+
+		// Add request metadata
+		if event.Metadata == nil {
+			event.Metadata = make(map[string]string)
+		}
+		event.Metadata[models.MetadataRequestID] = getRequestID(c)
+		event.Metadata["client_ip"] = c.ClientIP()
+		event.Metadata["user_agent"] = c.GetHeader("User-Agent")
+		event.Metadata["stream_line"] = strconv.Itoa(number)
+		if idempotencyKey != "" {
+			// Each line of a stream is deduplicated on its own
+			event.Metadata[models.MetadataIdempotencyKey] = idempotencyKey + ":" + strconv.Itoa(number)
+		}
+		setTraceContext(c, event)
+
+		state.events = append(state.events, event)
+		state.lines = append(state.lines, number)
+		switch len(state.events) {
+		case h.cfg.BatchSize:
+			flushTimer.Stop()
+			h.flush(c, state)
+			readErr = c.Request.Context().Err()
+		case 1:
+			flushTimer.Reset(flushInterval)
+		}
+	}
+	h.flush(c, state)
+
+	response := &state.response
+	status := http.StatusAccepted
+	if response.FailedCount > 0 {
+		status = http.StatusMultiStatus
+	}
+
+	// Nothing reached Kafka because of broker failures, so let clients retry the stream
+	if response.ProcessedCount == 0 && state.deliveryFailures > 0 {
+		status = http.StatusInternalServerError
+		if state.retryAfter > 0 {
+			status = http.StatusServiceUnavailable
+			c.Header("Retry-After", strconv.Itoa(state.retryAfter))
+		}
+	}
+
+	// The events read before the stream broke off were ingested, so the
+	// summary is still the answer
+	if readErr != nil {
+		response.Error = readErr.Error()
+		var tooLarge *http.MaxBytesError
+		if errors.As(readErr, &tooLarge) {
+			status = http.StatusRequestEntityTooLarge
+			response.Error = fmt.Sprintf("request body exceeds %d bytes", tooLarge.Limit)
+		} else if status != http.StatusInternalServerError && status != http.StatusServiceUnavailable {
+			status = http.StatusBadRequest
+		}
+	}
+
+	h.logger.Info("Event stream processed",
+		zap.String("request_id", getRequestID(c)),
+		zap.Int("lines", response.LineCount),
+		zap.Int("processed", response.ProcessedCount),
+		zap.Int("failed", response.FailedCount),
+		zap.NamedError("read_error", readErr))
+
+	c.JSON(status, response)
+}
+
+// flush produces the pending chunk of events and records their outcomes
+func (h *StreamHandler) flush(c *gin.Context, state *streamState) {
+	if len(state.events) == 0 {
+		return
+	}
+
+	deliveries := h.producer.ProduceBatch(c.Request.Context(), state.events)
+	for j, delivery := range deliveries {
+		event := state.events[j]
+		if delivery.Err != nil {
+			h.logger.Error("Failed to send stream event to Kafka",
+				zap.String("event_id", event.ID),
+				zap.String("request_id", getRequestID(c)),
+				zap.Int("line", state.lines[j]),
+				zap.Error(delivery.Err))
+
+			// A taken idempotency key is the client's mistake, not a broker failure
+			if _, _, rejected := idempotencyError(delivery.Err); !rejected {
+				state.deliveryFailures++
+			}
+			if seconds, ok := retryAfterSeconds(delivery.Err); ok {
+				state.retryAfter = seconds
+			}
+			h.fail(state, models.StreamLineError{
+				Line:    state.lines[j],
+				EventID: event.ID,
+				Error:   delivery.Err.Error(),
+			})
+			continue
+		}
+		if delivery.Dropped {
+			h.fail(state, models.StreamLineError{
+				Line:    state.lines[j],
+				EventID: event.ID,
+				Error:   errEventDropped,
+			})
+			continue
+		}
+		state.response.ProcessedCount++
+	}
+
+	state.events = state.events[:0]
+	state.lines = state.lines[:0]
+}
+
+// fail counts a failed line, listing it unless MaxErrors lines already are
+func (h *StreamHandler) fail(state *streamState, lineErr models.StreamLineError) {
+	state.response.FailedCount++
+	if len(state.response.Errors) < h.cfg.MaxErrors {
+		state.response.Errors = append(state.response.Errors, lineErr)
+	} else {
+		state.response.ErrorsTruncated = true
+	}
+}
+
+// readLines sends the lines of r to lines until the stream ends, the last
+// value carrying the error that ended it, or until done is closed. release,
+// if set, is called once reading has stopped.
+func readLines(r *bufio.Reader, max int, lines chan<- streamLine, done <-chan struct{}, release func()) {
+	if release != nil {
+		defer release()
+	}
+	for {
+		data, tooLong, err := readLine(r, nil, max)
+		select {
+		case lines <- streamLine{data: data, tooLong: tooLong, err: err}:
+		case <-done:
+			return
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// readLine reads the next line into buf, without its line ending. A line
+// longer than max bytes is skipped to its end and reported as too long, so
+// memory stays bounded by max whatever the input.
+func readLine(r *bufio.Reader, buf []byte, max int) ([]byte, bool, error) {
+	buf = buf[:0]
+	tooLong := false
+	for {
+		fragment, err := r.ReadSlice('\n')
+		if !tooLong {
+			buf = append(buf, fragment...)
+			if len(bytes.TrimRight(buf, "\r\n")) > max {
+				tooLong = true
+				buf = buf[:0]
+			}
+		}
+
+		switch {
+		case errors.Is(err, bufio.ErrBufferFull):
+			continue
+		case errors.Is(err, io.EOF) && (len(buf) > 0 || tooLong):
+			// The last line has no line ending
+		case err != nil:
+			return buf, false, err
+		}
+		return bytes.TrimRight(buf, "\r\n"), tooLong, nil
+	}
+}
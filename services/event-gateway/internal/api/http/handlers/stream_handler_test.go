@@ -0,0 +1,269 @@
+package handlers
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/distributed-event-processor/services/event-gateway/internal/config"
+	"github.com/distributed-event-processor/services/event-gateway/internal/kafka"
+	"github.com/distributed-event-processor/services/event-gateway/internal/models"
+	"github.com/gin-gonic/gin"
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+const streamEvent = `{"type": "user.created", "source": "user-service", "data": {"user_id": "123"}}`
+
+func setupStreamRouter(cfg config.StreamConfig, broker kafka.EventPublisher) *gin.Engine {
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Set("request_id", "test-request-id")
+		c.Next()
+	})
+	router.POST("/events/stream", NewStreamHandler(cfg, broker, nil, zap.NewNop()).IngestStream)
+	return router
+}
+
+func postStream(router *gin.Engine, body io.Reader, header map[string]string) (*httptest.ResponseRecorder, models.StreamEventResponse) {
+	req := httptest.NewRequest(http.MethodPost, "/events/stream", body)
+	req.Header.Set("Content-Type", ContentTypeNDJSON)
+	for name, value := range header {
+		req.Header.Set(name, value)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	var response models.StreamEventResponse
+	_ = json.Unmarshal(w.Body.Bytes(), &response)
+	return w, response
+}
+
+func TestIngestStream_Success(t *testing.T) {
+	broker := newTestBroker(t)
+	router := setupStreamRouter(config.StreamConfig{BatchSize: 2}, broker)
+
+	// Blank lines, CRLF line endings and a missing final newline are fine
+	body := streamEvent + "\n\n" + streamEvent + "\r\n" + streamEvent + "\n" + streamEvent + "\n" + streamEvent
+	w, response := postStream(router, strings.NewReader(body), map[string]string{"Idempotency-Key": "backfill-1"})
+
+	require.Equal(t, http.StatusAccepted, w.Code, w.Body.String())
+	assert.Equal(t, 5, response.LineCount)
+	assert.Equal(t, 5, response.ProcessedCount)
+	assert.Equal(t, 0, response.FailedCount)
+	assert.Empty(t, response.Errors)
+
+	messages := broker.Messages()
+	require.Len(t, messages, 5)
+	assert.Equal(t, "1", messages[0].Event.Metadata["stream_line"])
+	assert.Equal(t, "3", messages[1].Event.Metadata["stream_line"])
+	assert.Equal(t, "backfill-1:3", messages[1].Event.Metadata[models.MetadataIdempotencyKey])
+	assert.Equal(t, "test-request-id", messages[1].Event.Metadata[models.MetadataRequestID])
+}
+
+func TestIngestStream_LineErrors(t *testing.T) {
+	broker := newTestBroker(t)
+	router := setupStreamRouter(config.StreamConfig{MaxLineBytes: 200}, broker)
+
+	lines := []string{
+		streamEvent,
+		`{"type": "user.created"`,
+		`{"id": "evt-3", "type": "user.created", "data": {}}`,
+		`{"type": "user.created", "source": "user-service", "data": {"blob": "` + strings.Repeat("x", 300) + `"}}`,
+		streamEvent,
+	}
+	w, response := postStream(router, strings.NewReader(strings.Join(lines, "\n")), nil)
+
+	require.Equal(t, http.StatusMultiStatus, w.Code)
+	assert.Equal(t, 5, response.LineCount)
+	assert.Equal(t, 2, response.ProcessedCount)
+	assert.Equal(t, 3, response.FailedCount)
+	require.Len(t, response.Errors, 3)
+
+	assert.Equal(t, 2, response.Errors[0].Line)
+	assert.Contains(t, response.Errors[0].Error, "invalid JSON")
+
+	assert.Equal(t, 3, response.Errors[1].Line)
+	assert.Equal(t, "evt-3", response.Errors[1].EventID)
+	require.NotEmpty(t, response.Errors[1].Errors)
+	assert.Equal(t, "source", response.Errors[1].Errors[0].Field)
+
+	assert.Equal(t, 4, response.Errors[2].Line)
+	assert.Equal(t, "line exceeds 200 bytes", response.Errors[2].Error)
+	assert.Len(t, broker.Messages(), 2)
+}
+
+func TestIngestStream_MaxErrors(t *testing.T) {
+	router := setupStreamRouter(config.StreamConfig{MaxErrors: 2}, newTestBroker(t))
+
+	w, response := postStream(router, strings.NewReader("x\ny\nz\n"+streamEvent), nil)
+
+	assert.Equal(t, http.StatusMultiStatus, w.Code)
+	assert.Equal(t, 3, response.FailedCount)
+	assert.Len(t, response.Errors, 2)
+	assert.True(t, response.ErrorsTruncated)
+	assert.Equal(t, 1, response.ProcessedCount)
+}
+
+func TestIngestStream_Compressed(t *testing.T) {
+	broker := newTestBroker(t)
+	router := setupStreamRouter(config.StreamConfig{}, broker)
+
+	var body bytes.Buffer
+	gz := gzip.NewWriter(&body)
+	for i := 0; i < 250; i++ {
+		_, _ = gz.Write([]byte(streamEvent + "\n"))
+	}
+	require.NoError(t, gz.Close())
+
+	w, response := postStream(router, &body, map[string]string{"Content-Encoding": "gzip"})
+
+	require.Equal(t, http.StatusAccepted, w.Code, w.Body.String())
+	assert.Equal(t, 250, response.ProcessedCount)
+	assert.Len(t, broker.Messages(), 250)
+
+	encoder, err := zstd.NewWriter(nil)
+	require.NoError(t, err)
+	compressed := encoder.EncodeAll([]byte(strings.Repeat(streamEvent+"\n", 10)), nil)
+	w, response = postStream(router, bytes.NewReader(compressed), map[string]string{"Content-Encoding": "zstd"})
+	require.Equal(t, http.StatusAccepted, w.Code, w.Body.String())
+	assert.Equal(t, 10, response.ProcessedCount)
+
+	w, _ = postStream(router, strings.NewReader("not gzip"), map[string]string{"Content-Encoding": "gzip"})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w, _ = postStream(router, strings.NewReader(streamEvent), map[string]string{"Content-Encoding": "br"})
+	assert.Equal(t, http.StatusUnsupportedMediaType, w.Code)
+}
+
+func TestIngestStream_BodyTooLarge(t *testing.T) {
+	broker := newTestBroker(t)
+	router := setupStreamRouter(config.StreamConfig{BatchSize: 1, MaxBodyBytes: int64(2*len(streamEvent) + 10)}, broker)
+
+	body := strings.Repeat(streamEvent+"\n", 5)
+	w, response := postStream(router, strings.NewReader(body), nil)
+
+	// The lines read before the limit were ingested
+	assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+	assert.Equal(t, 2, response.ProcessedCount)
+	assert.Contains(t, response.Error, "request body exceeds")
+	assert.Len(t, broker.Messages(), 2)
+}
+
+func TestIngestStream_FlushesWhileOpen(t *testing.T) {
+	broker := newTestBroker(t)
+	router := setupStreamRouter(config.StreamConfig{FlushIntervalMs: 20}, broker)
+
+	body, client := io.Pipe()
+	finished := make(chan models.StreamEventResponse)
+	go func() {
+		_, response := postStream(router, body, nil)
+		finished <- response
+	}()
+
+	// The chunk is far from full, but its events are produced while the
+	// client keeps the stream open
+	_, err := io.WriteString(client, streamEvent+"\n"+streamEvent+"\n")
+	require.NoError(t, err)
+	require.Eventually(t, func() bool { return len(broker.Messages()) == 2 }, 5*time.Second, 5*time.Millisecond)
+
+	_, err = io.WriteString(client, streamEvent+"\n")
+	require.NoError(t, err)
+	require.Eventually(t, func() bool { return len(broker.Messages()) == 3 }, 5*time.Second, 5*time.Millisecond)
+
+	require.NoError(t, client.Close())
+	response := <-finished
+	assert.Equal(t, 3, response.LineCount)
+	assert.Equal(t, 3, response.ProcessedCount)
+	assert.Equal(t, "3", broker.Messages()[2].Event.Metadata["stream_line"])
+}
+
+func TestIngestStream_StalledClient(t *testing.T) {
+	broker := newTestBroker(t)
+	router := setupStreamRouter(config.StreamConfig{FlushIntervalMs: 20}, broker)
+
+	// The client sends one line and then neither sends nor closes the body
+	body, client := io.Pipe()
+	t.Cleanup(func() { _ = client.Close() })
+	go func() { _, _ = io.WriteString(client, streamEvent+"\n") }()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	req := httptest.NewRequest(http.MethodPost, "/events/stream", body).WithContext(ctx)
+	req.Header.Set("Content-Type", ContentTypeNDJSON)
+	w := httptest.NewRecorder()
+
+	finished := make(chan struct{})
+	go func() {
+		router.ServeHTTP(w, req)
+		close(finished)
+	}()
+
+	// The stream timeout ends the request although the read is still blocked
+	select {
+	case <-finished:
+	case <-time.After(5 * time.Second):
+		t.Fatal("handler is still waiting for the stalled client")
+	}
+
+	var response models.StreamEventResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, 1, response.ProcessedCount)
+	assert.Contains(t, response.Error, context.DeadlineExceeded.Error())
+	assert.Len(t, broker.Messages(), 1)
+}
+
+func TestIngestStream_KafkaFailure(t *testing.T) {
+	broker := newTestBroker(t)
+	broker.SetError(errors.New("broker down"))
+	router := setupStreamRouter(config.StreamConfig{}, broker)
+
+	w, response := postStream(router, strings.NewReader(streamEvent+"\n"+streamEvent), nil)
+
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Equal(t, 2, response.FailedCount)
+	assert.Equal(t, "broker down", response.Errors[0].Error)
+}
+
+func TestIngestStream_ContentType(t *testing.T) {
+	router := setupStreamRouter(config.StreamConfig{}, newTestBroker(t))
+
+	req := httptest.NewRequest(http.MethodPost, "/events/stream", strings.NewReader(streamEvent))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusUnsupportedMediaType, w.Code)
+}
+
+func TestReadLine(t *testing.T) {
+	reader := bufio.NewReaderSize(strings.NewReader("short\r\n"+strings.Repeat("x", 40)+"\nlast"), 16)
+
+	line, tooLong, err := readLine(reader, nil, 20)
+	require.NoError(t, err)
+	assert.False(t, tooLong)
+	assert.Equal(t, "short", string(line))
+
+	_, tooLong, err = readLine(reader, line, 20)
+	require.NoError(t, err)
+	assert.True(t, tooLong)
+
+	line, tooLong, err = readLine(reader, line, 20)
+	require.NoError(t, err)
+	assert.False(t, tooLong)
+	assert.Equal(t, "last", string(line))
+
+	_, _, err = readLine(reader, line, 20)
+	assert.ErrorIs(t, err, io.EOF)
+}
@@ -0,0 +1,439 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/distributed-event-processor/services/event-gateway/internal/config"
+	"github.com/distributed-event-processor/services/event-gateway/internal/ingest"
+	"github.com/distributed-event-processor/services/event-gateway/internal/kafka"
+	"github.com/distributed-event-processor/services/event-gateway/internal/models"
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"go.uber.org/zap"
+	"golang.org/x/time/rate"
+)
+
+// WebSocket limits applied when the configuration leaves them unset
+const (
+	defaultWebSocketPingInterval    = 30 * time.Second
+	defaultWebSocketMaxMessageBytes = 1 << 20
+	defaultWebSocketMaxInFlight     = 1000
+)
+
+// webSocketWriteTimeout bounds every write, so a client that stopped reading
+// cannot hold up acks for the others
+const webSocketWriteTimeout = 10 * time.Second
+
+// webSocketStatusError is the code of status frames reporting a failure
+const webSocketStatusError = "ERROR"
+
+// WebSocketHandler ingests events sent over WebSocket connections. Frames
+// follow the gRPC StreamEvents messages: clients send events, pings and
+// configuration, and the gateway answers with acks, pongs and statuses.
+type WebSocketHandler struct {
+	cfg          config.WebSocketConfig
+	producer     kafka.EventPublisher
+	converter    *ingest.Converter
+	logger       *zap.Logger
+	upgrader     websocket.Upgrader
+	pingInterval time.Duration
+}
+
+// NewWebSocketHandler creates the WebSocket handler. A nil converter applies
+// the default ingestion limits.
+func NewWebSocketHandler(cfg config.WebSocketConfig, producer kafka.EventPublisher, converter *ingest.Converter, logger *zap.Logger) *WebSocketHandler {
+	if cfg.MaxMessageBytes <= 0 {
+		cfg.MaxMessageBytes = defaultWebSocketMaxMessageBytes
+	}
+	if cfg.MaxInFlight <= 0 {
+		cfg.MaxInFlight = defaultWebSocketMaxInFlight
+	}
+	pingInterval := time.Duration(cfg.PingInterval) * time.Second
+	if pingInterval <= 0 {
+		pingInterval = defaultWebSocketPingInterval
+	}
+	if converter == nil {
+		converter = ingest.NewConverter(config.IngestConfig{}, nil)
+	}
+
+	h := &WebSocketHandler{
+		cfg:          cfg,
+		producer:     producer,
+		converter:    converter,
+		logger:       logger,
+		pingInterval: pingInterval,
+	}
+	h.upgrader = websocket.Upgrader{
+		EnableCompression: true,
+		CheckOrigin:       h.checkOrigin(),
+	}
+	return h
+}
+
+// Serve upgrades the request to a WebSocket connection and ingests the events
+// sent over it until the client closes it or stops answering pings
+func (h *WebSocketHandler) Serve(c *gin.Context) {
+	identity, ok := h.authorize(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error":      "unauthorized",
+			"message":    "A valid token is required",
+			"request_id": getRequestID(c),
+		})
+		return
+	}
+
+	// The upgrader answers failed handshakes itself
+	conn, err := h.upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		h.logger.Warn("WebSocket upgrade failed",
+			zap.String("request_id", getRequestID(c)),
+			zap.Error(err))
+		return
+	}
+
+	session := &webSocketSession{
+		handler:  h,
+		conn:     conn,
+		ctx:      c,
+		limiter:  newConnectionLimiter(h.cfg.RateLimit),
+		keyScope: "ws:" + identity + ":",
+		inFlight: make(chan struct{}, h.cfg.MaxInFlight),
+		done:     make(chan struct{}),
+	}
+
+	h.logger.Info("WebSocket connection established",
+		zap.String("request_id", getRequestID(c)),
+		zap.String("client_ip", c.ClientIP()))
+
+	session.run()
+
+	h.logger.Info("WebSocket connection closed",
+		zap.String("request_id", getRequestID(c)))
+}
+
+// authorize reports whether the request presents one of the configured
+// tokens, as a bearer token or, for browsers, which cannot set headers on
+// WebSocket requests, the access_token query parameter. The identity it
+// returns stands for the token without revealing it; it is empty when no
+// tokens are configured.
+func (h *WebSocketHandler) authorize(c *gin.Context) (identity string, ok bool) {
+	if len(h.cfg.AuthTokens) == 0 {
+		return "", true
+	}
+
+	token := c.Query("access_token")
+	if header := c.GetHeader("Authorization"); header != "" {
+		scheme, credentials, found := strings.Cut(header, " ")
+		if !found || !strings.EqualFold(scheme, "Bearer") {
+			return "", false
+		}
+		token = strings.TrimSpace(credentials)
+	}
+	if token == "" {
+		return "", false
+	}
+
+	for _, allowed := range h.cfg.AuthTokens {
+		if allowed != "" && subtle.ConstantTimeCompare([]byte(token), []byte(allowed)) == 1 {
+			sum := sha256.Sum256([]byte(token))
+			return hex.EncodeToString(sum[:8]), true
+		}
+	}
+	return "", false
+}
+
+// checkOrigin returns the handshake's origin check. Without allowed origins
+// the upgrader's default applies, which only accepts the gateway's own host.
+func (h *WebSocketHandler) checkOrigin() func(*http.Request) bool {
+	if len(h.cfg.AllowedOrigins) == 0 {
+		return nil
+	}
+	return func(r *http.Request) bool {
+		// Clients other than browsers do not send an origin
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			return true
+		}
+		for _, allowed := range h.cfg.AllowedOrigins {
+			if allowed == "*" || strings.EqualFold(allowed, origin) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// newConnectionLimiter returns the token bucket bounding the events of one
+// connection. Without a rate, events are not limited.
+func newConnectionLimiter(cfg config.RateLimitConfig) *rate.Limiter {
+	if cfg.RequestsPerSecond <= 0 {
+		return rate.NewLimiter(rate.Inf, 0)
+	}
+	burst := cfg.BurstSize
+	if burst <= 0 {
+		burst = cfg.RequestsPerSecond
+	}
+	return rate.NewLimiter(rate.Limit(cfg.RequestsPerSecond), burst)
+}
+
+// webSocketSession is one WebSocket connection. Acks are written from
+// delivery goroutines and pings from the keep-alive loop, so data frames are
+// written under writeMu. Event IDs are deduplicated within keyScope, which
+// is specific to the connection's token. inFlight holds a slot for every
+// event awaiting its ack.
+type webSocketSession struct {
+	handler  *WebSocketHandler
+	conn     *websocket.Conn
+	ctx      *gin.Context
+	limiter  *rate.Limiter
+	keyScope string
+	inFlight chan struct{}
+	writeMu  sync.Mutex
+	pending  sync.WaitGroup
+	done     chan struct{}
+}
+
+// run reads frames until the connection fails, then waits for outstanding
+// deliveries to be acked before closing it
+func (s *webSocketSession) run() {
+	h := s.handler
+	requestID := getRequestID(s.ctx)
+
+	// A connection silent for two ping intervals is dead
+	timeout := 2 * h.pingInterval
+	s.conn.SetReadLimit(h.cfg.MaxMessageBytes)
+	_ = s.conn.SetReadDeadline(time.Now().Add(timeout))
+	s.conn.SetPongHandler(func(string) error {
+		return s.conn.SetReadDeadline(time.Now().Add(timeout))
+	})
+
+	go s.keepAlive()
+
+	defer func() {
+		s.pending.Wait()
+		close(s.done)
+		_ = s.conn.Close()
+	}()
+
+	for {
+		_, data, err := s.conn.ReadMessage()
+		if err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				h.logger.Warn("WebSocket receive error",
+					zap.String("request_id", requestID),
+					zap.Error(err))
+			}
+			return
+		}
+		_ = s.conn.SetReadDeadline(time.Now().Add(timeout))
+
+		var req models.WebSocketRequest
+		if err := json.Unmarshal(data, &req); err != nil {
+			s.sendStatus(&models.WebSocketStatus{
+				Error:   "invalid_message",
+				Message: "Invalid JSON format: " + err.Error(),
+			})
+			continue
+		}
+
+		switch {
+		case countFrameFields(&req) != 1:
+			s.sendStatus(&models.WebSocketStatus{
+				Error:   "invalid_message",
+				Message: "A frame must carry exactly one of event, ping or config",
+			})
+
+		case req.Event != nil:
+			s.ingest(req.Event)
+
+		case req.Ping != nil:
+			s.send(&models.WebSocketResponse{
+				Pong: &models.WebSocketPong{Timestamp: time.Now().UTC()},
+			})
+
+		case req.Config != nil:
+			// Compression only applies if the handshake negotiated it
+			s.writeMu.Lock()
+			s.conn.EnableWriteCompression(req.Config.EnableCompression)
+			s.writeMu.Unlock()
+
+			h.logger.Info("WebSocket configuration received",
+				zap.String("request_id", requestID),
+				zap.Bool("compression", req.Config.EnableCompression),
+				zap.Int("batch_size", req.Config.BatchSize))
+		}
+	}
+}
+
+// ingest validates an event and submits it to Kafka, acking it once its
+// delivery completes
+func (s *webSocketSession) ingest(req *models.EventRequest) {
+	h := s.handler
+	c := s.ctx
+
+	if !s.limiter.Allow() {
+		s.sendStatus(&models.WebSocketStatus{
+			Error:      "rate_limit_exceeded",
+			Message:    "Rate limit exceeded",
+			EventID:    req.ID,
+			RetryAfter: 1,
+		})
+		return
+	}
+
+	event, result := h.converter.FromRequest(req)
+	if !result.Valid() {
+		s.sendStatus(&models.WebSocketStatus{
+			Error:   "validation_failed",
+			Message: result.Err().Error(),
+			EventID: req.ID,
+			Errors:  result.Errors,
+		})
+		return
+	}
+
+	// Add request metadata
+	if event.Metadata == nil {
+		event.Metadata = make(map[string]string)
+	}
+	event.Metadata[models.MetadataRequestID] = getRequestID(c)
+	event.Metadata["client_ip"] = c.ClientIP()
+	event.Metadata["user_agent"] = c.GetHeader("User-Agent")
+	setTraceContext(c, event)
+
+	// Events are deduplicated by the IDs the client gives them, among the
+	// events of clients with the same token
+	if req.ID != "" {
+		event.Metadata[models.MetadataIdempotencyKey] = s.keyScope + req.ID
+	}
+
+	// A client that does not wait for its acks is refused like one sending too fast
+	select {
+	case s.inFlight <- struct{}{}:
+	default:
+		s.sendStatus(&models.WebSocketStatus{
+			Error:      "rate_limit_exceeded",
+			Message:    "Too many events awaiting acknowledgement",
+			EventID:    req.ID,
+			RetryAfter: 1,
+		})
+		return
+	}
+
+	delivery := h.producer.ProduceEventAsync(c.Request.Context(), event)
+
+	s.pending.Add(1)
+	go func() {
+		defer s.pending.Done()
+
+		result := <-delivery
+		<-s.inFlight
+		if err := result.Err; err != nil {
+			h.logger.Error("Failed to send WebSocket event to Kafka",
+				zap.String("event_id", event.ID),
+				zap.String("request_id", getRequestID(c)),
+				zap.Error(err))
+
+			code := "ingestion_failed"
+			if _, rejected, ok := idempotencyError(err); ok {
+				code = rejected
+			}
+			retryAfter, _ := retryAfterSeconds(err)
+			s.sendStatus(&models.WebSocketStatus{
+				Error:      code,
+				Message:    err.Error(),
+				EventID:    event.ID,
+				RetryAfter: retryAfter,
+			})
+			return
+		}
+
+		s.send(&models.WebSocketResponse{
+			Ack: &models.EventAck{
+				EventID:     event.ID,
+				RequestID:   getRequestID(c),
+				AcceptedAt:  time.Now().UTC(),
+				Status:      ackStatus(result),
+				Topic:       result.Topic,
+				RoutingRule: result.Rule,
+				Cluster:     result.Cluster,
+				Partition:   result.Partition,
+				Offset:      result.Offset,
+				Duplicate:   result.Duplicate,
+			},
+		})
+	}()
+}
+
+// keepAlive pings the client every ping interval until the session ends
+func (s *webSocketSession) keepAlive() {
+	ticker := time.NewTicker(s.handler.pingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-ticker.C:
+			// Control frames may be written concurrently with data frames
+			if err := s.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(webSocketWriteTimeout)); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// sendStatus sends an ERROR status frame
+func (s *webSocketSession) sendStatus(st *models.WebSocketStatus) {
+	st.Code = webSocketStatusError
+	st.Timestamp = time.Now().UTC()
+	s.send(&models.WebSocketResponse{Status: st})
+}
+
+// send writes a frame. A failed write is only logged: the read loop notices
+// the broken connection and ends the session.
+func (s *webSocketSession) send(msg *models.WebSocketResponse) {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	_ = s.conn.SetWriteDeadline(time.Now().Add(webSocketWriteTimeout))
+	if err := s.conn.WriteJSON(msg); err != nil {
+		s.handler.logger.Warn("Failed to send WebSocket frame",
+			zap.String("request_id", getRequestID(s.ctx)),
+			zap.Error(err))
+	}
+}
+
+// countFrameFields returns how many of a frame's messages are set
+func countFrameFields(req *models.WebSocketRequest) int {
+	count := 0
+	if req.Event != nil {
+		count++
+	}
+	if req.Ping != nil {
+		count++
+	}
+	if req.Config != nil {
+		count++
+	}
+	return count
+}
+
+// ackStatus is the status of an acked event, as in the HTTP responses
+func ackStatus(delivery kafka.DeliveryResult) string {
+	if delivery.Dropped {
+		return "dropped"
+	}
+	if delivery.Queued {
+		return "queued"
+	}
+	return "accepted"
+}
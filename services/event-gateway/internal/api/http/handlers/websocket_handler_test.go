@@ -0,0 +1,247 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/distributed-event-processor/services/event-gateway/internal/config"
+	"github.com/distributed-event-processor/services/event-gateway/internal/kafka"
+	"github.com/distributed-event-processor/services/event-gateway/internal/models"
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+const webSocketEvent = `{"event": {"id": "evt-1", "type": "user.created", "source": "user-service", "data": {"user_id": "123"}}}`
+
+func setupWebSocketServer(t *testing.T, cfg config.WebSocketConfig, broker kafka.EventPublisher) string {
+	t.Helper()
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Set("request_id", "test-request-id")
+		c.Next()
+	})
+	router.GET("/ws", NewWebSocketHandler(cfg, broker, nil, zap.NewNop()).Serve)
+
+	server := httptest.NewServer(router)
+	t.Cleanup(server.Close)
+	return "ws" + strings.TrimPrefix(server.URL, "http") + "/ws"
+}
+
+func dialWebSocket(t *testing.T, url string, header http.Header) *websocket.Conn {
+	t.Helper()
+	conn, resp, err := websocket.DefaultDialer.Dial(url, header)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	t.Cleanup(func() { _ = conn.Close() })
+	return conn
+}
+
+func exchange(t *testing.T, conn *websocket.Conn, frame string) models.WebSocketResponse {
+	t.Helper()
+	require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(frame)))
+	return receive(t, conn)
+}
+
+func receive(t *testing.T, conn *websocket.Conn) models.WebSocketResponse {
+	t.Helper()
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+	var response models.WebSocketResponse
+	require.NoError(t, conn.ReadJSON(&response))
+	return response
+}
+
+func TestWebSocket_Event(t *testing.T) {
+	broker := newTestBroker(t)
+	url := setupWebSocketServer(t, config.WebSocketConfig{}, newTestDedupe(t, broker))
+	conn := dialWebSocket(t, url, http.Header{"traceparent": []string{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"}})
+
+	response := exchange(t, conn, webSocketEvent)
+	require.NotNil(t, response.Ack, "expected an ack, got %+v", response.Status)
+	assert.Equal(t, "evt-1", response.Ack.EventID)
+	assert.Equal(t, "test-request-id", response.Ack.RequestID)
+	assert.Equal(t, "accepted", response.Ack.Status)
+	assert.Equal(t, "events", response.Ack.Topic)
+	assert.GreaterOrEqual(t, response.Ack.Partition, int32(0))
+	assert.Equal(t, int64(0), response.Ack.Offset)
+
+	messages := broker.Messages()
+	require.Len(t, messages, 1)
+	metadata := messages[0].Event.Metadata
+	assert.Equal(t, "ws::evt-1", metadata[models.MetadataIdempotencyKey])
+	assert.Equal(t, "test-request-id", metadata[models.MetadataRequestID])
+	assert.NotEmpty(t, metadata[models.MetadataTraceParent])
+
+	// The client's ID deduplicates a resent event
+	response = exchange(t, conn, webSocketEvent)
+	require.NotNil(t, response.Ack)
+	assert.True(t, response.Ack.Duplicate)
+	assert.Len(t, broker.Messages(), 1)
+}
+
+func TestWebSocket_Frames(t *testing.T) {
+	url := setupWebSocketServer(t, config.WebSocketConfig{}, newTestBroker(t))
+	conn := dialWebSocket(t, url, nil)
+
+	response := exchange(t, conn, `{"ping": {}}`)
+	require.NotNil(t, response.Pong)
+	assert.False(t, response.Pong.Timestamp.IsZero())
+
+	// Configuration is not answered, so the next frame gets the next response
+	require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(`{"config": {"enable_compression": true, "batch_size": 10}}`)))
+
+	response = exchange(t, conn, `not json`)
+	require.NotNil(t, response.Status)
+	assert.Equal(t, "ERROR", response.Status.Code)
+	assert.Equal(t, "invalid_message", response.Status.Error)
+
+	response = exchange(t, conn, `{"ping": {}, "config": {}}`)
+	require.NotNil(t, response.Status)
+	assert.Equal(t, "invalid_message", response.Status.Error)
+
+	response = exchange(t, conn, `{"event": {"id": "evt-2", "type": "user.created", "data": {}}}`)
+	require.NotNil(t, response.Status)
+	assert.Equal(t, "validation_failed", response.Status.Error)
+	assert.Equal(t, "evt-2", response.Status.EventID)
+	require.NotEmpty(t, response.Status.Errors)
+	assert.Equal(t, "source", response.Status.Errors[0].Field)
+}
+
+func TestWebSocket_KafkaFailure(t *testing.T) {
+	broker := newTestBroker(t)
+	broker.SetError(errors.New("broker down"))
+	url := setupWebSocketServer(t, config.WebSocketConfig{}, broker)
+	conn := dialWebSocket(t, url, nil)
+
+	response := exchange(t, conn, webSocketEvent)
+	require.NotNil(t, response.Status)
+	assert.Equal(t, "ingestion_failed", response.Status.Error)
+	assert.Equal(t, "evt-1", response.Status.EventID)
+	assert.Equal(t, "broker down", response.Status.Message)
+}
+
+func TestWebSocket_Auth(t *testing.T) {
+	url := setupWebSocketServer(t, config.WebSocketConfig{AuthTokens: []string{"secret"}}, newTestBroker(t))
+
+	for _, header := range []http.Header{nil, {"Authorization": []string{"Bearer wrong"}}} {
+		_, resp, err := websocket.DefaultDialer.Dial(url, header)
+		require.Error(t, err)
+		require.NotNil(t, resp)
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+		_ = resp.Body.Close()
+	}
+
+	conn := dialWebSocket(t, url, http.Header{"Authorization": []string{"Bearer secret"}})
+	assert.NotNil(t, exchange(t, conn, `{"ping": {}}`).Pong)
+
+	conn = dialWebSocket(t, url+"?access_token=secret", nil)
+	assert.NotNil(t, exchange(t, conn, `{"ping": {}}`).Pong)
+}
+
+func TestWebSocket_DeduplicatesPerToken(t *testing.T) {
+	broker := newTestBroker(t)
+	url := setupWebSocketServer(t, config.WebSocketConfig{AuthTokens: []string{"team-a", "team-b"}}, newTestDedupe(t, broker))
+
+	first := dialWebSocket(t, url, http.Header{"Authorization": []string{"Bearer team-a"}})
+	response := exchange(t, first, webSocketEvent)
+	require.NotNil(t, response.Ack)
+	assert.False(t, response.Ack.Duplicate)
+
+	// Another client choosing the same ID is not mistaken for a retry
+	second := dialWebSocket(t, url, http.Header{"Authorization": []string{"Bearer team-b"}})
+	response = exchange(t, second, webSocketEvent)
+	require.NotNil(t, response.Ack)
+	assert.False(t, response.Ack.Duplicate)
+
+	// A reconnect with the same token is
+	again := dialWebSocket(t, url+"?access_token=team-a", nil)
+	response = exchange(t, again, webSocketEvent)
+	require.NotNil(t, response.Ack)
+	assert.True(t, response.Ack.Duplicate)
+
+	assert.Len(t, broker.Messages(), 2)
+}
+
+func TestWebSocket_Origin(t *testing.T) {
+	url := setupWebSocketServer(t, config.WebSocketConfig{AllowedOrigins: []string{"https://app.example.com"}}, newTestBroker(t))
+
+	_, resp, err := websocket.DefaultDialer.Dial(url, http.Header{"Origin": []string{"https://evil.example.com"}})
+	require.Error(t, err)
+	require.NotNil(t, resp)
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	_ = resp.Body.Close()
+
+	dialWebSocket(t, url, http.Header{"Origin": []string{"https://app.example.com"}})
+}
+
+func TestWebSocket_RateLimit(t *testing.T) {
+	broker := newTestBroker(t)
+	url := setupWebSocketServer(t, config.WebSocketConfig{
+		RateLimit: config.RateLimitConfig{RequestsPerSecond: 1, BurstSize: 2},
+	}, broker)
+	conn := dialWebSocket(t, url, nil)
+
+	assert.NotNil(t, exchange(t, conn, `{"event": {"type": "user.created", "source": "user-service", "data": {}}}`).Ack)
+	assert.NotNil(t, exchange(t, conn, `{"event": {"type": "user.created", "source": "user-service", "data": {}}}`).Ack)
+
+	response := exchange(t, conn, `{"event": {"id": "evt-3", "type": "user.created", "source": "user-service", "data": {}}}`)
+	require.NotNil(t, response.Status)
+	assert.Equal(t, "rate_limit_exceeded", response.Status.Error)
+	assert.Equal(t, "evt-3", response.Status.EventID)
+	assert.Equal(t, 1, response.Status.RetryAfter)
+	assert.Len(t, broker.Messages(), 2)
+
+	// Pings are not limited, and other connections have their own budget
+	assert.NotNil(t, exchange(t, conn, `{"ping": {}}`).Pong)
+	other := dialWebSocket(t, url, nil)
+	assert.NotNil(t, exchange(t, other, webSocketEvent).Ack)
+}
+
+func TestWebSocket_MaxInFlight(t *testing.T) {
+	broker := newTestBroker(t)
+	broker.SetLatency(300 * time.Millisecond)
+	url := setupWebSocketServer(t, config.WebSocketConfig{MaxInFlight: 1}, broker)
+	conn := dialWebSocket(t, url, nil)
+
+	// The first event is still awaiting its ack when the second arrives
+	require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(webSocketEvent)))
+	response := exchange(t, conn, `{"event": {"id": "evt-2", "type": "user.created", "source": "user-service", "data": {}}}`)
+	require.NotNil(t, response.Status)
+	assert.Equal(t, "rate_limit_exceeded", response.Status.Error)
+	assert.Equal(t, "evt-2", response.Status.EventID)
+
+	response = receive(t, conn)
+	require.NotNil(t, response.Ack)
+	assert.Equal(t, "evt-1", response.Ack.EventID)
+
+	// The ack frees the slot
+	assert.NotNil(t, exchange(t, conn, `{"event": {"id": "evt-3", "type": "user.created", "source": "user-service", "data": {}}}`).Ack)
+	assert.Len(t, broker.Messages(), 2)
+}
+
+func TestWebSocket_PingTimeout(t *testing.T) {
+	url := setupWebSocketServer(t, config.WebSocketConfig{PingInterval: 1}, newTestBroker(t))
+	conn := dialWebSocket(t, url, nil)
+
+	// Without pongs, the gateway drops the connection after two intervals
+	pings := 0
+	conn.SetPingHandler(func(string) error {
+		pings++
+		return nil
+	})
+
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+	start := time.Now()
+	_, _, err := conn.ReadMessage()
+	require.Error(t, err)
+	elapsed := time.Since(start)
+	assert.GreaterOrEqual(t, elapsed, 1500*time.Millisecond)
+	assert.Less(t, elapsed, 4*time.Second, "connection was not closed: %v", err)
+	assert.GreaterOrEqual(t, pings, 1)
+}
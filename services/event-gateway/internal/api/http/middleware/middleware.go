@@ -1,15 +1,21 @@
 package middleware
 
 import (
+	"compress/gzip"
 	"context"
+	"crypto/subtle"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/distributed-event-processor/services/event-gateway/internal/config"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"github.com/klauspost/compress/zstd"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
 	"go.uber.org/zap"
@@ -92,8 +98,8 @@ func CORS() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		c.Header("Access-Control-Allow-Origin", "*")
 		c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		c.Header("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Request-ID")
-		c.Header("Access-Control-Expose-Headers", "X-Request-ID, X-Event-ID")
+		c.Header("Access-Control-Allow-Headers", "Content-Type, Content-Encoding, Authorization, X-Request-ID, Idempotency-Key, traceparent, tracestate")
+		c.Header("Access-Control-Expose-Headers", "X-Request-ID, X-Event-ID, Idempotent-Replayed, Retry-After")
 		c.Header("Access-Control-Allow-Credentials", "false")
 		c.Header("Access-Control-Max-Age", "3600")
 
@@ -128,6 +134,31 @@ func RateLimit(cfg config.RateLimitConfig) gin.HandlerFunc {
 	}
 }
 
+// BearerToken middleware admits only requests that present one of tokens as
+// an Authorization bearer token
+func BearerToken(tokens []string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		scheme, token, _ := strings.Cut(c.GetHeader("Authorization"), " ")
+		token = strings.TrimSpace(token)
+		if strings.EqualFold(scheme, "Bearer") && token != "" {
+			for _, allowed := range tokens {
+				if allowed != "" && subtle.ConstantTimeCompare([]byte(token), []byte(allowed)) == 1 {
+					c.Next()
+					return
+				}
+			}
+		}
+
+		c.Header("WWW-Authenticate", "Bearer")
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error":      "unauthorized",
+			"message":    "A valid bearer token is required",
+			"request_id": getRequestID(c),
+		})
+		c.Abort()
+	}
+}
+
 // Metrics middleware collects Prometheus metrics
 func Metrics() gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -163,38 +194,173 @@ func Metrics() gin.HandlerFunc {
 	}
 }
 
-// RequestSizeLimit middleware limits request body size
-func RequestSizeLimit(maxSize string) gin.HandlerFunc {
-	return func(c *gin.Context) {
-		var maxBytes int64
-
-		// Parse max size (e.g., "10MB", "1KB")
-		switch maxSize {
-		case "1KB":
-			maxBytes = 1024
-		case "1MB":
-			maxBytes = 1024 * 1024
-		case "10MB":
-			maxBytes = 10 * 1024 * 1024
-		default:
-			maxBytes = 10 * 1024 * 1024 // Default 10MB
-		}
+// RequestSizeLimit middleware limits request bodies to cfg.MaxBytes as sent.
+// Bodies with a gzip or zstd Content-Encoding are decompressed for the
+// handlers and limited to cfg.MaxDecompressedBytes once decompressed, so a
+// decompression bomb fails like any oversized body, with a
+// *http.MaxBytesError from the body's reads.
+func RequestSizeLimit(cfg config.BodyLimitConfig) gin.HandlerFunc {
+	maxBytes := cfg.MaxBytes
+	if maxBytes <= 0 {
+		maxBytes = defaultMaxBodyBytes
+	}
+	maxDecompressed := cfg.MaxDecompressedBytes
+	if maxDecompressed <= 0 {
+		maxDecompressed = maxBytes
+	}
 
+	return func(c *gin.Context) {
 		if c.Request.ContentLength > maxBytes {
 			c.JSON(http.StatusRequestEntityTooLarge, gin.H{
 				"error":      "request_too_large",
-				"message":    fmt.Sprintf("Request body too large. Maximum allowed: %s", maxSize),
+				"message":    fmt.Sprintf("Request body too large. Maximum allowed: %d bytes", maxBytes),
 				"request_id": getRequestID(c),
 			})
 			c.Abort()
 			return
 		}
 
-		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxBytes)
+		body := http.MaxBytesReader(c.Writer, c.Request.Body, maxBytes)
+
+		encoding := strings.ToLower(strings.TrimSpace(c.GetHeader("Content-Encoding")))
+		if encoding == "" || encoding == "identity" {
+			c.Request.Body = body
+			c.Next()
+			return
+		}
+
+		decoder, closeDecoder, err := NewDecoder(encoding, body, maxDecompressed)
+		if err != nil {
+			var tooLarge *http.MaxBytesError
+			switch {
+			case errors.Is(err, ErrUnsupportedEncoding):
+				c.JSON(http.StatusUnsupportedMediaType, gin.H{
+					"error":      "unsupported_encoding",
+					"message":    "Content-Encoding must be gzip, zstd or identity, not " + encoding,
+					"request_id": getRequestID(c),
+				})
+			case errors.As(err, &tooLarge):
+				c.JSON(http.StatusRequestEntityTooLarge, gin.H{
+					"error":      "request_too_large",
+					"message":    fmt.Sprintf("Request body too large. Maximum allowed: %d bytes", maxBytes),
+					"request_id": getRequestID(c),
+				})
+			default:
+				c.JSON(http.StatusBadRequest, gin.H{
+					"error":      "invalid_encoding",
+					"message":    "Request body is not valid " + encoding,
+					"details":    err.Error(),
+					"request_id": getRequestID(c),
+				})
+			}
+			c.Abort()
+			return
+		}
+		defer closeDecoder()
+
+		// Handlers see the decompressed body, of unknown length
+		c.Request.Body = &decompressedBody{
+			decoder:   decoder,
+			body:      body,
+			limit:     maxDecompressed,
+			remaining: maxDecompressed,
+		}
+		c.Request.Header.Del("Content-Encoding")
+		c.Request.Header.Del("Content-Length")
+		c.Request.ContentLength = -1
 		c.Next()
 	}
 }
 
+// ErrInvalidEncoding is wrapped by the read errors of request bodies whose
+// compressed data is corrupt
+var ErrInvalidEncoding = errors.New("invalid content encoding")
+
+// ErrUnsupportedEncoding is returned by NewDecoder for encodings other than
+// gzip and zstd
+var ErrUnsupportedEncoding = errors.New("unsupported content encoding")
+
+// defaultMaxBodyBytes limits request bodies when the configuration leaves the
+// limit unset
+const defaultMaxBodyBytes = 10 * 1024 * 1024
+
+// maxZstdWindow bounds the memory of zstd decompression, at the window size
+// the zstd specification recommends decoders to support
+const maxZstdWindow = 8 << 20
+
+// NewDecoder returns a reader decompressing body, of the gzip or zstd
+// encoding, and the function releasing it. The zstd window, and so the
+// decoder's memory, is bounded by maxZstdWindow and by a positive limit, which
+// also refuses frames declaring more than limit bytes of content. Bodies read
+// as a stream pass no limit.
+func NewDecoder(encoding string, body io.Reader, limit int64) (io.Reader, func(), error) {
+	switch encoding {
+	case "gzip":
+		decoder, err := gzip.NewReader(body)
+		if err != nil {
+			return nil, nil, err
+		}
+		return decoder, func() { _ = decoder.Close() }, nil
+	case "zstd":
+		options := []zstd.DOption{
+			zstd.WithDecoderConcurrency(1),
+			zstd.WithDecoderMaxWindow(maxZstdWindow),
+		}
+		if limit > 0 {
+			options = append(options, zstd.WithDecoderMaxMemory(uint64(limit)))
+		}
+		decoder, err := zstd.NewReader(body, options...)
+		if err != nil {
+			return nil, nil, err
+		}
+		return decoder, decoder.Close, nil
+	}
+	return nil, nil, ErrUnsupportedEncoding
+}
+
+// decompressedBody is a decompressed request body. Like http.MaxBytesReader,
+// it fails with a *http.MaxBytesError once more than limit bytes come out.
+type decompressedBody struct {
+	decoder   io.Reader
+	body      io.Closer
+	limit     int64
+	remaining int64
+}
+
+func (b *decompressedBody) Read(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	// Read one byte more than allowed to tell a body of exactly limit bytes
+	// from a longer one
+	if int64(len(p))-1 > b.remaining {
+		p = p[:b.remaining+1]
+	}
+
+	n, err := b.decoder.Read(p)
+	if int64(n) > b.remaining {
+		n = int(b.remaining)
+		b.remaining = 0
+		return n, &http.MaxBytesError{Limit: b.limit}
+	}
+	b.remaining -= int64(n)
+
+	// zstd refuses frames declaring more content or a larger window than the
+	// limit before decompressing them
+	if errors.Is(err, zstd.ErrDecoderSizeExceeded) || errors.Is(err, zstd.ErrWindowSizeExceeded) {
+		return n, &http.MaxBytesError{Limit: b.limit}
+	}
+	var tooLarge *http.MaxBytesError
+	if err != nil && !errors.Is(err, io.EOF) && !errors.As(err, &tooLarge) {
+		err = fmt.Errorf("%w: %v", ErrInvalidEncoding, err)
+	}
+	return n, err
+}
+
+func (b *decompressedBody) Close() error {
+	return b.body.Close()
+}
+
 // Timeout middleware sets a timeout for request processing
 func Timeout(timeout time.Duration) gin.HandlerFunc {
 	return func(c *gin.Context) {
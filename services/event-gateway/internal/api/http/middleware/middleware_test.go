@@ -2,6 +2,9 @@ package middleware
 
 import (
 	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -9,7 +12,9 @@ import (
 
 	"github.com/distributed-event-processor/services/event-gateway/internal/config"
 	"github.com/gin-gonic/gin"
+	"github.com/klauspost/compress/zstd"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"go.uber.org/zap"
 )
 
@@ -81,6 +86,12 @@ func TestCORS(t *testing.T) {
 
 		assert.Equal(t, http.StatusNoContent, w.Code)
 		assert.Equal(t, "*", w.Header().Get("Access-Control-Allow-Origin"))
+
+		// Browsers may send the idempotency and trace context headers
+		for _, header := range []string{"Idempotency-Key", "traceparent", "tracestate"} {
+			assert.Contains(t, w.Header().Get("Access-Control-Allow-Headers"), header)
+		}
+		assert.Contains(t, w.Header().Get("Access-Control-Expose-Headers"), "Idempotent-Replayed")
 	})
 }
 
@@ -111,9 +122,47 @@ func TestRateLimit(t *testing.T) {
 	assert.Equal(t, http.StatusTooManyRequests, w.Code)
 }
 
+func TestBearerToken(t *testing.T) {
+	router := gin.New()
+	router.Use(BearerToken([]string{"secret", "other"}))
+	router.PUT("/test", func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	tests := []struct {
+		name          string
+		authorization string
+		expected      int
+	}{
+		{"no token", "", http.StatusUnauthorized},
+		{"wrong token", "Bearer wrong", http.StatusUnauthorized},
+		{"wrong scheme", "Basic secret", http.StatusUnauthorized},
+		{"empty token", "Bearer ", http.StatusUnauthorized},
+		{"valid token", "Bearer secret", http.StatusOK},
+		{"second token", "bearer other", http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPut, "/test", nil)
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expected, w.Code)
+			if tt.expected == http.StatusUnauthorized {
+				assert.Equal(t, "Bearer", w.Header().Get("WWW-Authenticate"))
+				assert.Contains(t, w.Body.String(), "unauthorized")
+			}
+		})
+	}
+}
+
 func TestRequestSizeLimit(t *testing.T) {
 	router := gin.New()
-	router.Use(RequestSizeLimit("1KB"))
+	router.Use(RequestSizeLimit(config.BodyLimitConfig{MaxBytes: 1024}))
 	router.POST("/test", func(c *gin.Context) {
 		c.Status(http.StatusOK)
 	})
@@ -142,6 +191,89 @@ func TestRequestSizeLimit(t *testing.T) {
 	})
 }
 
+func TestRequestSizeLimit_Decompression(t *testing.T) {
+	router := gin.New()
+	router.Use(RequestSizeLimit(config.BodyLimitConfig{MaxBytes: 1024, MaxDecompressedBytes: 4096}))
+	router.POST("/test", func(c *gin.Context) {
+		body, err := io.ReadAll(c.Request.Body)
+		var tooLarge *http.MaxBytesError
+		switch {
+		case errors.As(err, &tooLarge):
+			c.String(http.StatusRequestEntityTooLarge, "%d", tooLarge.Limit)
+		case errors.Is(err, ErrInvalidEncoding):
+			c.String(http.StatusBadRequest, err.Error())
+		case err != nil:
+			c.String(http.StatusInternalServerError, err.Error())
+		default:
+			c.String(http.StatusOK, "%s %d", c.GetHeader("Content-Encoding"), len(body))
+		}
+	})
+
+	post := func(encoding string, body []byte) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/test", bytes.NewReader(body))
+		req.Header.Set("Content-Encoding", encoding)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	gzipped := func(data []byte) []byte {
+		var buf bytes.Buffer
+		gz := gzip.NewWriter(&buf)
+		_, _ = gz.Write(data)
+		_ = gz.Close()
+		return buf.Bytes()
+	}
+	zstded := func(data []byte) []byte {
+		encoder, err := zstd.NewWriter(nil)
+		require.NoError(t, err)
+		return encoder.EncodeAll(data, nil)
+	}
+
+	t.Run("decompresses gzip and zstd", func(t *testing.T) {
+		data := bytes.Repeat([]byte("x"), 4096)
+
+		w := post("gzip", gzipped(data))
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, " 4096", w.Body.String())
+
+		w = post("ZSTD", zstded(data))
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, " 4096", w.Body.String())
+	})
+
+	t.Run("stops decompression bombs", func(t *testing.T) {
+		// A few hundred bytes that expand to 256KB
+		bomb := gzipped(bytes.Repeat([]byte("x"), 256<<10))
+		require.Less(t, len(bomb), 1024)
+
+		w := post("gzip", bomb)
+		assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+		assert.Equal(t, "4096", w.Body.String())
+
+		w = post("zstd", zstded(bytes.Repeat([]byte("x"), 1<<20)))
+		assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+		assert.Equal(t, "4096", w.Body.String())
+	})
+
+	t.Run("rejects corrupt and unknown encodings", func(t *testing.T) {
+		w := post("gzip", []byte("not gzip"))
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "invalid_encoding")
+
+		corrupt := gzipped(bytes.Repeat([]byte("x"), 100))
+		corrupt[len(corrupt)-5] ^= 0xff
+		w = post("gzip", corrupt)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		w = post("zstd", []byte("not zstd"))
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		w = post("br", []byte("data"))
+		assert.Equal(t, http.StatusUnsupportedMediaType, w.Code)
+	})
+}
+
 func TestTimeout(t *testing.T) {
 	router := gin.New()
 	router.Use(Timeout(100 * time.Millisecond))
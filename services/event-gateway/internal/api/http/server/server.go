@@ -7,23 +7,35 @@ import (
 
 	"github.com/distributed-event-processor/services/event-gateway/internal/api/http/handlers"
 	"github.com/distributed-event-processor/services/event-gateway/internal/api/http/middleware"
+	"github.com/distributed-event-processor/services/event-gateway/internal/cloudevents"
 	"github.com/distributed-event-processor/services/event-gateway/internal/config"
+	"github.com/distributed-event-processor/services/event-gateway/internal/ingest"
 	"github.com/distributed-event-processor/services/event-gateway/internal/kafka"
+	"github.com/distributed-event-processor/services/event-gateway/internal/registry"
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"go.uber.org/zap"
 )
 
-const defaultRequestTimeout = 30 * time.Second
+// Request timeouts applied when the configuration leaves them unset
+const (
+	defaultRequestTimeout = 30 * time.Second
+	defaultStreamTimeout  = time.Hour
+)
 
 type Server struct {
-	config   *config.Config
-	producer *kafka.Producer
-	logger   *zap.Logger
-	router   *gin.Engine
+	config    *config.Config
+	producer  kafka.EventPublisher
+	converter *ingest.Converter
+	registry  *registry.Registry
+	logger    *zap.Logger
+	router    *gin.Engine
 }
 
-func New(cfg *config.Config, producer *kafka.Producer, logger *zap.Logger) *Server {
+// New creates the HTTP server. The schema registry API is served only when
+// schemas is not nil.
+func New(cfg *config.Config, producer kafka.EventPublisher, converter *ingest.Converter, schemas *registry.Registry, logger *zap.Logger) *Server {
 	// Set Gin mode based on environment
 	if os.Getenv("GIN_MODE") == "release" {
 		gin.SetMode(gin.ReleaseMode)
@@ -34,10 +46,12 @@ func New(cfg *config.Config, producer *kafka.Producer, logger *zap.Logger) *Serv
 	router := gin.New()
 
 	server := &Server{
-		config:   cfg,
-		producer: producer,
-		logger:   logger,
-		router:   router,
+		config:    cfg,
+		producer:  producer,
+		converter: converter,
+		registry:  schemas,
+		logger:    logger,
+		router:    router,
 	}
 
 	server.setupMiddleware()
@@ -62,30 +76,41 @@ func (s *Server) setupMiddleware() {
 	// CORS middleware
 	s.router.Use(middleware.CORS())
 
-	// Timeout middleware
-	timeout := time.Duration(s.config.Server.WriteTimeout) * time.Second
-	if timeout == 0 {
-		timeout = defaultRequestTimeout
-	}
-	s.router.Use(middleware.Timeout(timeout))
-
 	// Rate limiting middleware
 	s.router.Use(middleware.RateLimit(s.config.RateLimit))
 
 	// Metrics middleware
 	s.router.Use(middleware.Metrics())
-
-	// Request size limit middleware
-	s.router.Use(middleware.RequestSizeLimit("10MB"))
 }
 
 func (s *Server) setupRoutes() {
 	// Create handlers
-	eventHandler := handlers.NewEventHandler(s.producer, s.logger)
+	eventHandler := handlers.NewEventHandler(s.producer, s.converter, s.logger)
+	streamHandler := handlers.NewStreamHandler(s.config.Stream, s.producer, s.converter, s.logger)
 	healthHandler := handlers.NewHealthHandler(s.logger, s.producer)
 
+	// Requests are bounded in time and size, except event streams, which
+	// are read as they arrive and bound themselves
+	timeout := time.Duration(s.config.Server.WriteTimeout) * time.Second
+	if timeout == 0 {
+		timeout = defaultRequestTimeout
+	}
+	bounded := s.router.Group("", middleware.Timeout(timeout), middleware.RequestSizeLimit(s.config.Server.BodyLimit))
+
+	streamTimeout := time.Duration(s.config.Stream.TimeoutMs) * time.Millisecond
+	if streamTimeout == 0 {
+		streamTimeout = defaultStreamTimeout
+	}
+	s.router.POST("/api/v1/events/stream", middleware.Timeout(streamTimeout), streamHandler.IngestStream)
+
+	// WebSocket connections stay open, so they are not bounded either
+	if s.config.WebSocket.Enabled {
+		webSocketHandler := handlers.NewWebSocketHandler(s.config.WebSocket, s.producer, s.converter, s.logger)
+		s.router.GET(s.config.WebSocket.Path, webSocketHandler.Serve)
+	}
+
 	// API v1 routes
-	v1 := s.router.Group("/api/v1")
+	v1 := bounded.Group("/api/v1")
 	{
 		// Event ingestion endpoints
 		v1.POST("/events", eventHandler.IngestEvent)
@@ -93,24 +118,41 @@ func (s *Server) setupRoutes() {
 
 		// Event validation endpoint (dry-run)
 		v1.POST("/events/validate", eventHandler.ValidateEvent)
+
+		// Schema registry endpoints. Schemas decide which events are accepted,
+		// so changing them takes a write token and is off without one.
+		if s.registry != nil {
+			registryHandler := handlers.NewRegistryHandler(s.registry, s.logger)
+			v1.GET("/registry/subjects", registryHandler.ListSubjects)
+			v1.GET("/registry/subjects/:subject/versions", registryHandler.ListVersions)
+			v1.GET("/registry/subjects/:subject/versions/:version", registryHandler.GetVersion)
+			v1.POST("/registry/compatibility/subjects/:subject", registryHandler.CheckCompatibility)
+			v1.GET("/registry/config/:subject", registryHandler.GetCompatibility)
+
+			if len(s.config.Registry.WriteTokens) > 0 {
+				registryWrites := v1.Group("/registry", middleware.BearerToken(s.config.Registry.WriteTokens))
+				registryWrites.POST("/subjects/:subject/versions", registryHandler.RegisterSchema)
+				registryWrites.PUT("/config/:subject", registryHandler.SetCompatibility)
+			}
+		}
 	}
 
 	// Health check endpoints
-	s.router.GET("/health", healthHandler.Health)
-	s.router.GET("/health/detailed", healthHandler.DetailedHealth)
-	s.router.GET("/health/ready", healthHandler.Ready)
-	s.router.GET("/health/live", healthHandler.Live)
+	bounded.GET("/health", healthHandler.Health)
+	bounded.GET("/health/detailed", healthHandler.DetailedHealth)
+	bounded.GET("/health/ready", healthHandler.Ready)
+	bounded.GET("/health/live", healthHandler.Live)
 
 	// Metrics endpoint
 	if s.config.Metrics.Enabled {
-		s.router.GET(s.config.Metrics.Path, gin.WrapH(promhttp.Handler()))
+		bounded.GET(s.config.Metrics.Path, gin.WrapH(promhttp.Handler()))
 	}
 
 	// API documentation endpoint
-	s.router.GET("/api/docs", s.apiDocs)
+	bounded.GET("/api/docs", s.apiDocs)
 
 	// Root endpoint
-	s.router.GET("/", s.root)
+	bounded.GET("/", s.root)
 }
 
 func (s *Server) GetRouter() http.Handler {
@@ -124,8 +166,10 @@ func (s *Server) apiDocs(c *gin.Context) {
 		"version": "1.0.0",
 		"endpoints": gin.H{
 			"POST /api/v1/events": gin.H{
-				"description":  "Ingest a single event",
-				"content_type": "application/json",
+				"description":       "Ingest a single event",
+				"content_type":      "application/json",
+				"content_types":     []string{"application/json", binding.MIMEPROTOBUF, binding.MIMEMSGPACK2, cloudevents.ContentType},
+				"content_encodings": []string{"gzip", "zstd"},
 				"example": gin.H{
 					"type":    "user.created",
 					"source":  "user-service",
@@ -137,14 +181,40 @@ func (s *Server) apiDocs(c *gin.Context) {
 				},
 			},
 			"POST /api/v1/events/batch": gin.H{
-				"description":  "Ingest multiple events in a single request",
-				"content_type": "application/json",
-				"max_events":   100,
+				"description":       "Ingest multiple events in a single request",
+				"content_type":      "application/json",
+				"content_types":     []string{"application/json", binding.MIMEPROTOBUF, binding.MIMEMSGPACK2, cloudevents.BatchContentType},
+				"content_encodings": []string{"gzip", "zstd"},
+				"max_events":        100,
+			},
+			"POST /api/v1/events/stream": gin.H{
+				"description":       "Ingest a stream of events, one JSON event per line, optionally gzip or zstd compressed",
+				"content_type":      handlers.ContentTypeNDJSON,
+				"content_encodings": []string{"gzip", "zstd"},
+			},
+			"GET " + s.config.WebSocket.Path: gin.H{
+				"description": "Ingest events over a WebSocket connection, when enabled; frames carry one of event, ping or config",
+				"example": gin.H{
+					"event": gin.H{
+						"type":   "user.created",
+						"source": "user-service",
+						"data":   gin.H{"user_id": "123"},
+					},
+				},
 			},
 			"POST /api/v1/events/validate": gin.H{
 				"description":  "Validate event without ingesting (dry-run)",
 				"content_type": "application/json",
 			},
+			"POST /api/v1/registry/subjects/{subject}/versions": gin.H{
+				"description":  "Register a schema as the next version of a subject, when the schema registry is enabled with write tokens",
+				"auth":         "Authorization: Bearer <registry write token>",
+				"content_type": "application/json",
+				"example": gin.H{
+					"schema_type": "JSON",
+					"schema":      `{"type": "object", "required": ["user_id"]}`,
+				},
+			},
 			"GET /health": gin.H{
 				"description": "Basic health check",
 			},
@@ -0,0 +1,343 @@
+// Package cloudevents maps CloudEvents 1.0 onto the gateway's events and back,
+// in the JSON event format of the HTTP structured, batched and binary content
+// modes.
+package cloudevents
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"mime"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/distributed-event-processor/services/event-gateway/internal/models"
+)
+
+// SpecVersion is the CloudEvents version the gateway reads and writes
+const SpecVersion = "1.0"
+
+// Content types of the structured and batched content modes
+const (
+	ContentType      = "application/cloudevents+json"
+	BatchContentType = "application/cloudevents-batch+json"
+)
+
+// headerPrefix starts the headers that carry attributes in binary mode
+const headerPrefix = "ce-"
+
+// Extension attributes that carry the gateway's own event fields. The
+// cloudevents record header mapping uses the same names.
+const (
+	ExtensionTenantID      = "tenantid"
+	ExtensionCorrelationID = "correlationid"
+	ExtensionVersion       = "version"
+	ExtensionSchemaVersion = "schemaversion"
+	ExtensionPriority      = "priority"
+)
+
+// MetadataID keeps the id of a CloudEvent that is not a valid event ID. Such
+// an event gets an ID derived from its source and id, and is written back out
+// with its own id.
+const MetadataID = "cloudevents_id"
+
+// maxIDLength bounds the ids of CloudEvents, which the specification only
+// requires to be non-empty
+const maxIDLength = 1024
+
+// contextAttributes are the attributes defined by the specification, which
+// metadata cannot be written as
+var contextAttributes = map[string]bool{
+	"specversion":     true,
+	"id":              true,
+	"source":          true,
+	"type":            true,
+	"subject":         true,
+	"time":            true,
+	"datacontenttype": true,
+	"dataschema":      true,
+	"data":            true,
+	"data_base64":     true,
+}
+
+// ErrInvalid is wrapped by the errors for requests that are well-formed JSON,
+// but not a CloudEvent the gateway can ingest
+var ErrInvalid = errors.New("invalid CloudEvent")
+
+func invalid(format string, args ...interface{}) error {
+	return fmt.Errorf("%w: %s", ErrInvalid, fmt.Sprintf(format, args...))
+}
+
+// Decode reads a CloudEvent in structured content mode
+func Decode(body []byte) (*models.EventRequest, error) {
+	var attributes map[string]interface{}
+	if err := json.Unmarshal(body, &attributes); err != nil {
+		return nil, err
+	}
+	return fromAttributes(attributes)
+}
+
+// DecodeBatch reads the CloudEvents of a batched content mode request. An
+// event that is not a valid CloudEvent fails the whole batch.
+func DecodeBatch(body []byte) ([]models.EventRequest, error) {
+	var batch []map[string]interface{}
+	if err := json.Unmarshal(body, &batch); err != nil {
+		return nil, err
+	}
+
+	requests := make([]models.EventRequest, len(batch))
+	for i, attributes := range batch {
+		req, err := fromAttributes(attributes)
+		if err != nil {
+			return nil, fmt.Errorf("event %d: %w", i, err)
+		}
+		requests[i] = *req
+	}
+	return requests, nil
+}
+
+// IsBinary reports whether a request carries a CloudEvent in binary content
+// mode
+func IsBinary(header http.Header) bool {
+	return header.Get(headerPrefix+"specversion") != ""
+}
+
+// DecodeBinary reads a CloudEvent in binary content mode: the attributes are
+// the ce- headers, percent-encoded, and the data is the body, described by
+// the Content-Type header
+func DecodeBinary(header http.Header, body []byte) (*models.EventRequest, error) {
+	attributes := make(map[string]interface{})
+	for key, values := range header {
+		name := strings.ToLower(key)
+		if !strings.HasPrefix(name, headerPrefix) || len(values) == 0 {
+			continue
+		}
+		value, err := url.PathUnescape(values[0])
+		if err != nil {
+			value = values[0]
+		}
+		attributes[strings.TrimPrefix(name, headerPrefix)] = value
+	}
+	if contentType := header.Get("Content-Type"); contentType != "" {
+		if !isJSON(contentType) {
+			return nil, invalid("Content-Type %s is not supported, data must be a JSON object", contentType)
+		}
+		attributes["datacontenttype"] = contentType
+	}
+
+	if len(body) > 0 {
+		var data interface{}
+		if err := json.Unmarshal(body, &data); err != nil {
+			return nil, err
+		}
+		attributes["data"] = data
+	}
+	return fromAttributes(attributes)
+}
+
+// fromAttributes maps the attributes of a CloudEvent onto an event request.
+// The gateway's extensions set their fields and other extensions become
+// metadata.
+func fromAttributes(attributes map[string]interface{}) (*models.EventRequest, error) {
+	specVersion, ok := attributes["specversion"]
+	if !ok {
+		return nil, invalid("specversion is required")
+	}
+	if specVersion != SpecVersion {
+		return nil, invalid("specversion %v is not supported (expected: %s)", specVersion, SpecVersion)
+	}
+	if _, ok := attributes["data_base64"]; ok {
+		return nil, invalid("data_base64 is not supported, data must be a JSON object")
+	}
+	if contentType, ok := attributes["datacontenttype"]; ok {
+		if s, isString := contentType.(string); !isString || !isJSON(s) {
+			return nil, invalid("datacontenttype %v is not supported, data must be a JSON object", contentType)
+		}
+	}
+
+	req := &models.EventRequest{}
+	for _, name := range sortedNames(attributes) {
+		if name == "data" || name == "specversion" || name == "datacontenttype" {
+			continue
+		}
+		value, err := attributeString(name, attributes[name])
+		if err != nil {
+			return nil, err
+		}
+
+		switch name {
+		case "id":
+			req.ID = value
+		case "source":
+			req.Source = value
+		case "type":
+			req.Type = value
+		case "subject":
+			req.Subject = value
+		case "dataschema":
+			req.DataSchema = value
+		case "time":
+			timestamp, err := time.Parse(time.RFC3339Nano, value)
+			if err != nil {
+				return nil, invalid("time must be an RFC 3339 timestamp: %s", value)
+			}
+			req.Timestamp = &timestamp
+		case ExtensionTenantID:
+			req.TenantID = value
+		case ExtensionCorrelationID:
+			req.CorrelationID = value
+		case ExtensionVersion:
+			req.Version = value
+		case ExtensionSchemaVersion:
+			req.SchemaVersion = value
+		case ExtensionPriority:
+			priority, err := strconv.Atoi(value)
+			if err != nil {
+				return nil, invalid("priority must be an integer: %s", value)
+			}
+			req.Priority = priority
+		default:
+			if req.Metadata == nil {
+				req.Metadata = make(map[string]string)
+			}
+			req.Metadata[name] = value
+		}
+	}
+
+	if req.ID != "" && !models.ValidEventID(req.ID) {
+		if len(req.ID) > maxIDLength {
+			return nil, invalid("id must be at most %d bytes", maxIDLength)
+		}
+		if req.Metadata == nil {
+			req.Metadata = make(map[string]string)
+		}
+		req.Metadata[MetadataID] = req.ID
+		req.ID = derivedID(req.Source, req.ID)
+	}
+
+	switch data := attributes["data"].(type) {
+	case nil:
+	case map[string]interface{}:
+		req.Data = data
+	default:
+		return nil, invalid("data must be a JSON object")
+	}
+	return req, nil
+}
+
+// derivedID returns the event ID of a CloudEvent whose id is not a valid event
+// ID. Source and id identify a CloudEvent, so a resent event gets the same ID.
+func derivedID(source, id string) string {
+	sum := sha256.Sum256([]byte(source + "\x00" + id))
+	return "ce-" + hex.EncodeToString(sum[:16])
+}
+
+// ID returns the CloudEvents id of an event: the id it was received with, or
+// its event ID
+func ID(event *models.Event) string {
+	if id := event.Metadata[MetadataID]; id != "" {
+		return id
+	}
+	return event.ID
+}
+
+// attributeString returns the string form of an attribute value. Only
+// strings, numbers and booleans are attribute values.
+func attributeString(name string, value interface{}) (string, error) {
+	switch v := value.(type) {
+	case string:
+		return v, nil
+	case float64:
+		if v == math.Trunc(v) && math.Abs(v) < 1<<53 {
+			return strconv.FormatInt(int64(v), 10), nil
+		}
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	default:
+		return "", invalid("attribute %s must be a string, number or boolean", name)
+	}
+}
+
+// isJSON reports whether a media type is JSON, including types with a +json
+// suffix
+func isJSON(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return mediaType == "application/json" || mediaType == "text/json" || strings.HasSuffix(mediaType, "+json")
+}
+
+// Encode writes an event as a CloudEvent in structured content mode. The
+// gateway's fields are written as its extensions, and metadata entries as
+// extensions named by their key in lowercase without other characters than
+// letters and digits.
+func Encode(event *models.Event) ([]byte, error) {
+	attributes := map[string]interface{}{
+		"specversion":     SpecVersion,
+		"id":              ID(event),
+		"source":          event.Source,
+		"type":            event.Type,
+		"datacontenttype": "application/json",
+		"data":            event.Data,
+	}
+	if !event.Timestamp.IsZero() {
+		attributes["time"] = event.Timestamp.UTC().Format(time.RFC3339Nano)
+	}
+
+	optional := map[string]string{
+		"subject":              event.Subject,
+		"dataschema":           event.DataSchema,
+		ExtensionTenantID:      event.TenantID,
+		ExtensionCorrelationID: event.CorrelationID,
+		ExtensionVersion:       event.Version,
+		ExtensionSchemaVersion: event.SchemaVersion,
+	}
+	for name, value := range optional {
+		if value != "" {
+			attributes[name] = value
+		}
+	}
+	attributes[ExtensionPriority] = event.Priority
+
+	for _, key := range sortedNames(event.Metadata) {
+		name := extensionName(key)
+		if name == "" || contextAttributes[name] || key == MetadataID {
+			continue
+		}
+		if _, taken := attributes[name]; taken {
+			continue
+		}
+		attributes[name] = event.Metadata[key]
+	}
+
+	return json.Marshal(attributes)
+}
+
+// extensionName turns a metadata key into a valid attribute name
+func extensionName(key string) string {
+	var name strings.Builder
+	for _, c := range strings.ToLower(key) {
+		if (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') {
+			name.WriteRune(c)
+		}
+	}
+	return name.String()
+}
+
+func sortedNames[V any](m map[string]V) []string {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
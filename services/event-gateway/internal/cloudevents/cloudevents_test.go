@@ -0,0 +1,208 @@
+package cloudevents
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/distributed-event-processor/services/event-gateway/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const structuredEvent = `{
+	"specversion": "1.0",
+	"id": "A234-1234-1234",
+	"source": "https://github.com/cloudevents/spec/pull",
+	"type": "com.github.pull_request.opened",
+	"subject": "123",
+	"time": "2018-04-05T17:31:00+02:00",
+	"datacontenttype": "application/json",
+	"dataschema": "https://example.com/schemas/pull.json",
+	"tenantid": "acme",
+	"priority": 7,
+	"comexampleextension": "value",
+	"sampled": true,
+	"data": {"number": 123}
+}`
+
+func TestDecode(t *testing.T) {
+	req, err := Decode([]byte(structuredEvent))
+	require.NoError(t, err)
+
+	assert.Equal(t, "A234-1234-1234", req.ID)
+	assert.Equal(t, "https://github.com/cloudevents/spec/pull", req.Source)
+	assert.Equal(t, "com.github.pull_request.opened", req.Type)
+	assert.Equal(t, "123", req.Subject)
+	assert.Equal(t, "https://example.com/schemas/pull.json", req.DataSchema)
+	require.NotNil(t, req.Timestamp)
+	assert.True(t, req.Timestamp.Equal(time.Date(2018, 4, 5, 15, 31, 0, 0, time.UTC)))
+	assert.Equal(t, "acme", req.TenantID)
+	assert.Equal(t, 7, req.Priority)
+	assert.Equal(t, map[string]string{"comexampleextension": "value", "sampled": "true"}, req.Metadata)
+	assert.Equal(t, map[string]interface{}{"number": float64(123)}, req.Data)
+}
+
+func TestDecode_Invalid(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+	}{
+		{"no specversion", `{"id": "1", "source": "s", "type": "t", "data": {}}`},
+		{"unsupported specversion", `{"specversion": "0.3", "id": "1", "source": "s", "type": "t", "data": {}}`},
+		{"binary data", `{"specversion": "1.0", "id": "1", "source": "s", "type": "t", "data_base64": "AAE="}`},
+		{"non-JSON data", `{"specversion": "1.0", "id": "1", "source": "s", "type": "t", "datacontenttype": "text/plain", "data": "hi"}`},
+		{"data not an object", `{"specversion": "1.0", "id": "1", "source": "s", "type": "t", "data": [1, 2]}`},
+		{"invalid time", `{"specversion": "1.0", "id": "1", "source": "s", "type": "t", "time": "yesterday", "data": {}}`},
+		{"invalid priority", `{"specversion": "1.0", "id": "1", "source": "s", "type": "t", "priority": "high", "data": {}}`},
+		{"object attribute", `{"specversion": "1.0", "id": "1", "source": "s", "type": "t", "ext": {"a": 1}, "data": {}}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Decode([]byte(tt.body))
+			assert.ErrorIs(t, err, ErrInvalid)
+		})
+	}
+
+	// Malformed JSON is not a CloudEvent error
+	_, err := Decode([]byte(`{"specversion": `))
+	assert.Error(t, err)
+	assert.NotErrorIs(t, err, ErrInvalid)
+}
+
+func TestDecode_ID(t *testing.T) {
+	decode := func(id, source string) (*models.EventRequest, error) {
+		body, _ := json.Marshal(map[string]interface{}{"specversion": "1.0", "id": id, "source": source, "type": "t", "data": map[string]interface{}{}})
+		return Decode(body)
+	}
+
+	// Ids that are valid event IDs are kept
+	req, err := decode("urn:x:1", "s")
+	require.NoError(t, err)
+	assert.Equal(t, "urn:x:1", req.ID)
+	assert.Empty(t, req.Metadata)
+
+	// Other ids get a derived event ID, stable for the same source and id
+	req, err = decode("a/b", "s")
+	require.NoError(t, err)
+	assert.True(t, models.ValidEventID(req.ID), req.ID)
+	assert.Equal(t, "a/b", req.Metadata[MetadataID])
+	assert.Equal(t, "a/b", ID(req.ToEvent()))
+
+	again, err := decode("a/b", "s")
+	require.NoError(t, err)
+	assert.Equal(t, req.ID, again.ID)
+	other, err := decode("a/b", "other")
+	require.NoError(t, err)
+	assert.NotEqual(t, req.ID, other.ID)
+
+	_, err = decode(strings.Repeat("x/", maxIDLength), "s")
+	assert.ErrorIs(t, err, ErrInvalid)
+}
+
+func TestDecode_ApplicationJSONSuffix(t *testing.T) {
+	req, err := Decode([]byte(`{"specversion": "1.0", "id": "1", "source": "s", "type": "t",
+		"datacontenttype": "application/vnd.order+json; charset=utf-8", "data": {"a": 1}}`))
+	require.NoError(t, err)
+	assert.NotNil(t, req.Data)
+}
+
+func TestDecodeBatch(t *testing.T) {
+	requests, err := DecodeBatch([]byte(`[
+		{"specversion": "1.0", "id": "1", "source": "s", "type": "t", "data": {}},
+		{"specversion": "1.0", "id": "2", "source": "s", "type": "t", "schemaversion": "2", "data": {}}
+	]`))
+	require.NoError(t, err)
+	require.Len(t, requests, 2)
+	assert.Equal(t, "2", requests[1].ID)
+	assert.Equal(t, "2", requests[1].SchemaVersion)
+
+	_, err = DecodeBatch([]byte(`[{"specversion": "1.0"}, {"id": "2"}]`))
+	assert.ErrorIs(t, err, ErrInvalid)
+	assert.Contains(t, err.Error(), "event 1")
+}
+
+func TestDecodeBinary(t *testing.T) {
+	header := http.Header{}
+	header.Set("Content-Type", "application/json")
+	header.Set("ce-specversion", "1.0")
+	header.Set("ce-id", "evt-1")
+	header.Set("ce-source", "/orders")
+	header.Set("ce-type", "order.created")
+	header.Set("ce-subject", "order%20%2342")
+	header.Set("ce-time", "2024-05-01T12:00:00Z")
+	header.Set("ce-correlationid", "corr-1")
+	header.Set("ce-region", "eu-west-1")
+	header.Set("X-Other", "ignored")
+
+	req, err := DecodeBinary(header, []byte(`{"order_id": "42"}`))
+	require.NoError(t, err)
+
+	assert.Equal(t, "evt-1", req.ID)
+	assert.Equal(t, "/orders", req.Source)
+	assert.Equal(t, "order.created", req.Type)
+	assert.Equal(t, "order #42", req.Subject)
+	assert.Equal(t, "corr-1", req.CorrelationID)
+	assert.Equal(t, map[string]string{"region": "eu-west-1"}, req.Metadata)
+	assert.Equal(t, map[string]interface{}{"order_id": "42"}, req.Data)
+	assert.True(t, IsBinary(header))
+
+	header.Set("Content-Type", "text/plain")
+	_, err = DecodeBinary(header, []byte(`hello`))
+	assert.ErrorIs(t, err, ErrInvalid)
+
+	assert.False(t, IsBinary(http.Header{"Content-Type": []string{"application/json"}}))
+}
+
+func TestEncode(t *testing.T) {
+	event := &models.Event{
+		ID:            "evt-1",
+		Type:          "order.created",
+		Source:        "orders",
+		Subject:       "order-42",
+		TenantID:      "acme",
+		Data:          map[string]interface{}{"amount": 10.5},
+		Timestamp:     time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+		SchemaVersion: "2",
+		DataSchema:    "https://example.com/order.json",
+		CorrelationID: "corr-1",
+		Priority:      5,
+		Metadata:      map[string]string{"request_id": "req-1", "region": "eu", "type": "clash", "--": "dropped"},
+	}
+
+	value, err := Encode(event)
+	require.NoError(t, err)
+
+	var attributes map[string]interface{}
+	require.NoError(t, json.Unmarshal(value, &attributes))
+	assert.Equal(t, map[string]interface{}{
+		"specversion":     "1.0",
+		"id":              "evt-1",
+		"source":          "orders",
+		"type":            "order.created",
+		"subject":         "order-42",
+		"time":            "2024-05-01T12:00:00Z",
+		"datacontenttype": "application/json",
+		"dataschema":      "https://example.com/order.json",
+		"tenantid":        "acme",
+		"correlationid":   "corr-1",
+		"schemaversion":   "2",
+		"priority":        float64(5),
+		"requestid":       "req-1",
+		"region":          "eu",
+		"data":            map[string]interface{}{"amount": 10.5},
+	}, attributes)
+
+	// Encoded events read back as they were
+	req, err := Decode(value)
+	require.NoError(t, err)
+	decoded := req.AsEvent()
+	assert.Equal(t, event.ID, decoded.ID)
+	assert.Equal(t, event.DataSchema, decoded.DataSchema)
+	assert.Equal(t, event.Priority, decoded.Priority)
+	assert.True(t, event.Timestamp.Equal(decoded.Timestamp))
+	assert.Equal(t, event.Data, decoded.Data)
+}
@@ -9,20 +9,34 @@ import (
 )
 
 type Config struct {
-	Environment string           `mapstructure:"environment"`
-	Server      ServerConfig     `mapstructure:"server"`
-	GRPC        GRPCConfig       `mapstructure:"grpc"`
-	WebSocket   WebSocketConfig  `mapstructure:"websocket"`
-	Kafka       KafkaConfig      `mapstructure:"kafka"`
-	Metrics     MetricsConfig    `mapstructure:"metrics"`
-	RateLimit   RateLimitConfig  `mapstructure:"rate_limit"`
+	Environment string          `mapstructure:"environment"`
+	Server      ServerConfig    `mapstructure:"server"`
+	GRPC        GRPCConfig      `mapstructure:"grpc"`
+	WebSocket   WebSocketConfig `mapstructure:"websocket"`
+	Kafka       KafkaConfig     `mapstructure:"kafka"`
+	Metrics     MetricsConfig   `mapstructure:"metrics"`
+	RateLimit   RateLimitConfig `mapstructure:"rate_limit"`
+	Ingest      IngestConfig    `mapstructure:"ingest"`
+	Stream      StreamConfig    `mapstructure:"stream"`
+	Dedupe      DedupeConfig    `mapstructure:"dedupe"`
+	Schemas     SchemasConfig   `mapstructure:"schemas"`
+	Registry    RegistryConfig  `mapstructure:"registry"`
 }
 
 type ServerConfig struct {
-	Address      string `mapstructure:"address"`
-	ReadTimeout  int    `mapstructure:"read_timeout"`
-	WriteTimeout int    `mapstructure:"write_timeout"`
-	IdleTimeout  int    `mapstructure:"idle_timeout"`
+	Address      string          `mapstructure:"address"`
+	ReadTimeout  int             `mapstructure:"read_timeout"`
+	WriteTimeout int             `mapstructure:"write_timeout"`
+	IdleTimeout  int             `mapstructure:"idle_timeout"`
+	BodyLimit    BodyLimitConfig `mapstructure:"body_limit"`
+}
+
+// BodyLimitConfig bounds HTTP request bodies to MaxBytes as sent and, once a
+// gzip or zstd Content-Encoding is undone, to MaxDecompressedBytes, so a small
+// compressed body cannot expand without bound
+type BodyLimitConfig struct {
+	MaxBytes             int64 `mapstructure:"max_bytes"`
+	MaxDecompressedBytes int64 `mapstructure:"max_decompressed_bytes"`
 }
 
 type GRPCConfig struct {
@@ -35,18 +49,262 @@ type GRPCConfig struct {
 	KeepAliveMinAge int    `mapstructure:"keepalive_min_age"`
 }
 
+// WebSocketConfig serves event ingestion over WebSocket at Path. The gateway
+// pings every PingInterval seconds and drops connections that stay silent for
+// two intervals. Frames may be at most MaxMessageBytes. Browsers may connect
+// from AllowedOrigins ("*" for any, none for the gateway's own origin only).
+// With AuthTokens, connections must present one of them, and RateLimit bounds
+// the events of each connection. At most MaxInFlight events of a connection
+// may await their acks.
 type WebSocketConfig struct {
-	Enabled      bool   `mapstructure:"enabled"`
-	Path         string `mapstructure:"path"`
-	PingInterval int    `mapstructure:"ping_interval"`
+	Enabled         bool            `mapstructure:"enabled"`
+	Path            string          `mapstructure:"path"`
+	PingInterval    int             `mapstructure:"ping_interval"`
+	MaxMessageBytes int64           `mapstructure:"max_message_bytes"`
+	AllowedOrigins  []string        `mapstructure:"allowed_origins"`
+	AuthTokens      []string        `mapstructure:"auth_tokens"`
+	RateLimit       RateLimitConfig `mapstructure:"rate_limit"`
+	MaxInFlight     int             `mapstructure:"max_in_flight"`
 }
 
 type KafkaConfig struct {
+	// Mode is kafka, or memory to keep events in process without a broker
+	Mode   string             `mapstructure:"mode"`
+	Memory MemoryBrokerConfig `mapstructure:"memory"`
+
+	// Cluster names the cluster at Brokers in metrics, health and responses
+	Cluster      string   `mapstructure:"cluster"`
 	Brokers      []string `mapstructure:"brokers"`
 	Topic        string   `mapstructure:"topic"`
 	Retries      int      `mapstructure:"retries"`
 	BatchSize    int      `mapstructure:"batch_size"`
 	RequiredAcks int      `mapstructure:"required_acks"`
+	ProducerMode string   `mapstructure:"producer_mode"`
+
+	// DeliveryGuarantee is one of at_most_once, at_least_once, idempotent or transactional
+	DeliveryGuarantee string `mapstructure:"delivery_guarantee"`
+	TransactionalID   string `mapstructure:"transactional_id"`
+
+	// Compression is one of none, gzip, snappy, lz4 or zstd; a level of 0 uses the codec default
+	Compression      string `mapstructure:"compression"`
+	CompressionLevel int    `mapstructure:"compression_level"`
+	LingerMs         int    `mapstructure:"linger_ms"`
+	MaxMessageBytes  int    `mapstructure:"max_message_bytes"`
+	Partitioner      string `mapstructure:"partitioner"`
+	ClientID         string `mapstructure:"client_id"`
+
+	TLS  KafkaTLSConfig  `mapstructure:"tls"`
+	SASL KafkaSASLConfig `mapstructure:"sasl"`
+
+	Secondary SecondaryClusterConfig `mapstructure:"secondary"`
+
+	Serialization  SerializationConfig  `mapstructure:"serialization"`
+	Headers        HeadersConfig        `mapstructure:"headers"`
+	PartitionKey   PartitionKeyConfig   `mapstructure:"partition_key"`
+	Routing        RoutingConfig        `mapstructure:"routing"`
+	Spool          SpoolConfig          `mapstructure:"spool"`
+	Health         KafkaHealthConfig    `mapstructure:"health"`
+	DeadLetter     DeadLetterConfig     `mapstructure:"dead_letter"`
+	CircuitBreaker CircuitBreakerConfig `mapstructure:"circuit_breaker"`
+	PriorityLanes  PriorityLanesConfig  `mapstructure:"priority_lanes"`
+}
+
+// MemoryBrokerConfig shapes the in-memory broker used with mode memory. Each
+// topic has Partitions partitions; every send is delayed by LatencyMs and fails
+// with probability ErrorRate, to exercise clients against a slow or flaky broker.
+type MemoryBrokerConfig struct {
+	Partitions int     `mapstructure:"partitions"`
+	LatencyMs  int     `mapstructure:"latency_ms"`
+	ErrorRate  float64 `mapstructure:"error_rate"`
+}
+
+// KafkaTLSConfig encrypts broker connections. CAFile verifies the brokers in
+// place of the system roots, and CertFile with KeyFile enables mutual TLS. The
+// files are re-read when they change, so rotated certificates are picked up by
+// new connections without a restart.
+type KafkaTLSConfig struct {
+	Enabled            bool   `mapstructure:"enabled"`
+	CAFile             string `mapstructure:"ca_file"`
+	CertFile           string `mapstructure:"cert_file"`
+	KeyFile            string `mapstructure:"key_file"`
+	ServerName         string `mapstructure:"server_name"`
+	InsecureSkipVerify bool   `mapstructure:"insecure_skip_verify"`
+}
+
+// KafkaSASLConfig authenticates to the brokers. Mechanism is one of PLAIN,
+// SCRAM-SHA-256 or SCRAM-SHA-512. The password can come from the environment
+// (GATEWAY_KAFKA_SASL_PASSWORD) or be read from PasswordFile.
+type KafkaSASLConfig struct {
+	Enabled      bool   `mapstructure:"enabled"`
+	Mechanism    string `mapstructure:"mechanism"`
+	Username     string `mapstructure:"username"`
+	Password     string `mapstructure:"password"`
+	PasswordFile string `mapstructure:"password_file"`
+}
+
+// SecondaryClusterConfig adds a second Kafka cluster. Policy is failover (send
+// to the secondary while the primary's circuit breaker is open), round_robin
+// (alternate between the clusters) or dual_write (send every event to both,
+// with the primary's outcome deciding the response). The secondary has its own
+// brokers and credentials and shares every other producer setting.
+type SecondaryClusterConfig struct {
+	Enabled bool            `mapstructure:"enabled"`
+	Name    string          `mapstructure:"name"`
+	Policy  string          `mapstructure:"policy"`
+	Brokers []string        `mapstructure:"brokers"`
+	TLS     KafkaTLSConfig  `mapstructure:"tls"`
+	SASL    KafkaSASLConfig `mapstructure:"sasl"`
+}
+
+// SerializationConfig selects the wire format of message values. Format is one
+// of json, cloudevents, protobuf or avro, and Overrides switch the format for
+// specific topics. The protobuf and avro formats use the Confluent Schema Registry wire
+// format and need SchemaRegistry.
+type SerializationConfig struct {
+	Format         string                  `mapstructure:"format"`
+	Overrides      []SerializationOverride `mapstructure:"overrides"`
+	SchemaRegistry SchemaRegistryConfig    `mapstructure:"schema_registry"`
+}
+
+// SerializationOverride applies a different format to matching topics
+type SerializationOverride struct {
+	Topics []string `mapstructure:"topics"`
+	Format string   `mapstructure:"format"`
+}
+
+// SchemaRegistryConfig points at a Confluent-compatible schema registry. With
+// AutoRegister the event schema is registered under <topic>-value; otherwise it
+// must already be registered and is only looked up.
+type SchemaRegistryConfig struct {
+	URL          string `mapstructure:"url"`
+	Username     string `mapstructure:"username"`
+	Password     string `mapstructure:"password"`
+	PasswordFile string `mapstructure:"password_file"`
+	AutoRegister bool   `mapstructure:"auto_register"`
+	TimeoutMs    int    `mapstructure:"timeout_ms"`
+}
+
+// HeadersConfig selects the record headers that carry the event envelope.
+// Mapping is default or cloudevents (ce_* binding names), and Names renames
+// individual fields, or drops them when set to "". TraceContext adds a W3C
+// traceparent. Metadata entries are only sent as headers when their key is in
+// MetadataAllowList, under MetadataPrefix.
+type HeadersConfig struct {
+	Mapping           string            `mapstructure:"mapping"`
+	Names             map[string]string `mapstructure:"names"`
+	TraceContext      bool              `mapstructure:"trace_context"`
+	MetadataAllowList []string          `mapstructure:"metadata_allow_list"`
+	MetadataPrefix    string            `mapstructure:"metadata_prefix"`
+}
+
+// PartitionKeyConfig selects how the message key is derived from an event.
+// Strategy is one of type, subject, tenant_id, correlation_id, source, jsonpath,
+// composite or none. Events whose key resolves to empty are placed by Keyless.
+type PartitionKeyConfig struct {
+	Strategy  string                 `mapstructure:"strategy"`
+	Path      string                 `mapstructure:"path"`
+	Fields    []string               `mapstructure:"fields"`
+	Separator string                 `mapstructure:"separator"`
+	Keyless   string                 `mapstructure:"keyless"`
+	Overrides []PartitionKeyOverride `mapstructure:"overrides"`
+}
+
+// PartitionKeyOverride applies a different key strategy to matching event types
+type PartitionKeyOverride struct {
+	EventTypes []string `mapstructure:"event_types"`
+	Strategy   string   `mapstructure:"strategy"`
+	Path       string   `mapstructure:"path"`
+	Fields     []string `mapstructure:"fields"`
+	Separator  string   `mapstructure:"separator"`
+}
+
+// RoutingConfig maps events to topics. Rules are evaluated in order and the
+// first match wins; unmatched events go to DefaultTopic, or Topic when unset.
+type RoutingConfig struct {
+	DefaultTopic      string        `mapstructure:"default_topic"`
+	Rules             []RoutingRule `mapstructure:"rules"`
+	AutoCreateTopics  bool          `mapstructure:"auto_create_topics"`
+	TopicPartitions   int32         `mapstructure:"topic_partitions"`
+	ReplicationFactor int16         `mapstructure:"replication_factor"`
+}
+
+// RoutingRule matches when every criterion that is set holds for the event.
+// Partitions and ReplicationFactor override the routing defaults when the
+// rule's topic is auto-created.
+type RoutingRule struct {
+	Name              string   `mapstructure:"name"`
+	EventTypes        []string `mapstructure:"event_types"`
+	Tenants           []string `mapstructure:"tenants"`
+	MinPriority       *int     `mapstructure:"min_priority"`
+	MaxPriority       *int     `mapstructure:"max_priority"`
+	Topic             string   `mapstructure:"topic"`
+	Partitions        int32    `mapstructure:"partitions"`
+	ReplicationFactor int16    `mapstructure:"replication_factor"`
+}
+
+// SpoolConfig controls the on-disk write-ahead log that holds events while
+// Kafka is unavailable. FsyncPolicy is one of always, interval or never.
+type SpoolConfig struct {
+	Enabled          bool   `mapstructure:"enabled"`
+	Dir              string `mapstructure:"dir"`
+	MaxBytes         int64  `mapstructure:"max_bytes"`
+	SegmentBytes     int64  `mapstructure:"segment_bytes"`
+	FsyncPolicy      string `mapstructure:"fsync_policy"`
+	FsyncIntervalMs  int    `mapstructure:"fsync_interval_ms"`
+	ReplayIntervalMs int    `mapstructure:"replay_interval_ms"`
+	ReplayBatchSize  int    `mapstructure:"replay_batch_size"`
+}
+
+// KafkaHealthConfig controls the background prober behind the health endpoints.
+// With Canary set, each check also produces and reads back a message on CanaryTopic.
+type KafkaHealthConfig struct {
+	CheckIntervalMs int    `mapstructure:"check_interval_ms"`
+	TimeoutMs       int    `mapstructure:"timeout_ms"`
+	Canary          bool   `mapstructure:"canary"`
+	CanaryTopic     string `mapstructure:"canary_topic"`
+}
+
+// DeadLetterConfig sends events that fail serialization or exhaust their
+// retries to Topic, or to files in FallbackDir when Topic cannot be written
+type DeadLetterConfig struct {
+	Enabled     bool   `mapstructure:"enabled"`
+	Topic       string `mapstructure:"topic"`
+	FallbackDir string `mapstructure:"fallback_dir"`
+}
+
+// CircuitBreakerConfig makes the producer fail fast while Kafka is down. The
+// breaker opens after FailureThreshold consecutive transient failures, rejects
+// sends for OpenTimeoutMs, and then lets up to HalfOpenMaxRequests sends through;
+// SuccessThreshold successes among them close it again, and any failure reopens it.
+type CircuitBreakerConfig struct {
+	Enabled             bool `mapstructure:"enabled"`
+	FailureThreshold    int  `mapstructure:"failure_threshold"`
+	OpenTimeoutMs       int  `mapstructure:"open_timeout_ms"`
+	HalfOpenMaxRequests int  `mapstructure:"half_open_max_requests"`
+	SuccessThreshold    int  `mapstructure:"success_threshold"`
+}
+
+// PriorityLanesConfig queues events by priority band, each lane with its own
+// bounded queue and producer workers. ShedMode is reject (fail shed events so
+// clients retry) or drop (acknowledge and discard them). Built-in critical,
+// normal and bulk lanes are used when Lanes is empty.
+type PriorityLanesConfig struct {
+	Enabled  bool         `mapstructure:"enabled"`
+	ShedMode string       `mapstructure:"shed_mode"`
+	Lanes    []LaneConfig `mapstructure:"lanes"`
+}
+
+// LaneConfig takes events from MinPriority up to the next lane's MinPriority.
+// The lane sheds new events once its queue is full, or once the queues of all
+// lanes together are ShedThreshold (0-1] full. A Topic overrides routing for
+// the lane's events.
+type LaneConfig struct {
+	Name          string  `mapstructure:"name"`
+	MinPriority   int     `mapstructure:"min_priority"`
+	QueueSize     int     `mapstructure:"queue_size"`
+	Workers       int     `mapstructure:"workers"`
+	ShedThreshold float64 `mapstructure:"shed_threshold"`
+	Topic         string  `mapstructure:"topic"`
 }
 
 type MetricsConfig struct {
@@ -59,6 +317,81 @@ type RateLimitConfig struct {
 	BurstSize         int `mapstructure:"burst_size"`
 }
 
+// IngestConfig bounds the event timestamps clients may set. A timestamp may
+// be at most MaxClockSkewMs ahead of the gateway's clock and, when
+// MaxEventAgeMs is set, at most that far behind it.
+type IngestConfig struct {
+	MaxClockSkewMs int `mapstructure:"max_clock_skew_ms"`
+	MaxEventAgeMs  int `mapstructure:"max_event_age_ms"`
+}
+
+// StreamConfig bounds NDJSON stream ingestion. Events are produced in chunks
+// of BatchSize as their lines are read, or once the first event of a chunk has
+// waited FlushIntervalMs, and lines longer than MaxLineBytes fail on their own. MaxBodyBytes limits the request body before
+// decompression (0 for no limit), TimeoutMs replaces the request timeout of
+// the server for streams, and at most MaxErrors failed lines are listed in
+// the summary.
+type StreamConfig struct {
+	BatchSize       int   `mapstructure:"batch_size"`
+	FlushIntervalMs int   `mapstructure:"flush_interval_ms"`
+	MaxLineBytes    int   `mapstructure:"max_line_bytes"`
+	MaxBodyBytes    int64 `mapstructure:"max_body_bytes"`
+	MaxErrors       int   `mapstructure:"max_errors"`
+	TimeoutMs       int   `mapstructure:"timeout_ms"`
+}
+
+// DedupeConfig remembers the outcome of each idempotency key for TTLMs, so
+// that retried requests get the original response instead of producing the
+// event again. Backend is memory (an LRU of at most MaxEntries keys per
+// instance) or redis (shared by every instance). A key stays locked for at
+// most LockTimeoutMs while its first request is in flight.
+type DedupeConfig struct {
+	Enabled       bool        `mapstructure:"enabled"`
+	Backend       string      `mapstructure:"backend"`
+	TTLMs         int         `mapstructure:"ttl_ms"`
+	LockTimeoutMs int         `mapstructure:"lock_timeout_ms"`
+	MaxEntries    int         `mapstructure:"max_entries"`
+	Redis         RedisConfig `mapstructure:"redis"`
+}
+
+type RedisConfig struct {
+	Address   string `mapstructure:"address"`
+	Password  string `mapstructure:"password"`
+	DB        int    `mapstructure:"db"`
+	KeyPrefix string `mapstructure:"key_prefix"`
+	TimeoutMs int    `mapstructure:"timeout_ms"`
+}
+
+// SchemasConfig validates event data against JSON Schemas stored in Directory
+// as <event type>/<schema_version>.json, reloaded every ReloadIntervalMs (0
+// disables reloading). Mode is enforce (reject data that does not match),
+// warn (accept it with a warning) or off, and Overrides set it per event type.
+type SchemasConfig struct {
+	Enabled          bool                 `mapstructure:"enabled"`
+	Directory        string               `mapstructure:"directory"`
+	Mode             string               `mapstructure:"mode"`
+	ReloadIntervalMs int                  `mapstructure:"reload_interval_ms"`
+	Overrides        []SchemaModeOverride `mapstructure:"overrides"`
+}
+
+// SchemaModeOverride sets the schema validation mode of some event types
+type SchemaModeOverride struct {
+	EventTypes []string `mapstructure:"event_types"`
+	Mode       string   `mapstructure:"mode"`
+}
+
+// RegistryConfig enables the embedded schema registry, which keeps versioned
+// schemas in File ("" keeps them in memory). Compatibility (NONE, BACKWARD,
+// FORWARD or FULL) applies to subjects that do not set their own. The
+// registry is read-only over HTTP unless WriteTokens is set, and then only
+// requests bearing one of them may register schemas or change compatibility.
+type RegistryConfig struct {
+	Enabled       bool     `mapstructure:"enabled"`
+	File          string   `mapstructure:"file"`
+	Compatibility string   `mapstructure:"compatibility"`
+	WriteTokens   []string `mapstructure:"write_tokens"`
+}
+
 func Load() (*Config, error) {
 	viper.SetDefault("environment", "development")
 
@@ -66,6 +399,8 @@ func Load() (*Config, error) {
 	viper.SetDefault("server.read_timeout", 30)
 	viper.SetDefault("server.write_timeout", 30)
 	viper.SetDefault("server.idle_timeout", 120)
+	viper.SetDefault("server.body_limit.max_bytes", 10485760)
+	viper.SetDefault("server.body_limit.max_decompressed_bytes", 10485760)
 
 	viper.SetDefault("grpc.enabled", true)
 	viper.SetDefault("grpc.address", ":9090")
@@ -78,12 +413,97 @@ func Load() (*Config, error) {
 	viper.SetDefault("websocket.enabled", false)
 	viper.SetDefault("websocket.path", "/ws")
 	viper.SetDefault("websocket.ping_interval", 30)
+	viper.SetDefault("websocket.max_message_bytes", 1048576)
+	viper.SetDefault("websocket.allowed_origins", []string{})
+	viper.SetDefault("websocket.auth_tokens", []string{})
+	viper.SetDefault("websocket.rate_limit.requests_per_second", 100)
+	viper.SetDefault("websocket.rate_limit.burst_size", 200)
+	viper.SetDefault("websocket.max_in_flight", 1000)
 
+	viper.SetDefault("kafka.mode", "kafka")
+	viper.SetDefault("kafka.memory.partitions", 3)
+	viper.SetDefault("kafka.memory.latency_ms", 0)
+	viper.SetDefault("kafka.memory.error_rate", 0.0)
+	viper.SetDefault("kafka.cluster", "primary")
 	viper.SetDefault("kafka.brokers", []string{"localhost:9092"})
 	viper.SetDefault("kafka.topic", "events")
 	viper.SetDefault("kafka.retries", 3)
 	viper.SetDefault("kafka.batch_size", 100)
 	viper.SetDefault("kafka.required_acks", 1)
+	viper.SetDefault("kafka.producer_mode", "sync")
+	viper.SetDefault("kafka.delivery_guarantee", "at_least_once")
+	viper.SetDefault("kafka.transactional_id", "")
+	viper.SetDefault("kafka.compression", "none")
+	viper.SetDefault("kafka.compression_level", 0)
+	viper.SetDefault("kafka.linger_ms", 500)
+	viper.SetDefault("kafka.max_message_bytes", 1048576)
+	viper.SetDefault("kafka.partitioner", "hash")
+	viper.SetDefault("kafka.client_id", "event-gateway")
+	viper.SetDefault("kafka.tls.enabled", false)
+	viper.SetDefault("kafka.tls.ca_file", "")
+	viper.SetDefault("kafka.tls.cert_file", "")
+	viper.SetDefault("kafka.tls.key_file", "")
+	viper.SetDefault("kafka.tls.server_name", "")
+	viper.SetDefault("kafka.tls.insecure_skip_verify", false)
+	viper.SetDefault("kafka.sasl.enabled", false)
+	viper.SetDefault("kafka.sasl.mechanism", "PLAIN")
+	viper.SetDefault("kafka.sasl.username", "")
+	viper.SetDefault("kafka.sasl.password", "")
+	viper.SetDefault("kafka.sasl.password_file", "")
+	viper.SetDefault("kafka.secondary.enabled", false)
+	viper.SetDefault("kafka.secondary.name", "secondary")
+	viper.SetDefault("kafka.secondary.policy", "failover")
+	viper.SetDefault("kafka.secondary.brokers", []string{})
+	viper.SetDefault("kafka.secondary.tls.enabled", false)
+	viper.SetDefault("kafka.secondary.tls.ca_file", "")
+	viper.SetDefault("kafka.secondary.tls.cert_file", "")
+	viper.SetDefault("kafka.secondary.tls.key_file", "")
+	viper.SetDefault("kafka.secondary.tls.server_name", "")
+	viper.SetDefault("kafka.secondary.tls.insecure_skip_verify", false)
+	viper.SetDefault("kafka.secondary.sasl.enabled", false)
+	viper.SetDefault("kafka.secondary.sasl.mechanism", "PLAIN")
+	viper.SetDefault("kafka.secondary.sasl.username", "")
+	viper.SetDefault("kafka.secondary.sasl.password", "")
+	viper.SetDefault("kafka.secondary.sasl.password_file", "")
+	viper.SetDefault("kafka.serialization.format", "json")
+	viper.SetDefault("kafka.serialization.schema_registry.url", "")
+	viper.SetDefault("kafka.serialization.schema_registry.username", "")
+	viper.SetDefault("kafka.serialization.schema_registry.password", "")
+	viper.SetDefault("kafka.serialization.schema_registry.password_file", "")
+	viper.SetDefault("kafka.serialization.schema_registry.auto_register", true)
+	viper.SetDefault("kafka.serialization.schema_registry.timeout_ms", 5000)
+	viper.SetDefault("kafka.headers.mapping", "default")
+	viper.SetDefault("kafka.headers.trace_context", true)
+	viper.SetDefault("kafka.headers.metadata_prefix", "")
+	viper.SetDefault("kafka.partition_key.strategy", "type")
+	viper.SetDefault("kafka.partition_key.separator", ":")
+	viper.SetDefault("kafka.partition_key.keyless", "random")
+	viper.SetDefault("kafka.routing.default_topic", "")
+	viper.SetDefault("kafka.routing.auto_create_topics", false)
+	viper.SetDefault("kafka.routing.topic_partitions", 6)
+	viper.SetDefault("kafka.routing.replication_factor", 1)
+	viper.SetDefault("kafka.spool.enabled", false)
+	viper.SetDefault("kafka.spool.dir", "./data/spool")
+	viper.SetDefault("kafka.spool.max_bytes", 1073741824)
+	viper.SetDefault("kafka.spool.segment_bytes", 67108864)
+	viper.SetDefault("kafka.spool.fsync_policy", "interval")
+	viper.SetDefault("kafka.spool.fsync_interval_ms", 1000)
+	viper.SetDefault("kafka.spool.replay_interval_ms", 1000)
+	viper.SetDefault("kafka.spool.replay_batch_size", 100)
+	viper.SetDefault("kafka.health.check_interval_ms", 10000)
+	viper.SetDefault("kafka.health.timeout_ms", 5000)
+	viper.SetDefault("kafka.health.canary", false)
+	viper.SetDefault("kafka.health.canary_topic", "event-gateway-health")
+	viper.SetDefault("kafka.dead_letter.enabled", false)
+	viper.SetDefault("kafka.dead_letter.topic", "events.dlq")
+	viper.SetDefault("kafka.dead_letter.fallback_dir", "./data/dlq")
+	viper.SetDefault("kafka.circuit_breaker.enabled", true)
+	viper.SetDefault("kafka.circuit_breaker.failure_threshold", 5)
+	viper.SetDefault("kafka.circuit_breaker.open_timeout_ms", 30000)
+	viper.SetDefault("kafka.circuit_breaker.half_open_max_requests", 1)
+	viper.SetDefault("kafka.circuit_breaker.success_threshold", 1)
+	viper.SetDefault("kafka.priority_lanes.enabled", false)
+	viper.SetDefault("kafka.priority_lanes.shed_mode", "reject")
 
 	viper.SetDefault("metrics.enabled", true)
 	viper.SetDefault("metrics.path", "/metrics")
@@ -91,6 +511,37 @@ func Load() (*Config, error) {
 	viper.SetDefault("rate_limit.requests_per_second", 1000)
 	viper.SetDefault("rate_limit.burst_size", 2000)
 
+	viper.SetDefault("ingest.max_clock_skew_ms", 300000)
+	viper.SetDefault("ingest.max_event_age_ms", 0)
+
+	viper.SetDefault("stream.batch_size", 100)
+	viper.SetDefault("stream.flush_interval_ms", 1000)
+	viper.SetDefault("stream.max_line_bytes", 1048576)
+	viper.SetDefault("stream.max_body_bytes", 1073741824)
+	viper.SetDefault("stream.max_errors", 1000)
+	viper.SetDefault("stream.timeout_ms", 3600000)
+
+	viper.SetDefault("dedupe.enabled", false)
+	viper.SetDefault("dedupe.backend", "memory")
+	viper.SetDefault("dedupe.ttl_ms", 86400000)
+	viper.SetDefault("dedupe.lock_timeout_ms", 30000)
+	viper.SetDefault("dedupe.max_entries", 100000)
+	viper.SetDefault("dedupe.redis.address", "localhost:6379")
+	viper.SetDefault("dedupe.redis.password", "")
+	viper.SetDefault("dedupe.redis.db", 0)
+	viper.SetDefault("dedupe.redis.key_prefix", "event-gateway:idempotency:")
+	viper.SetDefault("dedupe.redis.timeout_ms", 100)
+
+	viper.SetDefault("schemas.enabled", false)
+	viper.SetDefault("schemas.directory", "./schemas")
+	viper.SetDefault("schemas.mode", "enforce")
+	viper.SetDefault("schemas.reload_interval_ms", 5000)
+
+	viper.SetDefault("registry.enabled", false)
+	viper.SetDefault("registry.file", "./data/registry/schemas.json")
+	viper.SetDefault("registry.compatibility", "BACKWARD")
+	viper.SetDefault("registry.write_tokens", []string{})
+
 	viper.SetConfigName("config")
 	viper.SetConfigType("yaml")
 	viper.AddConfigPath(".")
@@ -27,6 +27,8 @@ func TestLoad_Defaults(t *testing.T) {
 	assert.Equal(t, 30, cfg.Server.ReadTimeout)
 	assert.Equal(t, 30, cfg.Server.WriteTimeout)
 	assert.Equal(t, 120, cfg.Server.IdleTimeout)
+	assert.Equal(t, int64(10485760), cfg.Server.BodyLimit.MaxBytes)
+	assert.Equal(t, int64(10485760), cfg.Server.BodyLimit.MaxDecompressedBytes)
 
 	// Check gRPC defaults
 	assert.True(t, cfg.GRPC.Enabled)
@@ -36,12 +38,74 @@ func TestLoad_Defaults(t *testing.T) {
 	// Check WebSocket defaults
 	assert.False(t, cfg.WebSocket.Enabled)
 	assert.Equal(t, "/ws", cfg.WebSocket.Path)
+	assert.Equal(t, 30, cfg.WebSocket.PingInterval)
+	assert.Equal(t, int64(1048576), cfg.WebSocket.MaxMessageBytes)
+	assert.Empty(t, cfg.WebSocket.AllowedOrigins)
+	assert.Empty(t, cfg.WebSocket.AuthTokens)
+	assert.Equal(t, 100, cfg.WebSocket.RateLimit.RequestsPerSecond)
+	assert.Equal(t, 200, cfg.WebSocket.RateLimit.BurstSize)
 
 	// Check Kafka defaults
+	assert.Equal(t, "kafka", cfg.Kafka.Mode)
+	assert.Equal(t, 3, cfg.Kafka.Memory.Partitions)
+	assert.Zero(t, cfg.Kafka.Memory.ErrorRate)
+	assert.Equal(t, "primary", cfg.Kafka.Cluster)
 	assert.Equal(t, []string{"localhost:9092"}, cfg.Kafka.Brokers)
+	assert.False(t, cfg.Kafka.Secondary.Enabled)
+	assert.Equal(t, "secondary", cfg.Kafka.Secondary.Name)
+	assert.Equal(t, "failover", cfg.Kafka.Secondary.Policy)
 	assert.Equal(t, "events", cfg.Kafka.Topic)
 	assert.Equal(t, 3, cfg.Kafka.Retries)
 	assert.Equal(t, 100, cfg.Kafka.BatchSize)
+	assert.Equal(t, "sync", cfg.Kafka.ProducerMode)
+	assert.Equal(t, "at_least_once", cfg.Kafka.DeliveryGuarantee)
+	assert.Equal(t, 1, cfg.Kafka.RequiredAcks)
+	assert.Equal(t, "none", cfg.Kafka.Compression)
+	assert.Equal(t, 500, cfg.Kafka.LingerMs)
+	assert.Equal(t, 1048576, cfg.Kafka.MaxMessageBytes)
+	assert.Equal(t, "hash", cfg.Kafka.Partitioner)
+	assert.Equal(t, "event-gateway", cfg.Kafka.ClientID)
+	assert.False(t, cfg.Kafka.TLS.Enabled)
+	assert.False(t, cfg.Kafka.TLS.InsecureSkipVerify)
+	assert.False(t, cfg.Kafka.SASL.Enabled)
+	assert.Equal(t, "PLAIN", cfg.Kafka.SASL.Mechanism)
+	assert.Equal(t, "json", cfg.Kafka.Serialization.Format)
+	assert.Empty(t, cfg.Kafka.Serialization.Overrides)
+	assert.Empty(t, cfg.Kafka.Serialization.SchemaRegistry.URL)
+	assert.True(t, cfg.Kafka.Serialization.SchemaRegistry.AutoRegister)
+	assert.Equal(t, 5000, cfg.Kafka.Serialization.SchemaRegistry.TimeoutMs)
+	assert.Equal(t, "default", cfg.Kafka.Headers.Mapping)
+	assert.True(t, cfg.Kafka.Headers.TraceContext)
+	assert.Empty(t, cfg.Kafka.Headers.MetadataAllowList)
+	assert.Equal(t, "type", cfg.Kafka.PartitionKey.Strategy)
+	assert.Equal(t, "random", cfg.Kafka.PartitionKey.Keyless)
+	assert.Empty(t, cfg.Kafka.Routing.DefaultTopic)
+	assert.Empty(t, cfg.Kafka.Routing.Rules)
+	assert.False(t, cfg.Kafka.Routing.AutoCreateTopics)
+	assert.Equal(t, int32(6), cfg.Kafka.Routing.TopicPartitions)
+	assert.Equal(t, int16(1), cfg.Kafka.Routing.ReplicationFactor)
+	assert.False(t, cfg.Kafka.Spool.Enabled)
+	assert.Equal(t, "./data/spool", cfg.Kafka.Spool.Dir)
+	assert.Equal(t, int64(1073741824), cfg.Kafka.Spool.MaxBytes)
+	assert.Equal(t, int64(67108864), cfg.Kafka.Spool.SegmentBytes)
+	assert.Equal(t, "interval", cfg.Kafka.Spool.FsyncPolicy)
+	assert.Equal(t, 1000, cfg.Kafka.Spool.ReplayIntervalMs)
+	assert.Equal(t, 100, cfg.Kafka.Spool.ReplayBatchSize)
+	assert.Equal(t, 10000, cfg.Kafka.Health.CheckIntervalMs)
+	assert.Equal(t, 5000, cfg.Kafka.Health.TimeoutMs)
+	assert.False(t, cfg.Kafka.Health.Canary)
+	assert.Equal(t, "event-gateway-health", cfg.Kafka.Health.CanaryTopic)
+	assert.False(t, cfg.Kafka.DeadLetter.Enabled)
+	assert.Equal(t, "events.dlq", cfg.Kafka.DeadLetter.Topic)
+	assert.Equal(t, "./data/dlq", cfg.Kafka.DeadLetter.FallbackDir)
+	assert.True(t, cfg.Kafka.CircuitBreaker.Enabled)
+	assert.Equal(t, 5, cfg.Kafka.CircuitBreaker.FailureThreshold)
+	assert.Equal(t, 30000, cfg.Kafka.CircuitBreaker.OpenTimeoutMs)
+	assert.Equal(t, 1, cfg.Kafka.CircuitBreaker.HalfOpenMaxRequests)
+	assert.Equal(t, 1, cfg.Kafka.CircuitBreaker.SuccessThreshold)
+	assert.False(t, cfg.Kafka.PriorityLanes.Enabled)
+	assert.Equal(t, "reject", cfg.Kafka.PriorityLanes.ShedMode)
+	assert.Empty(t, cfg.Kafka.PriorityLanes.Lanes)
 
 	// Check metrics defaults
 	assert.True(t, cfg.Metrics.Enabled)
@@ -50,6 +114,38 @@ func TestLoad_Defaults(t *testing.T) {
 	// Check rate limit defaults
 	assert.Equal(t, 1000, cfg.RateLimit.RequestsPerSecond)
 	assert.Equal(t, 2000, cfg.RateLimit.BurstSize)
+
+	// Check ingest defaults
+	assert.Equal(t, 300000, cfg.Ingest.MaxClockSkewMs)
+	assert.Equal(t, 0, cfg.Ingest.MaxEventAgeMs)
+
+	// Check dedupe defaults
+	assert.False(t, cfg.Dedupe.Enabled)
+	assert.Equal(t, "memory", cfg.Dedupe.Backend)
+	assert.Equal(t, 86400000, cfg.Dedupe.TTLMs)
+	assert.Equal(t, 30000, cfg.Dedupe.LockTimeoutMs)
+	assert.Equal(t, 100000, cfg.Dedupe.MaxEntries)
+	assert.Equal(t, "localhost:6379", cfg.Dedupe.Redis.Address)
+
+	// Check schema defaults
+	assert.False(t, cfg.Schemas.Enabled)
+	assert.Equal(t, "./schemas", cfg.Schemas.Directory)
+	assert.Equal(t, "enforce", cfg.Schemas.Mode)
+	assert.Equal(t, 5000, cfg.Schemas.ReloadIntervalMs)
+
+	// Check stream defaults
+	assert.Equal(t, 100, cfg.Stream.BatchSize)
+	assert.Equal(t, 1000, cfg.Stream.FlushIntervalMs)
+	assert.Equal(t, 1048576, cfg.Stream.MaxLineBytes)
+	assert.Equal(t, int64(1073741824), cfg.Stream.MaxBodyBytes)
+	assert.Equal(t, 1000, cfg.Stream.MaxErrors)
+	assert.Equal(t, 3600000, cfg.Stream.TimeoutMs)
+
+	// Check registry defaults
+	assert.False(t, cfg.Registry.Enabled)
+	assert.Equal(t, "./data/registry/schemas.json", cfg.Registry.File)
+	assert.Equal(t, "BACKWARD", cfg.Registry.Compatibility)
+	assert.Empty(t, cfg.Registry.WriteTokens)
 }
 
 func TestLoad_EnvironmentVariableOverride(t *testing.T) {
@@ -70,6 +166,21 @@ func TestLoad_EnvironmentVariableOverride(t *testing.T) {
 	assert.Equal(t, ":9000", cfg.Server.Address)
 }
 
+func TestLoad_KafkaSecretsFromEnvironment(t *testing.T) {
+	resetViper()
+
+	t.Setenv("GATEWAY_KAFKA_SASL_USERNAME", "gateway")
+	t.Setenv("GATEWAY_KAFKA_SASL_PASSWORD", "s3cret")
+	t.Setenv("GATEWAY_KAFKA_TLS_CA_FILE", "/etc/kafka/ca.pem")
+
+	cfg, err := Load()
+
+	require.NoError(t, err)
+	assert.Equal(t, "gateway", cfg.Kafka.SASL.Username)
+	assert.Equal(t, "s3cret", cfg.Kafka.SASL.Password)
+	assert.Equal(t, "/etc/kafka/ca.pem", cfg.Kafka.TLS.CAFile)
+}
+
 func TestInitLogger_Development(t *testing.T) {
 	logger, err := InitLogger("development")
 
@@ -0,0 +1,338 @@
+package dedupe
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/distributed-event-processor/services/event-gateway/internal/config"
+	"github.com/distributed-event-processor/services/event-gateway/internal/kafka"
+	"github.com/distributed-event-processor/services/event-gateway/internal/models"
+	"go.uber.org/zap"
+)
+
+// Store backends
+const (
+	BackendMemory = "memory"
+	BackendRedis  = "redis"
+)
+
+// MaxKeyLength is the longest idempotency key the API handlers accept; longer
+// keys are refused rather than stored
+const MaxKeyLength = 255
+
+var (
+	// ErrInProgress is returned for a key whose first request has not finished
+	ErrInProgress = errors.New("a request with this idempotency key is already in progress")
+
+	// ErrKeyReused is returned when a key is sent again with a different event
+	ErrKeyReused = errors.New("idempotency key was already used for a different event")
+)
+
+// Record is what a store keeps for an idempotency key: the fingerprint of the
+// event it was used with and the outcome returned for it. A pending record
+// holds the key while its first request is in flight.
+type Record struct {
+	Fingerprint string    `json:"fingerprint"`
+	Pending     bool      `json:"pending,omitempty"`
+	EventID     string    `json:"event_id,omitempty"`
+	Timestamp   time.Time `json:"timestamp"`
+	Cluster     string    `json:"cluster,omitempty"`
+	Topic       string    `json:"topic,omitempty"`
+	Rule        string    `json:"rule,omitempty"`
+	Partition   int32     `json:"partition"`
+	Offset      int64     `json:"offset"`
+	Queued      bool      `json:"queued,omitempty"`
+}
+
+// Store keeps idempotency records until they expire
+type Store interface {
+	// Claim stores pending under key for lockTTL unless the key is taken, in
+	// which case the record already there is returned and claimed is false
+	Claim(ctx context.Context, key string, pending Record, lockTTL time.Duration) (existing Record, claimed bool, err error)
+
+	// Complete replaces the record of key with the final one for ttl
+	Complete(ctx context.Context, key string, record Record, ttl time.Duration) error
+
+	// Release forgets key so that the request can be tried again
+	Release(ctx context.Context, key string) error
+
+	// Close releases the store's resources
+	Close() error
+}
+
+// NewStore creates the store selected by cfg.Backend
+func NewStore(cfg config.DedupeConfig) (Store, error) {
+	switch cfg.Backend {
+	case BackendMemory, "":
+		if cfg.MaxEntries <= 0 {
+			return nil, errors.New("dedupe max_entries must be positive")
+		}
+		return newMemoryStore(cfg.MaxEntries), nil
+	case BackendRedis:
+		return newRedisStore(cfg.Redis)
+	default:
+		return nil, fmt.Errorf("unknown dedupe backend: %s (expected: %s or %s)", cfg.Backend, BackendMemory, BackendRedis)
+	}
+}
+
+// Fingerprint identifies what an event says, leaving out the ID, timestamp and
+// metadata that the gateway may fill in differently on every attempt
+func Fingerprint(event *models.Event) string {
+	payload, _ := json.Marshal(struct {
+		Type          string                 `json:"type"`
+		Source        string                 `json:"source"`
+		Subject       string                 `json:"subject"`
+		Data          map[string]interface{} `json:"data"`
+		TenantID      string                 `json:"tenant_id"`
+		CorrelationID string                 `json:"correlation_id"`
+		Version       string                 `json:"version"`
+		SchemaVersion string                 `json:"schema_version"`
+		Priority      int                    `json:"priority"`
+	}{
+		event.Type, event.Source, event.Subject, event.Data, event.TenantID,
+		event.CorrelationID, event.Version, event.SchemaVersion, event.Priority,
+	})
+	sum := sha256.Sum256(payload)
+	return hex.EncodeToString(sum[:])
+}
+
+// Publisher is an EventPublisher that produces each idempotency key at most
+// once within the TTL. The key travels in the event's metadata; events without
+// one pass straight through. A retried event gets the result of the original
+// and takes over its ID and timestamp, so the response matches the first one.
+// Only events that reached Kafka or the spool are remembered: after a failure
+// the key is released for the client's retry. The store failing does not stop
+// ingestion; the event is produced without deduplication.
+type Publisher struct {
+	next    kafka.EventPublisher
+	store   Store
+	backend string
+	ttl     time.Duration
+	lockTTL time.Duration
+	logger  *zap.Logger
+}
+
+// New puts deduplication with the store of cfg in front of next
+func New(cfg config.DedupeConfig, next kafka.EventPublisher, logger *zap.Logger) (*Publisher, error) {
+	if cfg.TTLMs <= 0 || cfg.LockTimeoutMs <= 0 {
+		return nil, errors.New("dedupe ttl_ms and lock_timeout_ms must be positive")
+	}
+
+	store, err := NewStore(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	backend := cfg.Backend
+	if backend == "" {
+		backend = BackendMemory
+	}
+	logger.Info("Deduplicating events by idempotency key",
+		zap.String("backend", backend),
+		zap.Int("ttl_ms", cfg.TTLMs))
+
+	return newPublisher(store, backend, time.Duration(cfg.TTLMs)*time.Millisecond,
+		time.Duration(cfg.LockTimeoutMs)*time.Millisecond, next, logger), nil
+}
+
+func newPublisher(store Store, backend string, ttl, lockTTL time.Duration, next kafka.EventPublisher, logger *zap.Logger) *Publisher {
+	return &Publisher{
+		next:    next,
+		store:   store,
+		backend: backend,
+		ttl:     ttl,
+		lockTTL: lockTTL,
+		logger:  logger,
+	}
+}
+
+// Produce publishes an event unless its idempotency key was already seen
+func (p *Publisher) Produce(ctx context.Context, event *models.Event) kafka.DeliveryResult {
+	key := idempotencyKey(event)
+	if key == "" {
+		return p.next.Produce(ctx, event)
+	}
+	if result, done := p.claim(ctx, key, event); done {
+		return result
+	}
+
+	result := p.next.Produce(ctx, event)
+	p.finish(key, event, result)
+	return result
+}
+
+// ProduceEventAsync publishes an event unless its idempotency key was already
+// seen, in which case the original result is already on the returned channel
+func (p *Publisher) ProduceEventAsync(ctx context.Context, event *models.Event) <-chan kafka.DeliveryResult {
+	key := idempotencyKey(event)
+	if key == "" {
+		return p.next.ProduceEventAsync(ctx, event)
+	}
+
+	result := make(chan kafka.DeliveryResult, 1)
+	if original, done := p.claim(ctx, key, event); done {
+		result <- original
+		return result
+	}
+
+	delivery := p.next.ProduceEventAsync(ctx, event)
+	go func() {
+		delivered := <-delivery
+		p.finish(key, event, delivered)
+		result <- delivered
+	}()
+	return result
+}
+
+// ProduceBatch publishes the events whose idempotency keys are new together
+// and answers the rest with their original results
+func (p *Publisher) ProduceBatch(ctx context.Context, events []*models.Event) []kafka.DeliveryResult {
+	results := make([]kafka.DeliveryResult, len(events))
+	send := make([]*models.Event, 0, len(events))
+	indices := make([]int, 0, len(events))
+
+	for i, event := range events {
+		if key := idempotencyKey(event); key != "" {
+			if result, done := p.claim(ctx, key, event); done {
+				results[i] = result
+				continue
+			}
+		}
+		send = append(send, event)
+		indices = append(indices, i)
+	}
+
+	if len(send) == 0 {
+		return results
+	}
+
+	for j, result := range p.next.ProduceBatch(ctx, send) {
+		results[indices[j]] = result
+		if key := idempotencyKey(send[j]); key != "" {
+			p.finish(key, send[j], result)
+		}
+	}
+	return results
+}
+
+// claim reserves key for event. done reports that the event must not be
+// produced, with result holding the original outcome or the reason why.
+func (p *Publisher) claim(ctx context.Context, key string, event *models.Event) (result kafka.DeliveryResult, done bool) {
+	fingerprint := Fingerprint(event)
+
+	existing, claimed, err := p.store.Claim(ctx, key, Record{Fingerprint: fingerprint, Pending: true}, p.lockTTL)
+	if err != nil {
+		storeErrors.Inc()
+		p.logger.Warn("Idempotency store unavailable, producing without deduplication",
+			zap.String("event_id", event.ID),
+			zap.Error(err))
+		return kafka.DeliveryResult{}, false
+	}
+
+	switch {
+	case claimed:
+		dedupeRequests.WithLabelValues(OutcomeNew).Inc()
+		return kafka.DeliveryResult{}, false
+	case existing.Fingerprint != fingerprint:
+		dedupeRequests.WithLabelValues(OutcomeConflict).Inc()
+		return kafka.DeliveryResult{Err: ErrKeyReused}, true
+	case existing.Pending:
+		dedupeRequests.WithLabelValues(OutcomeInProgress).Inc()
+		return kafka.DeliveryResult{Err: ErrInProgress}, true
+	}
+
+	dedupeRequests.WithLabelValues(OutcomeDuplicate).Inc()
+	p.logger.Debug("Duplicate event suppressed",
+		zap.String("event_id", existing.EventID),
+		zap.String("topic", existing.Topic))
+
+	event.ID = existing.EventID
+	event.Timestamp = existing.Timestamp
+	return kafka.DeliveryResult{
+		Cluster:   existing.Cluster,
+		Topic:     existing.Topic,
+		Rule:      existing.Rule,
+		Partition: existing.Partition,
+		Offset:    existing.Offset,
+		Queued:    existing.Queued,
+		Duplicate: true,
+	}, true
+}
+
+// finish records the outcome of a claimed key, or releases the key if the
+// event was not produced. It runs after the request may have been cancelled,
+// so it does not use the request's context.
+func (p *Publisher) finish(key string, event *models.Event, result kafka.DeliveryResult) {
+	ctx := context.Background()
+
+	var err error
+	if result.Err != nil || result.Dropped {
+		err = p.store.Release(ctx, key)
+	} else {
+		err = p.store.Complete(ctx, key, Record{
+			Fingerprint: Fingerprint(event),
+			EventID:     event.ID,
+			Timestamp:   event.Timestamp,
+			Cluster:     result.Cluster,
+			Topic:       result.Topic,
+			Rule:        result.Rule,
+			Partition:   result.Partition,
+			Offset:      result.Offset,
+			Queued:      result.Queued,
+		}, p.ttl)
+	}
+
+	if err != nil {
+		storeErrors.Inc()
+		p.logger.Warn("Failed to update idempotency store",
+			zap.String("event_id", event.ID),
+			zap.Error(err))
+	}
+}
+
+// Transactional reports whether batches are delivered all-or-nothing
+func (p *Publisher) Transactional() bool {
+	return p.next.Transactional()
+}
+
+// IsHealthy reports whether events can currently be published
+func (p *Publisher) IsHealthy() bool {
+	return p.next.IsHealthy()
+}
+
+// Health returns the health of the publisher behind deduplication, with the
+// store backend added to the details
+func (p *Publisher) Health() kafka.HealthStatus {
+	status := p.next.Health()
+
+	details := make(map[string]string, len(status.Details)+1)
+	for key, value := range status.Details {
+		details[key] = value
+	}
+	details["dedupe_backend"] = p.backend
+	status.Details = details
+	return status
+}
+
+// Close closes the publisher behind deduplication and then the store
+func (p *Publisher) Close() error {
+	return errors.Join(p.next.Close(), p.store.Close())
+}
+
+// idempotencyKey returns the store key of event, or "" if the event has no
+// idempotency key. Keys are scoped to the event's tenant and source so that
+// unrelated clients choosing the same key, such as an event ID "1", do not
+// collide. Both are quoted, which keeps the scope unambiguous.
+func idempotencyKey(event *models.Event) string {
+	key := event.Metadata[models.MetadataIdempotencyKey]
+	if key == "" {
+		return ""
+	}
+	return strconv.Quote(event.TenantID) + "|" + strconv.Quote(event.Source) + "|" + key
+}
@@ -0,0 +1,211 @@
+package dedupe
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/distributed-event-processor/services/event-gateway/internal/config"
+	"github.com/distributed-event-processor/services/event-gateway/internal/kafka"
+	"github.com/distributed-event-processor/services/event-gateway/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func newTestPublisher(t *testing.T) (*Publisher, *kafka.MemoryBroker) {
+	broker, err := kafka.NewMemoryBroker(config.KafkaConfig{Topic: "events"}, zap.NewNop())
+	require.NoError(t, err)
+	publisher := newPublisher(newMemoryStore(100), BackendMemory, time.Hour, time.Minute, broker, zap.NewNop())
+	t.Cleanup(func() { publisher.Close() })
+	return publisher, broker
+}
+
+func keyedEvent(id, key string) *models.Event {
+	event := &models.Event{
+		ID:        id,
+		Type:      "user.created",
+		Source:    "test-service",
+		Data:      map[string]interface{}{"user_id": "123"},
+		Timestamp: time.Now(),
+		Metadata:  map[string]string{},
+	}
+	if key != "" {
+		event.Metadata[models.MetadataIdempotencyKey] = key
+	}
+	return event
+}
+
+// failingStore is a Store whose backend is unreachable
+type failingStore struct{}
+
+func (failingStore) Claim(context.Context, string, Record, time.Duration) (Record, bool, error) {
+	return Record{}, false, errors.New("connection refused")
+}
+func (failingStore) Complete(context.Context, string, Record, time.Duration) error {
+	return errors.New("connection refused")
+}
+func (failingStore) Release(context.Context, string) error { return errors.New("connection refused") }
+func (failingStore) Close() error                          { return nil }
+
+func TestNew_InvalidConfig(t *testing.T) {
+	broker, err := kafka.NewMemoryBroker(config.KafkaConfig{Topic: "events"}, zap.NewNop())
+	require.NoError(t, err)
+
+	tests := []struct {
+		name string
+		cfg  config.DedupeConfig
+	}{
+		{"no ttl", config.DedupeConfig{LockTimeoutMs: 1000, MaxEntries: 10}},
+		{"no lock timeout", config.DedupeConfig{TTLMs: 1000, MaxEntries: 10}},
+		{"no entries", config.DedupeConfig{TTLMs: 1000, LockTimeoutMs: 1000}},
+		{"unknown backend", config.DedupeConfig{Backend: "etcd", TTLMs: 1000, LockTimeoutMs: 1000, MaxEntries: 10}},
+		{"redis without address", config.DedupeConfig{Backend: BackendRedis, TTLMs: 1000, LockTimeoutMs: 1000}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := New(tt.cfg, broker, zap.NewNop())
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestPublisher_ReturnsOriginalResultForDuplicate(t *testing.T) {
+	publisher, broker := newTestPublisher(t)
+
+	original := keyedEvent("event-1", "key-1")
+	first := publisher.Produce(context.Background(), original)
+	require.NoError(t, first.Err)
+	assert.False(t, first.Duplicate)
+
+	retry := keyedEvent("event-2", "key-1")
+	second := publisher.Produce(context.Background(), retry)
+	require.NoError(t, second.Err)
+	assert.True(t, second.Duplicate)
+	assert.Equal(t, first.Topic, second.Topic)
+	assert.Equal(t, first.Partition, second.Partition)
+	assert.Equal(t, first.Offset, second.Offset)
+	assert.Equal(t, first.Cluster, second.Cluster)
+	assert.Equal(t, "event-1", retry.ID)
+	assert.True(t, original.Timestamp.Equal(retry.Timestamp))
+
+	assert.Len(t, broker.Messages(), 1)
+}
+
+func TestPublisher_EventsWithoutKeyPassThrough(t *testing.T) {
+	publisher, broker := newTestPublisher(t)
+
+	require.NoError(t, publisher.Produce(context.Background(), keyedEvent("event-1", "")).Err)
+	require.NoError(t, publisher.Produce(context.Background(), keyedEvent("event-1", "")).Err)
+
+	assert.Len(t, broker.Messages(), 2)
+}
+
+func TestPublisher_KeyReusedForDifferentEvent(t *testing.T) {
+	publisher, _ := newTestPublisher(t)
+
+	require.NoError(t, publisher.Produce(context.Background(), keyedEvent("event-1", "key-1")).Err)
+
+	other := keyedEvent("event-2", "key-1")
+	other.Data = map[string]interface{}{"user_id": "456"}
+	assert.ErrorIs(t, publisher.Produce(context.Background(), other).Err, ErrKeyReused)
+}
+
+func TestPublisher_KeysAreScopedToTenantAndSource(t *testing.T) {
+	publisher, broker := newTestPublisher(t)
+
+	require.NoError(t, publisher.Produce(context.Background(), keyedEvent("event-1", "1")).Err)
+
+	otherSource := keyedEvent("event-2", "1")
+	otherSource.Source = "billing-service"
+	result := publisher.Produce(context.Background(), otherSource)
+	require.NoError(t, result.Err)
+	assert.False(t, result.Duplicate)
+
+	otherTenant := keyedEvent("event-3", "1")
+	otherTenant.TenantID = "tenant-b"
+	result = publisher.Produce(context.Background(), otherTenant)
+	require.NoError(t, result.Err)
+	assert.False(t, result.Duplicate)
+
+	assert.Len(t, broker.Messages(), 3)
+}
+
+func TestPublisher_KeyInProgress(t *testing.T) {
+	publisher, broker := newTestPublisher(t)
+	broker.SetLatency(time.Hour)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	first := publisher.ProduceEventAsync(ctx, keyedEvent("event-1", "key-1"))
+
+	second := publisher.Produce(context.Background(), keyedEvent("event-2", "key-1"))
+	assert.ErrorIs(t, second.Err, ErrInProgress)
+
+	// The first attempt failing frees the key for the retry
+	cancel()
+	require.ErrorIs(t, (<-first).Err, context.Canceled)
+
+	broker.SetLatency(0)
+	retry := publisher.Produce(context.Background(), keyedEvent("event-2", "key-1"))
+	require.NoError(t, retry.Err)
+	assert.False(t, retry.Duplicate)
+}
+
+func TestPublisher_FailedEventIsNotRemembered(t *testing.T) {
+	publisher, broker := newTestPublisher(t)
+	broker.FailNext(errors.New("leader not available"))
+
+	require.Error(t, publisher.Produce(context.Background(), keyedEvent("event-1", "key-1")).Err)
+
+	retry := publisher.Produce(context.Background(), keyedEvent("event-1", "key-1"))
+	require.NoError(t, retry.Err)
+	assert.False(t, retry.Duplicate)
+	assert.Len(t, broker.Messages(), 1)
+}
+
+func TestPublisher_Batch(t *testing.T) {
+	publisher, broker := newTestPublisher(t)
+
+	first := publisher.Produce(context.Background(), keyedEvent("event-1", "key-1"))
+	require.NoError(t, first.Err)
+
+	results := publisher.ProduceBatch(context.Background(), []*models.Event{
+		keyedEvent("event-2", "key-2"),
+		keyedEvent("event-3", "key-1"),
+		keyedEvent("event-4", ""),
+	})
+	require.Len(t, results, 3)
+	for _, result := range results {
+		require.NoError(t, result.Err)
+	}
+	assert.False(t, results[0].Duplicate)
+	assert.True(t, results[1].Duplicate)
+	assert.Equal(t, first.Offset, results[1].Offset)
+	assert.False(t, results[2].Duplicate)
+
+	assert.Len(t, broker.Messages(), 3)
+}
+
+func TestPublisher_StoreFailureFailsOpen(t *testing.T) {
+	broker, err := kafka.NewMemoryBroker(config.KafkaConfig{Topic: "events"}, zap.NewNop())
+	require.NoError(t, err)
+	publisher := newPublisher(failingStore{}, BackendRedis, time.Hour, time.Minute, broker, zap.NewNop())
+
+	require.NoError(t, publisher.Produce(context.Background(), keyedEvent("event-1", "key-1")).Err)
+	require.NoError(t, publisher.Produce(context.Background(), keyedEvent("event-1", "key-1")).Err)
+
+	assert.Len(t, broker.Messages(), 2)
+	assert.Equal(t, BackendRedis, publisher.Health().Details["dedupe_backend"])
+}
+
+func TestFingerprint(t *testing.T) {
+	event := keyedEvent("event-1", "key-1")
+	retry := keyedEvent("event-2", "key-2")
+	retry.Timestamp = event.Timestamp.Add(time.Minute)
+	assert.Equal(t, Fingerprint(event), Fingerprint(retry), "ID, timestamp and metadata are ignored")
+
+	retry.Subject = "user/123"
+	assert.NotEqual(t, Fingerprint(event), Fingerprint(retry))
+}
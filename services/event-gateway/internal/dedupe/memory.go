@@ -0,0 +1,102 @@
+package dedupe
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+)
+
+// memoryStore is an LRU of idempotency records local to this instance. When
+// full, the least recently used key is forgotten, even before it expires.
+type memoryStore struct {
+	maxEntries int
+	now        func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	order   *list.List // most recently used at the front
+}
+
+type memoryEntry struct {
+	key     string
+	record  Record
+	expires time.Time
+}
+
+func newMemoryStore(maxEntries int) *memoryStore {
+	return &memoryStore{
+		maxEntries: maxEntries,
+		now:        time.Now,
+		entries:    make(map[string]*list.Element),
+		order:      list.New(),
+	}
+}
+
+func (s *memoryStore) Claim(_ context.Context, key string, pending Record, lockTTL time.Duration) (Record, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if element, ok := s.entries[key]; ok {
+		entry := element.Value.(*memoryEntry)
+		if s.now().Before(entry.expires) {
+			s.order.MoveToFront(element)
+			return entry.record, false, nil
+		}
+		s.remove(element)
+	}
+
+	s.put(key, pending, lockTTL)
+	return Record{}, true, nil
+}
+
+func (s *memoryStore) Complete(_ context.Context, key string, record Record, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if element, ok := s.entries[key]; ok {
+		s.remove(element)
+	}
+	s.put(key, record, ttl)
+	return nil
+}
+
+func (s *memoryStore) Release(_ context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if element, ok := s.entries[key]; ok {
+		s.remove(element)
+	}
+	return nil
+}
+
+func (s *memoryStore) Close() error {
+	return nil
+}
+
+// Len returns the number of keys held, including expired ones not yet evicted
+func (s *memoryStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.order.Len()
+}
+
+// put adds a record and evicts the least recently used keys beyond
+// maxEntries. Callers hold mu.
+func (s *memoryStore) put(key string, record Record, ttl time.Duration) {
+	s.entries[key] = s.order.PushFront(&memoryEntry{
+		key:     key,
+		record:  record,
+		expires: s.now().Add(ttl),
+	})
+	for s.order.Len() > s.maxEntries {
+		s.remove(s.order.Back())
+	}
+}
+
+// remove drops an entry. Callers hold mu.
+func (s *memoryStore) remove(element *list.Element) {
+	s.order.Remove(element)
+	delete(s.entries, element.Value.(*memoryEntry).key)
+}
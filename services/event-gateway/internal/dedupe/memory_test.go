@@ -0,0 +1,81 @@
+package dedupe
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryStore_ClaimAndComplete(t *testing.T) {
+	store := newMemoryStore(10)
+	ctx := context.Background()
+
+	_, claimed, err := store.Claim(ctx, "key", Record{Fingerprint: "a", Pending: true}, time.Minute)
+	require.NoError(t, err)
+	assert.True(t, claimed)
+
+	existing, claimed, err := store.Claim(ctx, "key", Record{Fingerprint: "a", Pending: true}, time.Minute)
+	require.NoError(t, err)
+	assert.False(t, claimed)
+	assert.True(t, existing.Pending)
+
+	require.NoError(t, store.Complete(ctx, "key", Record{Fingerprint: "a", EventID: "event-1"}, time.Minute))
+
+	existing, claimed, err = store.Claim(ctx, "key", Record{Fingerprint: "a", Pending: true}, time.Minute)
+	require.NoError(t, err)
+	assert.False(t, claimed)
+	assert.False(t, existing.Pending)
+	assert.Equal(t, "event-1", existing.EventID)
+}
+
+func TestMemoryStore_Expiry(t *testing.T) {
+	store := newMemoryStore(10)
+	now := time.Now()
+	store.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	_, claimed, _ := store.Claim(ctx, "key", Record{Pending: true}, time.Second)
+	require.True(t, claimed)
+
+	// An abandoned claim stops holding the key once its lock times out
+	now = now.Add(2 * time.Second)
+	_, claimed, _ = store.Claim(ctx, "key", Record{Pending: true}, time.Second)
+	assert.True(t, claimed)
+}
+
+func TestMemoryStore_Release(t *testing.T) {
+	store := newMemoryStore(10)
+	ctx := context.Background()
+
+	store.Claim(ctx, "key", Record{Pending: true}, time.Minute)
+	require.NoError(t, store.Release(ctx, "key"))
+
+	_, claimed, _ := store.Claim(ctx, "key", Record{Pending: true}, time.Minute)
+	assert.True(t, claimed)
+	assert.Equal(t, 1, store.Len())
+}
+
+func TestMemoryStore_EvictsLeastRecentlyUsed(t *testing.T) {
+	store := newMemoryStore(3)
+	ctx := context.Background()
+
+	for i := range 3 {
+		store.Complete(ctx, fmt.Sprintf("key-%d", i), Record{Fingerprint: "a"}, time.Minute)
+	}
+
+	// Looking up key-0 makes key-1 the least recently used
+	_, claimed, _ := store.Claim(ctx, "key-0", Record{Pending: true}, time.Minute)
+	require.False(t, claimed)
+
+	store.Complete(ctx, "key-3", Record{Fingerprint: "a"}, time.Minute)
+	assert.Equal(t, 3, store.Len())
+
+	_, claimed, _ = store.Claim(ctx, "key-1", Record{Pending: true}, time.Minute)
+	assert.True(t, claimed, "key-1 was evicted")
+	_, claimed, _ = store.Claim(ctx, "key-0", Record{Pending: true}, time.Minute)
+	assert.False(t, claimed)
+}
@@ -0,0 +1,32 @@
+package dedupe
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Outcomes of looking up an idempotency key
+const (
+	OutcomeNew        = "new"
+	OutcomeDuplicate  = "duplicate"
+	OutcomeInProgress = "in_progress"
+	OutcomeConflict   = "conflict"
+)
+
+// Prometheus metrics
+var (
+	dedupeRequests = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "dedupe_requests_total",
+			Help: "Total number of events with an idempotency key, by outcome (new, duplicate, in_progress or conflict)",
+		},
+		[]string{"outcome"},
+	)
+
+	storeErrors = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "dedupe_store_errors_total",
+			Help: "Total number of idempotency store operations that failed",
+		},
+	)
+)
@@ -0,0 +1,96 @@
+package dedupe
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/distributed-event-processor/services/event-gateway/internal/config"
+	"github.com/redis/go-redis/v9"
+)
+
+// redisStore keeps idempotency records in Redis, shared by every gateway
+// instance. Records expire through Redis key TTLs.
+type redisStore struct {
+	client *redis.Client
+	prefix string
+}
+
+func newRedisStore(cfg config.RedisConfig) (*redisStore, error) {
+	if cfg.Address == "" {
+		return nil, errors.New("dedupe redis address is required")
+	}
+	if cfg.TimeoutMs <= 0 {
+		return nil, errors.New("dedupe redis timeout_ms must be positive")
+	}
+
+	timeout := time.Duration(cfg.TimeoutMs) * time.Millisecond
+	client := redis.NewClient(&redis.Options{
+		Addr:         cfg.Address,
+		Password:     cfg.Password,
+		DB:           cfg.DB,
+		DialTimeout:  timeout,
+		ReadTimeout:  timeout,
+		WriteTimeout: timeout,
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("failed to connect to Redis at %s: %w", cfg.Address, err)
+	}
+
+	return &redisStore{client: client, prefix: cfg.KeyPrefix}, nil
+}
+
+func (s *redisStore) Claim(ctx context.Context, key string, pending Record, lockTTL time.Duration) (Record, bool, error) {
+	value, err := json.Marshal(pending)
+	if err != nil {
+		return Record{}, false, err
+	}
+
+	// A key that expires between SETNX and GET is claimed on the second pass
+	for range 2 {
+		claimed, err := s.client.SetNX(ctx, s.prefix+key, value, lockTTL).Result()
+		if err != nil {
+			return Record{}, false, err
+		}
+		if claimed {
+			return Record{}, true, nil
+		}
+
+		stored, err := s.client.Get(ctx, s.prefix+key).Bytes()
+		if errors.Is(err, redis.Nil) {
+			continue
+		}
+		if err != nil {
+			return Record{}, false, err
+		}
+
+		var existing Record
+		if err := json.Unmarshal(stored, &existing); err != nil {
+			return Record{}, false, fmt.Errorf("corrupt idempotency record %s: %w", key, err)
+		}
+		return existing, false, nil
+	}
+	return Record{}, false, fmt.Errorf("idempotency key %s keeps expiring", key)
+}
+
+func (s *redisStore) Complete(ctx context.Context, key string, record Record, ttl time.Duration) error {
+	value, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	return s.client.Set(ctx, s.prefix+key, value, ttl).Err()
+}
+
+func (s *redisStore) Release(ctx context.Context, key string) error {
+	return s.client.Del(ctx, s.prefix+key).Err()
+}
+
+func (s *redisStore) Close() error {
+	return s.client.Close()
+}
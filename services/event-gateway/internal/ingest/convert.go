@@ -0,0 +1,122 @@
+package ingest
+
+import (
+	"time"
+
+	"github.com/distributed-event-processor/services/event-gateway/internal/config"
+	"github.com/distributed-event-processor/services/event-gateway/internal/models"
+	"github.com/distributed-event-processor/services/event-gateway/internal/schema"
+	"github.com/distributed-event-processor/services/event-gateway/internal/validation"
+	pb "github.com/distributed-event-processor/shared/proto/events/v1"
+)
+
+// Converter turns the event payloads of every transport into events the same
+// way. Each event is validated as the client sent it, and the ID and
+// timestamp it leaves out are filled in.
+type Converter struct {
+	validator *validation.Validator
+}
+
+// NewConverter creates a converter with the limits of cfg, checking event
+// data against schemas unless schemas is nil
+func NewConverter(cfg config.IngestConfig, schemas *schema.Validator) *Converter {
+	return &Converter{validator: validation.New(cfg, schemas)}
+}
+
+// FromRequest validates and converts an HTTP event request. The event is nil
+// unless the result is valid.
+func (c *Converter) FromRequest(req *models.EventRequest) (*models.Event, validation.Result) {
+	return c.convert(req.AsEvent(), false)
+}
+
+// ValidateRequest is FromRequest for dry runs. With strictSchema set, data
+// that does not match its schema is an error even where it would only be
+// warned about or not checked at ingest.
+func (c *Converter) ValidateRequest(req *models.EventRequest, strictSchema bool) (*models.Event, validation.Result) {
+	return c.convert(req.AsEvent(), strictSchema)
+}
+
+// FromProto validates and converts a gRPC event. The event is nil unless the
+// result is valid.
+func (c *Converter) FromProto(event *pb.Event) (*models.Event, validation.Result) {
+	return c.ValidateProto(event, false)
+}
+
+// ValidateProto is FromProto for dry runs, with strictSchema as in
+// ValidateRequest
+func (c *Converter) ValidateProto(event *pb.Event, strictSchema bool) (*models.Event, validation.Result) {
+	if event == nil {
+		return c.convert(nil, strictSchema)
+	}
+	return c.convert(protoEvent(event), strictSchema)
+}
+
+func (c *Converter) convert(event *models.Event, strictSchema bool) (*models.Event, validation.Result) {
+	validate := c.validator.Validate
+	if strictSchema {
+		validate = c.validator.ValidateStrict
+	}
+	result := validate(event)
+	if !result.Valid() {
+		return nil, result
+	}
+	event.SetDefaults()
+	return event, result
+}
+
+// ProtoToRequest maps a gRPC event onto an HTTP event request, for events
+// sent to the HTTP API as protobuf. A nil event is an empty request.
+func ProtoToRequest(event *pb.Event) *models.EventRequest {
+	if event == nil {
+		return &models.EventRequest{}
+	}
+
+	model := protoEvent(event)
+	req := &models.EventRequest{
+		ID:            model.ID,
+		Type:          model.Type,
+		Source:        model.Source,
+		Subject:       model.Subject,
+		TenantID:      model.TenantID,
+		Data:          model.Data,
+		Version:       model.Version,
+		SchemaVersion: model.SchemaVersion,
+		Metadata:      model.Metadata,
+		CorrelationID: model.CorrelationID,
+		Priority:      model.Priority,
+	}
+	if !model.Timestamp.IsZero() {
+		req.Timestamp = &model.Timestamp
+	}
+	return req
+}
+
+// protoEvent maps a gRPC event onto the internal model, leaving the
+// timestamp zero and the data nil when they are unset
+func protoEvent(event *pb.Event) *models.Event {
+	var timestamp time.Time
+	if event.Timestamp != nil {
+		timestamp = event.Timestamp.AsTime()
+	}
+
+	// Convert protobuf Struct to map
+	var data map[string]interface{}
+	if event.Data != nil {
+		data = event.Data.AsMap()
+	}
+
+	return &models.Event{
+		ID:            event.Id,
+		Type:          event.Type,
+		Source:        event.Source,
+		Subject:       event.Subject,
+		TenantID:      event.TenantId,
+		Data:          data,
+		Timestamp:     timestamp,
+		Version:       event.Version,
+		SchemaVersion: event.SchemaVersion,
+		Metadata:      event.Metadata,
+		CorrelationID: event.CorrelationId,
+		Priority:      int(event.Priority),
+	}
+}
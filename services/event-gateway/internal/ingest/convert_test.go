@@ -0,0 +1,116 @@
+package ingest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/distributed-event-processor/services/event-gateway/internal/config"
+	"github.com/distributed-event-processor/services/event-gateway/internal/models"
+	pb "github.com/distributed-event-processor/shared/proto/events/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func testRequest() *models.EventRequest {
+	return &models.EventRequest{
+		Type:   "user.created",
+		Source: "user-service",
+		Data:   map[string]interface{}{"user_id": "123"},
+	}
+}
+
+func TestConverter_FromRequest_GeneratesIDAndTimestamp(t *testing.T) {
+	event, result := NewConverter(config.IngestConfig{}, nil).FromRequest(testRequest())
+	require.True(t, result.Valid())
+
+	assert.NotEmpty(t, event.ID)
+	assert.WithinDuration(t, time.Now(), event.Timestamp, time.Second)
+}
+
+func TestConverter_FromRequest_ClientFields(t *testing.T) {
+	now := time.Now()
+	eventTime := now.Add(-time.Hour)
+	req := testRequest()
+	req.ID = "01HZX3V7K2M9Q4R8T6W0Y5B1C3"
+	req.Timestamp = &eventTime
+	req.TenantID = "tenant-1"
+	req.CorrelationID = "corr-1"
+	req.Priority = 9
+
+	event, result := NewConverter(config.IngestConfig{}, nil).FromRequest(req)
+	require.True(t, result.Valid())
+
+	assert.Equal(t, req.ID, event.ID)
+	assert.True(t, event.Timestamp.Equal(eventTime))
+	assert.Equal(t, "tenant-1", event.TenantID)
+	assert.Equal(t, "corr-1", event.CorrelationID)
+	assert.Equal(t, 9, event.Priority)
+}
+
+func TestConverter_FromProto(t *testing.T) {
+	converter := NewConverter(config.IngestConfig{}, nil)
+	data, err := structpb.NewStruct(map[string]interface{}{"key": "value"})
+	require.NoError(t, err)
+
+	event, result := converter.FromProto(&pb.Event{Type: "test.event", Source: "test-service", Data: data})
+	require.True(t, result.Valid())
+	assert.NotEmpty(t, event.ID)
+	assert.False(t, event.Timestamp.IsZero())
+
+	event, result = converter.FromProto(&pb.Event{
+		Type:      "test.event",
+		Source:    "test-service",
+		Data:      data,
+		Timestamp: timestamppb.New(time.Now().Add(time.Hour)),
+	})
+	assert.Nil(t, event)
+	assert.False(t, result.Valid())
+
+	_, result = converter.FromProto(nil)
+	require.Len(t, result.Errors, 1)
+	assert.Equal(t, "event", result.Errors[0].Field)
+}
+
+func TestConverter_SameRulesForEveryTransport(t *testing.T) {
+	converter := NewConverter(config.IngestConfig{}, nil)
+
+	_, fromRequest := converter.FromRequest(&models.EventRequest{ID: "bad id", Priority: 11})
+	_, fromProto := converter.FromProto(&pb.Event{Id: "bad id", Priority: 11})
+
+	assert.Len(t, fromRequest.Errors, 5)
+	assert.Equal(t, fromRequest, fromProto)
+}
+
+func TestProtoToRequest(t *testing.T) {
+	data, _ := structpb.NewStruct(map[string]interface{}{"key": "value"})
+	timestamp := timestamppb.New(time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC))
+	event := &pb.Event{
+		Id:            "event-123",
+		Type:          "user.created",
+		Source:        "user-service",
+		TenantId:      "tenant-456",
+		Data:          data,
+		Timestamp:     timestamp,
+		SchemaVersion: "2",
+		Priority:      3,
+	}
+
+	req := ProtoToRequest(event)
+
+	assert.Equal(t, "event-123", req.ID)
+	assert.Equal(t, "user.created", req.Type)
+	assert.Equal(t, "tenant-456", req.TenantID)
+	assert.Equal(t, "value", req.Data["key"])
+	require.NotNil(t, req.Timestamp)
+	assert.Equal(t, timestamp.AsTime(), *req.Timestamp)
+	assert.Equal(t, "2", req.SchemaVersion)
+	assert.Equal(t, 3, req.Priority)
+
+	// Unset fields stay unset, so validation sees what the client sent
+	req = ProtoToRequest(&pb.Event{Type: "user.created"})
+	assert.Nil(t, req.Timestamp)
+	assert.Nil(t, req.Data)
+	assert.NotNil(t, ProtoToRequest(nil))
+}
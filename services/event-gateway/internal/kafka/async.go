@@ -0,0 +1,59 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+
+	"github.com/IBM/sarama"
+)
+
+var errProducerClosed = errors.New("kafka producer is closed")
+
+// enqueue hands a message to the async producer without waiting for the broker.
+// The outcome is delivered on result by the delivery loop.
+func (p *Producer) enqueue(ctx context.Context, message *sarama.ProducerMessage, result chan DeliveryResult) {
+	delivery, ok := message.Metadata.(*deliveryContext)
+	if !ok {
+		delivery = &deliveryContext{}
+		message.Metadata = delivery
+	}
+	delivery.result = result
+
+	p.closeMu.RLock()
+	defer p.closeMu.RUnlock()
+
+	if p.closed {
+		result <- DeliveryResult{Topic: message.Topic, Rule: delivery.rule, Err: errProducerClosed}
+		return
+	}
+
+	select {
+	case p.async.Input() <- message:
+	case <-ctx.Done():
+		result <- DeliveryResult{Topic: message.Topic, Rule: delivery.rule, Err: ctx.Err()}
+	}
+}
+
+// startDeliveryLoop drains the async producer's Successes and Errors channels
+// and completes the pending delivery attached to each message
+func (p *Producer) startDeliveryLoop() {
+	p.wg.Add(2)
+
+	go func() {
+		defer p.wg.Done()
+		for message := range p.async.Successes() {
+			if delivery, ok := message.Metadata.(*deliveryContext); ok && delivery.result != nil {
+				delivery.result <- p.completeDelivery(message, nil)
+			}
+		}
+	}()
+
+	go func() {
+		defer p.wg.Done()
+		for producerErr := range p.async.Errors() {
+			if delivery, ok := producerErr.Msg.Metadata.(*deliveryContext); ok && delivery.result != nil {
+				delivery.result <- p.completeDelivery(producerErr.Msg, producerErr.Err)
+			}
+		}
+	}()
+}
@@ -0,0 +1,176 @@
+package kafka
+
+import (
+	"context"
+	"testing"
+
+	"github.com/IBM/sarama"
+	"github.com/IBM/sarama/mocks"
+	"github.com/distributed-event-processor/services/event-gateway/internal/config"
+	"github.com/distributed-event-processor/services/event-gateway/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func createTestAsyncProducer(t *testing.T, mockProducer *mocks.AsyncProducer) *Producer {
+	logger, _ := zap.NewDevelopment()
+	cfg := config.KafkaConfig{
+		Brokers:      []string{"localhost:9092"},
+		Topic:        "test-events",
+		Retries:      3,
+		BatchSize:    100,
+		ProducerMode: ModeAsync,
+	}
+
+	producer := &Producer{
+		async:  mockProducer,
+		config: cfg,
+		logger: logger,
+	}
+	producer.startDeliveryLoop()
+	return producer
+}
+
+func newMockAsyncProducer(t *testing.T) *mocks.AsyncProducer {
+	saramaConfig := mocks.NewTestConfig()
+	saramaConfig.Producer.Return.Successes = true
+	saramaConfig.Producer.Return.Errors = true
+	return mocks.NewAsyncProducer(t, saramaConfig)
+}
+
+func TestAsyncProduceEvent_Success(t *testing.T) {
+	mockProducer := newMockAsyncProducer(t)
+	mockProducer.ExpectInputAndSucceed()
+
+	producer := createTestAsyncProducer(t, mockProducer)
+	defer producer.Close()
+
+	partition, offset, err := producer.ProduceEvent(context.Background(), createTestEvent())
+
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, partition, int32(0))
+	assert.Equal(t, int64(1), offset)
+}
+
+func TestAsyncProduceEvent_Failure(t *testing.T) {
+	mockProducer := newMockAsyncProducer(t)
+	mockProducer.ExpectInputAndFail(sarama.ErrNotLeaderForPartition)
+
+	producer := createTestAsyncProducer(t, mockProducer)
+	defer producer.Close()
+
+	_, _, err := producer.ProduceEvent(context.Background(), createTestEvent())
+
+	require.Error(t, err)
+	assert.ErrorIs(t, err, sarama.ErrNotLeaderForPartition)
+	assert.Contains(t, err.Error(), "failed to send event to Kafka")
+}
+
+func TestAsyncProduceEventAsync_PipelinesDeliveries(t *testing.T) {
+	mockProducer := newMockAsyncProducer(t)
+	mockProducer.ExpectInputAndSucceed()
+	mockProducer.ExpectInputAndFail(sarama.ErrBrokerNotAvailable)
+	mockProducer.ExpectInputAndSucceed()
+
+	producer := createTestAsyncProducer(t, mockProducer)
+	defer producer.Close()
+
+	// Submit everything before waiting on any result
+	deliveries := make([]<-chan DeliveryResult, 3)
+	for i := range deliveries {
+		event := createTestEvent()
+		event.ID = event.ID + "-" + string(rune('a'+i))
+		deliveries[i] = producer.ProduceEventAsync(context.Background(), event)
+	}
+
+	first := <-deliveries[0]
+	second := <-deliveries[1]
+	third := <-deliveries[2]
+
+	require.NoError(t, first.Err)
+	assert.Equal(t, "test-events", first.Topic)
+	assert.Equal(t, int64(1), first.Offset)
+
+	require.Error(t, second.Err)
+	assert.ErrorIs(t, second.Err, sarama.ErrBrokerNotAvailable)
+
+	require.NoError(t, third.Err)
+	assert.Equal(t, int64(2), third.Offset)
+}
+
+func TestAsyncSendBatchEvents_PartialFailure(t *testing.T) {
+	mockProducer := newMockAsyncProducer(t)
+	mockProducer.ExpectInputAndSucceed()
+	mockProducer.ExpectInputAndFail(sarama.ErrNotLeaderForPartition)
+	mockProducer.ExpectInputAndSucceed()
+
+	producer := createTestAsyncProducer(t, mockProducer)
+	defer producer.Close()
+
+	err := producer.SendBatchEvents([]*models.Event{
+		createTestEvent(),
+		createTestEvent(),
+		createTestEvent(),
+	})
+
+	require.Error(t, err)
+	assert.ErrorIs(t, err, sarama.ErrNotLeaderForPartition)
+}
+
+func TestAsyncProduceEvent_AfterClose(t *testing.T) {
+	mockProducer := newMockAsyncProducer(t)
+	producer := createTestAsyncProducer(t, mockProducer)
+
+	require.NoError(t, producer.Close())
+
+	_, _, err := producer.ProduceEvent(context.Background(), createTestEvent())
+
+	require.Error(t, err)
+	assert.ErrorIs(t, err, errProducerClosed)
+}
+
+func TestAsyncIsHealthy(t *testing.T) {
+	mockProducer := newMockAsyncProducer(t)
+	producer := createTestAsyncProducer(t, mockProducer)
+	defer producer.Close()
+
+	assert.True(t, producer.IsHealthy())
+}
+
+func TestNewProducer_UnknownMode(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+
+	_, err := NewProducer(config.KafkaConfig{
+		Brokers:      []string{"localhost:9092"},
+		Topic:        "test-events",
+		RequiredAcks: 1,
+		ProducerMode: "fire-and-forget",
+	}, logger)
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "unknown producer mode")
+}
+
+func TestAsyncProduceBatch_PerEventResults(t *testing.T) {
+	mockProducer := newMockAsyncProducer(t)
+	mockProducer.ExpectInputAndSucceed()
+	mockProducer.ExpectInputAndFail(sarama.ErrNotLeaderForPartition)
+	mockProducer.ExpectInputAndSucceed()
+
+	producer := createTestAsyncProducer(t, mockProducer)
+	defer producer.Close()
+
+	results := producer.ProduceBatch(context.Background(), []*models.Event{
+		createTestEvent(),
+		createTestEvent(),
+		createTestEvent(),
+	})
+
+	require.Len(t, results, 3)
+	require.NoError(t, results[0].Err)
+	assert.Equal(t, int64(1), results[0].Offset)
+	assert.ErrorIs(t, results[1].Err, sarama.ErrNotLeaderForPartition)
+	require.NoError(t, results[2].Err)
+	assert.Equal(t, int64(2), results[2].Offset)
+}
@@ -0,0 +1,98 @@
+package kafka
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/distributed-event-processor/services/event-gateway/internal/models"
+)
+
+// eventAvroSchema describes events in Avro. The payload is free-form, so data
+// carries it as a JSON document rather than as a typed record.
+const eventAvroSchema = `{
+  "type": "record",
+  "name": "Event",
+  "namespace": "events.v1",
+  "fields": [
+    {"name": "id", "type": "string"},
+    {"name": "type", "type": "string"},
+    {"name": "source", "type": "string"},
+    {"name": "subject", "type": "string", "default": ""},
+    {"name": "tenant_id", "type": "string", "default": ""},
+    {"name": "data", "type": "string"},
+    {"name": "timestamp", "type": {"type": "long", "logicalType": "timestamp-micros"}},
+    {"name": "version", "type": "string", "default": ""},
+    {"name": "schema_version", "type": "string", "default": ""},
+    {"name": "metadata", "type": {"type": "map", "values": "string"}, "default": {}},
+    {"name": "correlation_id", "type": "string", "default": ""},
+    {"name": "priority", "type": "int", "default": 0}
+  ]
+}`
+
+// avroSerializer writes events in the Confluent Avro wire format: magic byte,
+// schema ID, then the Avro binary encoding of eventAvroSchema
+type avroSerializer struct {
+	registry *schemaRegistry
+}
+
+func (s *avroSerializer) Serialize(topic string, event *models.Event) ([]byte, error) {
+	schemaID, err := s.resolveSchema(topic)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := json.Marshal(event.Data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to serialize event data: %w", err)
+	}
+
+	buf := appendWireHeader(nil, schemaID)
+	buf = appendAvroString(buf, event.ID)
+	buf = appendAvroString(buf, event.Type)
+	buf = appendAvroString(buf, event.Source)
+	buf = appendAvroString(buf, event.Subject)
+	buf = appendAvroString(buf, event.TenantID)
+	buf = appendAvroString(buf, string(data))
+	buf = binary.AppendVarint(buf, event.Timestamp.UnixMicro())
+	buf = appendAvroString(buf, event.Version)
+	buf = appendAvroString(buf, event.SchemaVersion)
+	buf = appendAvroStringMap(buf, event.Metadata)
+	buf = appendAvroString(buf, event.CorrelationID)
+	buf = binary.AppendVarint(buf, int64(event.Priority))
+	return buf, nil
+}
+
+func (s *avroSerializer) ContentType() string {
+	return ContentTypeAvro
+}
+
+func (s *avroSerializer) resolveSchema(topic string) (int, error) {
+	return s.registry.schemaID(valueSubject(topic), schemaTypeAvro, eventAvroSchema)
+}
+
+// appendAvroString writes a string as its zigzag varint length and UTF-8 bytes
+func appendAvroString(buf []byte, value string) []byte {
+	buf = binary.AppendVarint(buf, int64(len(value)))
+	return append(buf, value...)
+}
+
+// appendAvroStringMap writes a map as a single block of entries followed by
+// the empty block that ends it. Keys are sorted so encoding is deterministic.
+func appendAvroStringMap(buf []byte, values map[string]string) []byte {
+	if len(values) > 0 {
+		keys := make([]string, 0, len(values))
+		for key := range values {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		buf = binary.AppendVarint(buf, int64(len(keys)))
+		for _, key := range keys {
+			buf = appendAvroString(buf, key)
+			buf = appendAvroString(buf, values[key])
+		}
+	}
+	return binary.AppendVarint(buf, 0)
+}
@@ -0,0 +1,228 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/distributed-event-processor/services/event-gateway/internal/config"
+	"go.uber.org/zap"
+)
+
+// Circuit breaker states, as reported in health details
+const (
+	CircuitClosed   = "closed"
+	CircuitHalfOpen = "half_open"
+	CircuitOpen     = "open"
+)
+
+// circuitStateValues are the values of the kafka_circuit_breaker_state gauge
+var circuitStateValues = map[string]float64{
+	CircuitClosed:   0,
+	CircuitHalfOpen: 1,
+	CircuitOpen:     2,
+}
+
+// CircuitOpenError is returned without contacting Kafka while the circuit
+// breaker is open. RetryAfter is how long until sends are tried again.
+type CircuitOpenError struct {
+	RetryAfter time.Duration
+}
+
+func (e *CircuitOpenError) Error() string {
+	return fmt.Sprintf("kafka circuit breaker is open, retry after %s", e.RetryAfter)
+}
+
+// RetryAfterSeconds rounds RetryAfter up to whole seconds for Retry-After headers
+func (e *CircuitOpenError) RetryAfterSeconds() int {
+	return retryAfterSeconds(e.RetryAfter)
+}
+
+// retryAfterSeconds rounds d up to whole seconds, at least one
+func retryAfterSeconds(d time.Duration) int {
+	seconds := int((d + time.Second - 1) / time.Second)
+	if seconds < 1 {
+		return 1
+	}
+	return seconds
+}
+
+// circuitBreaker stops the producer from waiting out sarama's retry schedule
+// on every send while the cluster is down. Only failures that may clear once
+// Kafka recovers count against it; a rejected message says nothing about the
+// cluster's availability. A nil breaker admits every send.
+type circuitBreaker struct {
+	failureThreshold int
+	openTimeout      time.Duration
+	halfOpenMax      int
+	successThreshold int
+	cluster          string
+	logger           *zap.Logger
+	now              func() time.Time
+
+	mu        sync.Mutex
+	state     string
+	changed   time.Time
+	failures  int // consecutive failures while closed
+	probes    int // sends admitted while half-open
+	successes int // successful sends while half-open
+}
+
+func newCircuitBreaker(cfg config.CircuitBreakerConfig, cluster string, logger *zap.Logger) (*circuitBreaker, error) {
+	if cfg.FailureThreshold <= 0 || cfg.OpenTimeoutMs <= 0 || cfg.HalfOpenMaxRequests <= 0 || cfg.SuccessThreshold <= 0 {
+		return nil, errors.New("circuit_breaker failure_threshold, open_timeout_ms, half_open_max_requests and success_threshold must be positive")
+	}
+	if cfg.SuccessThreshold > cfg.HalfOpenMaxRequests {
+		return nil, errors.New("circuit_breaker success_threshold cannot exceed half_open_max_requests")
+	}
+
+	b := &circuitBreaker{
+		failureThreshold: cfg.FailureThreshold,
+		openTimeout:      time.Duration(cfg.OpenTimeoutMs) * time.Millisecond,
+		halfOpenMax:      cfg.HalfOpenMaxRequests,
+		successThreshold: cfg.SuccessThreshold,
+		cluster:          cluster,
+		logger:           logger,
+		now:              time.Now,
+	}
+	b.transition(CircuitClosed)
+	return b, nil
+}
+
+// allow reports whether a send may go to Kafka, returning a *CircuitOpenError
+// if not. Once the open timeout has passed, a limited number of probe sends are
+// let through; if their outcome never arrives, new probes are admitted after
+// another timeout so the breaker cannot stay half-open forever.
+func (b *circuitBreaker) allow() error {
+	if b == nil {
+		return nil
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	elapsed := b.now().Sub(b.changed)
+
+	switch b.state {
+	case CircuitOpen:
+		if elapsed < b.openTimeout {
+			return b.reject(b.openTimeout - elapsed)
+		}
+		b.transition(CircuitHalfOpen)
+	case CircuitHalfOpen:
+		if b.probes >= b.halfOpenMax {
+			if elapsed < b.openTimeout {
+				return b.reject(b.openTimeout - elapsed)
+			}
+			b.transition(CircuitHalfOpen)
+		}
+	default:
+		return nil
+	}
+
+	b.probes++
+	return nil
+}
+
+func (b *circuitBreaker) reject(retryAfter time.Duration) error {
+	circuitRejected.WithLabelValues(b.cluster).Inc()
+	return &CircuitOpenError{RetryAfter: retryAfter}
+}
+
+// record feeds the outcome of a send back into the breaker
+func (b *circuitBreaker) record(err error) {
+	if b == nil {
+		return
+	}
+	if err != nil && !tripsBreaker(err) {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case CircuitClosed:
+		if err == nil {
+			b.failures = 0
+			return
+		}
+		b.failures++
+		if b.failures >= b.failureThreshold {
+			b.logger.Warn("Kafka circuit breaker opened",
+				zap.Int("consecutive_failures", b.failures),
+				zap.Duration("open_timeout", b.openTimeout),
+				zap.Error(err))
+			b.transition(CircuitOpen)
+		}
+	case CircuitHalfOpen:
+		if err != nil {
+			b.logger.Warn("Kafka circuit breaker probe failed, reopening", zap.Error(err))
+			b.transition(CircuitOpen)
+			return
+		}
+		b.successes++
+		if b.successes >= b.successThreshold {
+			b.logger.Info("Kafka circuit breaker closed")
+			b.transition(CircuitClosed)
+		}
+	}
+}
+
+// transition moves to state and resets the counters of the previous one.
+// Callers hold mu, except the constructor.
+func (b *circuitBreaker) transition(state string) {
+	b.state = state
+	b.changed = b.now()
+	b.failures = 0
+	b.probes = 0
+	b.successes = 0
+	circuitState.WithLabelValues(b.cluster).Set(circuitStateValues[state])
+}
+
+// rejecting reports whether allow would currently fail fast, without
+// admitting a probe
+func (b *circuitBreaker) rejecting() bool {
+	if b == nil {
+		return false
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	elapsed := b.now().Sub(b.changed)
+	switch b.state {
+	case CircuitOpen:
+		return elapsed < b.openTimeout
+	case CircuitHalfOpen:
+		return b.probes >= b.halfOpenMax && elapsed < b.openTimeout
+	default:
+		return false
+	}
+}
+
+// State returns the current breaker state
+func (b *circuitBreaker) State() string {
+	if b == nil {
+		return CircuitClosed
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
+
+// tripsBreaker reports whether a failed send says the cluster is unavailable.
+// Cancelled requests, closed producers and permanently rejected messages do not.
+func tripsBreaker(err error) bool {
+	var openErr *CircuitOpenError
+	if errors.As(err, &openErr) {
+		return false
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) || errors.Is(err, errProducerClosed) {
+		return false
+	}
+	return spoolable(err)
+}
@@ -0,0 +1,205 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/IBM/sarama"
+	"github.com/IBM/sarama/mocks"
+	"github.com/distributed-event-processor/services/event-gateway/internal/config"
+	"github.com/distributed-event-processor/services/event-gateway/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func validBreakerConfig() config.CircuitBreakerConfig {
+	return config.CircuitBreakerConfig{
+		Enabled:             true,
+		FailureThreshold:    3,
+		OpenTimeoutMs:       30000,
+		HalfOpenMaxRequests: 2,
+		SuccessThreshold:    2,
+	}
+}
+
+// createTestBreaker returns a breaker driven by a clock the test advances
+func createTestBreaker(t *testing.T, cfg config.CircuitBreakerConfig) (*circuitBreaker, func(time.Duration)) {
+	breaker, err := newCircuitBreaker(cfg, DefaultCluster, zap.NewNop())
+	require.NoError(t, err)
+
+	now := time.Now()
+	breaker.now = func() time.Time { return now }
+	breaker.changed = now
+	return breaker, func(d time.Duration) { now = now.Add(d) }
+}
+
+func TestCircuitBreaker_OpensAfterConsecutiveFailures(t *testing.T) {
+	breaker, _ := createTestBreaker(t, validBreakerConfig())
+
+	breaker.record(sarama.ErrOutOfBrokers)
+	breaker.record(sarama.ErrOutOfBrokers)
+	breaker.record(nil) // a success resets the count
+	breaker.record(sarama.ErrOutOfBrokers)
+	breaker.record(sarama.ErrOutOfBrokers)
+	assert.Equal(t, CircuitClosed, breaker.State())
+	assert.NoError(t, breaker.allow())
+
+	breaker.record(sarama.ErrOutOfBrokers)
+	assert.Equal(t, CircuitOpen, breaker.State())
+
+	var openErr *CircuitOpenError
+	require.ErrorAs(t, breaker.allow(), &openErr)
+	assert.Equal(t, 30*time.Second, openErr.RetryAfter)
+	assert.Equal(t, 30, openErr.RetryAfterSeconds())
+}
+
+func TestCircuitBreaker_IgnoresPermanentErrors(t *testing.T) {
+	breaker, _ := createTestBreaker(t, validBreakerConfig())
+
+	for i := 0; i < 5; i++ {
+		breaker.record(sarama.ErrMessageSizeTooLarge)
+		breaker.record(context.Canceled)
+		breaker.record(errProducerClosed)
+	}
+	assert.Equal(t, CircuitClosed, breaker.State())
+}
+
+func TestCircuitBreaker_HalfOpenCloses(t *testing.T) {
+	breaker, advance := createTestBreaker(t, validBreakerConfig())
+	for i := 0; i < 3; i++ {
+		breaker.record(sarama.ErrOutOfBrokers)
+	}
+
+	advance(10 * time.Second)
+	var openErr *CircuitOpenError
+	require.ErrorAs(t, breaker.allow(), &openErr)
+	assert.Equal(t, 20*time.Second, openErr.RetryAfter)
+
+	// After the open timeout only HalfOpenMaxRequests probes are admitted
+	advance(20 * time.Second)
+	require.NoError(t, breaker.allow())
+	require.NoError(t, breaker.allow())
+	assert.Equal(t, CircuitHalfOpen, breaker.State())
+	assert.Error(t, breaker.allow())
+
+	breaker.record(nil)
+	assert.Equal(t, CircuitHalfOpen, breaker.State())
+	breaker.record(nil)
+	assert.Equal(t, CircuitClosed, breaker.State())
+	assert.NoError(t, breaker.allow())
+}
+
+func TestCircuitBreaker_HalfOpenReopensOnFailure(t *testing.T) {
+	breaker, advance := createTestBreaker(t, validBreakerConfig())
+	for i := 0; i < 3; i++ {
+		breaker.record(sarama.ErrOutOfBrokers)
+	}
+
+	advance(30 * time.Second)
+	require.NoError(t, breaker.allow())
+	breaker.record(sarama.ErrNotLeaderForPartition)
+
+	assert.Equal(t, CircuitOpen, breaker.State())
+	assert.Error(t, breaker.allow())
+}
+
+func TestCircuitBreaker_HalfOpenRecoversLostProbes(t *testing.T) {
+	cfg := validBreakerConfig()
+	cfg.HalfOpenMaxRequests = 1
+	cfg.SuccessThreshold = 1
+	breaker, advance := createTestBreaker(t, cfg)
+	for i := 0; i < 3; i++ {
+		breaker.record(sarama.ErrOutOfBrokers)
+	}
+
+	advance(30 * time.Second)
+	require.NoError(t, breaker.allow())
+	assert.Error(t, breaker.allow())
+
+	// The probe's outcome never arrived, so a new one is admitted after another timeout
+	advance(30 * time.Second)
+	assert.NoError(t, breaker.allow())
+}
+
+func TestNewCircuitBreaker_InvalidConfig(t *testing.T) {
+	cfg := validBreakerConfig()
+	cfg.FailureThreshold = 0
+	_, err := newCircuitBreaker(cfg, DefaultCluster, zap.NewNop())
+	assert.Error(t, err)
+
+	cfg = validBreakerConfig()
+	cfg.SuccessThreshold = 3
+	_, err = newCircuitBreaker(cfg, DefaultCluster, zap.NewNop())
+	assert.Error(t, err)
+}
+
+func TestCircuitOpenError_RetryAfterSeconds(t *testing.T) {
+	assert.Equal(t, 1, (&CircuitOpenError{RetryAfter: 0}).RetryAfterSeconds())
+	assert.Equal(t, 2, (&CircuitOpenError{RetryAfter: 1500 * time.Millisecond}).RetryAfterSeconds())
+	assert.Equal(t, 5, (&CircuitOpenError{RetryAfter: 5 * time.Second}).RetryAfterSeconds())
+}
+
+// openTestBreaker attaches an already open breaker to producer
+func openTestBreaker(t *testing.T, producer *Producer) {
+	breaker, _ := createTestBreaker(t, validBreakerConfig())
+	for i := 0; i < 3; i++ {
+		breaker.record(sarama.ErrOutOfBrokers)
+	}
+	require.Equal(t, CircuitOpen, breaker.State())
+	producer.breaker = breaker
+}
+
+func TestProduce_FailsFastWhenCircuitOpen(t *testing.T) {
+	// No send is expected: the mock fails the test if one is made
+	producer := createTestProducer(t, mocks.NewSyncProducer(t, nil))
+	openTestBreaker(t, producer)
+
+	result := producer.Produce(context.Background(), createTestEvent())
+
+	var openErr *CircuitOpenError
+	require.ErrorAs(t, result.Err, &openErr)
+	assert.Equal(t, "test-events", result.Topic)
+	assert.False(t, result.Queued)
+
+	results := producer.ProduceBatch(context.Background(), []*models.Event{createTestEvent(), createTestEvent()})
+	for _, result := range results {
+		assert.True(t, errors.As(result.Err, &openErr))
+	}
+}
+
+func TestProduce_SpoolsWhenCircuitOpen(t *testing.T) {
+	producer := createTestSpoolingProducer(t, mocks.NewSyncProducer(t, nil))
+	defer producer.Close()
+	openTestBreaker(t, producer)
+
+	result := producer.Produce(context.Background(), createTestEvent())
+
+	require.NoError(t, result.Err)
+	assert.True(t, result.Queued)
+	assert.Equal(t, 1, producer.Spooled())
+
+	// Replay waits for the breaker instead of sending
+	producer.replaySpool(context.Background())
+	assert.Equal(t, 1, producer.Spooled())
+}
+
+func TestProduce_OpensCircuitOnFailures(t *testing.T) {
+	mockProducer := mocks.NewSyncProducer(t, nil)
+	for i := 0; i < 3; i++ {
+		mockProducer.ExpectSendMessageAndFail(sarama.ErrOutOfBrokers)
+	}
+	producer := createTestProducer(t, mockProducer)
+	producer.breaker, _ = createTestBreaker(t, validBreakerConfig())
+
+	for i := 0; i < 3; i++ {
+		require.Error(t, producer.Produce(context.Background(), createTestEvent()).Err)
+	}
+
+	status := producer.Health()
+	assert.False(t, status.Healthy)
+	assert.Equal(t, "Kafka circuit breaker is open", status.Message)
+	assert.Equal(t, CircuitOpen, status.Details["circuit_breaker"])
+}
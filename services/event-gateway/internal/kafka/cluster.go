@@ -0,0 +1,264 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	"github.com/distributed-event-processor/services/event-gateway/internal/config"
+	"github.com/distributed-event-processor/services/event-gateway/internal/models"
+	"go.uber.org/zap"
+)
+
+// Secondary cluster policies
+const (
+	ClusterFailover   = "failover"
+	ClusterRoundRobin = "round_robin"
+	ClusterDualWrite  = "dual_write"
+)
+
+// DefaultCluster names the cluster at KafkaConfig.Brokers when none is configured
+const DefaultCluster = "primary"
+
+// clusterName returns the configured name of the cluster at cfg.Brokers
+func clusterName(cfg config.KafkaConfig) string {
+	if cfg.Cluster == "" {
+		return DefaultCluster
+	}
+	return cfg.Cluster
+}
+
+// ClusterSet is an EventPublisher over a primary and a secondary Kafka cluster.
+// With the failover policy events go to the primary until its circuit breaker
+// opens; round_robin alternates between the clusters, skipping one whose
+// breaker is open; dual_write sends every event to both and answers with the
+// primary's outcome, so a migration target can be filled without affecting
+// clients. The cluster that took each event is reported in its DeliveryResult.
+type ClusterSet struct {
+	policy    string
+	primary   *Producer
+	secondary *Producer
+	logger    *zap.Logger
+
+	// next counts round_robin sends to pick the cluster of the next one
+	next atomic.Uint64
+}
+
+// NewClusterSet connects to the primary cluster and to cfg.Secondary
+func NewClusterSet(cfg config.KafkaConfig, logger *zap.Logger) (*ClusterSet, error) {
+	switch cfg.Secondary.Policy {
+	case ClusterFailover, ClusterRoundRobin, ClusterDualWrite:
+	default:
+		return nil, fmt.Errorf("unknown secondary cluster policy: %s (expected: %s, %s or %s)",
+			cfg.Secondary.Policy, ClusterFailover, ClusterRoundRobin, ClusterDualWrite)
+	}
+	if len(cfg.Secondary.Brokers) == 0 {
+		return nil, errors.New("secondary cluster requires brokers")
+	}
+	// Only an open breaker moves events away from a cluster
+	if cfg.Secondary.Policy != ClusterDualWrite && !cfg.CircuitBreaker.Enabled {
+		return nil, fmt.Errorf("secondary cluster policy %s requires the circuit breaker to be enabled", cfg.Secondary.Policy)
+	}
+	secondaryCfg := secondaryConfig(cfg)
+	if clusterName(secondaryCfg) == clusterName(cfg) {
+		return nil, fmt.Errorf("secondary cluster name %s is also the primary's", clusterName(cfg))
+	}
+
+	primary, err := NewProducer(cfg, logger)
+	if err != nil {
+		return nil, err
+	}
+	secondary, err := NewProducer(secondaryCfg, logger)
+	if err != nil {
+		primary.Close()
+		return nil, fmt.Errorf("secondary cluster: %w", err)
+	}
+
+	logger.Info("Using secondary Kafka cluster",
+		zap.String("primary", primary.cluster()),
+		zap.String("secondary", secondary.cluster()),
+		zap.String("policy", cfg.Secondary.Policy))
+
+	return &ClusterSet{
+		policy:    cfg.Secondary.Policy,
+		primary:   primary,
+		secondary: secondary,
+		logger:    logger,
+	}, nil
+}
+
+// secondaryConfig derives the producer configuration of the secondary cluster.
+// It keeps a spool, dead-letter fallback directory and transactional ID of its
+// own, so the two producers never share local state or fence each other.
+func secondaryConfig(cfg config.KafkaConfig) config.KafkaConfig {
+	secondary := cfg
+	secondary.Cluster = cfg.Secondary.Name
+	secondary.Brokers = cfg.Secondary.Brokers
+	secondary.TLS = cfg.Secondary.TLS
+	secondary.SASL = cfg.Secondary.SASL
+	secondary.Secondary = config.SecondaryClusterConfig{}
+
+	name := clusterName(secondary)
+	if cfg.Spool.Dir != "" {
+		secondary.Spool.Dir = cfg.Spool.Dir + "-" + name
+	}
+	if cfg.DeadLetter.FallbackDir != "" {
+		secondary.DeadLetter.FallbackDir = cfg.DeadLetter.FallbackDir + "-" + name
+	}
+	if cfg.TransactionalID != "" {
+		secondary.TransactionalID = cfg.TransactionalID + "-" + name
+	}
+	return secondary
+}
+
+// choose returns the producer for the next event or batch under the failover
+// and round_robin policies. A cluster whose breaker is failing fast is skipped
+// while the other accepts sends; if neither does, the preferred cluster takes
+// the event so that it is spooled or rejected there.
+func (c *ClusterSet) choose() *Producer {
+	preferred, other := c.primary, c.secondary
+	if c.policy == ClusterRoundRobin && c.next.Add(1)%2 == 0 {
+		preferred, other = other, preferred
+	}
+
+	if preferred.breaker.rejecting() && !other.breaker.rejecting() {
+		clusterFailovers.WithLabelValues(preferred.cluster(), other.cluster()).Inc()
+		return other
+	}
+	return preferred
+}
+
+// Produce publishes an event and waits for the outcome
+func (c *ClusterSet) Produce(ctx context.Context, event *models.Event) DeliveryResult {
+	if c.policy != ClusterDualWrite {
+		return c.choose().Produce(ctx, event)
+	}
+
+	var mirrored DeliveryResult
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		mirrored = c.secondary.Produce(ctx, event)
+	}()
+	result := c.primary.Produce(ctx, event)
+	wg.Wait()
+
+	c.checkMirror(event, mirrored)
+	return result
+}
+
+// ProduceEventAsync publishes an event without waiting. Under dual_write the
+// result is delivered once both clusters have answered.
+func (c *ClusterSet) ProduceEventAsync(ctx context.Context, event *models.Event) <-chan DeliveryResult {
+	if c.policy != ClusterDualWrite {
+		return c.choose().ProduceEventAsync(ctx, event)
+	}
+
+	primary := c.primary.ProduceEventAsync(ctx, event)
+	mirror := c.secondary.ProduceEventAsync(ctx, event)
+
+	result := make(chan DeliveryResult, 1)
+	go func() {
+		delivered := <-primary
+		c.checkMirror(event, <-mirror)
+		result <- delivered
+	}()
+	return result
+}
+
+// ProduceBatch publishes events together on one cluster, or on both under
+// dual_write, and returns one result per event
+func (c *ClusterSet) ProduceBatch(ctx context.Context, events []*models.Event) []DeliveryResult {
+	if c.policy != ClusterDualWrite {
+		return c.choose().ProduceBatch(ctx, events)
+	}
+
+	var mirrored []DeliveryResult
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		mirrored = c.secondary.ProduceBatch(ctx, events)
+	}()
+	results := c.primary.ProduceBatch(ctx, events)
+	wg.Wait()
+
+	for i, event := range events {
+		c.checkMirror(event, mirrored[i])
+	}
+	return results
+}
+
+// checkMirror logs a dual-written event the secondary did not accept. The
+// secondary's own metrics, spool and dead-letter queue have already seen it.
+func (c *ClusterSet) checkMirror(event *models.Event, result DeliveryResult) {
+	if result.Err == nil {
+		return
+	}
+	c.logger.Warn("Dual write to secondary Kafka cluster failed",
+		zap.String("cluster", c.secondary.cluster()),
+		zap.String("event_id", event.ID),
+		zap.Error(result.Err))
+}
+
+// Transactional reports whether batches are committed atomically. Each
+// cluster commits its own transaction; there is no atomicity across them.
+func (c *ClusterSet) Transactional() bool {
+	return c.primary.Transactional()
+}
+
+// IsHealthy reports whether events can currently be published
+func (c *ClusterSet) IsHealthy() bool {
+	return c.Health().Healthy
+}
+
+// Health combines the health of both clusters. The set is healthy while the
+// primary is, or under failover and round_robin while the secondary can take
+// its events. Under dual_write the primary's outcome answers every request, so
+// only its health counts. Each cluster's details are prefixed with its name.
+func (c *ClusterSet) Health() HealthStatus {
+	primary := c.primary.Health()
+	secondary := c.secondary.Health()
+
+	status := HealthStatus{
+		Healthy:   primary.Healthy,
+		Message:   primary.Message,
+		LastCheck: primary.LastCheck,
+		Details: map[string]string{
+			"cluster_policy": c.policy,
+		},
+	}
+	if !primary.Healthy && secondary.Healthy && c.policy != ClusterDualWrite {
+		status.Healthy = true
+		status.Message = fmt.Sprintf("Kafka cluster %s is unhealthy, using %s", c.primary.cluster(), c.secondary.cluster())
+	}
+	if secondary.LastCheck.After(status.LastCheck) {
+		status.LastCheck = secondary.LastCheck
+	}
+
+	for _, cluster := range []struct {
+		name   string
+		health HealthStatus
+	}{
+		{c.primary.cluster(), primary},
+		{c.secondary.cluster(), secondary},
+	} {
+		state := "healthy"
+		if !cluster.health.Healthy {
+			state = "unhealthy: " + cluster.health.Message
+		}
+		status.Details[cluster.name] = state
+		for key, value := range cluster.health.Details {
+			status.Details[cluster.name+"."+key] = value
+		}
+	}
+	return status
+}
+
+// Close closes both clusters' producers
+func (c *ClusterSet) Close() error {
+	return errors.Join(c.primary.Close(), c.secondary.Close())
+}
@@ -0,0 +1,188 @@
+package kafka
+
+import (
+	"context"
+	"testing"
+
+	"github.com/IBM/sarama"
+	"github.com/IBM/sarama/mocks"
+	"github.com/distributed-event-processor/services/event-gateway/internal/config"
+	"github.com/distributed-event-processor/services/event-gateway/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// createClusterProducer returns a producer for the named cluster whose breaker
+// opens on the first failure
+func createClusterProducer(t *testing.T, name string, mockProducer *mocks.SyncProducer) *Producer {
+	cfg := validBreakerConfig()
+	cfg.FailureThreshold = 1
+
+	breaker, err := newCircuitBreaker(cfg, name, zap.NewNop())
+	require.NoError(t, err)
+
+	return &Producer{
+		producer: mockProducer,
+		config:   config.KafkaConfig{Cluster: name, Topic: "test-events"},
+		logger:   zap.NewNop(),
+		breaker:  breaker,
+	}
+}
+
+func createTestClusterSet(t *testing.T, policy string, primary, secondary *mocks.SyncProducer) *ClusterSet {
+	return &ClusterSet{
+		policy:    policy,
+		primary:   createClusterProducer(t, "primary", primary),
+		secondary: createClusterProducer(t, "secondary", secondary),
+		logger:    zap.NewNop(),
+	}
+}
+
+func TestClusterSet_FailsOverWhilePrimaryCircuitIsOpen(t *testing.T) {
+	primary := mocks.NewSyncProducer(t, nil)
+	primary.ExpectSendMessageAndSucceed()
+	primary.ExpectSendMessageAndFail(sarama.ErrBrokerNotAvailable)
+	secondary := mocks.NewSyncProducer(t, nil)
+	secondary.ExpectSendMessageAndSucceed()
+	secondary.ExpectSendMessageAndSucceed()
+
+	clusters := createTestClusterSet(t, ClusterFailover, primary, secondary)
+	ctx := context.Background()
+
+	result := clusters.Produce(ctx, createTestEvent())
+	require.NoError(t, result.Err)
+	assert.Equal(t, "primary", result.Cluster)
+
+	// The failure opens the primary's breaker; the event itself is not retried
+	result = clusters.Produce(ctx, createTestEvent())
+	require.Error(t, result.Err)
+	assert.Equal(t, "primary", result.Cluster)
+
+	result = clusters.Produce(ctx, createTestEvent())
+	require.NoError(t, result.Err)
+	assert.Equal(t, "secondary", result.Cluster)
+
+	results := clusters.ProduceBatch(ctx, []*models.Event{createTestEvent()})
+	require.NoError(t, results[0].Err)
+	assert.Equal(t, "secondary", results[0].Cluster)
+
+	status := clusters.Health()
+	assert.True(t, status.Healthy)
+	assert.Equal(t, CircuitOpen, status.Details["primary.circuit_breaker"])
+	assert.Equal(t, "healthy", status.Details["secondary"])
+}
+
+func TestClusterSet_BothCircuitsOpenStaysOnPrimary(t *testing.T) {
+	primary := mocks.NewSyncProducer(t, nil)
+	primary.ExpectSendMessageAndFail(sarama.ErrBrokerNotAvailable)
+	secondary := mocks.NewSyncProducer(t, nil)
+
+	clusters := createTestClusterSet(t, ClusterFailover, primary, secondary)
+	clusters.secondary.breaker.record(sarama.ErrBrokerNotAvailable)
+
+	clusters.Produce(context.Background(), createTestEvent())
+	result := clusters.Produce(context.Background(), createTestEvent())
+
+	var openErr *CircuitOpenError
+	require.ErrorAs(t, result.Err, &openErr)
+	assert.Equal(t, "primary", result.Cluster)
+	assert.False(t, clusters.Health().Healthy)
+}
+
+func TestClusterSet_RoundRobin(t *testing.T) {
+	primary := mocks.NewSyncProducer(t, nil)
+	primary.ExpectSendMessageAndSucceed()
+	primary.ExpectSendMessageAndSucceed()
+	secondary := mocks.NewSyncProducer(t, nil)
+	secondary.ExpectSendMessageAndSucceed()
+	secondary.ExpectSendMessageAndSucceed()
+
+	clusters := createTestClusterSet(t, ClusterRoundRobin, primary, secondary)
+
+	var chosen []string
+	for range 4 {
+		result := <-clusters.ProduceEventAsync(context.Background(), createTestEvent())
+		require.NoError(t, result.Err)
+		chosen = append(chosen, result.Cluster)
+	}
+	assert.Equal(t, []string{"primary", "secondary", "primary", "secondary"}, chosen)
+}
+
+func TestClusterSet_DualWrite(t *testing.T) {
+	primary := mocks.NewSyncProducer(t, nil)
+	primary.ExpectSendMessageAndSucceed()
+	primary.ExpectSendMessageAndSucceed()
+	secondary := mocks.NewSyncProducer(t, nil)
+	secondary.ExpectSendMessageAndSucceed()
+	secondary.ExpectSendMessageAndFail(sarama.ErrBrokerNotAvailable)
+
+	clusters := createTestClusterSet(t, ClusterDualWrite, primary, secondary)
+
+	result := clusters.Produce(context.Background(), createTestEvent())
+	require.NoError(t, result.Err)
+	assert.Equal(t, "primary", result.Cluster)
+
+	// A secondary failure does not fail the event
+	result = clusters.Produce(context.Background(), createTestEvent())
+	require.NoError(t, result.Err)
+	assert.Equal(t, "primary", result.Cluster)
+
+	// Only the primary answers requests, so only its health counts
+	status := clusters.Health()
+	assert.True(t, status.Healthy)
+	assert.Contains(t, status.Details["secondary"], "unhealthy")
+}
+
+func TestSecondaryConfig(t *testing.T) {
+	cfg := config.KafkaConfig{
+		Cluster:         "east",
+		Brokers:         []string{"east:9092"},
+		Topic:           "events",
+		TransactionalID: "gateway",
+		TLS:             config.KafkaTLSConfig{Enabled: true},
+		Spool:           config.SpoolConfig{Enabled: true, Dir: "./data/spool"},
+		Secondary: config.SecondaryClusterConfig{
+			Enabled: true,
+			Name:    "west",
+			Policy:  ClusterFailover,
+			Brokers: []string{"west:9092"},
+		},
+	}
+
+	secondary := secondaryConfig(cfg)
+
+	assert.Equal(t, "west", clusterName(secondary))
+	assert.Equal(t, []string{"west:9092"}, secondary.Brokers)
+	assert.False(t, secondary.TLS.Enabled)
+	assert.False(t, secondary.Secondary.Enabled)
+	assert.Equal(t, "events", secondary.Topic)
+	assert.Equal(t, "./data/spool-west", secondary.Spool.Dir)
+	assert.Equal(t, "gateway-west", secondary.TransactionalID)
+}
+
+func TestNewClusterSet_InvalidConfig(t *testing.T) {
+	breaker := validBreakerConfig()
+	tests := []struct {
+		name      string
+		breaker   config.CircuitBreakerConfig
+		secondary config.SecondaryClusterConfig
+	}{
+		{"unknown policy", breaker, config.SecondaryClusterConfig{Name: "dr", Policy: "broadcast", Brokers: []string{"dr:9092"}}},
+		{"no brokers", breaker, config.SecondaryClusterConfig{Name: "dr", Policy: ClusterFailover}},
+		{"same name as primary", breaker, config.SecondaryClusterConfig{Name: DefaultCluster, Policy: ClusterFailover, Brokers: []string{"dr:9092"}}},
+		// Without a breaker no cluster is ever avoided
+		{"failover without breaker", config.CircuitBreakerConfig{}, config.SecondaryClusterConfig{Name: "dr", Policy: ClusterFailover, Brokers: []string{"dr:9092"}}},
+		{"round_robin without breaker", config.CircuitBreakerConfig{}, config.SecondaryClusterConfig{Name: "dr", Policy: ClusterRoundRobin, Brokers: []string{"dr:9092"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewClusterSet(config.KafkaConfig{CircuitBreaker: tt.breaker, Secondary: tt.secondary}, zap.NewNop())
+			assert.Error(t, err)
+		})
+	}
+
+	_, err := NewClusterSet(config.KafkaConfig{Secondary: config.SecondaryClusterConfig{Name: "dr", Policy: ClusterFailover, Brokers: []string{"dr:9092"}}}, zap.NewNop())
+	assert.ErrorContains(t, err, "requires the circuit breaker")
+}
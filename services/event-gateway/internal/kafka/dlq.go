@@ -0,0 +1,309 @@
+package kafka
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/IBM/sarama"
+	"github.com/distributed-event-processor/services/event-gateway/internal/config"
+	"github.com/distributed-event-processor/services/event-gateway/internal/models"
+	"go.uber.org/zap"
+)
+
+// Reasons recorded on dead letters
+const (
+	DeadLetterSerializationFailed = "serialization_failed"
+	DeadLetterRetriesExhausted    = "retries_exhausted"
+	DeadLetterRejected            = "rejected"
+	DeadLetterCorruptSpoolRecord  = "corrupt_spool_record"
+)
+
+// deadLetterQueueSize bounds how many dead letters may wait for the DLQ
+// producer before further ones are written straight to disk
+const deadLetterQueueSize = 1024
+
+// DeadLetter is the envelope written to the dead-letter topic, or to the
+// fallback directory as one JSON document per line. The event is kept exactly
+// as it would have been produced, so it can be re-driven by producing it to
+// OriginalTopic: JSON values in Payload, and binary ones (protobuf or Avro,
+// per ContentType) base64-encoded in PayloadBytes. Events that could not be
+// serialized carry their JSON form in Payload when it exists, and otherwise a
+// textual dump in PayloadText. ReportedToClient is set when the failure was
+// also returned to the client, which may send the event again; re-drives must
+// skip those letters or the event can reach Kafka twice.
+type DeadLetter struct {
+	EventID          string            `json:"event_id"`
+	EventType        string            `json:"event_type,omitempty"`
+	Source           string            `json:"source,omitempty"`
+	OriginalTopic    string            `json:"original_topic,omitempty"`
+	ContentType      string            `json:"content_type,omitempty"`
+	Reason           string            `json:"reason"`
+	Error            string            `json:"error"`
+	ReportedToClient bool              `json:"reported_to_client"`
+	Attempts         int               `json:"attempts"`
+	FailedAt         time.Time         `json:"failed_at"`
+	Metadata         map[string]string `json:"metadata,omitempty"`
+	Payload          json.RawMessage   `json:"payload,omitempty"`
+	PayloadBytes     []byte            `json:"payload_bytes,omitempty"`
+	PayloadText      string            `json:"payload_text,omitempty"`
+}
+
+// deadLetterQueue writes dead letters to the DLQ topic from a background
+// worker, so that a slow or unreachable cluster never blocks the delivery path.
+// Letters that cannot be produced fall back to files in dir.
+type deadLetterQueue struct {
+	producer sarama.SyncProducer
+	topic    string
+	dir      string
+	logger   *zap.Logger
+
+	// closeMu guards sends to queue against a concurrent close
+	closeMu sync.RWMutex
+	closed  bool
+	queue   chan *DeadLetter
+	wg      sync.WaitGroup
+
+	// fileMu serializes appends to the fallback files
+	fileMu sync.Mutex
+}
+
+func newDeadLetterQueue(cfg config.KafkaConfig, saramaConfig *sarama.Config, logger *zap.Logger) (*deadLetterQueue, error) {
+	if cfg.DeadLetter.Topic == "" {
+		return nil, errors.New("dead_letter topic is required when the dead-letter queue is enabled")
+	}
+	if cfg.DeadLetter.FallbackDir == "" {
+		return nil, errors.New("dead_letter fallback_dir is required when the dead-letter queue is enabled")
+	}
+	if err := os.MkdirAll(cfg.DeadLetter.FallbackDir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create dead-letter directory: %w", err)
+	}
+
+	producer, err := sarama.NewSyncProducer(cfg.Brokers, newAuxiliaryConfig(saramaConfig, "dlq"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create dead-letter producer: %w", err)
+	}
+
+	return startDeadLetterQueue(producer, cfg.DeadLetter, logger), nil
+}
+
+func startDeadLetterQueue(producer sarama.SyncProducer, cfg config.DeadLetterConfig, logger *zap.Logger) *deadLetterQueue {
+	q := &deadLetterQueue{
+		producer: producer,
+		topic:    cfg.Topic,
+		dir:      cfg.FallbackDir,
+		logger:   logger,
+		queue:    make(chan *DeadLetter, deadLetterQueueSize),
+	}
+
+	q.wg.Add(1)
+	go func() {
+		defer q.wg.Done()
+		for letter := range q.queue {
+			q.write(letter)
+		}
+	}()
+
+	return q
+}
+
+// Send hands a dead letter to the background worker. When the worker is
+// backed up or the queue is closed the letter goes straight to disk.
+func (q *deadLetterQueue) Send(letter *DeadLetter) {
+	q.closeMu.RLock()
+	if !q.closed {
+		select {
+		case q.queue <- letter:
+			q.closeMu.RUnlock()
+			return
+		default:
+		}
+	}
+	q.closeMu.RUnlock()
+
+	q.writeFile(letter)
+}
+
+// write produces a dead letter to the DLQ topic, falling back to disk
+func (q *deadLetterQueue) write(letter *DeadLetter) {
+	value, err := json.Marshal(letter)
+	if err != nil {
+		deadLetterFailures.Inc()
+		q.logger.Error("Failed to serialize dead letter",
+			zap.String("event_id", letter.EventID),
+			zap.Error(err))
+		return
+	}
+
+	_, _, err = q.producer.SendMessage(&sarama.ProducerMessage{
+		Topic: q.topic,
+		Key:   sarama.StringEncoder(letter.EventID),
+		Value: sarama.ByteEncoder(value),
+		Headers: []sarama.RecordHeader{
+			{Key: []byte(contentTypeHeader), Value: []byte(ContentTypeJSON)},
+			{Key: []byte("event_id"), Value: []byte(letter.EventID)},
+			{Key: []byte("dlq_reason"), Value: []byte(letter.Reason)},
+			{Key: []byte("original_topic"), Value: []byte(letter.OriginalTopic)},
+		},
+	})
+	if err != nil {
+		q.logger.Warn("Failed to produce dead letter, writing it to disk",
+			zap.String("event_id", letter.EventID),
+			zap.Error(err))
+		q.writeFile(letter)
+		return
+	}
+
+	deadLetters.WithLabelValues(letter.Reason, "kafka").Inc()
+}
+
+// writeFile appends a dead letter to the fallback file for the current day
+func (q *deadLetterQueue) writeFile(letter *DeadLetter) {
+	value, err := json.Marshal(letter)
+	if err == nil {
+		err = q.appendFile(append(value, '\n'))
+	}
+	if err != nil {
+		deadLetterFailures.Inc()
+		q.logger.Error("Failed to write dead letter to disk; event is lost",
+			zap.String("event_id", letter.EventID),
+			zap.String("reason", letter.Reason),
+			zap.Error(err))
+		return
+	}
+
+	deadLetters.WithLabelValues(letter.Reason, "disk").Inc()
+}
+
+func (q *deadLetterQueue) appendFile(line []byte) error {
+	q.fileMu.Lock()
+	defer q.fileMu.Unlock()
+
+	path := filepath.Join(q.dir, "dead-letters-"+time.Now().UTC().Format("2006-01-02")+".jsonl")
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(line); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// close drains every queued dead letter and releases the DLQ producer
+func (q *deadLetterQueue) close() error {
+	q.closeMu.Lock()
+	if q.closed {
+		q.closeMu.Unlock()
+		return nil
+	}
+	q.closed = true
+	close(q.queue)
+	q.closeMu.Unlock()
+
+	q.wg.Wait()
+	return q.producer.Close()
+}
+
+// deadLetterEvent records an event that never made it into a Kafka message.
+// The serialization error is always returned to the client as well.
+func (p *Producer) deadLetterEvent(event *models.Event, err error) {
+	if p.dlq == nil {
+		return
+	}
+
+	topic, _ := p.route(event)
+	letter := &DeadLetter{
+		EventID:          event.ID,
+		EventType:        event.Type,
+		Source:           event.Source,
+		OriginalTopic:    topic,
+		Reason:           DeadLetterSerializationFailed,
+		Error:            err.Error(),
+		ReportedToClient: true,
+		FailedAt:         time.Now().UTC(),
+		Metadata:         event.Metadata,
+	}
+
+	// A binary format may fail where JSON does not, e.g. while the schema
+	// registry is unreachable
+	if value, jsonErr := json.Marshal(event); jsonErr == nil {
+		letter.ContentType = ContentTypeJSON
+		letter.Payload = value
+	} else {
+		letter.PayloadText = fmt.Sprintf("%+v", *event)
+	}
+
+	p.dlq.Send(letter)
+}
+
+// deadLetterMessage records a message that Kafka did not accept after the
+// producer's retries. reported tells whether a client was given the error.
+func (p *Producer) deadLetterMessage(message *sarama.ProducerMessage, event *models.Event, err error, reported bool) {
+	if p.dlq == nil {
+		return
+	}
+
+	reason := DeadLetterRetriesExhausted
+	if !spoolable(err) {
+		reason = DeadLetterRejected
+	}
+
+	letter := &DeadLetter{
+		OriginalTopic:    message.Topic,
+		Reason:           reason,
+		Error:            err.Error(),
+		ReportedToClient: reported,
+		Attempts:         p.attempts,
+		FailedAt:         time.Now().UTC(),
+	}
+	if event != nil {
+		letter.EventID = event.ID
+		letter.EventType = event.Type
+		letter.Source = event.Source
+		letter.Metadata = event.Metadata
+	}
+	letter.ContentType = headerValue(message, contentTypeHeader)
+	if value, encodeErr := message.Value.Encode(); encodeErr == nil {
+		if letter.ContentType == ContentTypeJSON || letter.ContentType == ContentTypeCloudEvents || letter.ContentType == "" {
+			letter.Payload = value
+		} else {
+			letter.PayloadBytes = value
+		}
+	}
+
+	p.dlq.Send(letter)
+}
+
+// deadLetterRecord records a spooled record that can no longer be decoded
+func (p *Producer) deadLetterRecord(record []byte, err error) {
+	if p.dlq == nil {
+		return
+	}
+
+	p.dlq.Send(&DeadLetter{
+		Reason:      DeadLetterCorruptSpoolRecord,
+		Error:       err.Error(),
+		FailedAt:    time.Now().UTC(),
+		PayloadText: string(record),
+	})
+}
+
+// headerValue returns the value of the named message header, or "" if unset
+func headerValue(message *sarama.ProducerMessage, key string) string {
+	for _, header := range message.Headers {
+		if string(header.Key) == key {
+			return string(header.Value)
+		}
+	}
+	return ""
+}
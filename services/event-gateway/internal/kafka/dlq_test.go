@@ -0,0 +1,198 @@
+package kafka
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/IBM/sarama"
+	"github.com/IBM/sarama/mocks"
+	"github.com/distributed-event-processor/services/event-gateway/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// recordingSyncProducer accepts every message and keeps it for inspection
+type recordingSyncProducer struct {
+	sarama.SyncProducer
+	mu       sync.Mutex
+	messages []*sarama.ProducerMessage
+}
+
+func (r *recordingSyncProducer) SendMessage(msg *sarama.ProducerMessage) (int32, int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.messages = append(r.messages, msg)
+	return 0, int64(len(r.messages) - 1), nil
+}
+
+func (r *recordingSyncProducer) Close() error {
+	return nil
+}
+
+// createTestDeadLetterProducer returns a producer with a dead-letter queue and
+// a func that drains the queue and returns the letters written to the DLQ topic
+func createTestDeadLetterProducer(t *testing.T, mockProducer *mocks.SyncProducer) (*Producer, func() []DeadLetter) {
+	producer := createTestProducer(t, mockProducer)
+	producer.attempts = 4
+
+	dlqProducer := &recordingSyncProducer{}
+	producer.dlq = startDeadLetterQueue(dlqProducer, config.DeadLetterConfig{
+		Enabled:     true,
+		Topic:       "events.dlq",
+		FallbackDir: t.TempDir(),
+	}, zap.NewNop())
+
+	drain := func() []DeadLetter {
+		require.NoError(t, producer.dlq.close())
+
+		letters := make([]DeadLetter, 0, len(dlqProducer.messages))
+		for _, msg := range dlqProducer.messages {
+			assert.Equal(t, "events.dlq", msg.Topic)
+			value, err := msg.Value.Encode()
+			require.NoError(t, err)
+			var letter DeadLetter
+			require.NoError(t, json.Unmarshal(value, &letter))
+			letters = append(letters, letter)
+		}
+		return letters
+	}
+	return producer, drain
+}
+
+func TestDeadLetter_SerializationFailure(t *testing.T) {
+	producer, drain := createTestDeadLetterProducer(t, mocks.NewSyncProducer(t, nil))
+
+	bad := createTestEvent()
+	bad.Data = map[string]interface{}{"ch": make(chan int)}
+
+	_, _, err := producer.ProduceEvent(context.Background(), bad)
+	require.Error(t, err)
+
+	letters := drain()
+	require.Len(t, letters, 1)
+	assert.Equal(t, bad.ID, letters[0].EventID)
+	assert.Equal(t, DeadLetterSerializationFailed, letters[0].Reason)
+	assert.Equal(t, "test-events", letters[0].OriginalTopic)
+	assert.Equal(t, "test", letters[0].Metadata["env"])
+	assert.Zero(t, letters[0].Attempts)
+	assert.True(t, letters[0].ReportedToClient)
+	assert.Contains(t, letters[0].Error, "failed to serialize event")
+	assert.NotEmpty(t, letters[0].PayloadText)
+	assert.Empty(t, letters[0].Payload)
+}
+
+func TestDeadLetter_RetriesExhausted(t *testing.T) {
+	mockProducer := mocks.NewSyncProducer(t, nil)
+	mockProducer.ExpectSendMessageAndFail(sarama.ErrOutOfBrokers)
+	producer, drain := createTestDeadLetterProducer(t, mockProducer)
+
+	event := createTestEvent()
+	_, _, err := producer.ProduceEvent(context.Background(), event)
+	require.Error(t, err)
+
+	letters := drain()
+	require.Len(t, letters, 1)
+	assert.Equal(t, event.ID, letters[0].EventID)
+	assert.Equal(t, DeadLetterRetriesExhausted, letters[0].Reason)
+	assert.Equal(t, 4, letters[0].Attempts)
+	assert.Equal(t, "user.created", letters[0].EventType)
+	assert.True(t, letters[0].ReportedToClient, "the client was told and may retry")
+
+	// The payload is the event exactly as it would have been produced
+	var payload map[string]interface{}
+	require.NoError(t, json.Unmarshal(letters[0].Payload, &payload))
+	assert.Equal(t, event.ID, payload["id"])
+}
+
+func TestDeadLetter_Rejected(t *testing.T) {
+	mockProducer := mocks.NewSyncProducer(t, nil)
+	mockProducer.ExpectSendMessageAndFail(sarama.ErrMessageSizeTooLarge)
+	producer, drain := createTestDeadLetterProducer(t, mockProducer)
+
+	_, _, err := producer.ProduceEvent(context.Background(), createTestEvent())
+	require.Error(t, err)
+
+	letters := drain()
+	require.Len(t, letters, 1)
+	assert.Equal(t, DeadLetterRejected, letters[0].Reason)
+}
+
+func TestDeadLetter_NotWrittenForSpooledEvents(t *testing.T) {
+	mockProducer := mocks.NewSyncProducer(t, nil)
+	mockProducer.ExpectSendMessageAndFail(sarama.ErrOutOfBrokers)
+	producer, drain := createTestDeadLetterProducer(t, mockProducer)
+
+	spooling := createTestSpoolingProducer(t, mockProducer)
+	producer.spool = spooling.spool
+	producer.config.Spool = spooling.config.Spool
+
+	result := producer.Produce(context.Background(), createTestEvent())
+	require.NoError(t, result.Err)
+	assert.True(t, result.Queued)
+	assert.Empty(t, drain())
+}
+
+func TestDeadLetter_SpoolReplay(t *testing.T) {
+	mockProducer := mocks.NewSyncProducer(t, nil)
+	mockProducer.ExpectSendMessageAndFail(sarama.ErrOutOfBrokers)
+	producer, drain := createTestDeadLetterProducer(t, mockProducer)
+
+	spooling := createTestSpoolingProducer(t, mockProducer)
+	producer.spool = spooling.spool
+	producer.config.Spool = spooling.config.Spool
+
+	event := createTestEvent()
+	require.True(t, producer.Produce(context.Background(), event).Queued)
+
+	// Nobody is waiting for a replayed event, so its letter is safe to re-drive
+	mockProducer.ExpectSendMessageAndFail(sarama.ErrMessageSizeTooLarge)
+	producer.replaySpool(context.Background())
+	assert.Zero(t, producer.Spooled())
+
+	letters := drain()
+	require.Len(t, letters, 1)
+	assert.Equal(t, event.ID, letters[0].EventID)
+	assert.Equal(t, DeadLetterRejected, letters[0].Reason)
+	assert.False(t, letters[0].ReportedToClient)
+}
+
+func TestDeadLetterQueue_FallsBackToDisk(t *testing.T) {
+	dlqProducer := mocks.NewSyncProducer(t, nil)
+	dlqProducer.ExpectSendMessageAndFail(sarama.ErrOutOfBrokers)
+
+	dir := t.TempDir()
+	queue := startDeadLetterQueue(dlqProducer, config.DeadLetterConfig{
+		Enabled:     true,
+		Topic:       "events.dlq",
+		FallbackDir: dir,
+	}, zap.NewNop())
+
+	queue.Send(&DeadLetter{EventID: "event-1", Reason: DeadLetterRetriesExhausted, Error: "kafka down"})
+	require.NoError(t, queue.close())
+
+	// Letters sent after close are written to disk directly
+	queue.Send(&DeadLetter{EventID: "event-2", Reason: DeadLetterRejected, Error: "too large"})
+
+	files, err := filepath.Glob(filepath.Join(dir, "dead-letters-*.jsonl"))
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+
+	f, err := os.Open(files[0])
+	require.NoError(t, err)
+	defer f.Close()
+
+	var ids []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var letter DeadLetter
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &letter))
+		ids = append(ids, letter.EventID)
+	}
+	assert.Equal(t, []string{"event-1", "event-2"}, ids)
+}
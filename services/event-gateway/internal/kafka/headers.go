@@ -0,0 +1,250 @@
+package kafka
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/IBM/sarama"
+	"github.com/distributed-event-processor/services/event-gateway/internal/cloudevents"
+	"github.com/distributed-event-processor/services/event-gateway/internal/config"
+	"github.com/distributed-event-processor/services/event-gateway/internal/models"
+)
+
+// Header mappings
+const (
+	HeaderMappingDefault     = "default"
+	HeaderMappingCloudEvents = "cloudevents"
+)
+
+// W3C trace context headers
+const (
+	traceParentHeader = "traceparent"
+	traceStateHeader  = "tracestate"
+)
+
+// cloudEventsSpecVersion is sent as ce_specversion with the cloudevents mapping
+const cloudEventsSpecVersion = cloudevents.SpecVersion
+
+// headerField extracts one envelope field from an event; fields that resolve
+// to empty are left out of the headers
+type headerField struct {
+	name  string
+	value func(event *models.Event) string
+}
+
+// headerFields lists the envelope fields in the order they are written
+var headerFields = []headerField{
+	{"id", func(e *models.Event) string { return e.ID }},
+	{"type", func(e *models.Event) string { return e.Type }},
+	{"source", func(e *models.Event) string { return e.Source }},
+	{"subject", func(e *models.Event) string { return e.Subject }},
+	{"time", func(e *models.Event) string {
+		if e.Timestamp.IsZero() {
+			return ""
+		}
+		return e.Timestamp.UTC().Format(time.RFC3339Nano)
+	}},
+	{"tenant_id", func(e *models.Event) string { return e.TenantID }},
+	{"correlation_id", func(e *models.Event) string { return e.CorrelationID }},
+	{"version", func(e *models.Event) string { return e.Version }},
+	{"schema_version", func(e *models.Event) string { return e.SchemaVersion }},
+	{"data_schema", func(e *models.Event) string { return e.DataSchema }},
+	{"priority", func(e *models.Event) string { return strconv.Itoa(e.Priority) }},
+	{"request_id", func(e *models.Event) string { return e.Metadata[models.MetadataRequestID] }},
+}
+
+// headerMappings give the header name of each field; fields missing from a
+// mapping are not sent. The time is already the record timestamp, so only
+// CloudEvents consumers get it as a header.
+var headerMappings = map[string]map[string]string{
+	HeaderMappingDefault: {
+		"id":             "event_id",
+		"type":           "event_type",
+		"source":         "source",
+		"subject":        "subject",
+		"tenant_id":      "tenant_id",
+		"correlation_id": "correlation_id",
+		"version":        "version",
+		"schema_version": "schema_version",
+		"data_schema":    "data_schema",
+		"priority":       "priority",
+		"request_id":     "request_id",
+	},
+	HeaderMappingCloudEvents: {
+		"id":             "ce_id",
+		"type":           "ce_type",
+		"source":         "ce_source",
+		"subject":        "ce_subject",
+		"time":           "ce_time",
+		"tenant_id":      "ce_tenantid",
+		"correlation_id": "ce_correlationid",
+		"version":        "ce_version",
+		"schema_version": "ce_schemaversion",
+		"data_schema":    "ce_dataschema",
+		"priority":       "ce_priority",
+		"request_id":     "ce_requestid",
+	},
+}
+
+type mappedHeader struct {
+	key   []byte
+	value func(event *models.Event) string
+}
+
+// headerMapper builds the record headers that expose an event's envelope, so
+// consumers can filter and route without deserializing the value
+type headerMapper struct {
+	fields       []mappedHeader
+	specVersion  bool
+	traceContext bool
+	metadata     []string
+	prefix       string
+}
+
+// defaultHeaders is used by producers created without a header configuration
+var defaultHeaders, _ = newHeaderMapper(config.HeadersConfig{Mapping: HeaderMappingDefault})
+
+func newHeaderMapper(cfg config.HeadersConfig) (*headerMapper, error) {
+	mapping := cfg.Mapping
+	if mapping == "" {
+		mapping = HeaderMappingDefault
+	}
+	names, ok := headerMappings[mapping]
+	if !ok {
+		return nil, fmt.Errorf("unknown header mapping: %s (expected: %s or %s)", mapping, HeaderMappingDefault, HeaderMappingCloudEvents)
+	}
+
+	known := make(map[string]struct{}, len(headerFields))
+	for _, field := range headerFields {
+		known[field.name] = struct{}{}
+	}
+	for field := range cfg.Names {
+		if _, ok := known[field]; !ok {
+			return nil, fmt.Errorf("headers.names: unknown event field %s", field)
+		}
+	}
+
+	mapper := &headerMapper{
+		specVersion:  mapping == HeaderMappingCloudEvents,
+		traceContext: cfg.TraceContext,
+		prefix:       cfg.MetadataPrefix,
+	}
+
+	for _, field := range headerFields {
+		name := names[field.name]
+		if override, ok := cfg.Names[field.name]; ok {
+			name = override
+		}
+		if name == "" {
+			continue
+		}
+		value := field.value
+		if mapping == HeaderMappingCloudEvents && field.name == "id" {
+			// CloudEvents consumers get the id the event was received with
+			value = cloudevents.ID
+		}
+		mapper.fields = append(mapper.fields, mappedHeader{key: []byte(name), value: value})
+	}
+
+	for _, key := range cfg.MetadataAllowList {
+		if key == "" {
+			return nil, errors.New("headers.metadata_allow_list contains an empty key")
+		}
+		mapper.metadata = append(mapper.metadata, key)
+	}
+
+	return mapper, nil
+}
+
+// Headers returns the envelope headers for event. The content type is added
+// by the caller, since it depends on the topic's serializer.
+func (m *headerMapper) Headers(event *models.Event) []sarama.RecordHeader {
+	headers := make([]sarama.RecordHeader, 0, len(m.fields)+len(m.metadata)+3)
+
+	if m.specVersion {
+		headers = append(headers, sarama.RecordHeader{Key: []byte("ce_specversion"), Value: []byte(cloudEventsSpecVersion)})
+	}
+
+	for _, field := range m.fields {
+		if value := field.value(event); value != "" {
+			headers = append(headers, sarama.RecordHeader{Key: field.key, Value: []byte(value)})
+		}
+	}
+
+	if m.traceContext {
+		parent := event.Metadata[models.MetadataTraceParent]
+		traceParent, continued := childTraceParent(parent)
+		headers = append(headers, sarama.RecordHeader{Key: []byte(traceParentHeader), Value: []byte(traceParent)})
+		if state := event.Metadata[models.MetadataTraceState]; continued && state != "" {
+			headers = append(headers, sarama.RecordHeader{Key: []byte(traceStateHeader), Value: []byte(state)})
+		}
+	}
+
+	for _, key := range m.metadata {
+		if value, ok := event.Metadata[key]; ok {
+			headers = append(headers, sarama.RecordHeader{Key: []byte(m.prefix + key), Value: []byte(value)})
+		}
+	}
+
+	return headers
+}
+
+// childTraceParent returns the W3C traceparent for producing an event. A valid
+// parent is continued in a new span of the same trace; otherwise a new sampled
+// trace is started. continued reports whether parent was used.
+func childTraceParent(parent string) (traceParent string, continued bool) {
+	traceID, flags, ok := parseTraceParent(parent)
+	if !ok {
+		traceID, flags = randomHex(16), "01"
+	}
+	return "00-" + traceID + "-" + randomHex(8) + "-" + flags, ok
+}
+
+// parseTraceParent validates a version-00 traceparent and returns its trace ID
+// and flags. Later versions are accepted as long as they start with the
+// version-00 fields.
+func parseTraceParent(value string) (traceID, flags string, ok bool) {
+	parts := strings.Split(strings.TrimSpace(value), "-")
+	if len(parts) < 4 {
+		return "", "", false
+	}
+	version, traceID, spanID, flags := parts[0], parts[1], parts[2], parts[3]
+
+	if !isLowerHex(version, 2) || version == "ff" || (version == "00" && len(parts) != 4) {
+		return "", "", false
+	}
+	if !isLowerHex(traceID, 32) || traceID == strings.Repeat("0", 32) {
+		return "", "", false
+	}
+	if !isLowerHex(spanID, 16) || spanID == strings.Repeat("0", 16) {
+		return "", "", false
+	}
+	if !isLowerHex(flags, 2) {
+		return "", "", false
+	}
+	return traceID, flags, true
+}
+
+func isLowerHex(s string, length int) bool {
+	if len(s) != length {
+		return false
+	}
+	for _, c := range s {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// randomHex returns n random bytes as lowercase hex
+func randomHex(n int) string {
+	buf := make([]byte, n)
+	rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
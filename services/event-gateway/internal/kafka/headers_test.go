@@ -0,0 +1,164 @@
+package kafka
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/IBM/sarama"
+	"github.com/distributed-event-processor/services/event-gateway/internal/cloudevents"
+	"github.com/distributed-event-processor/services/event-gateway/internal/config"
+	"github.com/distributed-event-processor/services/event-gateway/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testTraceParent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+func headerMap(headers []sarama.RecordHeader) map[string]string {
+	values := make(map[string]string, len(headers))
+	for _, header := range headers {
+		values[string(header.Key)] = string(header.Value)
+	}
+	return values
+}
+
+func headerTestEvent() *models.Event {
+	return &models.Event{
+		ID:            "event-1",
+		Type:          "order.created",
+		Source:        "orders",
+		TenantID:      "acme",
+		CorrelationID: "corr-1",
+		SchemaVersion: "1.2.0",
+		Priority:      7,
+		Timestamp:     time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+		Metadata: map[string]string{
+			models.MetadataRequestID:   "request-1",
+			models.MetadataTraceParent: testTraceParent,
+			models.MetadataTraceState:  "vendor=value",
+			"region":                   "eu-west-1",
+			"client_ip":                "10.0.0.1",
+		},
+	}
+}
+
+func TestHeaderMapper_Default(t *testing.T) {
+	mapper, err := newHeaderMapper(config.HeadersConfig{})
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string]string{
+		"event_id":       "event-1",
+		"event_type":     "order.created",
+		"source":         "orders",
+		"tenant_id":      "acme",
+		"correlation_id": "corr-1",
+		"schema_version": "1.2.0",
+		"priority":       "7",
+		"request_id":     "request-1",
+	}, headerMap(mapper.Headers(headerTestEvent())))
+}
+
+func TestHeaderMapper_CloudEvents(t *testing.T) {
+	mapper, err := newHeaderMapper(config.HeadersConfig{Mapping: HeaderMappingCloudEvents})
+	require.NoError(t, err)
+
+	headers := headerMap(mapper.Headers(headerTestEvent()))
+	assert.Equal(t, "1.0", headers["ce_specversion"])
+	assert.Equal(t, "event-1", headers["ce_id"])
+	assert.Equal(t, "order.created", headers["ce_type"])
+	assert.Equal(t, "orders", headers["ce_source"])
+	assert.Equal(t, "2026-01-02T03:04:05Z", headers["ce_time"])
+	assert.Equal(t, "acme", headers["ce_tenantid"])
+	assert.Equal(t, "7", headers["ce_priority"])
+	assert.NotContains(t, headers, "event_id")
+
+	// A CloudEvent keeps the id it was received with
+	event := headerTestEvent()
+	event.Metadata[cloudevents.MetadataID] = "a/b"
+	assert.Equal(t, "a/b", headerMap(mapper.Headers(event))["ce_id"])
+}
+
+func TestHeaderMapper_Names(t *testing.T) {
+	mapper, err := newHeaderMapper(config.HeadersConfig{
+		Names: map[string]string{"tenant_id": "x-tenant", "request_id": ""},
+	})
+	require.NoError(t, err)
+
+	headers := headerMap(mapper.Headers(headerTestEvent()))
+	assert.Equal(t, "acme", headers["x-tenant"])
+	assert.NotContains(t, headers, "tenant_id")
+	assert.NotContains(t, headers, "request_id")
+}
+
+func TestHeaderMapper_MetadataAllowList(t *testing.T) {
+	mapper, err := newHeaderMapper(config.HeadersConfig{
+		MetadataAllowList: []string{"region", "missing"},
+		MetadataPrefix:    "meta_",
+	})
+	require.NoError(t, err)
+
+	headers := headerMap(mapper.Headers(headerTestEvent()))
+	assert.Equal(t, "eu-west-1", headers["meta_region"])
+	assert.NotContains(t, headers, "meta_missing")
+	assert.NotContains(t, headers, "meta_client_ip")
+	assert.NotContains(t, headers, "client_ip")
+}
+
+func TestHeaderMapper_TraceContext(t *testing.T) {
+	mapper, err := newHeaderMapper(config.HeadersConfig{TraceContext: true})
+	require.NoError(t, err)
+
+	headers := headerMap(mapper.Headers(headerTestEvent()))
+	traceID, flags, ok := parseTraceParent(headers[traceParentHeader])
+	require.True(t, ok)
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", traceID)
+	assert.Equal(t, "01", flags)
+	assert.NotEqual(t, testTraceParent, headers[traceParentHeader], "the producer span gets its own ID")
+	assert.Equal(t, "vendor=value", headers[traceStateHeader])
+
+	// Without a valid parent a new trace is started and tracestate is dropped
+	event := headerTestEvent()
+	event.Metadata[models.MetadataTraceParent] = "garbage"
+	headers = headerMap(mapper.Headers(event))
+	traceID, _, ok = parseTraceParent(headers[traceParentHeader])
+	require.True(t, ok)
+	assert.NotEqual(t, "4bf92f3577b34da6a3ce929d0e0e4736", traceID)
+	assert.NotContains(t, headers, traceStateHeader)
+}
+
+func TestParseTraceParent(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		valid bool
+	}{
+		{"valid", testTraceParent, true},
+		{"future version with extra fields", "01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra", true},
+		{"empty", "", false},
+		{"version ff", "ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", false},
+		{"extra fields in version 00", testTraceParent + "-extra", false},
+		{"zero trace ID", "00-" + strings.Repeat("0", 32) + "-00f067aa0ba902b7-01", false},
+		{"zero span ID", "00-4bf92f3577b34da6a3ce929d0e0e4736-" + strings.Repeat("0", 16) + "-01", false},
+		{"uppercase", strings.ToUpper(testTraceParent), false},
+		{"short trace ID", "00-4bf92f35-00f067aa0ba902b7-01", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, ok := parseTraceParent(tt.value)
+			assert.Equal(t, tt.valid, ok)
+		})
+	}
+}
+
+func TestNewHeaderMapper_InvalidConfig(t *testing.T) {
+	_, err := newHeaderMapper(config.HeadersConfig{Mapping: "kafka-connect"})
+	assert.Error(t, err)
+
+	_, err = newHeaderMapper(config.HeadersConfig{Names: map[string]string{"payload": "x"}})
+	assert.Error(t, err)
+
+	_, err = newHeaderMapper(config.HeadersConfig{MetadataAllowList: []string{""}})
+	assert.Error(t, err)
+}
@@ -0,0 +1,331 @@
+package kafka
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/IBM/sarama"
+	"github.com/distributed-event-processor/services/event-gateway/internal/config"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// HealthStatus is the result of the most recent Kafka health check. Details
+// holds per-broker and per-topic findings suitable for health endpoints.
+type HealthStatus struct {
+	Healthy   bool
+	Message   string
+	LastCheck time.Time
+	Details   map[string]string
+}
+
+// healthProber periodically checks that the cluster is reachable and that every
+// destination topic has a leader for each partition, optionally round-tripping
+// a canary message. Results are cached so health endpoints never block on Kafka.
+type healthProber struct {
+	cluster  string
+	client   sarama.Client
+	topics   []string
+	interval time.Duration
+	timeout  time.Duration
+	logger   *zap.Logger
+
+	canaryTopic    string
+	canaryProducer sarama.SyncProducer
+	canaryConsumer sarama.Consumer
+
+	mu     sync.RWMutex
+	status HealthStatus
+
+	stop chan struct{}
+	wg   sync.WaitGroup
+}
+
+// newHealthConfig derives the prober's client config from the producer's, with
+// timeouts short enough that a check never outlives its interval
+func newHealthConfig(cfg config.KafkaConfig, saramaConfig *sarama.Config) *sarama.Config {
+	timeout := time.Duration(cfg.Health.TimeoutMs) * time.Millisecond
+
+	healthConfig := newAuxiliaryConfig(saramaConfig, "health")
+	healthConfig.Net.DialTimeout = timeout
+	healthConfig.Net.ReadTimeout = timeout
+	healthConfig.Net.WriteTimeout = timeout
+	healthConfig.Metadata.Timeout = timeout
+	healthConfig.Metadata.Retry.Max = 0
+	healthConfig.Producer.Retry.Max = 0
+	return healthConfig
+}
+
+func newHealthProber(cfg config.KafkaConfig, saramaConfig *sarama.Config, topics []string, logger *zap.Logger) (*healthProber, error) {
+	if cfg.Health.CheckIntervalMs <= 0 || cfg.Health.TimeoutMs <= 0 {
+		return nil, errors.New("kafka health check_interval_ms and timeout_ms must be positive")
+	}
+	if cfg.Health.Canary && cfg.Health.CanaryTopic == "" {
+		return nil, errors.New("kafka health canary_topic is required when the canary is enabled")
+	}
+
+	client, err := sarama.NewClient(cfg.Brokers, newHealthConfig(cfg, saramaConfig))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Kafka health client: %w", err)
+	}
+
+	h := &healthProber{
+		cluster:  clusterName(cfg),
+		client:   client,
+		topics:   topics,
+		interval: time.Duration(cfg.Health.CheckIntervalMs) * time.Millisecond,
+		timeout:  time.Duration(cfg.Health.TimeoutMs) * time.Millisecond,
+		logger:   logger,
+		status:   HealthStatus{Message: "Kafka health has not been checked yet"},
+		stop:     make(chan struct{}),
+	}
+
+	if cfg.Health.Canary {
+		h.canaryTopic = cfg.Health.CanaryTopic
+		if h.canaryProducer, err = sarama.NewSyncProducerFromClient(client); err != nil {
+			client.Close()
+			return nil, fmt.Errorf("failed to create Kafka canary producer: %w", err)
+		}
+		if h.canaryConsumer, err = sarama.NewConsumerFromClient(client); err != nil {
+			h.canaryProducer.Close()
+			client.Close()
+			return nil, fmt.Errorf("failed to create Kafka canary consumer: %w", err)
+		}
+	}
+
+	return h, nil
+}
+
+// start runs a check immediately and then on every interval until close
+func (h *healthProber) start() {
+	h.wg.Add(1)
+	go func() {
+		defer h.wg.Done()
+
+		h.refresh()
+
+		ticker := time.NewTicker(h.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-h.stop:
+				return
+			case <-ticker.C:
+				h.refresh()
+			}
+		}
+	}()
+}
+
+func (h *healthProber) refresh() {
+	status := h.check()
+
+	h.mu.Lock()
+	wasHealthy := h.status.Healthy
+	h.status = status
+	h.mu.Unlock()
+
+	if status.Healthy {
+		kafkaHealthy.WithLabelValues(h.cluster).Set(1)
+	} else {
+		kafkaHealthy.WithLabelValues(h.cluster).Set(0)
+	}
+
+	if status.Healthy != wasHealthy {
+		if status.Healthy {
+			h.logger.Info("Kafka health check recovered")
+		} else {
+			h.logger.Warn("Kafka health check failed", zap.String("reason", status.Message))
+		}
+	}
+}
+
+// Status returns the cached result of the last check. A result older than three
+// check intervals is reported as unhealthy, since the prober itself is stuck.
+func (h *healthProber) Status() HealthStatus {
+	h.mu.RLock()
+	status := h.status
+	h.mu.RUnlock()
+
+	details := make(map[string]string, len(status.Details))
+	for k, v := range status.Details {
+		details[k] = v
+	}
+	status.Details = details
+
+	if status.Healthy && time.Since(status.LastCheck) > 3*h.interval {
+		status.Healthy = false
+		status.Message = "Kafka health check is stale"
+	}
+	return status
+}
+
+// check performs a full health check against the cluster
+func (h *healthProber) check() HealthStatus {
+	status := HealthStatus{
+		LastCheck: time.Now().UTC(),
+		Details:   make(map[string]string),
+	}
+
+	if err := h.client.RefreshMetadata(); err != nil {
+		status.Message = fmt.Sprintf("failed to refresh Kafka metadata: %v", err)
+		return status
+	}
+
+	reachable := h.checkBrokers(status.Details)
+	if reachable == 0 {
+		status.Message = "no Kafka brokers are reachable"
+		return status
+	}
+
+	if controller, err := h.client.Controller(); err == nil {
+		status.Details["controller"] = strconv.Itoa(int(controller.ID()))
+	}
+
+	var problems []string
+	for _, topic := range h.topics {
+		if problem := h.checkTopic(topic, status.Details); problem != "" {
+			problems = append(problems, problem)
+		}
+	}
+
+	if h.canaryProducer != nil {
+		start := time.Now()
+		if err := h.roundTripCanary(); err != nil {
+			status.Details["canary"] = "failed: " + err.Error()
+			problems = append(problems, "canary round trip failed")
+		} else {
+			status.Details["canary"] = "ok"
+			status.Details["canary_latency_ms"] = strconv.FormatInt(time.Since(start).Milliseconds(), 10)
+		}
+	}
+
+	if len(problems) > 0 {
+		status.Message = problems[0]
+		if len(problems) > 1 {
+			status.Message += fmt.Sprintf(" (and %d more problems)", len(problems)-1)
+		}
+		return status
+	}
+
+	status.Healthy = true
+	status.Message = "Kafka cluster is reachable"
+	return status
+}
+
+// checkBrokers records the connection state of every known broker and returns
+// how many of them are reachable
+func (h *healthProber) checkBrokers(details map[string]string) int {
+	brokers := h.client.Brokers()
+	reachable := 0
+
+	for _, broker := range brokers {
+		key := fmt.Sprintf("broker.%d", broker.ID())
+
+		connected, err := broker.Connected()
+		if !connected {
+			if openErr := broker.Open(h.client.Config()); openErr != nil && !errors.Is(openErr, sarama.ErrAlreadyConnected) {
+				err = openErr
+			} else {
+				// Connected blocks until the connection attempt started by Open completes
+				connected, err = broker.Connected()
+			}
+		}
+
+		if connected {
+			reachable++
+			details[key] = broker.Addr() + " up"
+			continue
+		}
+
+		state := broker.Addr() + " down"
+		if err != nil {
+			state += ": " + err.Error()
+		}
+		details[key] = state
+	}
+
+	details["brokers_total"] = strconv.Itoa(len(brokers))
+	details["brokers_reachable"] = strconv.Itoa(reachable)
+	return reachable
+}
+
+// checkTopic verifies that a topic exists and that every partition has a leader,
+// returning a description of the problem if not
+func (h *healthProber) checkTopic(topic string, details map[string]string) string {
+	key := "topic." + topic
+
+	partitions, err := h.client.Partitions(topic)
+	if err != nil || len(partitions) == 0 {
+		details[key] = "missing"
+		return fmt.Sprintf("topic %s does not exist", topic)
+	}
+
+	leaderless := 0
+	for _, partition := range partitions {
+		if _, err := h.client.Leader(topic, partition); err != nil {
+			leaderless++
+		}
+	}
+
+	if leaderless > 0 {
+		details[key] = fmt.Sprintf("%d of %d partitions without leader", leaderless, len(partitions))
+		return fmt.Sprintf("topic %s has partitions without a leader", topic)
+	}
+
+	details[key] = fmt.Sprintf("ok (%d partitions)", len(partitions))
+	return ""
+}
+
+// roundTripCanary produces a unique message to the health topic and reads it back
+func (h *healthProber) roundTripCanary() error {
+	payload := []byte("canary-" + uuid.New().String())
+
+	partition, offset, err := h.canaryProducer.SendMessage(&sarama.ProducerMessage{
+		Topic: h.canaryTopic,
+		Value: sarama.ByteEncoder(payload),
+	})
+	if err != nil {
+		return fmt.Errorf("produce: %w", err)
+	}
+
+	consumer, err := h.canaryConsumer.ConsumePartition(h.canaryTopic, partition, offset)
+	if err != nil {
+		return fmt.Errorf("consume: %w", err)
+	}
+	defer consumer.Close()
+
+	timer := time.NewTimer(h.timeout)
+	defer timer.Stop()
+
+	for {
+		select {
+		case message := <-consumer.Messages():
+			if bytes.Equal(message.Value, payload) {
+				return nil
+			}
+		case consumerErr := <-consumer.Errors():
+			return fmt.Errorf("consume: %w", consumerErr.Err)
+		case <-timer.C:
+			return errors.New("timed out waiting for canary message")
+		}
+	}
+}
+
+func (h *healthProber) close() {
+	close(h.stop)
+	h.wg.Wait()
+
+	if h.canaryConsumer != nil {
+		h.canaryConsumer.Close()
+	}
+	if h.canaryProducer != nil {
+		h.canaryProducer.Close()
+	}
+	h.client.Close()
+}
@@ -0,0 +1,132 @@
+package kafka
+
+import (
+	"testing"
+	"time"
+
+	"github.com/IBM/sarama"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func createTestHealthProber(t *testing.T, broker *sarama.MockBroker, topics ...string) *healthProber {
+	cfg := validKafkaConfig()
+	cfg.Brokers = []string{broker.Addr()}
+	cfg.Health.CheckIntervalMs = 1000
+	cfg.Health.TimeoutMs = 1000
+
+	saramaConfig, err := newSaramaConfig(cfg, zap.NewNop())
+	require.NoError(t, err)
+
+	prober, err := newHealthProber(cfg, saramaConfig, topics, zap.NewNop())
+	require.NoError(t, err)
+	// Registered after the broker's cleanup so that the prober disconnects first
+	t.Cleanup(prober.close)
+	return prober
+}
+
+func TestHealthProber_Healthy(t *testing.T) {
+	broker := sarama.NewMockBroker(t, 1)
+	t.Cleanup(broker.Close)
+	broker.SetHandlerByMap(map[string]sarama.MockResponse{
+		"ApiVersionsRequest": sarama.NewMockApiVersionsResponse(t),
+		"MetadataRequest": sarama.NewMockMetadataResponse(t).
+			SetBroker(broker.Addr(), broker.BrokerID()).
+			SetController(broker.BrokerID()).
+			SetLeader("events", 0, broker.BrokerID()).
+			SetLeader("events", 1, broker.BrokerID()),
+	})
+
+	prober := createTestHealthProber(t, broker, "events")
+	prober.refresh()
+
+	status := prober.Status()
+	assert.True(t, status.Healthy, status.Message)
+	assert.False(t, status.LastCheck.IsZero())
+	assert.Equal(t, "1", status.Details["brokers_total"])
+	assert.Equal(t, "1", status.Details["brokers_reachable"])
+	assert.Equal(t, broker.Addr()+" up", status.Details["broker.1"])
+	assert.Equal(t, "1", status.Details["controller"])
+	assert.Equal(t, "ok (2 partitions)", status.Details["topic.events"])
+}
+
+func TestHealthProber_MissingTopic(t *testing.T) {
+	broker := sarama.NewMockBroker(t, 1)
+	t.Cleanup(broker.Close)
+	broker.SetHandlerByMap(map[string]sarama.MockResponse{
+		"ApiVersionsRequest": sarama.NewMockApiVersionsResponse(t),
+		"MetadataRequest": sarama.NewMockMetadataResponse(t).
+			SetBroker(broker.Addr(), broker.BrokerID()).
+			SetLeader("events", 0, broker.BrokerID()),
+	})
+
+	prober := createTestHealthProber(t, broker, "events", "events.payments")
+	prober.refresh()
+
+	status := prober.Status()
+	assert.False(t, status.Healthy)
+	assert.Contains(t, status.Message, "events.payments")
+	assert.Equal(t, "missing", status.Details["topic.events.payments"])
+	assert.Equal(t, "ok (1 partitions)", status.Details["topic.events"])
+}
+
+func TestHealthProber_BrokersUnreachable(t *testing.T) {
+	broker := sarama.NewMockBroker(t, 1)
+	broker.SetHandlerByMap(map[string]sarama.MockResponse{
+		"ApiVersionsRequest": sarama.NewMockApiVersionsResponse(t),
+		"MetadataRequest": sarama.NewMockMetadataResponse(t).
+			SetBroker(broker.Addr(), broker.BrokerID()).
+			SetLeader("events", 0, broker.BrokerID()),
+	})
+
+	prober := createTestHealthProber(t, broker, "events")
+	prober.refresh()
+	require.True(t, prober.Status().Healthy)
+
+	broker.Close()
+	prober.refresh()
+
+	status := prober.Status()
+	assert.False(t, status.Healthy)
+	assert.NotEmpty(t, status.Message)
+}
+
+func TestHealthProber_StaleResult(t *testing.T) {
+	prober := &healthProber{
+		interval: time.Second,
+		status: HealthStatus{
+			Healthy:   true,
+			LastCheck: time.Now().Add(-time.Minute),
+			Details:   map[string]string{"brokers_reachable": "1"},
+		},
+	}
+
+	status := prober.Status()
+	assert.False(t, status.Healthy)
+	assert.Equal(t, "Kafka health check is stale", status.Message)
+	assert.Equal(t, "1", status.Details["brokers_reachable"])
+}
+
+func TestNewHealthProber_InvalidConfig(t *testing.T) {
+	cfg := validKafkaConfig()
+	saramaConfig, err := newSaramaConfig(cfg, zap.NewNop())
+	require.NoError(t, err)
+
+	_, err = newHealthProber(cfg, saramaConfig, []string{"events"}, zap.NewNop())
+	assert.Error(t, err)
+
+	cfg.Health.CheckIntervalMs = 1000
+	cfg.Health.TimeoutMs = 1000
+	cfg.Health.Canary = true
+	_, err = newHealthProber(cfg, saramaConfig, []string{"events"}, zap.NewNop())
+	assert.Error(t, err)
+}
+
+func TestProducer_HealthWithoutProber(t *testing.T) {
+	producer := &Producer{logger: zap.NewNop()}
+	status := producer.Health()
+
+	assert.False(t, status.Healthy)
+	assert.Equal(t, "Kafka producer is not initialized", status.Message)
+}
@@ -0,0 +1,443 @@
+package kafka
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/distributed-event-processor/services/event-gateway/internal/config"
+	"github.com/distributed-event-processor/services/event-gateway/internal/models"
+	"go.uber.org/zap"
+)
+
+// Load shedding modes
+const (
+	ShedReject = "reject"
+	ShedDrop   = "drop"
+)
+
+// shedRetryAfter is the delay suggested to clients whose events were shed
+const shedRetryAfter = time.Second
+
+// defaultLanes are used when priority lanes are enabled without a lane list.
+// Bulk traffic is shed first, and critical events only once their own queue
+// is full.
+var defaultLanes = []config.LaneConfig{
+	{Name: "critical", MinPriority: 8, QueueSize: 1000, Workers: 4, ShedThreshold: 1},
+	{Name: "normal", MinPriority: 3, QueueSize: 5000, Workers: 4, ShedThreshold: 0.9},
+	{Name: "bulk", MinPriority: 0, QueueSize: 5000, Workers: 2, ShedThreshold: 0.6},
+}
+
+// LoadShedError is returned for events a priority lane refused under load.
+// RetryAfter is how long clients should wait before sending them again.
+type LoadShedError struct {
+	Lane       string
+	RetryAfter time.Duration
+}
+
+func (e *LoadShedError) Error() string {
+	return fmt.Sprintf("ingestion lane %s is overloaded, retry after %s", e.Lane, e.RetryAfter)
+}
+
+// RetryAfterSeconds rounds RetryAfter up to whole seconds for Retry-After headers
+func (e *LoadShedError) RetryAfterSeconds() int {
+	return retryAfterSeconds(e.RetryAfter)
+}
+
+// lane is one priority band with its own queue and workers
+type lane struct {
+	name        string
+	minPriority int
+	maxPriority int
+	workers     int
+	threshold   float64
+	topic       string
+	queue       chan *laneJob
+}
+
+// laneJob is a single event or a whole batch waiting in a lane. deliver
+// receives one result per event.
+type laneJob struct {
+	ctx      context.Context
+	events   []*models.Event
+	batch    bool
+	enqueued time.Time
+	deliver  func([]DeliveryResult)
+}
+
+// newLanes validates the lane configuration and orders the lanes from the
+// highest priority band to the lowest
+func newLanes(cfg config.PriorityLanesConfig) ([]*lane, error) {
+	configs := cfg.Lanes
+	if len(configs) == 0 {
+		configs = defaultLanes
+	}
+	configs = append([]config.LaneConfig(nil), configs...)
+	sort.SliceStable(configs, func(i, j int) bool {
+		return configs[i].MinPriority > configs[j].MinPriority
+	})
+
+	lanes := make([]*lane, 0, len(configs))
+	names := make(map[string]struct{}, len(configs))
+	for i, lc := range configs {
+		if lc.Name == "" {
+			return nil, fmt.Errorf("priority lane %d: name is required", i)
+		}
+		if _, ok := names[lc.Name]; ok {
+			return nil, fmt.Errorf("priority lane %s is defined twice", lc.Name)
+		}
+		names[lc.Name] = struct{}{}
+
+		if lc.MinPriority < 0 || lc.MinPriority > 10 {
+			return nil, fmt.Errorf("priority lane %s: min_priority must be between 0 and 10", lc.Name)
+		}
+		if lc.QueueSize <= 0 || lc.Workers <= 0 {
+			return nil, fmt.Errorf("priority lane %s: queue_size and workers must be positive", lc.Name)
+		}
+		threshold := lc.ShedThreshold
+		if threshold == 0 {
+			threshold = 1
+		}
+		if threshold < 0 || threshold > 1 {
+			return nil, fmt.Errorf("priority lane %s: shed_threshold must be between 0 and 1", lc.Name)
+		}
+
+		maxPriority := 10
+		if i > 0 {
+			above := lanes[i-1]
+			if lc.MinPriority == above.minPriority {
+				return nil, fmt.Errorf("priority lanes %s and %s have the same min_priority", above.name, lc.Name)
+			}
+			maxPriority = above.minPriority - 1
+		}
+
+		lanes = append(lanes, &lane{
+			name:        lc.Name,
+			minPriority: lc.MinPriority,
+			maxPriority: maxPriority,
+			workers:     lc.Workers,
+			threshold:   threshold,
+			topic:       lc.Topic,
+			queue:       make(chan *laneJob, lc.QueueSize),
+		})
+	}
+
+	if lowest := lanes[len(lanes)-1]; lowest.minPriority != 0 {
+		return nil, fmt.Errorf("priority lane %s: the lowest lane must have min_priority 0", lowest.name)
+	}
+	return lanes, nil
+}
+
+// laneRoutingRules turns the lanes that have their own topic into routing
+// rules, which go ahead of the configured rules
+func laneRoutingRules(lanes []*lane) []config.RoutingRule {
+	var rules []config.RoutingRule
+	for _, ln := range lanes {
+		if ln.topic == "" {
+			continue
+		}
+		minPriority, maxPriority := ln.minPriority, ln.maxPriority
+		rules = append(rules, config.RoutingRule{
+			Name:        "lane-" + ln.name,
+			MinPriority: &minPriority,
+			MaxPriority: &maxPriority,
+			Topic:       ln.topic,
+		})
+	}
+	return rules
+}
+
+// PriorityLanes is an EventPublisher that queues events by priority before
+// handing them to the publisher behind it, so bulk traffic cannot hold up
+// critical events. Each lane has a bounded queue drained by its own workers.
+// Under load, lanes shed new events from the lowest priority up: a lane sheds
+// once its queue is full, or once all queues together reach its threshold.
+type PriorityLanes struct {
+	next     EventPublisher
+	lanes    []*lane
+	capacity int
+	shedMode string
+	logger   *zap.Logger
+
+	// closeMu guards queueing against a concurrent Close
+	closeMu sync.RWMutex
+	closed  bool
+	wg      sync.WaitGroup
+}
+
+// newPriorityLanes starts the workers of lanes, which send to next
+func newPriorityLanes(lanes []*lane, shedMode string, next EventPublisher, logger *zap.Logger) (*PriorityLanes, error) {
+	switch shedMode {
+	case ShedReject, ShedDrop:
+	case "":
+		shedMode = ShedReject
+	default:
+		return nil, fmt.Errorf("unknown priority_lanes shed_mode: %s (expected: %s or %s)", shedMode, ShedReject, ShedDrop)
+	}
+
+	l := &PriorityLanes{
+		next:     next,
+		lanes:    lanes,
+		shedMode: shedMode,
+		logger:   logger,
+	}
+	for _, ln := range lanes {
+		l.capacity += cap(ln.queue)
+		laneDepth.WithLabelValues(ln.name).Set(0)
+		for range ln.workers {
+			l.wg.Add(1)
+			go l.work(ln)
+		}
+		logger.Info("Priority lane started",
+			zap.String("lane", ln.name),
+			zap.Int("min_priority", ln.minPriority),
+			zap.Int("max_priority", ln.maxPriority),
+			zap.Int("queue_size", cap(ln.queue)),
+			zap.Int("workers", ln.workers),
+			zap.Float64("shed_threshold", ln.threshold))
+	}
+	return l, nil
+}
+
+// laneFor returns the lane of an event priority. Priorities below every
+// lane's minimum go to the lowest lane.
+func (l *PriorityLanes) laneFor(priority int) *lane {
+	for _, ln := range l.lanes {
+		if priority >= ln.minPriority {
+			return ln
+		}
+	}
+	return l.lanes[len(l.lanes)-1]
+}
+
+// Produce queues an event in its lane and waits for the outcome
+func (l *PriorityLanes) Produce(ctx context.Context, event *models.Event) DeliveryResult {
+	select {
+	case result := <-l.ProduceEventAsync(ctx, event):
+		return result
+	case <-ctx.Done():
+		return DeliveryResult{Err: ctx.Err()}
+	}
+}
+
+// ProduceEventAsync queues an event in its lane. A shed event's result is
+// already on the returned channel.
+func (l *PriorityLanes) ProduceEventAsync(ctx context.Context, event *models.Event) <-chan DeliveryResult {
+	result := make(chan DeliveryResult, 1)
+	l.submit(l.laneFor(event.Priority), &laneJob{
+		ctx:     ctx,
+		events:  []*models.Event{event},
+		deliver: func(results []DeliveryResult) { result <- results[0] },
+	})
+	return result
+}
+
+// ProduceBatch queues each event of the batch in the lane of its own
+// priority, so a few high-priority events cannot carry bulk events past
+// shedding. Results come back in the order of events. A transactional batch
+// must stay atomic and is queued as a whole in the lane of its highest
+// priority event instead.
+func (l *PriorityLanes) ProduceBatch(ctx context.Context, events []*models.Event) []DeliveryResult {
+	if len(events) == 0 {
+		return []DeliveryResult{}
+	}
+	if l.Transactional() {
+		return l.produceWholeBatch(ctx, events)
+	}
+
+	// Split the batch by lane, remembering where each event came from
+	type part struct {
+		events  []*models.Event
+		indexes []int
+	}
+	parts := make(map[*lane]*part)
+	order := make([]*lane, 0, len(l.lanes))
+	for i, event := range events {
+		ln := l.laneFor(event.Priority)
+		p, ok := parts[ln]
+		if !ok {
+			p = &part{}
+			parts[ln] = p
+			order = append(order, ln)
+		}
+		p.events = append(p.events, event)
+		p.indexes = append(p.indexes, i)
+	}
+
+	type partResults struct {
+		indexes []int
+		results []DeliveryResult
+	}
+	done := make(chan partResults, len(order))
+	for _, ln := range order {
+		p := parts[ln]
+		l.submit(ln, &laneJob{
+			ctx:     ctx,
+			events:  p.events,
+			batch:   true,
+			deliver: func(results []DeliveryResult) { done <- partResults{p.indexes, results} },
+		})
+	}
+
+	results := make([]DeliveryResult, len(events))
+	for range order {
+		select {
+		case part := <-done:
+			for j, i := range part.indexes {
+				results[i] = part.results[j]
+			}
+		case <-ctx.Done():
+			return failedResults(len(events), ctx.Err())
+		}
+	}
+	return results
+}
+
+// produceWholeBatch queues the batch as a whole in the lane of its highest
+// priority event
+func (l *PriorityLanes) produceWholeBatch(ctx context.Context, events []*models.Event) []DeliveryResult {
+	priority := events[0].Priority
+	for _, event := range events[1:] {
+		priority = max(priority, event.Priority)
+	}
+
+	result := make(chan []DeliveryResult, 1)
+	l.submit(l.laneFor(priority), &laneJob{
+		ctx:     ctx,
+		events:  events,
+		batch:   true,
+		deliver: func(results []DeliveryResult) { result <- results },
+	})
+
+	select {
+	case results := <-result:
+		return results
+	case <-ctx.Done():
+		return failedResults(len(events), ctx.Err())
+	}
+}
+
+// submit queues job in ln unless the lane is shedding
+func (l *PriorityLanes) submit(ln *lane, job *laneJob) {
+	l.closeMu.RLock()
+	defer l.closeMu.RUnlock()
+
+	if l.closed {
+		job.deliver(failedResults(len(job.events), errProducerClosed))
+		return
+	}
+
+	if l.pressure() < ln.threshold {
+		job.enqueued = time.Now()
+		select {
+		case ln.queue <- job:
+			laneDepth.WithLabelValues(ln.name).Set(float64(len(ln.queue)))
+			return
+		default:
+		}
+	}
+	l.shed(ln, job)
+}
+
+// pressure is the fraction of all lane capacity in use
+func (l *PriorityLanes) pressure() float64 {
+	depth := 0
+	for _, ln := range l.lanes {
+		depth += len(ln.queue)
+	}
+	return float64(depth) / float64(l.capacity)
+}
+
+// shed rejects or drops a job the lane has no room for
+func (l *PriorityLanes) shed(ln *lane, job *laneJob) {
+	laneShed.WithLabelValues(ln.name, l.shedMode).Add(float64(len(job.events)))
+	l.logger.Debug("Priority lane shed events",
+		zap.String("lane", ln.name),
+		zap.String("mode", l.shedMode),
+		zap.Int("event_count", len(job.events)))
+
+	if l.shedMode == ShedDrop {
+		results := make([]DeliveryResult, len(job.events))
+		for i := range results {
+			results[i].Dropped = true
+		}
+		job.deliver(results)
+		return
+	}
+	job.deliver(failedResults(len(job.events), &LoadShedError{Lane: ln.name, RetryAfter: shedRetryAfter}))
+}
+
+// work sends the jobs of one lane until its queue is closed
+func (l *PriorityLanes) work(ln *lane) {
+	defer l.wg.Done()
+
+	for job := range ln.queue {
+		laneDepth.WithLabelValues(ln.name).Set(float64(len(ln.queue)))
+
+		var results []DeliveryResult
+		if job.batch {
+			results = l.next.ProduceBatch(job.ctx, job.events)
+		} else {
+			results = []DeliveryResult{l.next.Produce(job.ctx, job.events[0])}
+		}
+
+		laneLatency.WithLabelValues(ln.name).Observe(time.Since(job.enqueued).Seconds())
+		job.deliver(results)
+	}
+}
+
+// Transactional reports whether batches are delivered all-or-nothing
+func (l *PriorityLanes) Transactional() bool {
+	return l.next.Transactional()
+}
+
+// IsHealthy reports whether events can currently be published
+func (l *PriorityLanes) IsHealthy() bool {
+	return l.Health().Healthy
+}
+
+// Health returns the health of the publisher behind the lanes, with the depth
+// of each lane added to the details
+func (l *PriorityLanes) Health() HealthStatus {
+	status := l.next.Health()
+
+	details := make(map[string]string, len(status.Details)+len(l.lanes))
+	for key, value := range status.Details {
+		details[key] = value
+	}
+	for _, ln := range l.lanes {
+		details["lane."+ln.name] = strconv.Itoa(len(ln.queue)) + "/" + strconv.Itoa(cap(ln.queue))
+	}
+	status.Details = details
+	return status
+}
+
+// Close stops accepting events, waits for the queued ones to be sent and then
+// closes the publisher behind the lanes
+func (l *PriorityLanes) Close() error {
+	l.closeMu.Lock()
+	if l.closed {
+		l.closeMu.Unlock()
+		return nil
+	}
+	l.closed = true
+	for _, ln := range l.lanes {
+		close(ln.queue)
+	}
+	l.closeMu.Unlock()
+
+	l.wg.Wait()
+	return l.next.Close()
+}
+
+// failedResults returns n results failed with err
+func failedResults(n int, err error) []DeliveryResult {
+	results := make([]DeliveryResult, n)
+	for i := range results {
+		results[i].Err = err
+	}
+	return results
+}
@@ -0,0 +1,268 @@
+package kafka
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/distributed-event-processor/services/event-gateway/internal/config"
+	"github.com/distributed-event-processor/services/event-gateway/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func newTestLanes(t *testing.T, cfg config.PriorityLanesConfig, next EventPublisher) *PriorityLanes {
+	lanes, err := newLanes(cfg)
+	require.NoError(t, err)
+	publisher, err := newPriorityLanes(lanes, cfg.ShedMode, next, zap.NewNop())
+	require.NoError(t, err)
+	t.Cleanup(func() { publisher.Close() })
+	return publisher
+}
+
+func priorityEvent(priority int) *models.Event {
+	event := createTestEvent()
+	event.Priority = priority
+	return event
+}
+
+// waitForDequeue waits until the workers of lane have taken every queued job
+func waitForDequeue(t *testing.T, ln *lane) {
+	require.Eventually(t, func() bool { return len(ln.queue) == 0 }, time.Second, time.Millisecond)
+}
+
+func TestNewLanes_Defaults(t *testing.T) {
+	lanes, err := newLanes(config.PriorityLanesConfig{})
+	require.NoError(t, err)
+	require.Len(t, lanes, 3)
+
+	assert.Equal(t, "critical", lanes[0].name)
+	assert.Equal(t, 8, lanes[0].minPriority)
+	assert.Equal(t, 10, lanes[0].maxPriority)
+	assert.Equal(t, "normal", lanes[1].name)
+	assert.Equal(t, 7, lanes[1].maxPriority)
+	assert.Equal(t, "bulk", lanes[2].name)
+	assert.Equal(t, 2, lanes[2].maxPriority)
+}
+
+func TestNewLanes_InvalidConfig(t *testing.T) {
+	tests := []struct {
+		name  string
+		lanes []config.LaneConfig
+	}{
+		{"missing name", []config.LaneConfig{{QueueSize: 1, Workers: 1}}},
+		{"duplicate name", []config.LaneConfig{
+			{Name: "a", MinPriority: 5, QueueSize: 1, Workers: 1},
+			{Name: "a", QueueSize: 1, Workers: 1},
+		}},
+		{"same min_priority", []config.LaneConfig{
+			{Name: "a", QueueSize: 1, Workers: 1},
+			{Name: "b", QueueSize: 1, Workers: 1},
+		}},
+		{"no lane for priority 0", []config.LaneConfig{{Name: "a", MinPriority: 5, QueueSize: 1, Workers: 1}}},
+		{"priority out of range", []config.LaneConfig{{Name: "a", MinPriority: 11, QueueSize: 1, Workers: 1}}},
+		{"no workers", []config.LaneConfig{{Name: "a", QueueSize: 1}}},
+		{"threshold above one", []config.LaneConfig{{Name: "a", QueueSize: 1, Workers: 1, ShedThreshold: 1.5}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := newLanes(config.PriorityLanesConfig{Lanes: tt.lanes})
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestNewPriorityLanes_UnknownShedMode(t *testing.T) {
+	lanes, err := newLanes(config.PriorityLanesConfig{})
+	require.NoError(t, err)
+
+	_, err = newPriorityLanes(lanes, "delay", newTestMemoryBroker(t, config.KafkaConfig{}), zap.NewNop())
+	assert.Error(t, err)
+}
+
+func TestNewPublisher_LaneTopics(t *testing.T) {
+	publisher, err := NewPublisher(config.KafkaConfig{
+		Mode:  PublisherMemory,
+		Topic: "events",
+		PriorityLanes: config.PriorityLanesConfig{
+			Enabled: true,
+			Lanes: []config.LaneConfig{
+				{Name: "critical", MinPriority: 8, QueueSize: 10, Workers: 1, Topic: "events.critical"},
+				{Name: "bulk", MinPriority: 0, QueueSize: 10, Workers: 1},
+			},
+		},
+	}, zap.NewNop())
+	require.NoError(t, err)
+	defer publisher.Close()
+
+	critical := publisher.Produce(context.Background(), priorityEvent(9))
+	require.NoError(t, critical.Err)
+	assert.Equal(t, "events.critical", critical.Topic)
+	assert.Equal(t, "lane-critical", critical.Rule)
+
+	bulk := publisher.Produce(context.Background(), priorityEvent(7))
+	require.NoError(t, bulk.Err)
+	assert.Equal(t, "events", bulk.Topic)
+
+	assert.Equal(t, "0/10", publisher.Health().Details["lane.critical"])
+}
+
+func TestPriorityLanes_RejectsWhenLaneIsFull(t *testing.T) {
+	broker := newTestMemoryBroker(t, config.KafkaConfig{})
+	broker.SetLatency(time.Hour)
+	lanes := newTestLanes(t, config.PriorityLanesConfig{Lanes: []config.LaneConfig{
+		{Name: "only", QueueSize: 1, Workers: 1},
+	}}, broker)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// The worker blocks on the first event and the second fills the queue
+	sending := lanes.ProduceEventAsync(ctx, priorityEvent(0))
+	waitForDequeue(t, lanes.lanes[0])
+	queued := lanes.ProduceEventAsync(ctx, priorityEvent(0))
+
+	result := lanes.Produce(ctx, priorityEvent(0))
+	var shedErr *LoadShedError
+	require.ErrorAs(t, result.Err, &shedErr)
+	assert.Equal(t, "only", shedErr.Lane)
+	assert.Equal(t, 1, shedErr.RetryAfterSeconds())
+
+	cancel()
+	assert.ErrorIs(t, (<-sending).Err, context.Canceled)
+	assert.ErrorIs(t, (<-queued).Err, context.Canceled)
+}
+
+func TestPriorityLanes_ShedsLowPriorityFirst(t *testing.T) {
+	broker := newTestMemoryBroker(t, config.KafkaConfig{})
+	broker.SetLatency(time.Hour)
+	lanes := newTestLanes(t, config.PriorityLanesConfig{
+		ShedMode: ShedDrop,
+		Lanes: []config.LaneConfig{
+			{Name: "critical", MinPriority: 5, QueueSize: 3, Workers: 1},
+			{Name: "bulk", MinPriority: 0, QueueSize: 3, Workers: 1, ShedThreshold: 0.3},
+		},
+	}, broker)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Two waiting critical events put a third of all lane capacity in use
+	lanes.ProduceEventAsync(ctx, priorityEvent(9))
+	waitForDequeue(t, lanes.lanes[0])
+	lanes.ProduceEventAsync(ctx, priorityEvent(9))
+	lanes.ProduceEventAsync(ctx, priorityEvent(9))
+
+	bulk := lanes.Produce(ctx, priorityEvent(1))
+	require.NoError(t, bulk.Err)
+	assert.True(t, bulk.Dropped)
+
+	// Batched events are shed the same way, each with its own result
+	batch := lanes.ProduceBatch(ctx, []*models.Event{priorityEvent(1), priorityEvent(2)})
+	require.Len(t, batch, 2)
+	for _, result := range batch {
+		require.NoError(t, result.Err)
+		assert.True(t, result.Dropped)
+	}
+
+	// Critical events are still queued while their lane has room
+	critical := lanes.ProduceEventAsync(ctx, priorityEvent(9))
+	select {
+	case result := <-critical:
+		t.Fatalf("critical event was not queued: %+v", result)
+	default:
+	}
+}
+
+func TestPriorityLanes_Batch(t *testing.T) {
+	broker := newTestMemoryBroker(t, config.KafkaConfig{})
+	lanes := newTestLanes(t, config.PriorityLanesConfig{}, broker)
+
+	results := lanes.ProduceBatch(context.Background(), []*models.Event{priorityEvent(1), priorityEvent(9)})
+	require.Len(t, results, 2)
+	for _, result := range results {
+		require.NoError(t, result.Err)
+	}
+	assert.Len(t, broker.Messages(), 2)
+	assert.Empty(t, lanes.ProduceBatch(context.Background(), nil))
+}
+
+// bulkBlocker holds sends of low-priority events until their context ends
+type bulkBlocker struct {
+	*MemoryBroker
+}
+
+func (b bulkBlocker) Produce(ctx context.Context, event *models.Event) DeliveryResult {
+	if event.Priority < 5 {
+		<-ctx.Done()
+		return DeliveryResult{Err: ctx.Err()}
+	}
+	return b.MemoryBroker.Produce(ctx, event)
+}
+
+func TestPriorityLanes_MixedPriorityBatch(t *testing.T) {
+	broker := newTestMemoryBroker(t, config.KafkaConfig{})
+	lanes := newTestLanes(t, config.PriorityLanesConfig{Lanes: []config.LaneConfig{
+		{Name: "critical", MinPriority: 5, QueueSize: 10, Workers: 1},
+		{Name: "bulk", MinPriority: 0, QueueSize: 1, Workers: 1},
+	}}, bulkBlocker{broker})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Fill the bulk lane
+	lanes.ProduceEventAsync(ctx, priorityEvent(1))
+	waitForDequeue(t, lanes.lanes[1])
+	lanes.ProduceEventAsync(ctx, priorityEvent(1))
+
+	// The critical event is sent while the bulk events around it are shed
+	results := lanes.ProduceBatch(context.Background(), []*models.Event{priorityEvent(1), priorityEvent(9), priorityEvent(2)})
+	require.Len(t, results, 3)
+	var shedErr *LoadShedError
+	require.ErrorAs(t, results[0].Err, &shedErr)
+	assert.Equal(t, "bulk", shedErr.Lane)
+	require.NoError(t, results[1].Err)
+	assert.Equal(t, "events", results[1].Topic)
+	require.ErrorAs(t, results[2].Err, &shedErr)
+
+	messages := broker.Messages()
+	require.Len(t, messages, 1)
+	assert.Equal(t, 9, messages[0].Event.Priority)
+}
+
+func TestPriorityLanes_TransactionalBatch(t *testing.T) {
+	broker := newTestMemoryBroker(t, config.KafkaConfig{DeliveryGuarantee: GuaranteeTransactional})
+	lanes := newTestLanes(t, config.PriorityLanesConfig{Lanes: []config.LaneConfig{
+		{Name: "critical", MinPriority: 5, QueueSize: 10, Workers: 1},
+		{Name: "bulk", MinPriority: 0, QueueSize: 1, Workers: 1},
+	}}, bulkBlocker{broker})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	lanes.ProduceEventAsync(ctx, priorityEvent(1))
+	waitForDequeue(t, lanes.lanes[1])
+	lanes.ProduceEventAsync(ctx, priorityEvent(1))
+
+	// A transactional batch is not split, so it goes through the critical lane
+	results := lanes.ProduceBatch(context.Background(), []*models.Event{priorityEvent(1), priorityEvent(9)})
+	require.Len(t, results, 2)
+	for _, result := range results {
+		require.NoError(t, result.Err)
+	}
+	assert.Len(t, broker.Messages(), 2)
+}
+
+func TestPriorityLanes_Close(t *testing.T) {
+	broker := newTestMemoryBroker(t, config.KafkaConfig{})
+	lanes := newTestLanes(t, config.PriorityLanesConfig{}, broker)
+
+	require.NoError(t, lanes.Produce(context.Background(), priorityEvent(5)).Err)
+	require.NoError(t, lanes.Close())
+
+	result := lanes.Produce(context.Background(), priorityEvent(5))
+	assert.ErrorIs(t, result.Err, errProducerClosed)
+	assert.False(t, broker.IsHealthy(), "closing the lanes closes the publisher behind them")
+}
@@ -0,0 +1,345 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/IBM/sarama"
+	"github.com/distributed-event-processor/services/event-gateway/internal/config"
+	"github.com/distributed-event-processor/services/event-gateway/internal/models"
+	"go.uber.org/zap"
+)
+
+// ErrMemoryBrokerFailure is returned for sends failed by the memory broker's
+// configured error rate
+var ErrMemoryBrokerFailure = errors.New("memory broker: injected failure")
+
+// MemoryMessage is a message accepted by the memory broker
+type MemoryMessage struct {
+	Topic     string
+	Partition int32
+	Offset    int64
+	Key       []byte
+	Value     []byte
+	Headers   map[string]string
+	Timestamp time.Time
+	Event     *models.Event
+}
+
+// MemoryBroker is an EventPublisher that keeps messages in memory instead of
+// producing them to Kafka. Events are routed, keyed, serialized and partitioned
+// as the Kafka producer would, and each partition assigns increasing offsets.
+// Latency and errors can be injected through config or by tests.
+type MemoryBroker struct {
+	builder        *Producer
+	cluster        string
+	newPartitioner sarama.PartitionerConstructor
+	partitions     int32
+	transactional  bool
+	logger         *zap.Logger
+
+	mu           sync.Mutex
+	messages     []MemoryMessage
+	offsets      map[string][]int64
+	partitioners map[string]sarama.Partitioner
+	latency      time.Duration
+	errorRate    float64
+	err          error
+	failNext     []error
+	closed       bool
+}
+
+// NewMemoryBroker creates an in-memory broker shaped by cfg.Memory. The routing,
+// partition key, serialization, header and partitioner settings of cfg apply
+// as they would to Kafka.
+func NewMemoryBroker(cfg config.KafkaConfig, logger *zap.Logger) (*MemoryBroker, error) {
+	partitions := cfg.Memory.Partitions
+	if partitions == 0 {
+		partitions = 1
+	}
+	if partitions < 0 {
+		return nil, fmt.Errorf("memory.partitions must be positive, got %d", partitions)
+	}
+	if cfg.Memory.LatencyMs < 0 {
+		return nil, fmt.Errorf("memory.latency_ms must be >= 0, got %d", cfg.Memory.LatencyMs)
+	}
+	if cfg.Memory.ErrorRate < 0 || cfg.Memory.ErrorRate > 1 {
+		return nil, fmt.Errorf("memory.error_rate must be between 0 and 1, got %g", cfg.Memory.ErrorRate)
+	}
+
+	partitioner, err := newPartitioner(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	builder, err := newMessageBuilder(cfg, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	logger.Info("Using in-memory broker; events are not sent to Kafka",
+		zap.Int("partitions", partitions),
+		zap.Int("latency_ms", cfg.Memory.LatencyMs),
+		zap.Float64("error_rate", cfg.Memory.ErrorRate))
+
+	return &MemoryBroker{
+		builder:        builder,
+		cluster:        clusterName(cfg),
+		newPartitioner: partitioner,
+		partitions:     int32(partitions),
+		transactional:  cfg.DeliveryGuarantee == GuaranteeTransactional,
+		logger:         logger,
+		offsets:        make(map[string][]int64),
+		partitioners:   make(map[string]sarama.Partitioner),
+		latency:        time.Duration(cfg.Memory.LatencyMs) * time.Millisecond,
+		errorRate:      cfg.Memory.ErrorRate,
+	}, nil
+}
+
+// Produce stores an event and returns its partition and offset
+func (b *MemoryBroker) Produce(ctx context.Context, event *models.Event) DeliveryResult {
+	return b.ProduceBatch(ctx, []*models.Event{event})[0]
+}
+
+// ProduceEventAsync stores an event in the background
+func (b *MemoryBroker) ProduceEventAsync(ctx context.Context, event *models.Event) <-chan DeliveryResult {
+	result := make(chan DeliveryResult, 1)
+	go func() {
+		result <- b.Produce(ctx, event)
+	}()
+	return result
+}
+
+// ProduceBatch stores events after a single injected delay. With the
+// transactional guarantee any failure fails the whole batch.
+func (b *MemoryBroker) ProduceBatch(ctx context.Context, events []*models.Event) []DeliveryResult {
+	results := make([]DeliveryResult, len(events))
+	messages := make([]*sarama.ProducerMessage, len(events))
+	errs := make([]error, len(events))
+
+	for i, event := range events {
+		topic, rule := b.builder.route(event)
+		results[i] = DeliveryResult{Cluster: b.cluster, Topic: topic, Rule: rule}
+		messages[i], errs[i] = b.builder.buildMessage(event)
+	}
+
+	if err := b.wait(ctx); err != nil {
+		for i := range results {
+			results[i].Err = err
+		}
+		return results
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for i := range events {
+		if errs[i] == nil {
+			errs[i] = b.injectedError()
+		}
+	}
+
+	if b.transactional {
+		for _, err := range errs {
+			if err != nil {
+				for i := range results {
+					results[i].Err = fmt.Errorf("transaction aborted: %w", err)
+				}
+				return results
+			}
+		}
+	}
+
+	for i, message := range messages {
+		if errs[i] != nil {
+			results[i].Err = errs[i]
+			continue
+		}
+		results[i].Partition, results[i].Offset = b.append(message, events[i])
+	}
+	return results
+}
+
+// wait applies the configured latency, returning early if ctx is done
+func (b *MemoryBroker) wait(ctx context.Context) error {
+	b.mu.Lock()
+	latency := b.latency
+	b.mu.Unlock()
+
+	if latency <= 0 {
+		return ctx.Err()
+	}
+
+	timer := time.NewTimer(latency)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// injectedError returns the error the next send should fail with, if any.
+// Callers must hold b.mu.
+func (b *MemoryBroker) injectedError() error {
+	if b.closed {
+		return errProducerClosed
+	}
+	if len(b.failNext) > 0 {
+		err := b.failNext[0]
+		b.failNext = b.failNext[1:]
+		return err
+	}
+	if b.err != nil {
+		return b.err
+	}
+	if b.errorRate > 0 && rand.Float64() < b.errorRate {
+		return ErrMemoryBrokerFailure
+	}
+	return nil
+}
+
+// append records message on its partition and returns the assigned offset.
+// Callers must hold b.mu.
+func (b *MemoryBroker) append(message *sarama.ProducerMessage, event *models.Event) (int32, int64) {
+	partitioner, ok := b.partitioners[message.Topic]
+	if !ok {
+		partitioner = b.newPartitioner(message.Topic)
+		b.partitioners[message.Topic] = partitioner
+	}
+	partition, err := partitioner.Partition(message, b.partitions)
+	if err != nil || partition < 0 || partition >= b.partitions {
+		partition = 0
+	}
+
+	offsets, ok := b.offsets[message.Topic]
+	if !ok {
+		offsets = make([]int64, b.partitions)
+		b.offsets[message.Topic] = offsets
+	}
+	offset := offsets[partition]
+	offsets[partition]++
+
+	stored := MemoryMessage{
+		Topic:     message.Topic,
+		Partition: partition,
+		Offset:    offset,
+		Headers:   make(map[string]string, len(message.Headers)),
+		Timestamp: message.Timestamp,
+		Event:     event,
+	}
+	if message.Key != nil {
+		stored.Key, _ = message.Key.Encode()
+	}
+	stored.Value, _ = message.Value.Encode()
+	for _, header := range message.Headers {
+		stored.Headers[string(header.Key)] = string(header.Value)
+	}
+
+	b.messages = append(b.messages, stored)
+	return partition, offset
+}
+
+// Messages returns every stored message in the order it was accepted
+func (b *MemoryBroker) Messages() []MemoryMessage {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]MemoryMessage(nil), b.messages...)
+}
+
+// TopicMessages returns the stored messages of one topic
+func (b *MemoryBroker) TopicMessages(topic string) []MemoryMessage {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var messages []MemoryMessage
+	for _, message := range b.messages {
+		if message.Topic == topic {
+			messages = append(messages, message)
+		}
+	}
+	return messages
+}
+
+// Reset drops all stored messages and offsets and clears injected errors
+func (b *MemoryBroker) Reset() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.messages = nil
+	b.offsets = make(map[string][]int64)
+	b.err = nil
+	b.failNext = nil
+}
+
+// SetLatency delays every subsequent send by latency
+func (b *MemoryBroker) SetLatency(latency time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.latency = latency
+}
+
+// SetError fails every subsequent send with err, and marks the broker
+// unhealthy, until it is cleared with nil
+func (b *MemoryBroker) SetError(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.err = err
+}
+
+// FailNext fails the next send with err. Calls queue up, one send each.
+func (b *MemoryBroker) FailNext(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failNext = append(b.failNext, err)
+}
+
+// Transactional reports whether batches are all-or-nothing
+func (b *MemoryBroker) Transactional() bool {
+	return b.transactional
+}
+
+// IsHealthy reports whether the broker accepts sends
+func (b *MemoryBroker) IsHealthy() bool {
+	return b.Health().Healthy
+}
+
+// Health describes the broker's state
+func (b *MemoryBroker) Health() HealthStatus {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	status := HealthStatus{
+		Healthy:   true,
+		Message:   "In-memory broker",
+		LastCheck: time.Now(),
+		Details: map[string]string{
+			"mode":     PublisherMemory,
+			"messages": strconv.Itoa(len(b.messages)),
+		},
+	}
+	switch {
+	case b.closed:
+		status.Healthy = false
+		status.Message = "In-memory broker is closed"
+	case b.err != nil:
+		status.Healthy = false
+		status.Message = b.err.Error()
+	}
+	return status
+}
+
+// Close stops the broker accepting sends. Stored messages remain readable.
+func (b *MemoryBroker) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.closed = true
+	return nil
+}
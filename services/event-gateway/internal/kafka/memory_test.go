@@ -0,0 +1,174 @@
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/distributed-event-processor/services/event-gateway/internal/config"
+	"github.com/distributed-event-processor/services/event-gateway/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func newTestMemoryBroker(t *testing.T, cfg config.KafkaConfig) *MemoryBroker {
+	if cfg.Topic == "" {
+		cfg.Topic = "events"
+	}
+	broker, err := NewMemoryBroker(cfg, zap.NewNop())
+	require.NoError(t, err)
+	return broker
+}
+
+func TestMemoryBroker_Produce(t *testing.T) {
+	broker := newTestMemoryBroker(t, config.KafkaConfig{Memory: config.MemoryBrokerConfig{Partitions: 4}})
+
+	event := createTestEvent()
+	result := broker.Produce(context.Background(), event)
+	require.NoError(t, result.Err)
+	assert.Equal(t, "events", result.Topic)
+	assert.Equal(t, defaultRule, result.Rule)
+	assert.Less(t, result.Partition, int32(4))
+	assert.Equal(t, int64(0), result.Offset)
+
+	// Events of the same type share a key, so they land on the same partition
+	second := broker.Produce(context.Background(), createTestEvent())
+	require.NoError(t, second.Err)
+	assert.Equal(t, result.Partition, second.Partition)
+	assert.Equal(t, int64(1), second.Offset)
+
+	messages := broker.Messages()
+	require.Len(t, messages, 2)
+	assert.Equal(t, "events", messages[0].Topic)
+	assert.Equal(t, []byte(event.Type), messages[0].Key)
+	assert.Equal(t, event.ID, messages[0].Headers["event_id"])
+	assert.Equal(t, ContentTypeJSON, messages[0].Headers[contentTypeHeader])
+	assert.Same(t, event, messages[0].Event)
+
+	var stored models.Event
+	require.NoError(t, json.Unmarshal(messages[0].Value, &stored))
+	assert.Equal(t, event.ID, stored.ID)
+}
+
+func TestMemoryBroker_Routing(t *testing.T) {
+	broker := newTestMemoryBroker(t, config.KafkaConfig{
+		Routing: config.RoutingConfig{Rules: []config.RoutingRule{
+			{Name: "payments", EventTypes: []string{"payment.*"}, Topic: "events.payments"},
+		}},
+	})
+
+	result := broker.Produce(context.Background(), &models.Event{ID: "1", Type: "payment.captured", Source: "billing"})
+	require.NoError(t, result.Err)
+	assert.Equal(t, "events.payments", result.Topic)
+	assert.Equal(t, "payments", result.Rule)
+	assert.Len(t, broker.TopicMessages("events.payments"), 1)
+	assert.Empty(t, broker.TopicMessages("events"))
+}
+
+func TestMemoryBroker_InjectedErrors(t *testing.T) {
+	broker := newTestMemoryBroker(t, config.KafkaConfig{})
+	failure := errors.New("leader not available")
+
+	broker.FailNext(failure)
+	results := broker.ProduceBatch(context.Background(), []*models.Event{createTestEvent(), createTestEvent()})
+	assert.ErrorIs(t, results[0].Err, failure)
+	assert.NoError(t, results[1].Err)
+	assert.Len(t, broker.Messages(), 1)
+
+	broker.SetError(failure)
+	assert.ErrorIs(t, broker.Produce(context.Background(), createTestEvent()).Err, failure)
+	assert.False(t, broker.IsHealthy())
+
+	broker.SetError(nil)
+	assert.NoError(t, broker.Produce(context.Background(), createTestEvent()).Err)
+	assert.True(t, broker.IsHealthy())
+}
+
+func TestMemoryBroker_ErrorRate(t *testing.T) {
+	broker := newTestMemoryBroker(t, config.KafkaConfig{Memory: config.MemoryBrokerConfig{ErrorRate: 1}})
+
+	result := broker.Produce(context.Background(), createTestEvent())
+	assert.ErrorIs(t, result.Err, ErrMemoryBrokerFailure)
+	assert.Empty(t, broker.Messages())
+}
+
+func TestMemoryBroker_Transactional(t *testing.T) {
+	broker := newTestMemoryBroker(t, config.KafkaConfig{DeliveryGuarantee: GuaranteeTransactional})
+	assert.True(t, broker.Transactional())
+
+	broker.FailNext(errors.New("broker down"))
+	results := broker.ProduceBatch(context.Background(), []*models.Event{createTestEvent(), createTestEvent()})
+	for _, result := range results {
+		require.Error(t, result.Err)
+		assert.Contains(t, result.Err.Error(), "transaction aborted")
+	}
+	assert.Empty(t, broker.Messages())
+}
+
+func TestMemoryBroker_Latency(t *testing.T) {
+	broker := newTestMemoryBroker(t, config.KafkaConfig{})
+	broker.SetLatency(50 * time.Millisecond)
+
+	start := time.Now()
+	require.NoError(t, broker.Produce(context.Background(), createTestEvent()).Err)
+	assert.GreaterOrEqual(t, time.Since(start), 50*time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, broker.Produce(ctx, createTestEvent()).Err, context.DeadlineExceeded)
+	assert.Len(t, broker.Messages(), 1)
+}
+
+func TestMemoryBroker_Async(t *testing.T) {
+	broker := newTestMemoryBroker(t, config.KafkaConfig{})
+
+	result := <-broker.ProduceEventAsync(context.Background(), createTestEvent())
+	require.NoError(t, result.Err)
+	assert.Len(t, broker.Messages(), 1)
+}
+
+func TestMemoryBroker_CloseAndReset(t *testing.T) {
+	broker := newTestMemoryBroker(t, config.KafkaConfig{})
+	require.NoError(t, broker.Produce(context.Background(), createTestEvent()).Err)
+
+	broker.Reset()
+	assert.Empty(t, broker.Messages())
+	result := broker.Produce(context.Background(), createTestEvent())
+	require.NoError(t, result.Err)
+	assert.Equal(t, int64(0), result.Offset)
+
+	require.NoError(t, broker.Close())
+	assert.ErrorIs(t, broker.Produce(context.Background(), createTestEvent()).Err, errProducerClosed)
+	assert.False(t, broker.IsHealthy())
+	assert.Len(t, broker.Messages(), 1)
+}
+
+func TestNewMemoryBroker_InvalidConfig(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  config.MemoryBrokerConfig
+	}{
+		{"negative partitions", config.MemoryBrokerConfig{Partitions: -1}},
+		{"negative latency", config.MemoryBrokerConfig{LatencyMs: -1}},
+		{"error rate above 1", config.MemoryBrokerConfig{ErrorRate: 1.5}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewMemoryBroker(config.KafkaConfig{Topic: "events", Memory: tt.cfg}, zap.NewNop())
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestNewPublisher(t *testing.T) {
+	publisher, err := NewPublisher(config.KafkaConfig{Mode: PublisherMemory, Topic: "events"}, zap.NewNop())
+	require.NoError(t, err)
+	assert.IsType(t, &MemoryBroker{}, publisher)
+
+	_, err = NewPublisher(config.KafkaConfig{Mode: "file"}, zap.NewNop())
+	assert.Error(t, err)
+}
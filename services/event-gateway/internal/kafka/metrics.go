@@ -0,0 +1,126 @@
+package kafka
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Prometheus metrics
+var (
+	messagesProduced = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "kafka_messages_produced_total",
+			Help: "Total number of events acknowledged by Kafka",
+		},
+		[]string{"cluster", "topic"},
+	)
+
+	produceErrors = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "kafka_produce_errors_total",
+			Help: "Total number of events Kafka failed to accept",
+		},
+		[]string{"cluster", "topic"},
+	)
+
+	routedEvents = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "kafka_routed_events_total",
+			Help: "Total number of events delivered to a topic, by the routing rule that chose it",
+		},
+		[]string{"topic", "rule"},
+	)
+
+	kafkaHealthy = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "kafka_healthy",
+			Help: "Whether the last Kafka health check passed (1) or failed (0)",
+		},
+		[]string{"cluster"},
+	)
+
+	circuitState = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "kafka_circuit_breaker_state",
+			Help: "State of the Kafka circuit breaker: closed (0), half-open (1) or open (2)",
+		},
+		[]string{"cluster"},
+	)
+
+	circuitRejected = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "kafka_circuit_breaker_rejected_total",
+			Help: "Total number of sends failed fast because the Kafka circuit breaker was open",
+		},
+		[]string{"cluster"},
+	)
+
+	clusterFailovers = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "kafka_cluster_failovers_total",
+			Help: "Total number of sends redirected from a Kafka cluster whose circuit breaker was open",
+		},
+		[]string{"from", "to"},
+	)
+
+	laneDepth = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "ingest_lane_depth",
+			Help: "Number of events waiting in each priority lane",
+		},
+		[]string{"lane"},
+	)
+
+	laneLatency = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "ingest_lane_latency_seconds",
+			Help:    "Time from entering a priority lane to the delivery result, by lane",
+			Buckets: prometheus.DefBuckets,
+		},
+		[]string{"lane"},
+	)
+
+	laneShed = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "ingest_lane_shed_total",
+			Help: "Total number of events shed by each priority lane, by mode (reject or drop)",
+		},
+		[]string{"lane", "mode"},
+	)
+
+	deadLetters = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "kafka_dead_letters_total",
+			Help: "Total number of events dead-lettered, by reason and destination (kafka or disk)",
+		},
+		[]string{"reason", "destination"},
+	)
+
+	deadLetterFailures = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "kafka_dead_letter_failures_total",
+			Help: "Total number of dead letters that could not be written anywhere",
+		},
+	)
+
+	spoolReplayed = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "spool_replayed_total",
+			Help: "Total number of spooled events delivered to Kafka",
+		},
+	)
+
+	spoolDropped = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "spool_dropped_total",
+			Help: "Total number of spooled events discarded because they can never be produced",
+		},
+	)
+
+	spoolReplayFailures = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "spool_replay_failures_total",
+			Help: "Total number of spool replay attempts stopped by a Kafka error",
+		},
+	)
+)
@@ -0,0 +1,278 @@
+package kafka
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/IBM/sarama"
+	"github.com/distributed-event-processor/services/event-gateway/internal/config"
+	"github.com/distributed-event-processor/services/event-gateway/internal/models"
+)
+
+// Partition key strategies
+const (
+	KeyByType          = "type"
+	KeyBySubject       = "subject"
+	KeyByTenant        = "tenant_id"
+	KeyByCorrelationID = "correlation_id"
+	KeyBySource        = "source"
+	KeyByJSONPath      = "jsonpath"
+	KeyComposite       = "composite"
+	KeyNone            = "none"
+)
+
+const defaultKeySeparator = ":"
+
+// keyExtractor derives a partition key from an event; an empty key means the
+// event is produced without a key and placed by the keyless partitioner
+type keyExtractor func(event *models.Event) string
+
+type keyOverride struct {
+	eventTypes []string
+	extract    keyExtractor
+}
+
+// partitionKeyResolver picks the partition key for each event, applying the
+// first per-event-type override that matches before the global strategy
+type partitionKeyResolver struct {
+	extract   keyExtractor
+	overrides []keyOverride
+}
+
+func newPartitionKeyResolver(cfg config.PartitionKeyConfig) (*partitionKeyResolver, error) {
+	extract, err := newKeyExtractor(cfg.Strategy, cfg.Path, cfg.Fields, cfg.Separator)
+	if err != nil {
+		return nil, err
+	}
+
+	resolver := &partitionKeyResolver{extract: extract}
+
+	for i, override := range cfg.Overrides {
+		if len(override.EventTypes) == 0 {
+			return nil, fmt.Errorf("partition_key.overrides[%d]: event_types is required", i)
+		}
+		for _, pattern := range override.EventTypes {
+			if err := validateEventTypePattern(pattern); err != nil {
+				return nil, fmt.Errorf("partition_key.overrides[%d]: %w", i, err)
+			}
+		}
+
+		extract, err := newKeyExtractor(override.Strategy, override.Path, override.Fields, override.Separator)
+		if err != nil {
+			return nil, fmt.Errorf("partition_key.overrides[%d]: %w", i, err)
+		}
+		resolver.overrides = append(resolver.overrides, keyOverride{
+			eventTypes: override.EventTypes,
+			extract:    extract,
+		})
+	}
+
+	return resolver, nil
+}
+
+// Key returns the message key for an event, or nil when the event is keyless
+func (r *partitionKeyResolver) Key(event *models.Event) sarama.Encoder {
+	extract := r.extract
+	for _, override := range r.overrides {
+		if matchEventType(override.eventTypes, event.Type) {
+			extract = override.extract
+			break
+		}
+	}
+
+	key := extract(event)
+	if key == "" {
+		return nil
+	}
+	return sarama.StringEncoder(key)
+}
+
+func newKeyExtractor(strategy, jsonPath string, fields []string, separator string) (keyExtractor, error) {
+	switch strategy {
+	case KeyByType, "":
+		return func(event *models.Event) string { return event.Type }, nil
+	case KeyBySubject, KeyByTenant, KeyByCorrelationID, KeyBySource:
+		return fieldExtractor(strategy), nil
+	case KeyByJSONPath:
+		if jsonPath == "" {
+			return nil, fmt.Errorf("partition key strategy %s requires a path", KeyByJSONPath)
+		}
+		segments, err := parseJSONPath(jsonPath)
+		if err != nil {
+			return nil, err
+		}
+		return func(event *models.Event) string { return lookupJSONPath(event.Data, segments) }, nil
+	case KeyComposite:
+		if len(fields) == 0 {
+			return nil, fmt.Errorf("partition key strategy %s requires fields", KeyComposite)
+		}
+		if separator == "" {
+			separator = defaultKeySeparator
+		}
+		parts := make([]keyExtractor, 0, len(fields))
+		for _, field := range fields {
+			part, err := compositePart(field)
+			if err != nil {
+				return nil, err
+			}
+			parts = append(parts, part)
+		}
+		return func(event *models.Event) string {
+			values := make([]string, len(parts))
+			empty := true
+			for i, part := range parts {
+				values[i] = part(event)
+				empty = empty && values[i] == ""
+			}
+			if empty {
+				return ""
+			}
+			return strings.Join(values, separator)
+		}, nil
+	case KeyNone:
+		return func(*models.Event) string { return "" }, nil
+	default:
+		return nil, fmt.Errorf("unknown partition key strategy: %s (expected: type, subject, tenant_id, correlation_id, source, jsonpath, composite or none)", strategy)
+	}
+}
+
+// compositePart resolves a composite field, which is either an envelope field
+// name or a JSONPath into the event data
+func compositePart(field string) (keyExtractor, error) {
+	if strings.HasPrefix(field, "$") {
+		segments, err := parseJSONPath(field)
+		if err != nil {
+			return nil, err
+		}
+		return func(event *models.Event) string { return lookupJSONPath(event.Data, segments) }, nil
+	}
+
+	switch field {
+	case KeyByType:
+		return func(event *models.Event) string { return event.Type }, nil
+	case KeyBySubject, KeyByTenant, KeyByCorrelationID, KeyBySource:
+		return fieldExtractor(field), nil
+	default:
+		return nil, fmt.Errorf("unknown composite partition key field: %s", field)
+	}
+}
+
+func fieldExtractor(field string) keyExtractor {
+	switch field {
+	case KeyBySubject:
+		return func(event *models.Event) string { return event.Subject }
+	case KeyByTenant:
+		return func(event *models.Event) string { return event.TenantID }
+	case KeyByCorrelationID:
+		return func(event *models.Event) string { return event.CorrelationID }
+	default:
+		return func(event *models.Event) string { return event.Source }
+	}
+}
+
+// jsonPathSegment is a single object key or array index in a parsed JSONPath
+type jsonPathSegment struct {
+	key     string
+	index   int
+	isIndex bool
+}
+
+// parseJSONPath supports the dotted subset of JSONPath, e.g. $.order.items[0].sku
+func parseJSONPath(expr string) ([]jsonPathSegment, error) {
+	if expr != "$" && !strings.HasPrefix(expr, "$.") {
+		return nil, fmt.Errorf("invalid JSONPath %q: must start with $.", expr)
+	}
+
+	var segments []jsonPathSegment
+	for _, part := range strings.Split(strings.TrimPrefix(expr, "$"), ".")[1:] {
+		name, rest, hasIndex := strings.Cut(part, "[")
+		if name == "" && !hasIndex {
+			return nil, fmt.Errorf("invalid JSONPath %q: empty segment", expr)
+		}
+		if name != "" {
+			segments = append(segments, jsonPathSegment{key: name})
+		}
+
+		for hasIndex {
+			var indexText string
+			indexText, rest, _ = strings.Cut(rest, "]")
+			index, err := strconv.Atoi(indexText)
+			if err != nil || index < 0 {
+				return nil, fmt.Errorf("invalid JSONPath %q: bad array index %q", expr, indexText)
+			}
+			segments = append(segments, jsonPathSegment{index: index, isIndex: true})
+
+			if rest == "" {
+				break
+			}
+			if !strings.HasPrefix(rest, "[") {
+				return nil, fmt.Errorf("invalid JSONPath %q: unexpected %q", expr, rest)
+			}
+			rest = rest[1:]
+		}
+	}
+
+	if len(segments) == 0 {
+		return nil, fmt.Errorf("invalid JSONPath %q: path selects the whole document", expr)
+	}
+	return segments, nil
+}
+
+// lookupJSONPath walks the event data and renders the selected value as a key
+func lookupJSONPath(data map[string]interface{}, segments []jsonPathSegment) string {
+	var current interface{} = data
+	for _, segment := range segments {
+		switch node := current.(type) {
+		case map[string]interface{}:
+			if segment.isIndex {
+				return ""
+			}
+			current = node[segment.key]
+		case []interface{}:
+			if !segment.isIndex || segment.index >= len(node) {
+				return ""
+			}
+			current = node[segment.index]
+		default:
+			return ""
+		}
+	}
+
+	switch value := current.(type) {
+	case nil:
+		return ""
+	case string:
+		return value
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(value)
+	default:
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			return ""
+		}
+		return string(encoded)
+	}
+}
+
+// matchEventType reports whether an event type matches any of the glob
+// patterns, e.g. "order.*" matches "order.created"
+func matchEventType(patterns []string, eventType string) bool {
+	for _, pattern := range patterns {
+		if matched, _ := path.Match(pattern, eventType); matched {
+			return true
+		}
+	}
+	return false
+}
+
+func validateEventTypePattern(pattern string) error {
+	if _, err := path.Match(pattern, ""); err != nil {
+		return fmt.Errorf("invalid event type pattern %q: %w", pattern, err)
+	}
+	return nil
+}
@@ -0,0 +1,147 @@
+package kafka
+
+import (
+	"testing"
+
+	"github.com/IBM/sarama"
+	"github.com/distributed-event-processor/services/event-gateway/internal/config"
+	"github.com/distributed-event-processor/services/event-gateway/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func createKeyTestEvent() *models.Event {
+	return &models.Event{
+		ID:            "event-1",
+		Type:          "order.created",
+		Source:        "order-service",
+		Subject:       "order-42",
+		TenantID:      "tenant-a",
+		CorrelationID: "corr-7",
+		Data: map[string]interface{}{
+			"customer": map[string]interface{}{
+				"id":   "cust-9",
+				"tier": float64(3),
+			},
+			"items": []interface{}{
+				map[string]interface{}{"sku": "sku-1"},
+			},
+		},
+	}
+}
+
+func resolveKey(t *testing.T, cfg config.PartitionKeyConfig, event *models.Event) sarama.Encoder {
+	resolver, err := newPartitionKeyResolver(cfg)
+	require.NoError(t, err)
+	return resolver.Key(event)
+}
+
+func TestPartitionKeyResolver_Strategies(t *testing.T) {
+	tests := []struct {
+		name     string
+		cfg      config.PartitionKeyConfig
+		expected sarama.Encoder
+	}{
+		{"default is type", config.PartitionKeyConfig{}, sarama.StringEncoder("order.created")},
+		{"subject", config.PartitionKeyConfig{Strategy: KeyBySubject}, sarama.StringEncoder("order-42")},
+		{"tenant", config.PartitionKeyConfig{Strategy: KeyByTenant}, sarama.StringEncoder("tenant-a")},
+		{"correlation", config.PartitionKeyConfig{Strategy: KeyByCorrelationID}, sarama.StringEncoder("corr-7")},
+		{"source", config.PartitionKeyConfig{Strategy: KeyBySource}, sarama.StringEncoder("order-service")},
+		{"jsonpath string", config.PartitionKeyConfig{Strategy: KeyByJSONPath, Path: "$.customer.id"}, sarama.StringEncoder("cust-9")},
+		{"jsonpath number", config.PartitionKeyConfig{Strategy: KeyByJSONPath, Path: "$.customer.tier"}, sarama.StringEncoder("3")},
+		{"jsonpath array", config.PartitionKeyConfig{Strategy: KeyByJSONPath, Path: "$.items[0].sku"}, sarama.StringEncoder("sku-1")},
+		{
+			"composite",
+			config.PartitionKeyConfig{Strategy: KeyComposite, Fields: []string{"tenant_id", "$.customer.id"}},
+			sarama.StringEncoder("tenant-a:cust-9"),
+		},
+		{
+			"composite separator",
+			config.PartitionKeyConfig{Strategy: KeyComposite, Fields: []string{"tenant_id", "subject"}, Separator: "/"},
+			sarama.StringEncoder("tenant-a/order-42"),
+		},
+		{"none", config.PartitionKeyConfig{Strategy: KeyNone}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, resolveKey(t, tt.cfg, createKeyTestEvent()))
+		})
+	}
+}
+
+func TestPartitionKeyResolver_EmptyKeyIsKeyless(t *testing.T) {
+	event := createKeyTestEvent()
+	event.Subject = ""
+
+	assert.Nil(t, resolveKey(t, config.PartitionKeyConfig{Strategy: KeyBySubject}, event))
+	assert.Nil(t, resolveKey(t, config.PartitionKeyConfig{Strategy: KeyByJSONPath, Path: "$.customer.missing"}, event))
+	assert.Nil(t, resolveKey(t, config.PartitionKeyConfig{Strategy: KeyByJSONPath, Path: "$.items[5].sku"}, event))
+}
+
+func TestPartitionKeyResolver_Overrides(t *testing.T) {
+	cfg := config.PartitionKeyConfig{
+		Strategy: KeyBySubject,
+		Overrides: []config.PartitionKeyOverride{
+			{EventTypes: []string{"order.*"}, Strategy: KeyByTenant},
+			{EventTypes: []string{"metrics.sample"}, Strategy: KeyNone},
+		},
+	}
+
+	order := createKeyTestEvent()
+	assert.Equal(t, sarama.StringEncoder("tenant-a"), resolveKey(t, cfg, order))
+
+	user := createKeyTestEvent()
+	user.Type = "user.created"
+	assert.Equal(t, sarama.StringEncoder("order-42"), resolveKey(t, cfg, user))
+
+	metrics := createKeyTestEvent()
+	metrics.Type = "metrics.sample"
+	assert.Nil(t, resolveKey(t, cfg, metrics))
+}
+
+func TestNewPartitionKeyResolver_InvalidConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     config.PartitionKeyConfig
+		message string
+	}{
+		{"unknown strategy", config.PartitionKeyConfig{Strategy: "user_id"}, "unknown partition key strategy"},
+		{"jsonpath without path", config.PartitionKeyConfig{Strategy: KeyByJSONPath}, "requires a path"},
+		{"bad jsonpath", config.PartitionKeyConfig{Strategy: KeyByJSONPath, Path: "customer.id"}, "must start with $."},
+		{"bad index", config.PartitionKeyConfig{Strategy: KeyByJSONPath, Path: "$.items[x]"}, "bad array index"},
+		{"composite without fields", config.PartitionKeyConfig{Strategy: KeyComposite}, "requires fields"},
+		{"composite unknown field", config.PartitionKeyConfig{Strategy: KeyComposite, Fields: []string{"region"}}, "unknown composite"},
+		{
+			"override without types",
+			config.PartitionKeyConfig{Overrides: []config.PartitionKeyOverride{{Strategy: KeyBySubject}}},
+			"event_types is required",
+		},
+		{
+			"override bad pattern",
+			config.PartitionKeyConfig{Overrides: []config.PartitionKeyOverride{{EventTypes: []string{"order.["}}}},
+			"invalid event type pattern",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := newPartitionKeyResolver(tt.cfg)
+
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.message)
+		})
+	}
+}
+
+func TestBuildMessage_UsesPartitionKey(t *testing.T) {
+	resolver, err := newPartitionKeyResolver(config.PartitionKeyConfig{Strategy: KeyBySubject})
+	require.NoError(t, err)
+
+	producer := &Producer{config: config.KafkaConfig{Topic: "events"}, keys: resolver}
+
+	message, err := producer.buildMessage(createKeyTestEvent())
+
+	require.NoError(t, err)
+	assert.Equal(t, sarama.StringEncoder("order-42"), message.Key)
+}
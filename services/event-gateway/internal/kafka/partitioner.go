@@ -0,0 +1,103 @@
+package kafka
+
+import (
+	"fmt"
+	"math/rand"
+	"sync"
+
+	"github.com/IBM/sarama"
+	"github.com/distributed-event-processor/services/event-gateway/internal/config"
+)
+
+// Keyless partitioning modes for events produced without a key
+const (
+	KeylessRandom     = "random"
+	KeylessRoundRobin = "round_robin"
+	KeylessSticky     = "sticky"
+)
+
+// keyAwarePartitioner hashes keyed messages with the configured partitioner and
+// spreads keyless messages with a separate strategy
+type keyAwarePartitioner struct {
+	keyed   sarama.Partitioner
+	keyless sarama.Partitioner
+}
+
+func (p *keyAwarePartitioner) Partition(message *sarama.ProducerMessage, numPartitions int32) (int32, error) {
+	if message.Key == nil {
+		return p.keyless.Partition(message, numPartitions)
+	}
+	return p.keyed.Partition(message, numPartitions)
+}
+
+func (p *keyAwarePartitioner) RequiresConsistency() bool {
+	return p.keyed.RequiresConsistency()
+}
+
+// MessageRequiresConsistency lets sarama move keyless messages off unavailable partitions
+func (p *keyAwarePartitioner) MessageRequiresConsistency(message *sarama.ProducerMessage) bool {
+	if message.Key == nil {
+		return false
+	}
+	return p.keyed.RequiresConsistency()
+}
+
+// stickyPartitioner sends keyless messages to one partition until a batch worth
+// of messages has been sent, which yields fuller batches than random placement
+type stickyPartitioner struct {
+	mu        sync.Mutex
+	batchSize int
+	partition int32
+	remaining int
+}
+
+func (p *stickyPartitioner) Partition(_ *sarama.ProducerMessage, numPartitions int32) (int32, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.remaining <= 0 || p.partition >= numPartitions {
+		p.partition = int32(rand.Intn(int(numPartitions)))
+		p.remaining = p.batchSize
+	}
+	p.remaining--
+	return p.partition, nil
+}
+
+func (p *stickyPartitioner) RequiresConsistency() bool {
+	return false
+}
+
+// newPartitioner returns the configured partitioner for keyed messages,
+// combined with the keyless strategy
+func newPartitioner(cfg config.KafkaConfig) (sarama.PartitionerConstructor, error) {
+	partitioner, ok := partitioners[cfg.Partitioner]
+	if !ok {
+		return nil, fmt.Errorf("unknown partitioner: %s (expected: hash, reference_hash, crc32, random or round_robin)", cfg.Partitioner)
+	}
+	return newKeyAwarePartitioner(partitioner, cfg.PartitionKey.Keyless, cfg.BatchSize)
+}
+
+// newKeyAwarePartitioner combines the keyed partitioner with a keyless strategy
+func newKeyAwarePartitioner(keyed sarama.PartitionerConstructor, keyless string, batchSize int) (sarama.PartitionerConstructor, error) {
+	var newKeyless sarama.PartitionerConstructor
+
+	switch keyless {
+	case KeylessRandom, "":
+		newKeyless = sarama.NewRandomPartitioner
+	case KeylessRoundRobin:
+		newKeyless = sarama.NewRoundRobinPartitioner
+	case KeylessSticky:
+		newKeyless = func(string) sarama.Partitioner {
+			return &stickyPartitioner{batchSize: max(batchSize, 1)}
+		}
+	default:
+		return nil, fmt.Errorf("unknown keyless partitioner: %s (expected: random, round_robin or sticky)", keyless)
+	}
+
+	return func(topic string) sarama.Partitioner {
+		return &keyAwarePartitioner{
+			keyed:   keyed(topic),
+			keyless: newKeyless(topic),
+		}
+	}, nil
+}
@@ -0,0 +1,73 @@
+package kafka
+
+import (
+	"testing"
+
+	"github.com/IBM/sarama"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeyAwarePartitioner_KeyedMessagesAreConsistent(t *testing.T) {
+	newPartitioner, err := newKeyAwarePartitioner(sarama.NewHashPartitioner, KeylessRoundRobin, 100)
+	require.NoError(t, err)
+	partitioner := newPartitioner("events")
+
+	message := &sarama.ProducerMessage{Key: sarama.StringEncoder("order-42")}
+	first, err := partitioner.Partition(message, 12)
+	require.NoError(t, err)
+
+	for i := 0; i < 10; i++ {
+		partition, err := partitioner.Partition(message, 12)
+		require.NoError(t, err)
+		assert.Equal(t, first, partition)
+	}
+
+	dynamic, ok := partitioner.(sarama.DynamicConsistencyPartitioner)
+	require.True(t, ok)
+	assert.True(t, dynamic.MessageRequiresConsistency(message))
+	assert.False(t, dynamic.MessageRequiresConsistency(&sarama.ProducerMessage{}))
+}
+
+func TestKeyAwarePartitioner_RoundRobinKeyless(t *testing.T) {
+	newPartitioner, err := newKeyAwarePartitioner(sarama.NewHashPartitioner, KeylessRoundRobin, 100)
+	require.NoError(t, err)
+	partitioner := newPartitioner("events")
+
+	var partitions []int32
+	for i := 0; i < 6; i++ {
+		partition, err := partitioner.Partition(&sarama.ProducerMessage{}, 3)
+		require.NoError(t, err)
+		partitions = append(partitions, partition)
+	}
+
+	assert.Equal(t, []int32{0, 1, 2, 0, 1, 2}, partitions)
+}
+
+func TestKeyAwarePartitioner_StickyKeyless(t *testing.T) {
+	newPartitioner, err := newKeyAwarePartitioner(sarama.NewHashPartitioner, KeylessSticky, 4)
+	require.NoError(t, err)
+	partitioner := newPartitioner("events")
+
+	first, err := partitioner.Partition(&sarama.ProducerMessage{}, 8)
+	require.NoError(t, err)
+
+	// The remaining messages of the batch stick to the same partition
+	for i := 0; i < 3; i++ {
+		partition, err := partitioner.Partition(&sarama.ProducerMessage{}, 8)
+		require.NoError(t, err)
+		assert.Equal(t, first, partition)
+	}
+
+	// A shrinking partition count forces a new choice within range
+	partition, err := partitioner.Partition(&sarama.ProducerMessage{}, 1)
+	require.NoError(t, err)
+	assert.Equal(t, int32(0), partition)
+}
+
+func TestNewKeyAwarePartitioner_UnknownKeyless(t *testing.T) {
+	_, err := newKeyAwarePartitioner(sarama.NewHashPartitioner, "least_loaded", 100)
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "unknown keyless partitioner")
+}
@@ -2,98 +2,267 @@ package kafka
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
-	"time"
+	"strconv"
+	"sync"
 
 	"github.com/IBM/sarama"
 	"github.com/distributed-event-processor/services/event-gateway/internal/config"
 	"github.com/distributed-event-processor/services/event-gateway/internal/models"
+	"github.com/distributed-event-processor/services/event-gateway/internal/spool"
 	"go.uber.org/zap"
 )
 
+// Producer modes supported by NewProducer
+const (
+	ModeSync  = "sync"
+	ModeAsync = "async"
+)
+
 type Producer struct {
 	producer sarama.SyncProducer
+	async    sarama.AsyncProducer
 	config   config.KafkaConfig
 	logger   *zap.Logger
+	keys     *partitionKeyResolver
+	router   *topicRouter
+	codecs   *serializers
+	headers  *headerMapper
+	spool    *spool.Spool
+	health   *healthProber
+	dlq      *deadLetterQueue
+	breaker  *circuitBreaker
+
+	// attempts is how many times sarama tries a message before giving up
+	attempts int
+
+	// txnMu serializes transactions, since a transactional producer can only
+	// have one transaction open at a time
+	txnMu sync.Mutex
+
+	// closeMu guards sends to the async input channel against a concurrent Close
+	closeMu sync.RWMutex
+	closed  bool
+	wg      sync.WaitGroup
+
+	// stopReplay ends the loop that drains the spool back into Kafka
+	stopReplay context.CancelFunc
+	replayWG   sync.WaitGroup
+}
+
+// DeliveryResult describes the outcome of producing a single event. Cluster
+// names the Kafka cluster the event was sent to, and Rule the routing rule
+// that selected the topic. Queued is set when Kafka was unavailable and the
+// event was written to the local spool instead; it will be produced later, so
+// Partition and Offset are not known yet. Dropped is set when a priority lane
+// shed the event under load and discarded it without sending it. Duplicate
+// is set when the event's idempotency key was already seen; the result is
+// that of the original event, which was not produced again.
+type DeliveryResult struct {
+	Cluster   string
+	Topic     string
+	Rule      string
+	Partition int32
+	Offset    int64
+	Queued    bool
+	Dropped   bool
+	Duplicate bool
+	Err       error
+}
+
+// deliveryContext travels with each message as its Metadata so that the outcome
+// can be attributed to the originating event, and in async mode routed back to
+// the caller waiting on result. Replayed messages already came from the spool
+// and are never spooled again.
+type deliveryContext struct {
+	eventID string
+	rule    string
+	event   *models.Event
+	replay  bool
+	result  chan DeliveryResult
 }
 
 func NewProducer(cfg config.KafkaConfig, logger *zap.Logger) (*Producer, error) {
-	saramaConfig := sarama.NewConfig()
-	saramaConfig.Producer.RequiredAcks = sarama.WaitForAll
-	saramaConfig.Producer.Retry.Max = cfg.Retries
-	saramaConfig.Producer.Return.Successes = true
-	saramaConfig.Producer.Flush.Frequency = 500 * time.Millisecond
-	saramaConfig.Producer.Flush.Messages = cfg.BatchSize
+	logger = logger.With(zap.String("cluster", clusterName(cfg)))
+
+	saramaConfig, err := newSaramaConfig(cfg, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	p, err := newMessageBuilder(cfg, logger)
+	if err != nil {
+		return nil, err
+	}
+	p.attempts = saramaConfig.Producer.Retry.Max + 1
+
+	if cfg.CircuitBreaker.Enabled {
+		if p.breaker, err = newCircuitBreaker(cfg.CircuitBreaker, clusterName(cfg), logger); err != nil {
+			return nil, err
+		}
+	}
+
+	if cfg.Routing.AutoCreateTopics {
+		topics := p.router.Topics()
+		if cfg.Health.Canary {
+			topics = append(topics, cfg.Health.CanaryTopic)
+		}
+		if cfg.DeadLetter.Enabled {
+			topics = append(topics, cfg.DeadLetter.Topic)
+		}
+		if err := createTopics(cfg, saramaConfig, p.router, topics, logger); err != nil {
+			return nil, err
+		}
+	}
+
+	switch cfg.ProducerMode {
+	case ModeSync, "":
+		producer, err := sarama.NewSyncProducer(cfg.Brokers, saramaConfig)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create Kafka producer: %w", err)
+		}
+		p.producer = producer
+	case ModeAsync:
+		producer, err := sarama.NewAsyncProducer(cfg.Brokers, saramaConfig)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create Kafka producer: %w", err)
+		}
+		p.async = producer
+		p.startDeliveryLoop()
+	default:
+		return nil, fmt.Errorf("unknown producer mode: %s (expected: %s or %s)", cfg.ProducerMode, ModeSync, ModeAsync)
+	}
+
+	if cfg.DeadLetter.Enabled {
+		dlq, err := newDeadLetterQueue(cfg, saramaConfig, logger)
+		if err != nil {
+			p.Close()
+			return nil, err
+		}
+		p.dlq = dlq
+	}
+
+	if cfg.Spool.Enabled {
+		s, err := openSpool(cfg, logger)
+		if err != nil {
+			p.Close()
+			return nil, err
+		}
+		p.spool = s
+		p.startReplayLoop()
+	}
+
+	health, err := newHealthProber(cfg, saramaConfig, p.router.Topics(), logger)
+	if err != nil {
+		p.Close()
+		return nil, err
+	}
+	p.health = health
+	p.health.start()
+
+	return p, nil
+}
+
+// newMessageBuilder returns a Producer that can build messages from events, with
+// routing, partition keys, serialization and headers set up, but that is not
+// connected to Kafka
+func newMessageBuilder(cfg config.KafkaConfig, logger *zap.Logger) (*Producer, error) {
+	keys, err := newPartitionKeyResolver(cfg.PartitionKey)
+	if err != nil {
+		return nil, err
+	}
 
-	// Use custom partitioner for better distribution
-	saramaConfig.Producer.Partitioner = sarama.NewHashPartitioner
+	router, err := newTopicRouter(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	codecs, err := newSerializers(cfg.Serialization, router.Topics())
+	if err != nil {
+		return nil, err
+	}
 
-	producer, err := sarama.NewSyncProducer(cfg.Brokers, saramaConfig)
+	headers, err := newHeaderMapper(cfg.Headers)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create Kafka producer: %w", err)
+		return nil, err
 	}
 
 	return &Producer{
-		producer: producer,
-		config:   cfg,
-		logger:   logger,
+		config:  cfg,
+		logger:  logger,
+		keys:    keys,
+		router:  router,
+		codecs:  codecs,
+		headers: headers,
 	}, nil
 }
 
 // ProduceEvent sends an event to Kafka with context support and returns partition and offset
 func (p *Producer) ProduceEvent(ctx context.Context, event *models.Event) (int32, int64, error) {
+	result := p.Produce(ctx, event)
+	return result.Partition, result.Offset, result.Err
+}
+
+// Produce sends an event to Kafka and waits for its full delivery result,
+// including the topic and routing rule it was sent with
+func (p *Producer) Produce(ctx context.Context, event *models.Event) DeliveryResult {
+	select {
+	case result := <-p.ProduceEventAsync(ctx, event):
+		return result
+	case <-ctx.Done():
+		return DeliveryResult{Err: ctx.Err()}
+	}
+}
+
+// ProduceEventAsync submits an event to Kafka and returns a channel that receives
+// exactly one DeliveryResult once the broker acknowledges or rejects the message.
+// In sync mode the send happens before returning and the channel is already filled.
+func (p *Producer) ProduceEventAsync(ctx context.Context, event *models.Event) <-chan DeliveryResult {
+	result := make(chan DeliveryResult, 1)
+
 	// Check context cancellation before proceeding
 	select {
 	case <-ctx.Done():
-		return 0, 0, ctx.Err()
+		result <- DeliveryResult{Err: ctx.Err()}
+		return result
 	default:
 	}
 
-	// Serialize event to JSON
-	eventData, err := json.Marshal(event)
+	message, err := p.buildMessage(event)
 	if err != nil {
-		return 0, 0, fmt.Errorf("failed to serialize event: %w", err)
+		p.deadLetterEvent(event, err)
+		result <- DeliveryResult{Err: err}
+		return result
 	}
 
-	// Create Kafka message
-	message := &sarama.ProducerMessage{
-		Topic: p.config.Topic,
-		Key:   sarama.StringEncoder(event.Type), // Partition by event type
-		Value: sarama.ByteEncoder(eventData),
-		Headers: []sarama.RecordHeader{
-			{
-				Key:   []byte("event_id"),
-				Value: []byte(event.ID),
-			},
-			{
-				Key:   []byte("event_type"),
-				Value: []byte(event.Type),
-			},
-			{
-				Key:   []byte("source"),
-				Value: []byte(event.Source),
-			},
-		},
-		Timestamp: event.Timestamp,
+	// Events queue behind a spool backlog so that they reach Kafka in order
+	if p.spoolBehindBacklog(message) {
+		result <- p.queuedResult(message)
+		return result
 	}
 
-	// Send message
-	partition, offset, err := p.producer.SendMessage(message)
-	if err != nil {
-		p.logger.Error("Failed to send event to Kafka",
-			zap.String("event_id", event.ID),
-			zap.Error(err))
-		return 0, 0, fmt.Errorf("failed to send event to Kafka: %w", err)
+	if err := p.breaker.allow(); err != nil {
+		result <- p.rejectOpen(message, err)
+		return result
 	}
 
-	p.logger.Debug("Event sent to Kafka",
-		zap.String("event_id", event.ID),
-		zap.String("topic", p.config.Topic),
-		zap.Int32("partition", partition),
-		zap.Int64("offset", offset))
+	if p.async != nil {
+		p.enqueue(ctx, message, result)
+		return result
+	}
+
+	// Transactional producers may only send inside a transaction
+	if p.Transactional() {
+		err := p.sendTransaction([]*sarama.ProducerMessage{message})
+		result <- p.completeDelivery(message, err)
+		return result
+	}
 
-	return partition, offset, nil
+	// Send message; sarama records the partition and offset on the message
+	_, _, err = p.producer.SendMessage(message)
+	result <- p.completeDelivery(message, err)
+	return result
 }
 
 func (p *Producer) SendEvent(event *models.Event) error {
@@ -101,26 +270,337 @@ func (p *Producer) SendEvent(event *models.Event) error {
 	return err
 }
 
+// SendBatchEvents produces all events as a single batch and returns the first delivery error
 func (p *Producer) SendBatchEvents(events []*models.Event) error {
-	for _, event := range events {
-		if err := p.SendEvent(event); err != nil {
-			return err
+	for _, result := range p.ProduceBatch(context.Background(), events) {
+		if result.Err != nil {
+			return result.Err
 		}
 	}
 	return nil
 }
 
+// ProduceBatch submits every event to Kafka at once and returns one DeliveryResult
+// per event, in the same order as the input. A failure of one event does not stop
+// the others from being produced, except with transactional delivery where the
+// batch is committed atomically and either every event succeeds or none do.
+func (p *Producer) ProduceBatch(ctx context.Context, events []*models.Event) []DeliveryResult {
+	results := make([]DeliveryResult, len(events))
+	if len(events) == 0 {
+		return results
+	}
+
+	select {
+	case <-ctx.Done():
+		for i := range results {
+			results[i] = DeliveryResult{Err: ctx.Err()}
+		}
+		return results
+	default:
+	}
+
+	// Serialization failures are reported per event; everything else is sent together
+	messages := make([]*sarama.ProducerMessage, 0, len(events))
+	indices := make([]int, 0, len(events))
+	for i, event := range events {
+		message, err := p.buildMessage(event)
+		if err != nil {
+			p.deadLetterEvent(event, err)
+			results[i] = DeliveryResult{Err: err}
+			continue
+		}
+		messages = append(messages, message)
+		indices = append(indices, i)
+	}
+
+	// Events queue behind a spool backlog so that they reach Kafka in order
+	sending, sendingIndices := messages[:0], indices[:0]
+	for j, message := range messages {
+		if p.spoolBehindBacklog(message) {
+			results[indices[j]] = p.queuedResult(message)
+			continue
+		}
+		sending = append(sending, message)
+		sendingIndices = append(sendingIndices, indices[j])
+	}
+	messages, indices = sending, sendingIndices
+
+	if len(messages) == 0 {
+		return results
+	}
+
+	// The breaker admits or rejects the batch as a whole
+	if err := p.breaker.allow(); err != nil {
+		for j, message := range messages {
+			results[indices[j]] = p.rejectOpen(message, err)
+		}
+		return results
+	}
+
+	if p.Transactional() {
+		p.produceBatchTransactional(events, messages, indices, results)
+		return results
+	}
+
+	p.sendBatch(ctx, messages, indices, results)
+	return results
+}
+
+// sendBatch produces already built messages together and stores each outcome
+// in results at the matching index
+func (p *Producer) sendBatch(ctx context.Context, messages []*sarama.ProducerMessage, indices []int, results []DeliveryResult) {
+	if p.async != nil {
+		p.produceBatchAsync(ctx, messages, indices, results)
+		return
+	}
+
+	err := p.producer.SendMessages(messages)
+
+	// SendMessages reports failures as ProducerErrors keyed by message; any other
+	// error means the batch as a whole could not be sent
+	failed := make(map[*sarama.ProducerMessage]error)
+	var producerErrs sarama.ProducerErrors
+	if errors.As(err, &producerErrs) {
+		for _, producerErr := range producerErrs {
+			failed[producerErr.Msg] = producerErr.Err
+		}
+	} else if err != nil {
+		for _, message := range messages {
+			failed[message] = err
+		}
+	}
+
+	for j, message := range messages {
+		results[indices[j]] = p.completeDelivery(message, failed[message])
+	}
+
+	p.logger.Debug("Batch sent to Kafka",
+		zap.Int("event_count", len(messages)),
+		zap.Int("failed_count", len(failed)))
+}
+
+// produceBatchAsync pipelines a batch through the async producer and waits for every ack
+func (p *Producer) produceBatchAsync(ctx context.Context, messages []*sarama.ProducerMessage, indices []int, results []DeliveryResult) {
+	deliveries := make([]chan DeliveryResult, len(messages))
+	for j, message := range messages {
+		deliveries[j] = make(chan DeliveryResult, 1)
+		p.enqueue(ctx, message, deliveries[j])
+	}
+
+	for j, delivery := range deliveries {
+		select {
+		case results[indices[j]] = <-delivery:
+		case <-ctx.Done():
+			results[indices[j]] = DeliveryResult{Topic: messages[j].Topic, Err: ctx.Err()}
+		}
+	}
+}
+
 func (p *Producer) Close() error {
-	return p.producer.Close()
+	if p.health != nil {
+		p.health.close()
+	}
+
+	if p.stopReplay != nil {
+		p.stopReplay()
+		p.replayWG.Wait()
+	}
+
+	var err error
+	if p.async != nil {
+		p.closeMu.Lock()
+		p.closed = true
+		p.closeMu.Unlock()
+
+		// AsyncClose flushes buffered messages and then closes the Successes and
+		// Errors channels, which lets the delivery loop finish every pending result
+		p.async.AsyncClose()
+		p.wg.Wait()
+	} else if p.producer != nil {
+		err = p.producer.Close()
+	}
+
+	// The spool and dead-letter queue close last, since failed deliveries
+	// during the flush above still end up in them
+	if p.spool != nil {
+		if spoolErr := p.spool.Close(); err == nil {
+			err = spoolErr
+		}
+	}
+	if p.dlq != nil {
+		if dlqErr := p.dlq.close(); err == nil {
+			err = dlqErr
+		}
+	}
+	return err
+}
+
+// Transactional reports whether batches are committed atomically in a Kafka transaction
+func (p *Producer) Transactional() bool {
+	return p.config.DeliveryGuarantee == GuaranteeTransactional
 }
 
-// IsHealthy checks if the Kafka producer is healthy and can send messages
+// IsHealthy reports whether the last background health check found the
+// cluster reachable and every destination topic led
 func (p *Producer) IsHealthy() bool {
-	if p.producer == nil {
-		return false
+	return p.Health().Healthy
+}
+
+// Health returns the cached result of the last Kafka health check. An open
+// circuit breaker marks Kafka unhealthy whatever the last check found.
+func (p *Producer) Health() HealthStatus {
+	if p.producer == nil && p.async == nil {
+		return HealthStatus{Message: "Kafka producer is not initialized"}
+	}
+
+	status := HealthStatus{
+		Healthy: true,
+		Message: "Kafka health prober is not running",
+		Details: make(map[string]string),
+	}
+	if p.health != nil {
+		status = p.health.Status()
+	}
+
+	if p.spool != nil {
+		status.Details["spooled_events"] = strconv.Itoa(p.spool.Len())
+	}
+	if p.breaker != nil {
+		state := p.breaker.State()
+		status.Details["circuit_breaker"] = state
+		if state == CircuitOpen {
+			status.Healthy = false
+			status.Message = "Kafka circuit breaker is open"
+		}
+	}
+	return status
+}
+
+// buildMessage serializes an event into a Kafka message in its topic's format
+func (p *Producer) buildMessage(event *models.Event) (*sarama.ProducerMessage, error) {
+	topic, rule := p.route(event)
+
+	serializer := p.serializer(topic)
+	eventData, err := serializer.Serialize(topic, event)
+	if err != nil {
+		return nil, fmt.Errorf("failed to serialize event: %w", err)
+	}
+
+	// Create Kafka message
+	return &sarama.ProducerMessage{
+		Topic:     topic,
+		Key:       p.partitionKey(event),
+		Value:     sarama.ByteEncoder(eventData),
+		Headers:   p.recordHeaders(event, serializer.ContentType()),
+		Timestamp: event.Timestamp,
+		Metadata:  &deliveryContext{eventID: event.ID, rule: rule, event: event},
+	}, nil
+}
+
+// cluster names the Kafka cluster this producer writes to
+func (p *Producer) cluster() string {
+	return clusterName(p.config)
+}
+
+// route selects the destination topic, falling back to the configured topic
+// when no routing table is set up
+func (p *Producer) route(event *models.Event) (string, string) {
+	if p.router == nil {
+		return p.config.Topic, defaultRule
+	}
+	return p.router.Route(event)
+}
+
+// serializer returns the serializer for topic, writing JSON when no
+// serialization is configured
+func (p *Producer) serializer(topic string) Serializer {
+	if p.codecs == nil {
+		return jsonSerializer{}
+	}
+	return p.codecs.For(topic)
+}
+
+// recordHeaders returns the envelope headers of event followed by its content
+// type, using the default mapping when no header configuration is set
+func (p *Producer) recordHeaders(event *models.Event, contentType string) []sarama.RecordHeader {
+	mapper := p.headers
+	if mapper == nil {
+		mapper = defaultHeaders
+	}
+	headers := mapper.Headers(event)
+	return append(headers, sarama.RecordHeader{Key: []byte(contentTypeHeader), Value: []byte(contentType)})
+}
+
+// partitionKey resolves the message key, partitioning by event type when no
+// key strategy is configured
+func (p *Producer) partitionKey(event *models.Event) sarama.Encoder {
+	if p.keys == nil {
+		return sarama.StringEncoder(event.Type)
+	}
+	return p.keys.Key(event)
+}
+
+// completeDelivery logs and records the outcome of a send and converts it to a DeliveryResult
+func (p *Producer) completeDelivery(message *sarama.ProducerMessage, err error) DeliveryResult {
+	p.breaker.record(err)
+	return p.deliveryResult(message, err)
+}
+
+// deliveryResult is completeDelivery without the circuit breaker, for sends
+// whose outcome the caller has already recorded
+func (p *Producer) deliveryResult(message *sarama.ProducerMessage, err error) DeliveryResult {
+	var eventID, rule string
+	var event *models.Event
+	var replay bool
+	if delivery, ok := message.Metadata.(*deliveryContext); ok {
+		eventID, rule, event, replay = delivery.eventID, delivery.rule, delivery.event, delivery.replay
+	}
+
+	if err != nil {
+		produceErrors.WithLabelValues(p.cluster(), message.Topic).Inc()
+		if !replay && p.spoolFailed(message, eventID, err) {
+			return DeliveryResult{Cluster: p.cluster(), Topic: message.Topic, Rule: rule, Queued: true}
+		}
+		// Replayed events that may still succeed stay in the spool for the next
+		// attempt. Only a replay has no client waiting for the outcome.
+		if !replay || !spoolable(err) {
+			p.deadLetterMessage(message, event, err, !replay)
+		}
+		p.logger.Error("Failed to send event to Kafka",
+			zap.String("event_id", eventID),
+			zap.String("topic", message.Topic),
+			zap.Error(err))
+		return DeliveryResult{Cluster: p.cluster(), Topic: message.Topic, Rule: rule, Err: fmt.Errorf("failed to send event to Kafka: %w", err)}
+	}
+
+	messagesProduced.WithLabelValues(p.cluster(), message.Topic).Inc()
+	routedEvents.WithLabelValues(message.Topic, rule).Inc()
+	p.logger.Debug("Event sent to Kafka",
+		zap.String("event_id", eventID),
+		zap.String("topic", message.Topic),
+		zap.Int32("partition", message.Partition),
+		zap.Int64("offset", message.Offset))
+
+	return DeliveryResult{
+		Cluster:   p.cluster(),
+		Topic:     message.Topic,
+		Rule:      rule,
+		Partition: message.Partition,
+		Offset:    message.Offset,
+	}
+}
+
+// rejectOpen fails a message fast while the circuit breaker is open. With the
+// spool enabled the event is queued for replay instead, which is where a send
+// to the unavailable cluster would have left it anyway.
+func (p *Producer) rejectOpen(message *sarama.ProducerMessage, err error) DeliveryResult {
+	var eventID, rule string
+	if delivery, ok := message.Metadata.(*deliveryContext); ok {
+		eventID, rule = delivery.eventID, delivery.rule
+	}
+
+	if p.spoolFailed(message, eventID, err) {
+		return DeliveryResult{Cluster: p.cluster(), Topic: message.Topic, Rule: rule, Queued: true}
 	}
-	// Check if producer is still connected by verifying it's not closed
-	// Sarama doesn't expose a direct health check, but we can check if the producer exists
-	// A more robust check would involve sending a test message to a health topic
-	return true
+	return DeliveryResult{Cluster: p.cluster(), Topic: message.Topic, Rule: rule, Err: err}
 }
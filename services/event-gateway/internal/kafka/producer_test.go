@@ -178,3 +178,127 @@ func TestClose(t *testing.T) {
 
 	require.NoError(t, err)
 }
+
+// partialFailureSyncProducer fails selected messages of a SendMessages call the
+// way sarama does, by returning ProducerErrors for just those messages
+type partialFailureSyncProducer struct {
+	*mocks.SyncProducer
+	failIndices map[int]error
+}
+
+func (p *partialFailureSyncProducer) SendMessages(msgs []*sarama.ProducerMessage) error {
+	var errs sarama.ProducerErrors
+	for i, msg := range msgs {
+		if err, ok := p.failIndices[i]; ok {
+			errs = append(errs, &sarama.ProducerError{Msg: msg, Err: err})
+			continue
+		}
+		msg.Partition = int32(i % 3)
+		msg.Offset = int64(100 + i)
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func TestProduceBatch_Success(t *testing.T) {
+	mockProducer := mocks.NewSyncProducer(t, nil)
+	mockProducer.ExpectSendMessageAndSucceed()
+	mockProducer.ExpectSendMessageAndSucceed()
+
+	producer := createTestProducer(t, mockProducer)
+	results := producer.ProduceBatch(context.Background(), []*models.Event{
+		createTestEvent(),
+		createTestEvent(),
+	})
+
+	require.Len(t, results, 2)
+	for i, result := range results {
+		require.NoError(t, result.Err)
+		assert.Equal(t, "test-events", result.Topic)
+		assert.Equal(t, int64(i+1), result.Offset)
+	}
+}
+
+func TestProduceBatch_PartialFailure(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+	producer := &Producer{
+		producer: &partialFailureSyncProducer{
+			SyncProducer: mocks.NewSyncProducer(t, nil),
+			failIndices:  map[int]error{1: sarama.ErrMessageSizeTooLarge},
+		},
+		config: config.KafkaConfig{Topic: "test-events"},
+		logger: logger,
+	}
+
+	results := producer.ProduceBatch(context.Background(), []*models.Event{
+		createTestEvent(),
+		createTestEvent(),
+		createTestEvent(),
+	})
+
+	require.Len(t, results, 3)
+
+	require.NoError(t, results[0].Err)
+	assert.Equal(t, int32(0), results[0].Partition)
+	assert.Equal(t, int64(100), results[0].Offset)
+
+	require.Error(t, results[1].Err)
+	assert.ErrorIs(t, results[1].Err, sarama.ErrMessageSizeTooLarge)
+
+	require.NoError(t, results[2].Err)
+	assert.Equal(t, int32(2), results[2].Partition)
+	assert.Equal(t, int64(102), results[2].Offset)
+}
+
+func TestProduceBatch_BatchError(t *testing.T) {
+	mockProducer := mocks.NewSyncProducer(t, nil)
+	mockProducer.ExpectSendMessageAndFail(sarama.ErrBrokerNotAvailable)
+	mockProducer.ExpectSendMessageAndSucceed()
+
+	producer := createTestProducer(t, mockProducer)
+	results := producer.ProduceBatch(context.Background(), []*models.Event{
+		createTestEvent(),
+		createTestEvent(),
+	})
+
+	// A non-ProducerErrors failure is attributed to every event in the batch
+	require.Len(t, results, 2)
+	for _, result := range results {
+		assert.ErrorIs(t, result.Err, sarama.ErrBrokerNotAvailable)
+	}
+}
+
+func TestProduceBatch_SerializationFailure(t *testing.T) {
+	mockProducer := mocks.NewSyncProducer(t, nil)
+	mockProducer.ExpectSendMessageAndSucceed()
+
+	producer := createTestProducer(t, mockProducer)
+
+	bad := createTestEvent()
+	bad.Data = map[string]interface{}{"ch": make(chan int)}
+
+	results := producer.ProduceBatch(context.Background(), []*models.Event{
+		bad,
+		createTestEvent(),
+	})
+
+	require.Len(t, results, 2)
+	require.Error(t, results[0].Err)
+	assert.Contains(t, results[0].Err.Error(), "failed to serialize event")
+	require.NoError(t, results[1].Err)
+}
+
+func TestProduceBatch_ContextCancelled(t *testing.T) {
+	mockProducer := mocks.NewSyncProducer(t, nil)
+	producer := createTestProducer(t, mockProducer)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	results := producer.ProduceBatch(ctx, []*models.Event{createTestEvent()})
+
+	require.Len(t, results, 1)
+	assert.Equal(t, context.Canceled, results[0].Err)
+}
@@ -0,0 +1,94 @@
+package kafka
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/distributed-event-processor/services/event-gateway/internal/models"
+	pb "github.com/distributed-event-processor/shared/proto/events/v1"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// protobufSerializer writes events as events.v1.Event in the Confluent
+// protobuf wire format: magic byte, schema ID, the index path of Event within
+// events.proto, then the message itself
+type protobufSerializer struct {
+	registry *schemaRegistry
+}
+
+func (s *protobufSerializer) Serialize(topic string, event *models.Event) ([]byte, error) {
+	schemaID, err := s.resolveSchema(topic)
+	if err != nil {
+		return nil, err
+	}
+
+	message, err := modelToProto(event)
+	if err != nil {
+		return nil, err
+	}
+
+	buf := appendWireHeader(nil, schemaID)
+	buf = appendMessageIndexes(buf, eventMessageIndexes())
+	return proto.MarshalOptions{}.MarshalAppend(buf, message)
+}
+
+func (s *protobufSerializer) ContentType() string {
+	return ContentTypeProtobuf
+}
+
+func (s *protobufSerializer) resolveSchema(topic string) (int, error) {
+	return s.registry.schemaID(valueSubject(topic), schemaTypeProtobuf, pb.EventsProto)
+}
+
+// eventMessageIndexes locates Event within events.proto, outermost first
+func eventMessageIndexes() []int {
+	var indexes []int
+	var desc protoreflect.Descriptor = (&pb.Event{}).ProtoReflect().Descriptor()
+	for {
+		message, ok := desc.(protoreflect.MessageDescriptor)
+		if !ok {
+			return indexes
+		}
+		indexes = append([]int{message.Index()}, indexes...)
+		desc = message.Parent()
+	}
+}
+
+// appendMessageIndexes writes the index path as zigzag varints prefixed with
+// its length, using the single byte 0 for the common path [0]
+func appendMessageIndexes(buf []byte, indexes []int) []byte {
+	if len(indexes) == 1 && indexes[0] == 0 {
+		return append(buf, 0)
+	}
+	buf = binary.AppendVarint(buf, int64(len(indexes)))
+	for _, index := range indexes {
+		buf = binary.AppendVarint(buf, int64(index))
+	}
+	return buf
+}
+
+// modelToProto converts an event to its protobuf form
+func modelToProto(event *models.Event) (*pb.Event, error) {
+	data, err := structpb.NewStruct(event.Data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert event data to protobuf: %w", err)
+	}
+
+	return &pb.Event{
+		Id:            event.ID,
+		Type:          event.Type,
+		Source:        event.Source,
+		Subject:       event.Subject,
+		TenantId:      event.TenantID,
+		Data:          data,
+		Timestamp:     timestamppb.New(event.Timestamp),
+		Version:       event.Version,
+		SchemaVersion: event.SchemaVersion,
+		Metadata:      event.Metadata,
+		CorrelationId: event.CorrelationID,
+		Priority:      int32(event.Priority),
+	}, nil
+}
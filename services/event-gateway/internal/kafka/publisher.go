@@ -0,0 +1,92 @@
+package kafka
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/distributed-event-processor/services/event-gateway/internal/config"
+	"github.com/distributed-event-processor/services/event-gateway/internal/models"
+	"go.uber.org/zap"
+)
+
+// Publisher modes
+const (
+	PublisherKafka  = "kafka"
+	PublisherMemory = "memory"
+)
+
+// EventPublisher publishes events for the API handlers. Producer writes them to
+// a Kafka cluster, ClusterSet to a primary and a secondary cluster, and
+// MemoryBroker keeps them in memory for tests and local development.
+// PriorityLanes queues events by priority in front of any of them.
+type EventPublisher interface {
+	// Produce publishes an event and waits for the outcome
+	Produce(ctx context.Context, event *models.Event) DeliveryResult
+
+	// ProduceEventAsync publishes an event without waiting. The outcome is
+	// delivered on the returned channel.
+	ProduceEventAsync(ctx context.Context, event *models.Event) <-chan DeliveryResult
+
+	// ProduceBatch publishes events together and returns one result per event
+	ProduceBatch(ctx context.Context, events []*models.Event) []DeliveryResult
+
+	// Transactional reports whether batches are delivered all-or-nothing
+	Transactional() bool
+
+	// IsHealthy reports whether events can currently be published
+	IsHealthy() bool
+
+	// Health returns the details behind IsHealthy
+	Health() HealthStatus
+
+	// Close flushes pending events and releases resources
+	Close() error
+}
+
+var (
+	_ EventPublisher = (*Producer)(nil)
+	_ EventPublisher = (*MemoryBroker)(nil)
+	_ EventPublisher = (*ClusterSet)(nil)
+	_ EventPublisher = (*PriorityLanes)(nil)
+)
+
+// NewPublisher creates the publisher selected by cfg.Mode. In kafka mode an
+// enabled secondary cluster puts both clusters behind a ClusterSet. Enabled
+// priority lanes queue events in front of either mode.
+func NewPublisher(cfg config.KafkaConfig, logger *zap.Logger) (EventPublisher, error) {
+	if !cfg.PriorityLanes.Enabled {
+		return newModePublisher(cfg, logger)
+	}
+
+	lanes, err := newLanes(cfg.PriorityLanes)
+	if err != nil {
+		return nil, err
+	}
+	cfg.Routing.Rules = append(laneRoutingRules(lanes), cfg.Routing.Rules...)
+
+	next, err := newModePublisher(cfg, logger)
+	if err != nil {
+		return nil, err
+	}
+	publisher, err := newPriorityLanes(lanes, cfg.PriorityLanes.ShedMode, next, logger)
+	if err != nil {
+		next.Close()
+		return nil, err
+	}
+	return publisher, nil
+}
+
+// newModePublisher creates the publisher selected by cfg.Mode
+func newModePublisher(cfg config.KafkaConfig, logger *zap.Logger) (EventPublisher, error) {
+	switch cfg.Mode {
+	case PublisherKafka, "":
+		if cfg.Secondary.Enabled {
+			return NewClusterSet(cfg, logger)
+		}
+		return NewProducer(cfg, logger)
+	case PublisherMemory:
+		return NewMemoryBroker(cfg, logger)
+	default:
+		return nil, fmt.Errorf("unknown kafka mode: %s (expected: %s or %s)", cfg.Mode, PublisherKafka, PublisherMemory)
+	}
+}
@@ -0,0 +1,204 @@
+package kafka
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/IBM/sarama"
+	"github.com/distributed-event-processor/services/event-gateway/internal/config"
+	"github.com/distributed-event-processor/services/event-gateway/internal/models"
+	"go.uber.org/zap"
+)
+
+// defaultRule names the routing decision when no rule matched
+const defaultRule = "default"
+
+type routingRule struct {
+	name        string
+	eventTypes  []string
+	tenants     map[string]struct{}
+	minPriority *int
+	maxPriority *int
+	topic       string
+}
+
+// topicSettings are the partitions and replication factor a rule asks its
+// topic to be created with; zero values leave the routing defaults
+type topicSettings struct {
+	partitions        int32
+	replicationFactor int16
+}
+
+// matches reports whether every criterion set on the rule holds for the event
+func (r *routingRule) matches(event *models.Event) bool {
+	if len(r.eventTypes) > 0 && !matchEventType(r.eventTypes, event.Type) {
+		return false
+	}
+	if len(r.tenants) > 0 {
+		if _, ok := r.tenants[event.TenantID]; !ok {
+			return false
+		}
+	}
+	if r.minPriority != nil && event.Priority < *r.minPriority {
+		return false
+	}
+	if r.maxPriority != nil && event.Priority > *r.maxPriority {
+		return false
+	}
+	return true
+}
+
+// topicRouter maps events to destination topics using an ordered rule table.
+// The first matching rule wins; unmatched events go to the default topic.
+type topicRouter struct {
+	defaultTopic string
+	rules        []routingRule
+	settings     map[string]topicSettings
+}
+
+func newTopicRouter(cfg config.KafkaConfig) (*topicRouter, error) {
+	router := &topicRouter{defaultTopic: cfg.Routing.DefaultTopic, settings: make(map[string]topicSettings)}
+	if router.defaultTopic == "" {
+		router.defaultTopic = cfg.Topic
+	}
+
+	for i, rule := range cfg.Routing.Rules {
+		name := rule.Name
+		if name == "" {
+			name = fmt.Sprintf("rule-%d", i)
+		}
+
+		if rule.Topic == "" {
+			return nil, fmt.Errorf("routing rule %s: topic is required", name)
+		}
+		if len(rule.EventTypes) == 0 && len(rule.Tenants) == 0 && rule.MinPriority == nil && rule.MaxPriority == nil {
+			return nil, fmt.Errorf("routing rule %s: at least one of event_types, tenants, min_priority or max_priority is required", name)
+		}
+		for _, pattern := range rule.EventTypes {
+			if err := validateEventTypePattern(pattern); err != nil {
+				return nil, fmt.Errorf("routing rule %s: %w", name, err)
+			}
+		}
+		for _, priority := range []*int{rule.MinPriority, rule.MaxPriority} {
+			if priority != nil && (*priority < 0 || *priority > 10) {
+				return nil, fmt.Errorf("routing rule %s: priority bounds must be between 0 and 10", name)
+			}
+		}
+		if rule.MinPriority != nil && rule.MaxPriority != nil && *rule.MinPriority > *rule.MaxPriority {
+			return nil, fmt.Errorf("routing rule %s: min_priority is greater than max_priority", name)
+		}
+		if rule.Partitions < 0 || rule.ReplicationFactor < 0 {
+			return nil, fmt.Errorf("routing rule %s: partitions and replication_factor must not be negative", name)
+		}
+
+		// Rules sharing a topic must not ask for it to be created differently
+		if rule.Partitions != 0 || rule.ReplicationFactor != 0 {
+			settings := topicSettings{partitions: rule.Partitions, replicationFactor: rule.ReplicationFactor}
+			if existing, ok := router.settings[rule.Topic]; ok && existing != settings {
+				return nil, fmt.Errorf("routing rule %s: topic %s is already given different partitions or replication_factor", name, rule.Topic)
+			}
+			router.settings[rule.Topic] = settings
+		}
+
+		var tenants map[string]struct{}
+		if len(rule.Tenants) > 0 {
+			tenants = make(map[string]struct{}, len(rule.Tenants))
+			for _, tenant := range rule.Tenants {
+				tenants[tenant] = struct{}{}
+			}
+		}
+
+		router.rules = append(router.rules, routingRule{
+			name:        name,
+			eventTypes:  rule.EventTypes,
+			tenants:     tenants,
+			minPriority: rule.MinPriority,
+			maxPriority: rule.MaxPriority,
+			topic:       rule.Topic,
+		})
+	}
+
+	return router, nil
+}
+
+// Route returns the destination topic for an event and the name of the rule that chose it
+func (r *topicRouter) Route(event *models.Event) (string, string) {
+	for i := range r.rules {
+		if r.rules[i].matches(event) {
+			return r.rules[i].topic, r.rules[i].name
+		}
+	}
+	return r.defaultTopic, defaultRule
+}
+
+// Topics lists every topic the router can send to, without duplicates
+func (r *topicRouter) Topics() []string {
+	seen := map[string]struct{}{r.defaultTopic: {}}
+	topics := []string{r.defaultTopic}
+	for _, rule := range r.rules {
+		if _, ok := seen[rule.topic]; ok {
+			continue
+		}
+		seen[rule.topic] = struct{}{}
+		topics = append(topics, rule.topic)
+	}
+	return topics
+}
+
+// TopicDetail returns how topic is created: with the partitions and
+// replication factor its routing rules set, and defaults for the rest
+func (r *topicRouter) TopicDetail(topic string, defaults sarama.TopicDetail) *sarama.TopicDetail {
+	detail := defaults
+	if settings, ok := r.settings[topic]; ok {
+		if settings.partitions != 0 {
+			detail.NumPartitions = settings.partitions
+		}
+		if settings.replicationFactor != 0 {
+			detail.ReplicationFactor = settings.replicationFactor
+		}
+	}
+	return &detail
+}
+
+// createTopics creates any routing destination that does not exist yet
+func createTopics(cfg config.KafkaConfig, saramaConfig *sarama.Config, router *topicRouter, topics []string, logger *zap.Logger) error {
+	admin, err := sarama.NewClusterAdmin(cfg.Brokers, saramaConfig)
+	if err != nil {
+		return fmt.Errorf("failed to create Kafka cluster admin: %w", err)
+	}
+	defer admin.Close()
+
+	defaults := sarama.TopicDetail{
+		NumPartitions:     cfg.Routing.TopicPartitions,
+		ReplicationFactor: cfg.Routing.ReplicationFactor,
+	}
+	return ensureTopics(admin, topics, func(topic string) *sarama.TopicDetail {
+		return router.TopicDetail(topic, defaults)
+	}, logger)
+}
+
+func ensureTopics(admin sarama.ClusterAdmin, topics []string, details func(topic string) *sarama.TopicDetail, logger *zap.Logger) error {
+	existing, err := admin.ListTopics()
+	if err != nil {
+		return fmt.Errorf("failed to list Kafka topics: %w", err)
+	}
+
+	for _, topic := range topics {
+		if _, ok := existing[topic]; ok {
+			continue
+		}
+
+		detail := details(topic)
+		err := admin.CreateTopic(topic, detail, false)
+		if err != nil && !errors.Is(err, sarama.ErrTopicAlreadyExists) {
+			return fmt.Errorf("failed to create Kafka topic %s: %w", topic, err)
+		}
+
+		logger.Info("Created Kafka topic",
+			zap.String("topic", topic),
+			zap.Int32("partitions", detail.NumPartitions),
+			zap.Int16("replication_factor", detail.ReplicationFactor))
+	}
+
+	return nil
+}
@@ -0,0 +1,224 @@
+package kafka
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/IBM/sarama"
+	"github.com/IBM/sarama/mocks"
+	"github.com/distributed-event-processor/services/event-gateway/internal/config"
+	"github.com/distributed-event-processor/services/event-gateway/internal/models"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func intPtr(v int) *int {
+	return &v
+}
+
+func routingTestConfig(rules ...config.RoutingRule) config.KafkaConfig {
+	return config.KafkaConfig{
+		Topic:   "events",
+		Routing: config.RoutingConfig{Rules: rules},
+	}
+}
+
+func TestTopicRouter_Route(t *testing.T) {
+	router, err := newTopicRouter(routingTestConfig(
+		config.RoutingRule{Name: "payments", EventTypes: []string{"payment.*"}, Topic: "events.payments"},
+		config.RoutingRule{Name: "acme-urgent", Tenants: []string{"acme"}, MinPriority: intPtr(8), Topic: "events.acme.priority"},
+		config.RoutingRule{EventTypes: []string{"*.created"}, MaxPriority: intPtr(2), Topic: "events.bulk"},
+		config.RoutingRule{Name: "acme", Tenants: []string{"acme"}, Topic: "events.acme"},
+	))
+	require.NoError(t, err)
+
+	tests := []struct {
+		name          string
+		event         models.Event
+		expectedTopic string
+		expectedRule  string
+	}{
+		{"type glob", models.Event{Type: "payment.captured"}, "events.payments", "payments"},
+		{"first match wins", models.Event{Type: "payment.captured", TenantID: "acme", Priority: 9}, "events.payments", "payments"},
+		{"tenant and priority", models.Event{Type: "user.created", TenantID: "acme", Priority: 9}, "events.acme.priority", "acme-urgent"},
+		{"priority below min falls through", models.Event{Type: "user.updated", TenantID: "acme", Priority: 5}, "events.acme", "acme"},
+		{"unnamed rule", models.Event{Type: "user.created", Priority: 1}, "events.bulk", "rule-2"},
+		{"priority above max", models.Event{Type: "user.created", Priority: 3}, "events", defaultRule},
+		{"default", models.Event{Type: "user.deleted", TenantID: "globex"}, "events", defaultRule},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			topic, rule := router.Route(&tt.event)
+			assert.Equal(t, tt.expectedTopic, topic)
+			assert.Equal(t, tt.expectedRule, rule)
+		})
+	}
+}
+
+func TestTopicRouter_DefaultTopic(t *testing.T) {
+	cfg := routingTestConfig()
+	cfg.Routing.DefaultTopic = "events.default"
+
+	router, err := newTopicRouter(cfg)
+	require.NoError(t, err)
+
+	topic, rule := router.Route(&models.Event{Type: "user.created"})
+	assert.Equal(t, "events.default", topic)
+	assert.Equal(t, defaultRule, rule)
+}
+
+func TestTopicRouter_InvalidRules(t *testing.T) {
+	tests := []struct {
+		name string
+		rule config.RoutingRule
+	}{
+		{"missing topic", config.RoutingRule{EventTypes: []string{"user.*"}}},
+		{"no criteria", config.RoutingRule{Topic: "events.all"}},
+		{"bad pattern", config.RoutingRule{EventTypes: []string{"user.["}, Topic: "events.users"}},
+		{"priority out of range", config.RoutingRule{MinPriority: intPtr(11), Topic: "events.priority"}},
+		{"inverted priority range", config.RoutingRule{MinPriority: intPtr(7), MaxPriority: intPtr(3), Topic: "events.priority"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := newTopicRouter(routingTestConfig(tt.rule))
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestTopicRouter_Topics(t *testing.T) {
+	router, err := newTopicRouter(routingTestConfig(
+		config.RoutingRule{EventTypes: []string{"payment.*"}, Topic: "events.payments"},
+		config.RoutingRule{EventTypes: []string{"refund.*"}, Topic: "events.payments"},
+		config.RoutingRule{Tenants: []string{"acme"}, Topic: "events"},
+	))
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"events", "events.payments"}, router.Topics())
+}
+
+func TestTopicRouter_TopicDetail(t *testing.T) {
+	router, err := newTopicRouter(routingTestConfig(
+		config.RoutingRule{EventTypes: []string{"payment.*"}, Topic: "events.payments", Partitions: 24, ReplicationFactor: 3},
+		config.RoutingRule{EventTypes: []string{"refund.*"}, Topic: "events.payments"},
+		config.RoutingRule{EventTypes: []string{"audit.*"}, Topic: "events.audit", ReplicationFactor: 3},
+	))
+	require.NoError(t, err)
+
+	defaults := sarama.TopicDetail{NumPartitions: 6, ReplicationFactor: 1}
+	assert.Equal(t, &sarama.TopicDetail{NumPartitions: 24, ReplicationFactor: 3}, router.TopicDetail("events.payments", defaults))
+	assert.Equal(t, &sarama.TopicDetail{NumPartitions: 6, ReplicationFactor: 3}, router.TopicDetail("events.audit", defaults))
+	assert.Equal(t, &defaults, router.TopicDetail("events", defaults))
+
+	// Rules sharing a topic must agree on how it is created
+	_, err = newTopicRouter(routingTestConfig(
+		config.RoutingRule{EventTypes: []string{"payment.*"}, Topic: "events.payments", Partitions: 24},
+		config.RoutingRule{EventTypes: []string{"refund.*"}, Topic: "events.payments", Partitions: 12},
+	))
+	assert.Error(t, err)
+
+	_, err = newTopicRouter(routingTestConfig(
+		config.RoutingRule{EventTypes: []string{"payment.*"}, Topic: "events.payments", Partitions: -1},
+	))
+	assert.Error(t, err)
+}
+
+func TestProducer_RoutesEvents(t *testing.T) {
+	mockProducer := mocks.NewSyncProducer(t, nil)
+	mockProducer.ExpectSendMessageWithMessageCheckerFunctionAndSucceed(func(msg *sarama.ProducerMessage) error {
+		if msg.Topic != "events.payments" {
+			return errors.New("unexpected topic " + msg.Topic)
+		}
+		return nil
+	})
+
+	router, err := newTopicRouter(routingTestConfig(
+		config.RoutingRule{Name: "payments", EventTypes: []string{"payment.*"}, Topic: "events.payments"},
+	))
+	require.NoError(t, err)
+
+	producer := createTestProducer(t, mockProducer)
+	producer.router = router
+	defer producer.Close()
+
+	routed := routedEvents.WithLabelValues("events.payments", "payments")
+	before := testutil.ToFloat64(routed)
+
+	result := producer.Produce(t.Context(), &models.Event{ID: "event-1", Type: "payment.captured", Source: "billing"})
+	require.NoError(t, result.Err)
+	assert.Equal(t, "events.payments", result.Topic)
+	assert.Equal(t, "payments", result.Rule)
+	assert.Equal(t, before+1, testutil.ToFloat64(routed))
+
+	// Only delivered events are counted as routed
+	mockProducer.ExpectSendMessageAndFail(sarama.ErrMessageSizeTooLarge)
+	result = producer.Produce(t.Context(), &models.Event{ID: "event-2", Type: "payment.captured", Source: "billing"})
+	require.Error(t, result.Err)
+	assert.Equal(t, before+1, testutil.ToFloat64(routed))
+}
+
+// fakeClusterAdmin records topic creation; unimplemented methods panic via the nil embedded interface
+type fakeClusterAdmin struct {
+	sarama.ClusterAdmin
+	existing  map[string]sarama.TopicDetail
+	created   []string
+	details   []sarama.TopicDetail
+	createErr error
+}
+
+func (f *fakeClusterAdmin) ListTopics() (map[string]sarama.TopicDetail, error) {
+	return f.existing, nil
+}
+
+func (f *fakeClusterAdmin) CreateTopic(topic string, detail *sarama.TopicDetail, _ bool) error {
+	f.created = append(f.created, topic)
+	f.details = append(f.details, *detail)
+	return f.createErr
+}
+
+func TestEnsureTopics(t *testing.T) {
+	detail := func(string) *sarama.TopicDetail {
+		return &sarama.TopicDetail{NumPartitions: 6, ReplicationFactor: 1}
+	}
+
+	t.Run("creates missing topics", func(t *testing.T) {
+		admin := &fakeClusterAdmin{existing: map[string]sarama.TopicDetail{"events": {}}}
+		err := ensureTopics(admin, []string{"events", "events.payments"}, detail, zap.NewNop())
+		require.NoError(t, err)
+		assert.Equal(t, []string{"events.payments"}, admin.created)
+	})
+
+	t.Run("creates each topic with its own settings", func(t *testing.T) {
+		router, err := newTopicRouter(routingTestConfig(
+			config.RoutingRule{EventTypes: []string{"payment.*"}, Topic: "events.payments", Partitions: 24, ReplicationFactor: 3},
+		))
+		require.NoError(t, err)
+
+		admin := &fakeClusterAdmin{}
+		err = ensureTopics(admin, router.Topics(), func(topic string) *sarama.TopicDetail {
+			return router.TopicDetail(topic, sarama.TopicDetail{NumPartitions: 6, ReplicationFactor: 1})
+		}, zap.NewNop())
+		require.NoError(t, err)
+		assert.Equal(t, []string{"events", "events.payments"}, admin.created)
+		assert.Equal(t, []sarama.TopicDetail{
+			{NumPartitions: 6, ReplicationFactor: 1},
+			{NumPartitions: 24, ReplicationFactor: 3},
+		}, admin.details)
+	})
+
+	t.Run("ignores concurrently created topics", func(t *testing.T) {
+		admin := &fakeClusterAdmin{createErr: sarama.ErrTopicAlreadyExists}
+		err := ensureTopics(admin, []string{"events"}, detail, zap.NewNop())
+		assert.NoError(t, err)
+	})
+
+	t.Run("returns creation errors", func(t *testing.T) {
+		admin := &fakeClusterAdmin{createErr: sarama.ErrInvalidReplicationFactor}
+		err := ensureTopics(admin, []string{"events"}, detail, zap.NewNop())
+		assert.ErrorIs(t, err, sarama.ErrInvalidReplicationFactor)
+	})
+}
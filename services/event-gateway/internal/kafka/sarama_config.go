@@ -0,0 +1,204 @@
+package kafka
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/IBM/sarama"
+	"github.com/distributed-event-processor/services/event-gateway/internal/config"
+	"go.uber.org/zap"
+)
+
+// Delivery guarantees supported by the producer
+const (
+	GuaranteeAtMostOnce    = "at_most_once"
+	GuaranteeAtLeastOnce   = "at_least_once"
+	GuaranteeIdempotent    = "idempotent"
+	GuaranteeTransactional = "transactional"
+)
+
+// compressionCodecs maps config names to sarama codecs
+var compressionCodecs = map[string]sarama.CompressionCodec{
+	"":       sarama.CompressionNone,
+	"none":   sarama.CompressionNone,
+	"gzip":   sarama.CompressionGZIP,
+	"snappy": sarama.CompressionSnappy,
+	"lz4":    sarama.CompressionLZ4,
+	"zstd":   sarama.CompressionZSTD,
+}
+
+// compressionLevels holds the accepted level range for codecs that support one
+var compressionLevels = map[sarama.CompressionCodec][2]int{
+	sarama.CompressionGZIP: {1, 9},
+	sarama.CompressionLZ4:  {1, 9},
+	sarama.CompressionZSTD: {1, 22},
+}
+
+// partitioners maps config names to sarama partitioner constructors
+var partitioners = map[string]sarama.PartitionerConstructor{
+	"":               sarama.NewHashPartitioner,
+	"hash":           sarama.NewHashPartitioner,
+	"reference_hash": sarama.NewReferenceHashPartitioner,
+	"crc32":          sarama.NewConsistentCRCHashPartitioner,
+	"random":         sarama.NewRandomPartitioner,
+	"round_robin":    sarama.NewRoundRobinPartitioner,
+}
+
+// newSaramaConfig translates the gateway's Kafka configuration into a validated
+// sarama config. It rejects settings that cannot work together so that a bad
+// configuration fails at startup rather than on the first produce.
+func newSaramaConfig(cfg config.KafkaConfig, logger *zap.Logger) (*sarama.Config, error) {
+	saramaConfig := sarama.NewConfig()
+	saramaConfig.Producer.Return.Successes = true
+	saramaConfig.Producer.Return.Errors = true
+
+	if cfg.ClientID != "" {
+		saramaConfig.ClientID = cfg.ClientID
+	}
+
+	if cfg.BatchSize < 0 {
+		return nil, fmt.Errorf("batch_size must be >= 0, got %d", cfg.BatchSize)
+	}
+	saramaConfig.Producer.Flush.Messages = cfg.BatchSize
+
+	if cfg.LingerMs < 0 {
+		return nil, fmt.Errorf("linger_ms must be >= 0, got %d", cfg.LingerMs)
+	}
+	saramaConfig.Producer.Flush.Frequency = time.Duration(cfg.LingerMs) * time.Millisecond
+
+	if cfg.MaxMessageBytes != 0 {
+		if cfg.MaxMessageBytes < 0 || cfg.MaxMessageBytes >= int(sarama.MaxRequestSize) {
+			return nil, fmt.Errorf("max_message_bytes must be between 1 and %d, got %d", sarama.MaxRequestSize-1, cfg.MaxMessageBytes)
+		}
+		saramaConfig.Producer.MaxMessageBytes = cfg.MaxMessageBytes
+	}
+
+	partitioner, err := newPartitioner(cfg)
+	if err != nil {
+		return nil, err
+	}
+	saramaConfig.Producer.Partitioner = partitioner
+
+	if err := applyCompression(saramaConfig, cfg); err != nil {
+		return nil, err
+	}
+
+	acks, err := requiredAcks(cfg.RequiredAcks)
+	if err != nil {
+		return nil, err
+	}
+	saramaConfig.Producer.RequiredAcks = acks
+
+	switch cfg.DeliveryGuarantee {
+	case GuaranteeAtMostOnce:
+		// No retries, so an event is never written twice
+		saramaConfig.Producer.Retry.Max = 0
+	case GuaranteeAtLeastOnce, "":
+		if acks == sarama.NoResponse {
+			return nil, fmt.Errorf("delivery guarantee %s requires required_acks 1 or -1", GuaranteeAtLeastOnce)
+		}
+		saramaConfig.Producer.Retry.Max = cfg.Retries
+	case GuaranteeIdempotent, GuaranteeTransactional:
+		// The broker deduplicates retries by producer ID and sequence number,
+		// which needs acks from all replicas and a single in-flight request
+		if acks != sarama.WaitForAll {
+			return nil, fmt.Errorf("delivery guarantee %s requires required_acks -1", cfg.DeliveryGuarantee)
+		}
+		saramaConfig.Producer.Idempotent = true
+		saramaConfig.Producer.Retry.Max = max(cfg.Retries, 1)
+		saramaConfig.Net.MaxOpenRequests = 1
+
+		if cfg.DeliveryGuarantee == GuaranteeTransactional {
+			if cfg.ProducerMode == ModeAsync {
+				return nil, fmt.Errorf("transactional delivery requires producer mode %s", ModeSync)
+			}
+			saramaConfig.Producer.Transaction.ID = transactionalID(cfg)
+		}
+	default:
+		return nil, fmt.Errorf("unknown delivery guarantee: %s (expected: %s, %s, %s or %s)",
+			cfg.DeliveryGuarantee, GuaranteeAtMostOnce, GuaranteeAtLeastOnce, GuaranteeIdempotent, GuaranteeTransactional)
+	}
+
+	if err := applySecurity(saramaConfig, cfg, logger); err != nil {
+		return nil, err
+	}
+
+	if err := saramaConfig.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid Kafka producer configuration: %w", err)
+	}
+
+	return saramaConfig, nil
+}
+
+// newAuxiliaryConfig derives the config for a helper client, such as the health
+// prober or dead-letter producer, from the main producer's. It connects the same
+// way but sends plain synchronous writes: never idempotent or transactional, and
+// without waiting on linger.
+func newAuxiliaryConfig(saramaConfig *sarama.Config, role string) *sarama.Config {
+	auxConfig := *saramaConfig
+	auxConfig.ClientID = saramaConfig.ClientID + "-" + role
+	auxConfig.Net.MaxOpenRequests = 5
+	auxConfig.Producer.Idempotent = false
+	auxConfig.Producer.Transaction.ID = ""
+	auxConfig.Producer.RequiredAcks = sarama.WaitForAll
+	auxConfig.Producer.Flush.Frequency = 0
+	auxConfig.Producer.Flush.Messages = 0
+	auxConfig.Producer.Partitioner = sarama.NewHashPartitioner
+	auxConfig.Producer.Return.Successes = true
+	auxConfig.Producer.Return.Errors = true
+	return &auxConfig
+}
+
+// requiredAcks converts the numeric required_acks setting into sarama's enum
+func requiredAcks(value int) (sarama.RequiredAcks, error) {
+	switch value {
+	case 0:
+		return sarama.NoResponse, nil
+	case 1:
+		return sarama.WaitForLocal, nil
+	case -1:
+		return sarama.WaitForAll, nil
+	default:
+		return 0, fmt.Errorf("required_acks must be 0, 1 or -1, got %d", value)
+	}
+}
+
+// applyCompression sets the codec and level, where a level of 0 means the codec default
+func applyCompression(saramaConfig *sarama.Config, cfg config.KafkaConfig) error {
+	codec, ok := compressionCodecs[cfg.Compression]
+	if !ok {
+		return fmt.Errorf("unknown compression codec: %s (expected: none, gzip, snappy, lz4 or zstd)", cfg.Compression)
+	}
+	saramaConfig.Producer.Compression = codec
+
+	if cfg.CompressionLevel == 0 {
+		return nil
+	}
+
+	levels, ok := compressionLevels[codec]
+	if !ok {
+		return fmt.Errorf("compression codec %s does not support compression_level", codec)
+	}
+	if cfg.CompressionLevel < levels[0] || cfg.CompressionLevel > levels[1] {
+		return fmt.Errorf("compression_level for %s must be between %d and %d, got %d",
+			codec, levels[0], levels[1], cfg.CompressionLevel)
+	}
+	saramaConfig.Producer.CompressionLevel = cfg.CompressionLevel
+
+	return nil
+}
+
+// transactionalID returns the configured transactional ID, falling back to one
+// derived from the hostname so that gateway replicas do not fence each other
+func transactionalID(cfg config.KafkaConfig) string {
+	if cfg.TransactionalID != "" {
+		return cfg.TransactionalID
+	}
+
+	hostname, err := os.Hostname()
+	if err != nil || hostname == "" {
+		hostname = "local"
+	}
+	return "event-gateway-" + hostname
+}
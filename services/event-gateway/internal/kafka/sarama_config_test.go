@@ -0,0 +1,223 @@
+package kafka
+
+import (
+	"testing"
+	"time"
+
+	"github.com/IBM/sarama"
+	"github.com/distributed-event-processor/services/event-gateway/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestNewSaramaConfig_DeliveryGuarantees(t *testing.T) {
+	t.Run("at most once", func(t *testing.T) {
+		cfg, err := newSaramaConfig(config.KafkaConfig{Retries: 3, DeliveryGuarantee: GuaranteeAtMostOnce}, zap.NewNop())
+
+		require.NoError(t, err)
+		assert.Equal(t, sarama.NoResponse, cfg.Producer.RequiredAcks)
+		assert.Equal(t, 0, cfg.Producer.Retry.Max)
+		assert.False(t, cfg.Producer.Idempotent)
+	})
+
+	t.Run("at least once is the default", func(t *testing.T) {
+		cfg, err := newSaramaConfig(config.KafkaConfig{Retries: 3, RequiredAcks: -1}, zap.NewNop())
+
+		require.NoError(t, err)
+		assert.Equal(t, sarama.WaitForAll, cfg.Producer.RequiredAcks)
+		assert.Equal(t, 3, cfg.Producer.Retry.Max)
+		assert.False(t, cfg.Producer.Idempotent)
+	})
+
+	t.Run("idempotent", func(t *testing.T) {
+		cfg, err := newSaramaConfig(config.KafkaConfig{Retries: 0, RequiredAcks: -1, DeliveryGuarantee: GuaranteeIdempotent}, zap.NewNop())
+
+		require.NoError(t, err)
+		assert.True(t, cfg.Producer.Idempotent)
+		assert.Equal(t, sarama.WaitForAll, cfg.Producer.RequiredAcks)
+		assert.Equal(t, 1, cfg.Producer.Retry.Max)
+		assert.Equal(t, 1, cfg.Net.MaxOpenRequests)
+		assert.Empty(t, cfg.Producer.Transaction.ID)
+	})
+
+	t.Run("transactional", func(t *testing.T) {
+		cfg, err := newSaramaConfig(config.KafkaConfig{
+			Retries:           3,
+			RequiredAcks:      -1,
+			DeliveryGuarantee: GuaranteeTransactional,
+			TransactionalID:   "gateway-1",
+		}, zap.NewNop())
+
+		require.NoError(t, err)
+		assert.True(t, cfg.Producer.Idempotent)
+		assert.Equal(t, "gateway-1", cfg.Producer.Transaction.ID)
+	})
+
+	t.Run("transactional ID defaults to hostname", func(t *testing.T) {
+		cfg, err := newSaramaConfig(config.KafkaConfig{RequiredAcks: -1, DeliveryGuarantee: GuaranteeTransactional}, zap.NewNop())
+
+		require.NoError(t, err)
+		assert.Contains(t, cfg.Producer.Transaction.ID, "event-gateway-")
+	})
+
+	t.Run("transactional rejects async mode", func(t *testing.T) {
+		_, err := newSaramaConfig(config.KafkaConfig{
+			RequiredAcks:      -1,
+			DeliveryGuarantee: GuaranteeTransactional,
+			ProducerMode:      ModeAsync,
+		}, zap.NewNop())
+
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "requires producer mode sync")
+	})
+
+	t.Run("unknown guarantee", func(t *testing.T) {
+		_, err := newSaramaConfig(config.KafkaConfig{RequiredAcks: 1, DeliveryGuarantee: "exactly_twice"}, zap.NewNop())
+
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "unknown delivery guarantee")
+	})
+}
+
+func validKafkaConfig() config.KafkaConfig {
+	return config.KafkaConfig{
+		Brokers:           []string{"localhost:9092"},
+		Topic:             "events",
+		Retries:           3,
+		BatchSize:         100,
+		RequiredAcks:      1,
+		DeliveryGuarantee: GuaranteeAtLeastOnce,
+		LingerMs:          500,
+		MaxMessageBytes:   1048576,
+		Partitioner:       "hash",
+		ClientID:          "event-gateway",
+	}
+}
+
+func TestNewSaramaConfig_HonorsSettings(t *testing.T) {
+	cfg := validKafkaConfig()
+	cfg.Compression = "zstd"
+	cfg.CompressionLevel = 3
+	cfg.LingerMs = 20
+	cfg.MaxMessageBytes = 2 * 1024 * 1024
+	cfg.ClientID = "gateway-eu-1"
+
+	saramaConfig, err := newSaramaConfig(cfg, zap.NewNop())
+
+	require.NoError(t, err)
+	assert.Equal(t, sarama.WaitForLocal, saramaConfig.Producer.RequiredAcks)
+	assert.Equal(t, sarama.CompressionZSTD, saramaConfig.Producer.Compression)
+	assert.Equal(t, 3, saramaConfig.Producer.CompressionLevel)
+	assert.Equal(t, 20*time.Millisecond, saramaConfig.Producer.Flush.Frequency)
+	assert.Equal(t, 100, saramaConfig.Producer.Flush.Messages)
+	assert.Equal(t, 2*1024*1024, saramaConfig.Producer.MaxMessageBytes)
+	assert.Equal(t, "gateway-eu-1", saramaConfig.ClientID)
+}
+
+func TestNewSaramaConfig_RequiredAcks(t *testing.T) {
+	tests := []struct {
+		acks     int
+		expected sarama.RequiredAcks
+	}{
+		{1, sarama.WaitForLocal},
+		{-1, sarama.WaitForAll},
+	}
+
+	for _, tt := range tests {
+		cfg := validKafkaConfig()
+		cfg.RequiredAcks = tt.acks
+
+		saramaConfig, err := newSaramaConfig(cfg, zap.NewNop())
+
+		require.NoError(t, err)
+		assert.Equal(t, tt.expected, saramaConfig.Producer.RequiredAcks)
+	}
+}
+
+func TestNewSaramaConfig_Partitioners(t *testing.T) {
+	for _, name := range []string{"hash", "reference_hash", "crc32", "random", "round_robin"} {
+		cfg := validKafkaConfig()
+		cfg.Partitioner = name
+
+		saramaConfig, err := newSaramaConfig(cfg, zap.NewNop())
+
+		require.NoError(t, err, name)
+		assert.NotNil(t, saramaConfig.Producer.Partitioner("events"), name)
+	}
+}
+
+func TestNewSaramaConfig_RejectsInvalidCombinations(t *testing.T) {
+	tests := []struct {
+		name    string
+		mutate  func(cfg *config.KafkaConfig)
+		message string
+	}{
+		{
+			name:    "unsupported acks",
+			mutate:  func(cfg *config.KafkaConfig) { cfg.RequiredAcks = 2 },
+			message: "required_acks must be 0, 1 or -1",
+		},
+		{
+			name:    "at least once without acks",
+			mutate:  func(cfg *config.KafkaConfig) { cfg.RequiredAcks = 0 },
+			message: "requires required_acks 1 or -1",
+		},
+		{
+			name: "idempotent with leader acks",
+			mutate: func(cfg *config.KafkaConfig) {
+				cfg.DeliveryGuarantee = GuaranteeIdempotent
+				cfg.RequiredAcks = 1
+			},
+			message: "requires required_acks -1",
+		},
+		{
+			name:    "unknown codec",
+			mutate:  func(cfg *config.KafkaConfig) { cfg.Compression = "brotli" },
+			message: "unknown compression codec",
+		},
+		{
+			name: "level on snappy",
+			mutate: func(cfg *config.KafkaConfig) {
+				cfg.Compression = "snappy"
+				cfg.CompressionLevel = 5
+			},
+			message: "does not support compression_level",
+		},
+		{
+			name: "gzip level out of range",
+			mutate: func(cfg *config.KafkaConfig) {
+				cfg.Compression = "gzip"
+				cfg.CompressionLevel = 12
+			},
+			message: "must be between 1 and 9",
+		},
+		{
+			name:    "negative linger",
+			mutate:  func(cfg *config.KafkaConfig) { cfg.LingerMs = -1 },
+			message: "linger_ms must be >= 0",
+		},
+		{
+			name:    "oversized messages",
+			mutate:  func(cfg *config.KafkaConfig) { cfg.MaxMessageBytes = int(sarama.MaxRequestSize) },
+			message: "max_message_bytes must be between",
+		},
+		{
+			name:    "unknown partitioner",
+			mutate:  func(cfg *config.KafkaConfig) { cfg.Partitioner = "sticky-ish" },
+			message: "unknown partitioner",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := validKafkaConfig()
+			tt.mutate(&cfg)
+
+			_, err := newSaramaConfig(cfg, zap.NewNop())
+
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.message)
+		})
+	}
+}
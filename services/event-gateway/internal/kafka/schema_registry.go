@@ -0,0 +1,152 @@
+package kafka
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/distributed-event-processor/services/event-gateway/internal/config"
+)
+
+// Schema types understood by the schema registry
+const (
+	schemaTypeAvro     = "AVRO"
+	schemaTypeProtobuf = "PROTOBUF"
+)
+
+// schemaRegistryContentType is the media type of schema registry requests
+const schemaRegistryContentType = "application/vnd.schemaregistry.v1+json"
+
+// schemaRegistry is a minimal client for the Confluent Schema Registry REST
+// API. Schema IDs are cached per subject, so each subject costs one request
+// for the lifetime of the gateway.
+type schemaRegistry struct {
+	url          string
+	username     string
+	password     string
+	autoRegister bool
+	client       *http.Client
+
+	mu  sync.Mutex
+	ids map[string]int
+}
+
+type schemaRequest struct {
+	Schema     string `json:"schema"`
+	SchemaType string `json:"schemaType,omitempty"`
+}
+
+type schemaResponse struct {
+	ID int `json:"id"`
+}
+
+type schemaRegistryError struct {
+	ErrorCode int    `json:"error_code"`
+	Message   string `json:"message"`
+}
+
+func newSchemaRegistry(cfg config.SchemaRegistryConfig) (*schemaRegistry, error) {
+	if cfg.URL == "" {
+		return nil, errors.New("schema_registry url is required for the protobuf and avro formats")
+	}
+	if cfg.TimeoutMs <= 0 {
+		return nil, errors.New("schema_registry timeout_ms must be positive")
+	}
+
+	password, err := readSecret(cfg.Password, cfg.PasswordFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read schema registry password: %w", err)
+	}
+
+	return &schemaRegistry{
+		url:          strings.TrimRight(cfg.URL, "/"),
+		username:     cfg.Username,
+		password:     password,
+		autoRegister: cfg.AutoRegister,
+		client:       &http.Client{Timeout: time.Duration(cfg.TimeoutMs) * time.Millisecond},
+		ids:          make(map[string]int),
+	}, nil
+}
+
+// schemaID returns the ID of schema under subject, registering it first when
+// auto-registration is enabled
+func (r *schemaRegistry) schemaID(subject, schemaType, schema string) (int, error) {
+	r.mu.Lock()
+	id, ok := r.ids[subject]
+	r.mu.Unlock()
+	if ok {
+		return id, nil
+	}
+
+	// Registering a schema that already exists returns its existing ID, while
+	// looking one up fails if it was never registered
+	path := "/subjects/" + url.PathEscape(subject)
+	if r.autoRegister {
+		path += "/versions"
+	}
+
+	id, err := r.post(path, schemaRequest{Schema: schema, SchemaType: schemaType})
+	if err != nil {
+		return 0, fmt.Errorf("failed to resolve schema for subject %s: %w", subject, err)
+	}
+
+	r.mu.Lock()
+	r.ids[subject] = id
+	r.mu.Unlock()
+	return id, nil
+}
+
+func (r *schemaRegistry) post(path string, body schemaRequest) (int, error) {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return 0, err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, r.url+path, bytes.NewReader(payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", schemaRegistryContentType)
+	req.Header.Set("Accept", schemaRegistryContentType)
+	if r.username != "" {
+		req.SetBasicAuth(r.username, r.password)
+	}
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return 0, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		var registryErr schemaRegistryError
+		if json.Unmarshal(data, &registryErr) == nil && registryErr.Message != "" {
+			return 0, fmt.Errorf("schema registry returned %d: %s (error code %d)", resp.StatusCode, registryErr.Message, registryErr.ErrorCode)
+		}
+		return 0, fmt.Errorf("schema registry returned %d", resp.StatusCode)
+	}
+
+	var schemaResp schemaResponse
+	if err := json.Unmarshal(data, &schemaResp); err != nil {
+		return 0, fmt.Errorf("invalid schema registry response: %w", err)
+	}
+	return schemaResp.ID, nil
+}
+
+// valueSubject names the subject of a topic's values, following the
+// registry's default TopicNameStrategy
+func valueSubject(topic string) string {
+	return topic + "-value"
+}
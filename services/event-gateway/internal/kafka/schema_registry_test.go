@@ -0,0 +1,145 @@
+package kafka
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/distributed-event-processor/services/event-gateway/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeSchemaRegistry stands in for the Confluent Schema Registry. Every
+// subject that is registered gets the next schema ID.
+type fakeSchemaRegistry struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	requests []string
+	types    map[string]string
+	ids      map[string]int
+}
+
+func newFakeSchemaRegistry(t *testing.T) *fakeSchemaRegistry {
+	registry := &fakeSchemaRegistry{types: make(map[string]string), ids: make(map[string]int)}
+	registry.Server = httptest.NewServer(http.HandlerFunc(registry.handle))
+	t.Cleanup(registry.Close)
+	return registry
+}
+
+func (r *fakeSchemaRegistry) handle(w http.ResponseWriter, req *http.Request) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.requests = append(r.requests, req.Method+" "+req.URL.Path)
+
+	var body schemaRequest
+	if err := json.NewDecoder(req.Body).Decode(&body); err != nil || body.Schema == "" {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		return
+	}
+
+	w.Header().Set("Content-Type", schemaRegistryContentType)
+
+	subject, ok := strings.CutPrefix(req.URL.Path, "/subjects/")
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	subject, register := strings.CutSuffix(subject, "/versions")
+
+	id, ok := r.ids[subject]
+	if !ok && !register {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(schemaRegistryError{ErrorCode: 40401, Message: "Subject '" + subject + "' not found."})
+		return
+	}
+	if !ok {
+		id = len(r.ids) + 1
+		r.ids[subject] = id
+		r.types[subject] = body.SchemaType
+	}
+	json.NewEncoder(w).Encode(schemaResponse{ID: id})
+}
+
+func (r *fakeSchemaRegistry) config() config.SchemaRegistryConfig {
+	return config.SchemaRegistryConfig{URL: r.URL, AutoRegister: true, TimeoutMs: 1000}
+}
+
+func (r *fakeSchemaRegistry) requestLog() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.requests...)
+}
+
+func TestSchemaRegistry_RegistersAndCaches(t *testing.T) {
+	fake := newFakeSchemaRegistry(t)
+	registry, err := newSchemaRegistry(fake.config())
+	require.NoError(t, err)
+
+	id, err := registry.schemaID("events-value", schemaTypeAvro, eventAvroSchema)
+	require.NoError(t, err)
+	assert.Equal(t, 1, id)
+
+	id, err = registry.schemaID("events-value", schemaTypeAvro, eventAvroSchema)
+	require.NoError(t, err)
+	assert.Equal(t, 1, id)
+
+	id, err = registry.schemaID("events.payments-value", schemaTypeProtobuf, "syntax = \"proto3\";")
+	require.NoError(t, err)
+	assert.Equal(t, 2, id)
+
+	assert.Equal(t, []string{
+		"POST /subjects/events-value/versions",
+		"POST /subjects/events.payments-value/versions",
+	}, fake.requestLog())
+	assert.Equal(t, schemaTypeProtobuf, fake.types["events.payments-value"])
+}
+
+func TestSchemaRegistry_LookupOnly(t *testing.T) {
+	fake := newFakeSchemaRegistry(t)
+	cfg := fake.config()
+	cfg.AutoRegister = false
+	registry, err := newSchemaRegistry(cfg)
+	require.NoError(t, err)
+
+	_, err = registry.schemaID("events-value", schemaTypeAvro, eventAvroSchema)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "Subject 'events-value' not found.")
+	assert.Equal(t, []string{"POST /subjects/events-value"}, fake.requestLog())
+}
+
+func TestSchemaRegistry_BasicAuth(t *testing.T) {
+	var user, password string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		user, password, _ = req.BasicAuth()
+		json.NewEncoder(w).Encode(schemaResponse{ID: 3})
+	}))
+	defer server.Close()
+
+	registry, err := newSchemaRegistry(config.SchemaRegistryConfig{
+		URL:          server.URL + "/",
+		Username:     "gateway",
+		Password:     "secret",
+		AutoRegister: true,
+		TimeoutMs:    1000,
+	})
+	require.NoError(t, err)
+
+	id, err := registry.schemaID("events-value", schemaTypeAvro, eventAvroSchema)
+	require.NoError(t, err)
+	assert.Equal(t, 3, id)
+	assert.Equal(t, "gateway", user)
+	assert.Equal(t, "secret", password)
+}
+
+func TestNewSchemaRegistry_InvalidConfig(t *testing.T) {
+	_, err := newSchemaRegistry(config.SchemaRegistryConfig{TimeoutMs: 1000})
+	assert.Error(t, err)
+
+	_, err = newSchemaRegistry(config.SchemaRegistryConfig{URL: "http://localhost:8081"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,171 @@
+package kafka
+
+import (
+	"crypto/hmac"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"hash"
+	"strconv"
+	"strings"
+)
+
+var (
+	scramSHA256 = func() hash.Hash { return sha256.New() }
+	scramSHA512 = func() hash.Hash { return sha512.New() }
+)
+
+// scramClient implements the client side of SCRAM (RFC 5802) for sarama's
+// SCRAM-SHA-256 and SCRAM-SHA-512 mechanisms. Channel binding is not used.
+type scramClient struct {
+	hash  func() hash.Hash
+	nonce func() (string, error)
+
+	password        string
+	clientNonce     string
+	gs2Header       string
+	clientFirstBare string
+	serverSignature []byte
+	step            int
+	done            bool
+}
+
+func newSCRAMClient(h func() hash.Hash) *scramClient {
+	return &scramClient{hash: h, nonce: scramNonce}
+}
+
+func scramNonce() (string, error) {
+	buf := make([]byte, 24)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawStdEncoding.EncodeToString(buf), nil
+}
+
+// Begin prepares the client-first message
+func (c *scramClient) Begin(userName, password, authzID string) error {
+	nonce, err := c.nonce()
+	if err != nil {
+		return fmt.Errorf("failed to generate SCRAM nonce: %w", err)
+	}
+
+	c.password = password
+	c.clientNonce = nonce
+	c.gs2Header = "n,,"
+	if authzID != "" {
+		c.gs2Header = "n,a=" + scramEscape(authzID) + ","
+	}
+	c.clientFirstBare = "n=" + scramEscape(userName) + ",r=" + nonce
+	c.step = 0
+	c.done = false
+	return nil
+}
+
+// Step answers the server's challenge. The first call, with an empty
+// challenge, returns the client-first message.
+func (c *scramClient) Step(challenge string) (string, error) {
+	c.step++
+	switch c.step {
+	case 1:
+		return c.gs2Header + c.clientFirstBare, nil
+	case 2:
+		return c.clientFinal(challenge)
+	case 3:
+		return "", c.verifyServerFinal(challenge)
+	default:
+		return "", errors.New("SCRAM exchange has already completed")
+	}
+}
+
+// Done reports whether the server's signature has been verified
+func (c *scramClient) Done() bool {
+	return c.done
+}
+
+// clientFinal derives the client proof from the server-first message
+func (c *scramClient) clientFinal(serverFirst string) (string, error) {
+	attrs := scramAttributes(serverFirst)
+	if msg, ok := attrs["e"]; ok {
+		return "", fmt.Errorf("SCRAM server error: %s", msg)
+	}
+
+	serverNonce := attrs["r"]
+	if !strings.HasPrefix(serverNonce, c.clientNonce) || len(serverNonce) == len(c.clientNonce) {
+		return "", errors.New("SCRAM server nonce does not extend the client nonce")
+	}
+
+	salt, err := base64.StdEncoding.DecodeString(attrs["s"])
+	if err != nil || len(salt) == 0 {
+		return "", errors.New("SCRAM server sent an invalid salt")
+	}
+	iterations, err := strconv.Atoi(attrs["i"])
+	if err != nil || iterations <= 0 {
+		return "", errors.New("SCRAM server sent an invalid iteration count")
+	}
+
+	saltedPassword, err := pbkdf2.Key(c.hash, c.password, salt, iterations, c.hash().Size())
+	if err != nil {
+		return "", fmt.Errorf("failed to derive SCRAM key: %w", err)
+	}
+
+	clientFinalWithoutProof := "c=" + base64.StdEncoding.EncodeToString([]byte(c.gs2Header)) + ",r=" + serverNonce
+	authMessage := []byte(c.clientFirstBare + "," + serverFirst + "," + clientFinalWithoutProof)
+
+	clientKey := c.hmac(saltedPassword, []byte("Client Key"))
+	storedKey := c.hash()
+	storedKey.Write(clientKey)
+	clientSignature := c.hmac(storedKey.Sum(nil), authMessage)
+
+	proof := make([]byte, len(clientKey))
+	for i := range clientKey {
+		proof[i] = clientKey[i] ^ clientSignature[i]
+	}
+
+	serverKey := c.hmac(saltedPassword, []byte("Server Key"))
+	c.serverSignature = c.hmac(serverKey, authMessage)
+
+	return clientFinalWithoutProof + ",p=" + base64.StdEncoding.EncodeToString(proof), nil
+}
+
+// verifyServerFinal checks that the server knows the password too
+func (c *scramClient) verifyServerFinal(serverFinal string) error {
+	attrs := scramAttributes(serverFinal)
+	if msg, ok := attrs["e"]; ok {
+		return fmt.Errorf("SCRAM server error: %s", msg)
+	}
+
+	signature, err := base64.StdEncoding.DecodeString(attrs["v"])
+	if err != nil || subtle.ConstantTimeCompare(signature, c.serverSignature) != 1 {
+		return errors.New("SCRAM server signature does not match")
+	}
+
+	c.done = true
+	return nil
+}
+
+func (c *scramClient) hmac(key, message []byte) []byte {
+	mac := hmac.New(c.hash, key)
+	mac.Write(message)
+	return mac.Sum(nil)
+}
+
+// scramAttributes splits a SCRAM message into its single-letter attributes
+func scramAttributes(message string) map[string]string {
+	attrs := make(map[string]string)
+	for _, field := range strings.Split(message, ",") {
+		if len(field) >= 2 && field[1] == '=' {
+			attrs[field[:1]] = field[2:]
+		}
+	}
+	return attrs
+}
+
+// scramEscape encodes the characters SCRAM reserves in user names
+func scramEscape(name string) string {
+	return strings.NewReplacer("=", "=3D", ",", "=2C").Replace(name)
+}
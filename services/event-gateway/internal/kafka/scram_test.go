@@ -0,0 +1,59 @@
+package kafka
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestSCRAMClient_RFC7677 replays the SCRAM-SHA-256 exchange from RFC 7677
+func TestSCRAMClient_RFC7677(t *testing.T) {
+	client := newSCRAMClient(scramSHA256)
+	client.nonce = func() (string, error) { return "rOprNGfwEbeRWgbNEkqO", nil }
+
+	require.NoError(t, client.Begin("user", "pencil", ""))
+
+	clientFirst, err := client.Step("")
+	require.NoError(t, err)
+	assert.Equal(t, "n,,n=user,r=rOprNGfwEbeRWgbNEkqO", clientFirst)
+
+	clientFinal, err := client.Step("r=rOprNGfwEbeRWgbNEkqO%hvYDpWUa2RaTCAfuxFIlj)hNlF$k0,s=W22ZaJ0SNY7soEsUEjb6gQ==,i=4096")
+	require.NoError(t, err)
+	assert.Equal(t, "c=biws,r=rOprNGfwEbeRWgbNEkqO%hvYDpWUa2RaTCAfuxFIlj)hNlF$k0,p=dHzbZapWIk4jUhN+Ute9ytag9zjfMHgsqmmiz7AndVQ=", clientFinal)
+	assert.False(t, client.Done())
+
+	_, err = client.Step("v=6rriTRBi23WpRR/wtup+mMhUZUn/dB5nLTJRsjl95G4=")
+	require.NoError(t, err)
+	assert.True(t, client.Done())
+}
+
+func TestSCRAMClient_RejectsBadServer(t *testing.T) {
+	client := newSCRAMClient(scramSHA512)
+	client.nonce = func() (string, error) { return "client-nonce", nil }
+	require.NoError(t, client.Begin("user", "pencil", ""))
+
+	_, err := client.Step("")
+	require.NoError(t, err)
+
+	// The server must extend our nonce
+	_, err = client.Step("r=other-nonce,s=c2FsdA==,i=4096")
+	assert.Error(t, err)
+
+	require.NoError(t, client.Begin("user", "pencil", ""))
+	_, err = client.Step("")
+	require.NoError(t, err)
+	_, err = client.Step("r=client-nonce-server,s=c2FsdA==,i=4096")
+	require.NoError(t, err)
+
+	// A server that does not know the password cannot produce our signature
+	_, err = client.Step("v=AAAA")
+	assert.Error(t, err)
+	assert.False(t, client.Done())
+
+	require.NoError(t, client.Begin("user", "pencil", ""))
+	_, err = client.Step("")
+	require.NoError(t, err)
+	_, err = client.Step("e=invalid-proof")
+	assert.ErrorContains(t, err, "invalid-proof")
+}
@@ -0,0 +1,269 @@
+package kafka
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/IBM/sarama"
+	"github.com/distributed-event-processor/services/event-gateway/internal/config"
+	"go.uber.org/zap"
+)
+
+// saslMechanisms maps config names to sarama SASL mechanisms
+var saslMechanisms = map[string]sarama.SASLMechanism{
+	"":              sarama.SASLTypePlaintext,
+	"PLAIN":         sarama.SASLTypePlaintext,
+	"SCRAM-SHA-256": sarama.SASLTypeSCRAMSHA256,
+	"SCRAM-SHA-512": sarama.SASLTypeSCRAMSHA512,
+}
+
+// applySecurity configures TLS and SASL authentication on the producer config
+func applySecurity(saramaConfig *sarama.Config, cfg config.KafkaConfig, logger *zap.Logger) error {
+	if cfg.TLS.Enabled {
+		tlsConfig, err := newTLSConfig(cfg.TLS, logger)
+		if err != nil {
+			return err
+		}
+		saramaConfig.Net.TLS.Enable = true
+		saramaConfig.Net.TLS.Config = tlsConfig
+	}
+
+	if cfg.SASL.Enabled {
+		if err := applySASL(saramaConfig, cfg.SASL); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func applySASL(saramaConfig *sarama.Config, cfg config.KafkaSASLConfig) error {
+	mechanism, ok := saslMechanisms[strings.ToUpper(cfg.Mechanism)]
+	if !ok {
+		return fmt.Errorf("unknown SASL mechanism: %s (expected: PLAIN, SCRAM-SHA-256 or SCRAM-SHA-512)", cfg.Mechanism)
+	}
+
+	password, err := readSecret(cfg.Password, cfg.PasswordFile)
+	if err != nil {
+		return fmt.Errorf("failed to read SASL password: %w", err)
+	}
+	if cfg.Username == "" || password == "" {
+		return errors.New("SASL username and password (or password_file) are required when SASL is enabled")
+	}
+
+	saramaConfig.Net.SASL.Enable = true
+	saramaConfig.Net.SASL.Handshake = true
+	saramaConfig.Net.SASL.Mechanism = mechanism
+	saramaConfig.Net.SASL.User = cfg.Username
+	saramaConfig.Net.SASL.Password = password
+
+	switch mechanism {
+	case sarama.SASLTypeSCRAMSHA256:
+		saramaConfig.Net.SASL.SCRAMClientGeneratorFunc = func() sarama.SCRAMClient {
+			return newSCRAMClient(scramSHA256)
+		}
+	case sarama.SASLTypeSCRAMSHA512:
+		saramaConfig.Net.SASL.SCRAMClientGeneratorFunc = func() sarama.SCRAMClient {
+			return newSCRAMClient(scramSHA512)
+		}
+	}
+
+	return nil
+}
+
+// readSecret returns value, or the contents of file when one is set. Trailing
+// newlines are dropped, since secret files mounted from orchestrators often
+// end with one.
+func readSecret(value, file string) (string, error) {
+	if file == "" {
+		return value, nil
+	}
+	if value != "" {
+		return "", errors.New("set either the secret or its file, not both")
+	}
+
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
+
+// newTLSConfig builds a client TLS config whose CA bundle and client
+// certificate are re-read from disk when the files change, so that rotated
+// certificates are used for every new broker connection without a restart.
+func newTLSConfig(cfg config.KafkaTLSConfig, logger *zap.Logger) (*tls.Config, error) {
+	if (cfg.CertFile == "") != (cfg.KeyFile == "") {
+		return nil, errors.New("TLS cert_file and key_file must be set together")
+	}
+
+	reloader := &certReloader{
+		caFile:   cfg.CAFile,
+		certFile: cfg.CertFile,
+		keyFile:  cfg.KeyFile,
+		logger:   logger,
+	}
+	if err := reloader.load(); err != nil {
+		return nil, err
+	}
+
+	tlsConfig := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		ServerName: cfg.ServerName,
+	}
+
+	if cfg.CertFile != "" {
+		tlsConfig.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			return reloader.clientCertificate(), nil
+		}
+	}
+
+	if cfg.InsecureSkipVerify {
+		logger.Warn("Kafka TLS certificate verification is disabled; do not use this in production")
+		tlsConfig.InsecureSkipVerify = true
+		return tlsConfig, nil
+	}
+
+	// The standard verification pins RootCAs when the config is built, so the
+	// chain is verified here instead against the current CA bundle
+	if cfg.CAFile != "" {
+		tlsConfig.InsecureSkipVerify = true
+		tlsConfig.VerifyConnection = func(state tls.ConnectionState) error {
+			return verifyServerCertificate(state, reloader.rootCAs())
+		}
+	}
+
+	return tlsConfig, nil
+}
+
+// verifyServerCertificate checks the broker's chain and hostname, as crypto/tls
+// would with RootCAs set to roots
+func verifyServerCertificate(state tls.ConnectionState, roots *x509.CertPool) error {
+	if len(state.PeerCertificates) == 0 {
+		return errors.New("kafka broker presented no certificate")
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, cert := range state.PeerCertificates[1:] {
+		intermediates.AddCert(cert)
+	}
+
+	_, err := state.PeerCertificates[0].Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		DNSName:       state.ServerName,
+	})
+	return err
+}
+
+// certReloader holds the TLS material loaded from disk and reloads it on
+// access when any of the files has been modified since the last load. A
+// failed reload keeps the previous material, since a rotation may still be
+// half written.
+type certReloader struct {
+	caFile   string
+	certFile string
+	keyFile  string
+	logger   *zap.Logger
+
+	mu       sync.Mutex
+	roots    *x509.CertPool
+	cert     *tls.Certificate
+	versions map[string]fileVersion
+}
+
+// fileVersion identifies one revision of a file on disk
+type fileVersion struct {
+	modTime time.Time
+	size    int64
+}
+
+// load reads every configured file, failing if any of them is unusable
+func (r *certReloader) load() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	versions := r.stat()
+
+	if r.caFile != "" {
+		pem, err := os.ReadFile(r.caFile)
+		if err != nil {
+			return fmt.Errorf("failed to read TLS ca_file: %w", err)
+		}
+		roots := x509.NewCertPool()
+		if !roots.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("TLS ca_file %s contains no PEM certificates", r.caFile)
+		}
+		r.roots = roots
+	}
+
+	if r.certFile != "" {
+		cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+		if err != nil {
+			return fmt.Errorf("failed to load TLS client certificate: %w", err)
+		}
+		r.cert = &cert
+	}
+
+	r.versions = versions
+	return nil
+}
+
+// stat returns the current version of every configured file
+func (r *certReloader) stat() map[string]fileVersion {
+	versions := make(map[string]fileVersion, 3)
+	for _, file := range []string{r.caFile, r.certFile, r.keyFile} {
+		if file == "" {
+			continue
+		}
+		if info, err := os.Stat(file); err == nil {
+			versions[file] = fileVersion{modTime: info.ModTime(), size: info.Size()}
+		}
+	}
+	return versions
+}
+
+// refresh reloads the material if any file changed since it was last loaded
+func (r *certReloader) refresh() {
+	r.mu.Lock()
+	changed := false
+	for file, version := range r.stat() {
+		if r.versions[file] != version {
+			changed = true
+			break
+		}
+	}
+	r.mu.Unlock()
+
+	if !changed {
+		return
+	}
+
+	if err := r.load(); err != nil {
+		r.logger.Warn("Failed to reload Kafka TLS certificates, keeping the previous ones", zap.Error(err))
+		return
+	}
+	r.logger.Info("Reloaded Kafka TLS certificates")
+}
+
+func (r *certReloader) clientCertificate() *tls.Certificate {
+	r.refresh()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.cert
+}
+
+func (r *certReloader) rootCAs() *x509.CertPool {
+	r.refresh()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.roots
+}
@@ -0,0 +1,329 @@
+package kafka
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/IBM/sarama"
+	"github.com/distributed-event-processor/services/event-gateway/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// testCA issues certificates for TLS tests
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+func newTestCA(t *testing.T, name string) *testCA {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	return &testCA{cert: cert, key: key, pem: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+}
+
+// issue returns a PEM certificate and key for name, valid for servers and clients
+func (ca *testCA) issue(t *testing.T, name string) ([]byte, []byte) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		DNSNames:     []string{name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	require.NoError(t, err)
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
+
+func (ca *testCA) pool() *x509.CertPool {
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.cert)
+	return pool
+}
+
+// testFileWrites spaces out the modification times set by writeTestFile
+var testFileWrites atomic.Int64
+
+// writeTestFile writes data to path with a modification time later than any
+// previous write, so that rewrites within the filesystem's timestamp
+// granularity are still noticed
+func writeTestFile(t *testing.T, path string, data []byte) {
+	require.NoError(t, os.WriteFile(path, data, 0o600))
+	modTime := time.Now().Add(time.Duration(testFileWrites.Add(1)) * time.Second)
+	require.NoError(t, os.Chtimes(path, modTime, modTime))
+}
+
+// startTLSProxy terminates TLS in front of a mock broker, requiring client
+// certificates issued by clientCA, and returns the proxy's address
+func startTLSProxy(t *testing.T, broker *sarama.MockBroker, serverCA, clientCA *testCA) string {
+	certPEM, keyPEM := serverCA.issue(t, "localhost")
+	serverCert, err := tls.X509KeyPair(certPEM, keyPEM)
+	require.NoError(t, err)
+
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{
+		Certificates: []tls.Certificate{serverCert},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    clientCA.pool(),
+		MinVersion:   tls.VersionTLS12,
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				upstream, err := net.Dial("tcp", broker.Addr())
+				if err != nil {
+					return
+				}
+				defer upstream.Close()
+				go io.Copy(upstream, conn)
+				io.Copy(conn, upstream)
+			}()
+		}
+	}()
+
+	return listener.Addr().String()
+}
+
+func TestTLS_MutualAuthThroughTerminatingProxy(t *testing.T) {
+	serverCA := newTestCA(t, "server-ca")
+	clientCA := newTestCA(t, "client-ca")
+
+	broker := sarama.NewMockBroker(t, 1)
+	t.Cleanup(broker.Close)
+	proxyAddr := startTLSProxy(t, broker, serverCA, clientCA)
+	broker.SetHandlerByMap(map[string]sarama.MockResponse{
+		"ApiVersionsRequest": sarama.NewMockApiVersionsResponse(t),
+		"MetadataRequest": sarama.NewMockMetadataResponse(t).
+			SetBroker(proxyAddr, broker.BrokerID()).
+			SetLeader("events", 0, broker.BrokerID()),
+	})
+
+	dir := t.TempDir()
+	certPEM, keyPEM := clientCA.issue(t, "gateway")
+	writeTestFile(t, filepath.Join(dir, "ca.pem"), serverCA.pem)
+	writeTestFile(t, filepath.Join(dir, "client.pem"), certPEM)
+	writeTestFile(t, filepath.Join(dir, "client-key.pem"), keyPEM)
+
+	cfg := validKafkaConfig()
+	cfg.Brokers = []string{proxyAddr}
+	cfg.TLS = config.KafkaTLSConfig{
+		Enabled:    true,
+		CAFile:     filepath.Join(dir, "ca.pem"),
+		CertFile:   filepath.Join(dir, "client.pem"),
+		KeyFile:    filepath.Join(dir, "client-key.pem"),
+		ServerName: "localhost",
+	}
+
+	saramaConfig, err := newSaramaConfig(cfg, zap.NewNop())
+	require.NoError(t, err)
+	saramaConfig.Metadata.Retry.Max = 0
+
+	client, err := sarama.NewClient(cfg.Brokers, saramaConfig)
+	require.NoError(t, err)
+	defer client.Close()
+
+	partitions, err := client.Partitions("events")
+	require.NoError(t, err)
+	assert.Equal(t, []int32{0}, partitions)
+
+	// Without the client certificate the proxy refuses the handshake
+	cfg.TLS.CertFile, cfg.TLS.KeyFile = "", ""
+	saramaConfig, err = newSaramaConfig(cfg, zap.NewNop())
+	require.NoError(t, err)
+	saramaConfig.Metadata.Retry.Max = 0
+	saramaConfig.Net.DialTimeout = time.Second
+
+	_, err = sarama.NewClient(cfg.Brokers, saramaConfig)
+	assert.Error(t, err)
+}
+
+func TestTLS_ReloadsRotatedCertificates(t *testing.T) {
+	oldCA := newTestCA(t, "old-ca")
+	newCA := newTestCA(t, "new-ca")
+
+	dir := t.TempDir()
+	caFile := filepath.Join(dir, "ca.pem")
+	certFile := filepath.Join(dir, "client.pem")
+	keyFile := filepath.Join(dir, "client-key.pem")
+
+	oldCert, oldKey := oldCA.issue(t, "gateway")
+	writeTestFile(t, caFile, oldCA.pem)
+	writeTestFile(t, certFile, oldCert)
+	writeTestFile(t, keyFile, oldKey)
+
+	tlsConfig, err := newTLSConfig(config.KafkaTLSConfig{
+		Enabled:  true,
+		CAFile:   caFile,
+		CertFile: certFile,
+		KeyFile:  keyFile,
+	}, zap.NewNop())
+	require.NoError(t, err)
+
+	clientCert, err := tlsConfig.GetClientCertificate(nil)
+	require.NoError(t, err)
+	assert.Equal(t, "gateway", mustParse(t, clientCert).Subject.CommonName)
+	assert.Equal(t, "old-ca", mustParse(t, clientCert).Issuer.CommonName)
+
+	serverCert, _ := newCA.issue(t, "localhost")
+	state := tls.ConnectionState{ServerName: "localhost", PeerCertificates: []*x509.Certificate{parsePEM(t, serverCert)}}
+	assert.Error(t, tlsConfig.VerifyConnection(state), "the new CA is not trusted yet")
+
+	// Rotate everything to the new CA
+	newCert, newKey := newCA.issue(t, "gateway")
+	writeTestFile(t, caFile, newCA.pem)
+	writeTestFile(t, certFile, newCert)
+	writeTestFile(t, keyFile, newKey)
+
+	clientCert, err = tlsConfig.GetClientCertificate(nil)
+	require.NoError(t, err)
+	assert.Equal(t, "new-ca", mustParse(t, clientCert).Issuer.CommonName)
+	assert.NoError(t, tlsConfig.VerifyConnection(state))
+
+	// A half-written rotation keeps the previous material
+	writeTestFile(t, certFile, []byte("not a certificate"))
+	clientCert, err = tlsConfig.GetClientCertificate(nil)
+	require.NoError(t, err)
+	assert.Equal(t, "new-ca", mustParse(t, clientCert).Issuer.CommonName)
+}
+
+func mustParse(t *testing.T, cert *tls.Certificate) *x509.Certificate {
+	require.NotNil(t, cert)
+	parsed, err := x509.ParseCertificate(cert.Certificate[0])
+	require.NoError(t, err)
+	return parsed
+}
+
+func parsePEM(t *testing.T, certPEM []byte) *x509.Certificate {
+	block, _ := pem.Decode(certPEM)
+	require.NotNil(t, block)
+	cert, err := x509.ParseCertificate(block.Bytes)
+	require.NoError(t, err)
+	return cert
+}
+
+func TestNewSaramaConfig_SASL(t *testing.T) {
+	passwordFile := filepath.Join(t.TempDir(), "password")
+	writeTestFile(t, passwordFile, []byte("from-file\n"))
+
+	tests := []struct {
+		name      string
+		sasl      config.KafkaSASLConfig
+		mechanism sarama.SASLMechanism
+		password  string
+		wantErr   bool
+	}{
+		{
+			name:      "plain",
+			sasl:      config.KafkaSASLConfig{Enabled: true, Mechanism: "PLAIN", Username: "gateway", Password: "secret"},
+			mechanism: sarama.SASLTypePlaintext,
+			password:  "secret",
+		},
+		{
+			name:      "scram-sha-256 with password file",
+			sasl:      config.KafkaSASLConfig{Enabled: true, Mechanism: "SCRAM-SHA-256", Username: "gateway", PasswordFile: passwordFile},
+			mechanism: sarama.SASLTypeSCRAMSHA256,
+			password:  "from-file",
+		},
+		{
+			name:      "scram-sha-512",
+			sasl:      config.KafkaSASLConfig{Enabled: true, Mechanism: "scram-sha-512", Username: "gateway", Password: "secret"},
+			mechanism: sarama.SASLTypeSCRAMSHA512,
+			password:  "secret",
+		},
+		{
+			name:    "unknown mechanism",
+			sasl:    config.KafkaSASLConfig{Enabled: true, Mechanism: "GSSAPI", Username: "gateway", Password: "secret"},
+			wantErr: true,
+		},
+		{
+			name:    "missing password",
+			sasl:    config.KafkaSASLConfig{Enabled: true, Mechanism: "PLAIN", Username: "gateway"},
+			wantErr: true,
+		},
+		{
+			name:    "password and password file",
+			sasl:    config.KafkaSASLConfig{Enabled: true, Mechanism: "PLAIN", Username: "gateway", Password: "secret", PasswordFile: passwordFile},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := validKafkaConfig()
+			cfg.SASL = tt.sasl
+
+			saramaConfig, err := newSaramaConfig(cfg, zap.NewNop())
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.True(t, saramaConfig.Net.SASL.Enable)
+			assert.Equal(t, tt.mechanism, saramaConfig.Net.SASL.Mechanism)
+			assert.Equal(t, "gateway", saramaConfig.Net.SASL.User)
+			assert.Equal(t, tt.password, saramaConfig.Net.SASL.Password)
+		})
+	}
+}
+
+func TestNewTLSConfig_Invalid(t *testing.T) {
+	_, err := newTLSConfig(config.KafkaTLSConfig{Enabled: true, CertFile: "client.pem"}, zap.NewNop())
+	assert.Error(t, err, "cert_file without key_file")
+
+	_, err = newTLSConfig(config.KafkaTLSConfig{Enabled: true, CAFile: filepath.Join(t.TempDir(), "missing.pem")}, zap.NewNop())
+	assert.Error(t, err)
+
+	tlsConfig, err := newTLSConfig(config.KafkaTLSConfig{Enabled: true, InsecureSkipVerify: true}, zap.NewNop())
+	require.NoError(t, err)
+	assert.True(t, tlsConfig.InsecureSkipVerify)
+	assert.Nil(t, tlsConfig.VerifyConnection)
+}
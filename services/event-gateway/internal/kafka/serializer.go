@@ -0,0 +1,163 @@
+package kafka
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+
+	"github.com/distributed-event-processor/services/event-gateway/internal/cloudevents"
+	"github.com/distributed-event-processor/services/event-gateway/internal/config"
+	"github.com/distributed-event-processor/services/event-gateway/internal/models"
+)
+
+// Serialization formats supported for message values
+const (
+	FormatJSON        = "json"
+	FormatProtobuf    = "protobuf"
+	FormatAvro        = "avro"
+	FormatCloudEvents = "cloudevents"
+)
+
+// Content types recorded in the content-type header of every message
+const (
+	ContentTypeJSON        = "application/json"
+	ContentTypeProtobuf    = "application/x-protobuf"
+	ContentTypeAvro        = "application/avro"
+	ContentTypeCloudEvents = cloudevents.ContentType
+)
+
+// contentTypeHeader names the message header that carries the value's format
+const contentTypeHeader = "content-type"
+
+// confluentMagicByte starts every value in the Confluent wire format, followed
+// by the 4-byte big-endian schema ID
+const confluentMagicByte = 0
+
+// Serializer encodes events into Kafka message values
+type Serializer interface {
+	// Serialize encodes event for producing to topic
+	Serialize(topic string, event *models.Event) ([]byte, error)
+
+	// ContentType identifies the encoding to consumers
+	ContentType() string
+}
+
+// jsonSerializer writes events as plain JSON, as the gateway always has
+type jsonSerializer struct{}
+
+func (jsonSerializer) Serialize(_ string, event *models.Event) ([]byte, error) {
+	return json.Marshal(event)
+}
+
+func (jsonSerializer) ContentType() string {
+	return ContentTypeJSON
+}
+
+// cloudEventsSerializer writes events as CloudEvents in structured mode
+type cloudEventsSerializer struct{}
+
+func (cloudEventsSerializer) Serialize(_ string, event *models.Event) ([]byte, error) {
+	return cloudevents.Encode(event)
+}
+
+func (cloudEventsSerializer) ContentType() string {
+	return ContentTypeCloudEvents
+}
+
+// serializers selects the serializer for each topic
+type serializers struct {
+	fallback Serializer
+	byTopic  map[string]Serializer
+}
+
+// newSerializers builds the configured serializers. Schemas for the known
+// topics are resolved up front, so that an unreachable or incompatible
+// registry fails at startup rather than on the first produce.
+func newSerializers(cfg config.SerializationConfig, topics []string) (*serializers, error) {
+	var registry *schemaRegistry
+	byFormat := make(map[string]Serializer)
+
+	serializerFor := func(format string) (Serializer, error) {
+		if format == "" {
+			format = FormatJSON
+		}
+		if s, ok := byFormat[format]; ok {
+			return s, nil
+		}
+
+		var s Serializer
+		switch format {
+		case FormatJSON:
+			s = jsonSerializer{}
+		case FormatCloudEvents:
+			s = cloudEventsSerializer{}
+		case FormatProtobuf, FormatAvro:
+			if registry == nil {
+				var err error
+				if registry, err = newSchemaRegistry(cfg.SchemaRegistry); err != nil {
+					return nil, err
+				}
+			}
+			if format == FormatProtobuf {
+				s = &protobufSerializer{registry: registry}
+			} else {
+				s = &avroSerializer{registry: registry}
+			}
+		default:
+			return nil, fmt.Errorf("unknown serialization format: %s (expected: %s, %s, %s or %s)", format, FormatJSON, FormatCloudEvents, FormatProtobuf, FormatAvro)
+		}
+
+		byFormat[format] = s
+		return s, nil
+	}
+
+	fallback, err := serializerFor(cfg.Format)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &serializers{fallback: fallback, byTopic: make(map[string]Serializer)}
+	for _, override := range cfg.Overrides {
+		if len(override.Topics) == 0 {
+			return nil, fmt.Errorf("serialization override for format %s lists no topics", override.Format)
+		}
+		serializer, err := serializerFor(override.Format)
+		if err != nil {
+			return nil, err
+		}
+		for _, topic := range override.Topics {
+			s.byTopic[topic] = serializer
+		}
+	}
+
+	if registry != nil {
+		for _, topic := range topics {
+			if resolver, ok := s.For(topic).(schemaResolver); ok {
+				if _, err := resolver.resolveSchema(topic); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+
+	return s, nil
+}
+
+// For returns the serializer for topic
+func (s *serializers) For(topic string) Serializer {
+	if serializer, ok := s.byTopic[topic]; ok {
+		return serializer
+	}
+	return s.fallback
+}
+
+// schemaResolver is implemented by serializers that register their schema
+type schemaResolver interface {
+	resolveSchema(topic string) (int, error)
+}
+
+// appendWireHeader frames a value in the Confluent wire format
+func appendWireHeader(buf []byte, schemaID int) []byte {
+	buf = append(buf, confluentMagicByte)
+	return binary.BigEndian.AppendUint32(buf, uint32(schemaID))
+}
@@ -0,0 +1,162 @@
+package kafka
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"testing"
+
+	"github.com/IBM/sarama/mocks"
+	"github.com/distributed-event-processor/services/event-gateway/internal/config"
+	pb "github.com/distributed-event-processor/shared/proto/events/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestNewSerializers_PerTopicFormat(t *testing.T) {
+	fake := newFakeSchemaRegistry(t)
+	codecs, err := newSerializers(config.SerializationConfig{
+		Format: FormatJSON,
+		Overrides: []config.SerializationOverride{
+			{Topics: []string{"events.payments"}, Format: FormatProtobuf},
+			{Topics: []string{"events.audit"}, Format: FormatAvro},
+		},
+		SchemaRegistry: fake.config(),
+	}, []string{"events", "events.payments", "events.audit"})
+	require.NoError(t, err)
+
+	assert.Equal(t, ContentTypeJSON, codecs.For("events").ContentType())
+	assert.Equal(t, ContentTypeJSON, codecs.For("events.unknown").ContentType())
+	assert.Equal(t, ContentTypeProtobuf, codecs.For("events.payments").ContentType())
+	assert.Equal(t, ContentTypeAvro, codecs.For("events.audit").ContentType())
+
+	// Schemas of known topics are registered at startup
+	assert.ElementsMatch(t, []string{
+		"POST /subjects/events.payments-value/versions",
+		"POST /subjects/events.audit-value/versions",
+	}, fake.requestLog())
+}
+
+func TestNewSerializers_InvalidConfig(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  config.SerializationConfig
+	}{
+		{"unknown format", config.SerializationConfig{Format: "xml"}},
+		{"protobuf without registry", config.SerializationConfig{Format: FormatProtobuf}},
+		{"override without topics", config.SerializationConfig{
+			Overrides: []config.SerializationOverride{{Format: FormatJSON}},
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := newSerializers(tt.cfg, []string{"events"})
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestProtobufSerializer_WireFormat(t *testing.T) {
+	fake := newFakeSchemaRegistry(t)
+	codecs, err := newSerializers(config.SerializationConfig{
+		Format:         FormatProtobuf,
+		SchemaRegistry: fake.config(),
+	}, nil)
+	require.NoError(t, err)
+
+	event := createTestEvent()
+	event.TenantID = "acme"
+	event.Priority = 7
+	value, err := codecs.For("events").Serialize("events", event)
+	require.NoError(t, err)
+
+	require.Greater(t, len(value), 6)
+	assert.Equal(t, byte(confluentMagicByte), value[0])
+	assert.Equal(t, uint32(1), binary.BigEndian.Uint32(value[1:5]))
+	assert.Equal(t, byte(0), value[5], "Event is the first message in events.proto")
+
+	var decoded pb.Event
+	require.NoError(t, proto.Unmarshal(value[6:], &decoded))
+	assert.Equal(t, event.ID, decoded.Id)
+	assert.Equal(t, event.Type, decoded.Type)
+	assert.Equal(t, event.Subject, decoded.Subject)
+	assert.Equal(t, "acme", decoded.TenantId)
+	assert.Equal(t, int32(7), decoded.Priority)
+	assert.Equal(t, "value", decoded.Data.AsMap()["key"])
+	assert.Equal(t, event.Timestamp, decoded.Timestamp.AsTime())
+}
+
+func TestAppendMessageIndexes(t *testing.T) {
+	assert.Equal(t, []byte{0}, appendMessageIndexes(nil, []int{0}))
+	assert.Equal(t, []byte{4, 2, 0}, appendMessageIndexes(nil, []int{1, 0}))
+}
+
+func TestAvroSerializer_WireFormat(t *testing.T) {
+	fake := newFakeSchemaRegistry(t)
+	codecs, err := newSerializers(config.SerializationConfig{
+		Format:         FormatAvro,
+		SchemaRegistry: fake.config(),
+	}, nil)
+	require.NoError(t, err)
+
+	event := createTestEvent()
+	value, err := codecs.For("events").Serialize("events", event)
+	require.NoError(t, err)
+
+	assert.Equal(t, byte(confluentMagicByte), value[0])
+	assert.Equal(t, uint32(1), binary.BigEndian.Uint32(value[1:5]))
+
+	// The record opens with the id string: zigzag length, then its bytes
+	length, n := binary.Varint(value[5:])
+	require.Positive(t, n)
+	assert.Equal(t, event.ID, string(value[5+n:5+n+int(length)]))
+
+	// Priority 0 closes the record as a single zero byte
+	assert.Equal(t, byte(0), value[len(value)-1])
+}
+
+func TestAppendAvroStringMap(t *testing.T) {
+	assert.Equal(t, []byte{0}, appendAvroStringMap(nil, nil))
+	assert.Equal(t,
+		[]byte{4, 2, 'a', 2, '1', 2, 'b', 2, '2', 0},
+		appendAvroStringMap(nil, map[string]string{"b": "2", "a": "1"}))
+}
+
+func TestBuildMessage_ContentTypeHeader(t *testing.T) {
+	mockProducer := mocks.NewSyncProducer(t, nil)
+	producer := createTestProducer(t, mockProducer)
+
+	message, err := producer.buildMessage(createTestEvent())
+	require.NoError(t, err)
+	assert.Equal(t, ContentTypeJSON, headerValue(message, contentTypeHeader))
+
+	value, err := message.Value.Encode()
+	require.NoError(t, err)
+	assert.True(t, json.Valid(value))
+}
+
+func TestCloudEventsSerializer(t *testing.T) {
+	codecs, err := newSerializers(config.SerializationConfig{
+		Overrides: []config.SerializationOverride{
+			{Topics: []string{"events.external"}, Format: FormatCloudEvents},
+		},
+	}, []string{"events", "events.external"})
+	require.NoError(t, err)
+
+	serializer := codecs.For("events.external")
+	assert.Equal(t, ContentTypeCloudEvents, serializer.ContentType())
+	assert.Equal(t, ContentTypeJSON, codecs.For("events").ContentType())
+
+	event := createTestEvent()
+	value, err := serializer.Serialize("events.external", event)
+	require.NoError(t, err)
+
+	var attributes map[string]interface{}
+	require.NoError(t, json.Unmarshal(value, &attributes))
+	assert.Equal(t, "1.0", attributes["specversion"])
+	assert.Equal(t, event.ID, attributes["id"])
+	assert.Equal(t, event.Type, attributes["type"])
+	assert.Equal(t, event.Source, attributes["source"])
+	assert.NotNil(t, attributes["data"])
+}
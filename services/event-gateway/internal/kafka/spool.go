@@ -0,0 +1,232 @@
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/IBM/sarama"
+	"github.com/distributed-event-processor/services/event-gateway/internal/config"
+	"github.com/distributed-event-processor/services/event-gateway/internal/models"
+	"github.com/distributed-event-processor/services/event-gateway/internal/spool"
+	"go.uber.org/zap"
+)
+
+// errCorruptSpoolRecord marks a spooled record that no longer decodes into an event
+var errCorruptSpoolRecord = errors.New("corrupt spool record")
+
+// permanentErrors fail the same way however long we wait, so spooling the
+// event would only block the replay queue behind it
+var permanentErrors = []error{
+	errCorruptSpoolRecord,
+	sarama.ErrMessageSizeTooLarge,
+	sarama.ErrInvalidMessage,
+	sarama.ErrInvalidMessageSize,
+	sarama.ErrInvalidTopic,
+	sarama.ErrTopicAuthorizationFailed,
+}
+
+func openSpool(cfg config.KafkaConfig, logger *zap.Logger) (*spool.Spool, error) {
+	if cfg.DeliveryGuarantee == GuaranteeTransactional {
+		return nil, errors.New("spool cannot be combined with transactional delivery: replayed events would not be committed with their batch")
+	}
+	if cfg.Spool.ReplayIntervalMs <= 0 || cfg.Spool.ReplayBatchSize <= 0 {
+		return nil, errors.New("spool replay_interval_ms and replay_batch_size must be positive")
+	}
+	return spool.Open(cfg.Spool, logger)
+}
+
+// spoolable reports whether a send that failed with err may succeed once Kafka recovers
+func spoolable(err error) bool {
+	var configErr sarama.ConfigurationError
+	if errors.As(err, &configErr) {
+		return false
+	}
+	for _, permanent := range permanentErrors {
+		if errors.Is(err, permanent) {
+			return false
+		}
+	}
+	return true
+}
+
+// spoolFailed writes a message that Kafka rejected to the spool so that it can
+// be replayed later, and reports whether it did
+func (p *Producer) spoolFailed(message *sarama.ProducerMessage, eventID string, err error) bool {
+	if p.spool == nil || !spoolable(err) {
+		return false
+	}
+
+	value, encodeErr := spoolRecord(message)
+	if encodeErr == nil {
+		encodeErr = p.spool.Append(value)
+	}
+	if encodeErr != nil {
+		p.logger.Error("Failed to spool event",
+			zap.String("event_id", eventID),
+			zap.NamedError("kafka_error", err),
+			zap.Error(encodeErr))
+		return false
+	}
+
+	p.logger.Warn("Kafka unavailable, event spooled for replay",
+		zap.String("event_id", eventID),
+		zap.String("topic", message.Topic),
+		zap.Error(err))
+	return true
+}
+
+// spoolBehindBacklog appends message to the spool while older events are
+// still waiting there, so that events reach Kafka in the order they were
+// accepted, and reports whether it did. When the spool cannot take the event,
+// it is sent directly and may overtake the backlog.
+func (p *Producer) spoolBehindBacklog(message *sarama.ProducerMessage) bool {
+	if p.spool == nil || p.spool.Len() == 0 {
+		return false
+	}
+
+	value, err := spoolRecord(message)
+	if err == nil {
+		err = p.spool.Append(value)
+	}
+	if err != nil {
+		p.logger.Warn("Failed to spool event behind the backlog, sending it directly",
+			zap.String("topic", message.Topic),
+			zap.Error(err))
+		return false
+	}
+	return true
+}
+
+// queuedResult is the result of a message that waits in the spool
+func (p *Producer) queuedResult(message *sarama.ProducerMessage) DeliveryResult {
+	var rule string
+	if delivery, ok := message.Metadata.(*deliveryContext); ok {
+		rule = delivery.rule
+	}
+	return DeliveryResult{Cluster: p.cluster(), Topic: message.Topic, Rule: rule, Queued: true}
+}
+
+// spoolRecord returns the event behind message as JSON. Replay rebuilds the
+// message from it, so events are spooled the same way whatever format their
+// topic is produced in.
+func spoolRecord(message *sarama.ProducerMessage) ([]byte, error) {
+	if delivery, ok := message.Metadata.(*deliveryContext); ok && delivery.event != nil {
+		return json.Marshal(delivery.event)
+	}
+	return message.Value.Encode()
+}
+
+// startReplayLoop periodically drains the spool back into Kafka. Each attempt
+// doubles as a connectivity probe, so replay resumes on its own once the
+// brokers are reachable again.
+func (p *Producer) startReplayLoop() {
+	ctx, cancel := context.WithCancel(context.Background())
+	p.stopReplay = cancel
+	interval := time.Duration(p.config.Spool.ReplayIntervalMs) * time.Millisecond
+
+	p.replayWG.Add(1)
+	go func() {
+		defer p.replayWG.Done()
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				p.replaySpool(ctx)
+			}
+		}
+	}()
+}
+
+// replaySpool produces spooled events in order until the spool is empty or a
+// send fails. Only the leading run of delivered events is committed, so a
+// partially failed batch is retried from its first failure; events after it
+// that did reach Kafka are produced again, which keeps at-least-once delivery.
+func (p *Producer) replaySpool(ctx context.Context) {
+	for p.spool.Len() > 0 {
+		if ctx.Err() != nil {
+			return
+		}
+
+		// Replay waits while the breaker is open, and serves as its probe once half-open
+		if p.breaker.allow() != nil {
+			return
+		}
+
+		records, err := p.spool.Peek(p.config.Spool.ReplayBatchSize)
+		if err != nil {
+			p.logger.Error("Failed to read spool", zap.Error(err))
+			return
+		}
+
+		// Records that no longer decode are skipped rather than blocking the spool
+		results := make([]DeliveryResult, len(records))
+		messages := make([]*sarama.ProducerMessage, 0, len(records))
+		indices := make([]int, 0, len(records))
+		for i, record := range records {
+			var event models.Event
+			if err := json.Unmarshal(record, &event); err != nil {
+				results[i] = DeliveryResult{Err: fmt.Errorf("%w: %v", errCorruptSpoolRecord, err)}
+				p.deadLetterRecord(record, err)
+				continue
+			}
+			message, err := p.buildMessage(&event)
+			if err != nil {
+				results[i] = DeliveryResult{Err: fmt.Errorf("%w: %v", errCorruptSpoolRecord, err)}
+				p.deadLetterRecord(record, err)
+				continue
+			}
+			message.Metadata.(*deliveryContext).replay = true
+			messages = append(messages, message)
+			indices = append(indices, i)
+		}
+
+		if len(messages) > 0 {
+			p.sendBatch(ctx, messages, indices, results)
+		}
+
+		committed := 0
+		for _, result := range results {
+			if result.Err == nil {
+				spoolReplayed.Inc()
+			} else if spoolable(result.Err) {
+				break
+			} else {
+				spoolDropped.Inc()
+				p.logger.Error("Dropping spooled event that cannot be produced", zap.Error(result.Err))
+			}
+			committed++
+		}
+
+		if err := p.spool.Commit(committed); err != nil {
+			p.logger.Error("Failed to commit spool", zap.Error(err))
+			return
+		}
+
+		if committed < len(records) {
+			spoolReplayFailures.Inc()
+			p.logger.Debug("Kafka still unavailable, spool replay paused",
+				zap.Int("pending", p.spool.Len()))
+			return
+		}
+
+		p.logger.Info("Replayed spooled events",
+			zap.Int("count", committed),
+			zap.Int("pending", p.spool.Len()))
+	}
+}
+
+// Spooled returns the number of events waiting in the spool for Kafka to recover
+func (p *Producer) Spooled() int {
+	if p.spool == nil {
+		return 0
+	}
+	return p.spool.Len()
+}
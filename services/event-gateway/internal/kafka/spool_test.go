@@ -0,0 +1,222 @@
+package kafka
+
+import (
+	"context"
+	"testing"
+
+	"github.com/IBM/sarama"
+	"github.com/IBM/sarama/mocks"
+	"github.com/distributed-event-processor/services/event-gateway/internal/config"
+	"github.com/distributed-event-processor/services/event-gateway/internal/models"
+	"github.com/distributed-event-processor/services/event-gateway/internal/spool"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func createTestSpoolingProducer(t *testing.T, mockProducer *mocks.SyncProducer) *Producer {
+	producer := createTestProducer(t, mockProducer)
+	producer.config.Spool = config.SpoolConfig{
+		Enabled:          true,
+		Dir:              t.TempDir(),
+		MaxBytes:         1 << 20,
+		SegmentBytes:     1 << 16,
+		FsyncPolicy:      spool.FsyncAlways,
+		ReplayIntervalMs: 1000,
+		ReplayBatchSize:  10,
+	}
+
+	s, err := openSpool(producer.config, zap.NewNop())
+	require.NoError(t, err)
+	producer.spool = s
+	return producer
+}
+
+func TestProduce_SpoolsWhenKafkaUnavailable(t *testing.T) {
+	mockProducer := mocks.NewSyncProducer(t, nil)
+	mockProducer.ExpectSendMessageAndFail(sarama.ErrOutOfBrokers)
+
+	producer := createTestSpoolingProducer(t, mockProducer)
+	defer producer.Close()
+
+	result := producer.Produce(context.Background(), createTestEvent())
+
+	require.NoError(t, result.Err)
+	assert.True(t, result.Queued)
+	assert.Equal(t, "test-events", result.Topic)
+	assert.Equal(t, 1, producer.Spooled())
+}
+
+func TestProduce_DoesNotSpoolPermanentErrors(t *testing.T) {
+	mockProducer := mocks.NewSyncProducer(t, nil)
+	mockProducer.ExpectSendMessageAndFail(sarama.ErrMessageSizeTooLarge)
+
+	producer := createTestSpoolingProducer(t, mockProducer)
+	defer producer.Close()
+
+	result := producer.Produce(context.Background(), createTestEvent())
+
+	assert.ErrorIs(t, result.Err, sarama.ErrMessageSizeTooLarge)
+	assert.False(t, result.Queued)
+	assert.Zero(t, producer.Spooled())
+}
+
+func TestProduceBatch_SpoolsFailedEvents(t *testing.T) {
+	mockProducer := mocks.NewSyncProducer(t, nil)
+	producer := createTestSpoolingProducer(t, mockProducer)
+	producer.producer = &partialFailureSyncProducer{
+		SyncProducer: mockProducer,
+		failIndices:  map[int]error{1: sarama.ErrNotLeaderForPartition},
+	}
+	defer producer.Close()
+
+	events := []*models.Event{createTestEvent(), createTestEvent(), createTestEvent()}
+	results := producer.ProduceBatch(context.Background(), events)
+
+	require.Len(t, results, 3)
+	for _, result := range results {
+		assert.NoError(t, result.Err)
+	}
+	assert.False(t, results[0].Queued)
+	assert.True(t, results[1].Queued)
+	assert.False(t, results[2].Queued)
+	assert.Equal(t, 1, producer.Spooled())
+}
+
+func TestReplaySpool_DrainsInOrder(t *testing.T) {
+	mockProducer := mocks.NewSyncProducer(t, nil)
+	producer := createTestSpoolingProducer(t, mockProducer)
+	defer producer.Close()
+
+	// Only the first event is sent; the others queue behind it without a send
+	ids := []string{"event-1", "event-2", "event-3"}
+	mockProducer.ExpectSendMessageAndFail(sarama.ErrOutOfBrokers)
+	for _, id := range ids {
+		event := createTestEvent()
+		event.ID = id
+		require.True(t, producer.Produce(context.Background(), event).Queued)
+	}
+
+	// Kafka is still down: nothing is committed
+	mockProducer.ExpectSendMessageAndFail(sarama.ErrOutOfBrokers)
+	mockProducer.ExpectSendMessageAndFail(sarama.ErrOutOfBrokers)
+	mockProducer.ExpectSendMessageAndFail(sarama.ErrOutOfBrokers)
+	producer.replaySpool(context.Background())
+	assert.Equal(t, 3, producer.Spooled())
+
+	// Kafka is back: events are replayed in the order they were spooled
+	var replayed []string
+	for range ids {
+		mockProducer.ExpectSendMessageWithMessageCheckerFunctionAndSucceed(func(msg *sarama.ProducerMessage) error {
+			for _, header := range msg.Headers {
+				if string(header.Key) == "event_id" {
+					replayed = append(replayed, string(header.Value))
+				}
+			}
+			return nil
+		})
+	}
+	producer.replaySpool(context.Background())
+
+	assert.Zero(t, producer.Spooled())
+	assert.Equal(t, ids, replayed)
+}
+
+func TestProduce_QueuesBehindSpoolBacklog(t *testing.T) {
+	mockProducer := mocks.NewSyncProducer(t, nil)
+	producer := createTestSpoolingProducer(t, mockProducer)
+	defer producer.Close()
+
+	mockProducer.ExpectSendMessageAndFail(sarama.ErrOutOfBrokers)
+	first := createTestEvent()
+	first.ID = "event-1"
+	require.True(t, producer.Produce(context.Background(), first).Queued)
+
+	// Kafka has recovered, but newer events must not overtake the backlog
+	second := createTestEvent()
+	second.ID = "event-2"
+	result := producer.Produce(context.Background(), second)
+	require.NoError(t, result.Err)
+	assert.True(t, result.Queued)
+
+	third, fourth := createTestEvent(), createTestEvent()
+	third.ID, fourth.ID = "event-3", "event-4"
+	results := producer.ProduceBatch(context.Background(), []*models.Event{third, fourth})
+	for _, result := range results {
+		require.NoError(t, result.Err)
+		assert.True(t, result.Queued)
+	}
+	assert.Equal(t, 4, producer.Spooled())
+
+	var replayed []string
+	for i := 0; i < 4; i++ {
+		mockProducer.ExpectSendMessageWithMessageCheckerFunctionAndSucceed(func(msg *sarama.ProducerMessage) error {
+			for _, header := range msg.Headers {
+				if string(header.Key) == "event_id" {
+					replayed = append(replayed, string(header.Value))
+				}
+			}
+			return nil
+		})
+	}
+	producer.replaySpool(context.Background())
+
+	assert.Zero(t, producer.Spooled())
+	assert.Equal(t, []string{"event-1", "event-2", "event-3", "event-4"}, replayed)
+
+	// With the backlog drained, events are sent directly again
+	mockProducer.ExpectSendMessageAndSucceed()
+	result = producer.Produce(context.Background(), createTestEvent())
+	require.NoError(t, result.Err)
+	assert.False(t, result.Queued)
+}
+
+func TestReplaySpool_CommitsDeliveredPrefix(t *testing.T) {
+	mockProducer := mocks.NewSyncProducer(t, nil)
+	producer := createTestSpoolingProducer(t, mockProducer)
+	defer producer.Close()
+
+	for i := 0; i < 3; i++ {
+		require.NoError(t, producer.spool.Append([]byte(`{"id":"event","type":"user.created","source":"test"}`)))
+	}
+
+	producer.producer = &partialFailureSyncProducer{
+		SyncProducer: mockProducer,
+		failIndices:  map[int]error{1: sarama.ErrOutOfBrokers},
+	}
+	producer.replaySpool(context.Background())
+
+	// The first event is committed; the failed one and everything after it stay spooled
+	assert.Equal(t, 2, producer.Spooled())
+}
+
+func TestReplaySpool_DropsCorruptRecords(t *testing.T) {
+	mockProducer := mocks.NewSyncProducer(t, nil)
+	mockProducer.ExpectSendMessageAndSucceed()
+
+	producer := createTestSpoolingProducer(t, mockProducer)
+	defer producer.Close()
+
+	require.NoError(t, producer.spool.Append([]byte("not json")))
+	require.NoError(t, producer.spool.Append([]byte(`{"id":"event","type":"user.created","source":"test"}`)))
+
+	producer.replaySpool(context.Background())
+	assert.Zero(t, producer.Spooled())
+}
+
+func TestOpenSpool_RejectsTransactional(t *testing.T) {
+	cfg := validKafkaConfig()
+	cfg.DeliveryGuarantee = GuaranteeTransactional
+	cfg.Spool = config.SpoolConfig{
+		Enabled:          true,
+		Dir:              t.TempDir(),
+		MaxBytes:         1 << 20,
+		SegmentBytes:     1 << 16,
+		FsyncPolicy:      spool.FsyncNever,
+		ReplayIntervalMs: 1000,
+		ReplayBatchSize:  10,
+	}
+
+	_, err := openSpool(cfg, zap.NewNop())
+	assert.Error(t, err)
+}
@@ -0,0 +1,61 @@
+package kafka
+
+import (
+	"fmt"
+
+	"github.com/IBM/sarama"
+	"github.com/distributed-event-processor/services/event-gateway/internal/models"
+	"go.uber.org/zap"
+)
+
+// sendTransaction produces messages inside a single Kafka transaction. Either
+// all messages are committed or the transaction is aborted and an error returned.
+func (p *Producer) sendTransaction(messages []*sarama.ProducerMessage) error {
+	p.txnMu.Lock()
+	defer p.txnMu.Unlock()
+
+	if err := p.producer.BeginTxn(); err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	err := p.producer.SendMessages(messages)
+	if err == nil {
+		if err = p.producer.CommitTxn(); err == nil {
+			return nil
+		}
+		err = fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	if abortErr := p.producer.AbortTxn(); abortErr != nil {
+		p.logger.Error("Failed to abort Kafka transaction",
+			zap.Int("message_count", len(messages)),
+			zap.Error(abortErr))
+	}
+
+	return fmt.Errorf("transaction aborted: %w", err)
+}
+
+// produceBatchTransactional commits a batch atomically. A serialization failure
+// of any event aborts the whole batch before anything is sent.
+func (p *Producer) produceBatchTransactional(events []*models.Event, messages []*sarama.ProducerMessage, indices []int, results []DeliveryResult) {
+	if len(messages) != len(events) {
+		for j, i := range indices {
+			results[i] = DeliveryResult{
+				Topic: messages[j].Topic,
+				Err:   fmt.Errorf("transaction aborted: batch contains events that failed to serialize"),
+			}
+		}
+		return
+	}
+
+	// The transaction is a single send to the breaker, however many messages it holds
+	err := p.sendTransaction(messages)
+	p.breaker.record(err)
+	for j, message := range messages {
+		results[indices[j]] = p.deliveryResult(message, err)
+	}
+
+	p.logger.Debug("Transactional batch sent to Kafka",
+		zap.Int("event_count", len(events)),
+		zap.Bool("committed", err == nil))
+}
@@ -0,0 +1,132 @@
+package kafka
+
+import (
+	"context"
+	"testing"
+
+	"github.com/IBM/sarama"
+	"github.com/IBM/sarama/mocks"
+	"github.com/distributed-event-processor/services/event-gateway/internal/config"
+	"github.com/distributed-event-processor/services/event-gateway/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func createTestTransactionalProducer(t *testing.T) (*Producer, *mocks.SyncProducer) {
+	logger, _ := zap.NewDevelopment()
+	cfg := config.KafkaConfig{
+		Brokers:           []string{"localhost:9092"},
+		Topic:             "test-events",
+		Retries:           3,
+		BatchSize:         100,
+		RequiredAcks:      -1,
+		DeliveryGuarantee: GuaranteeTransactional,
+		TransactionalID:   "test-gateway",
+	}
+
+	saramaConfig, err := newSaramaConfig(cfg, zap.NewNop())
+	require.NoError(t, err)
+
+	mockProducer := mocks.NewSyncProducer(t, saramaConfig)
+	return &Producer{
+		producer: mockProducer,
+		config:   cfg,
+		logger:   logger,
+	}, mockProducer
+}
+
+func TestTransactionalProduceBatch_Commit(t *testing.T) {
+	producer, mockProducer := createTestTransactionalProducer(t)
+	mockProducer.ExpectSendMessageAndSucceed()
+	mockProducer.ExpectSendMessageAndSucceed()
+
+	results := producer.ProduceBatch(context.Background(), []*models.Event{
+		createTestEvent(),
+		createTestEvent(),
+	})
+
+	require.Len(t, results, 2)
+	for _, result := range results {
+		require.NoError(t, result.Err)
+	}
+	assert.Equal(t, sarama.ProducerTxnFlagReady, mockProducer.TxnStatus())
+}
+
+func TestTransactionalProduceBatch_AbortsWholeBatch(t *testing.T) {
+	producer, mockProducer := createTestTransactionalProducer(t)
+	mockProducer.ExpectSendMessageAndSucceed()
+	mockProducer.ExpectSendMessageAndFail(sarama.ErrNotEnoughReplicas)
+
+	results := producer.ProduceBatch(context.Background(), []*models.Event{
+		createTestEvent(),
+		createTestEvent(),
+	})
+
+	require.Len(t, results, 2)
+	for _, result := range results {
+		require.Error(t, result.Err)
+		assert.ErrorIs(t, result.Err, sarama.ErrNotEnoughReplicas)
+		assert.Contains(t, result.Err.Error(), "transaction aborted")
+	}
+}
+
+func TestTransactionalProduceBatch_AbortCountsOnceForBreaker(t *testing.T) {
+	producer, mockProducer := createTestTransactionalProducer(t)
+	breaker, err := newCircuitBreaker(validBreakerConfig(), DefaultCluster, zap.NewNop())
+	require.NoError(t, err)
+	producer.breaker = breaker
+
+	// One aborted transaction of three messages is one failure, not three
+	mockProducer.ExpectSendMessageAndSucceed()
+	mockProducer.ExpectSendMessageAndFail(sarama.ErrOutOfBrokers)
+	mockProducer.ExpectSendMessageAndFail(sarama.ErrOutOfBrokers)
+	results := producer.ProduceBatch(context.Background(), []*models.Event{
+		createTestEvent(),
+		createTestEvent(),
+		createTestEvent(),
+	})
+	for _, result := range results {
+		require.Error(t, result.Err)
+	}
+	assert.Equal(t, CircuitClosed, breaker.State())
+}
+
+func TestTransactionalProduceBatch_SerializationFailureAbortsBatch(t *testing.T) {
+	producer, _ := createTestTransactionalProducer(t)
+	cfg := producer.config
+	cfg.Routing.Rules = []config.RoutingRule{{Name: "orders", EventTypes: []string{"order.*"}, Topic: "orders"}}
+	router, err := newTopicRouter(cfg)
+	require.NoError(t, err)
+	producer.router = router
+
+	bad := createTestEvent()
+	bad.Data = map[string]interface{}{"ch": make(chan int)}
+	order := createTestEvent()
+	order.Type = "order.created"
+
+	results := producer.ProduceBatch(context.Background(), []*models.Event{
+		createTestEvent(),
+		bad,
+		order,
+	})
+
+	require.Len(t, results, 3)
+	assert.Contains(t, results[0].Err.Error(), "transaction aborted")
+	assert.Equal(t, "test-events", results[0].Topic)
+	assert.Contains(t, results[1].Err.Error(), "failed to serialize event")
+	assert.Empty(t, results[1].Topic)
+	assert.Contains(t, results[2].Err.Error(), "transaction aborted")
+	assert.Equal(t, "orders", results[2].Topic)
+}
+
+func TestTransactionalProduceEvent(t *testing.T) {
+	producer, mockProducer := createTestTransactionalProducer(t)
+	mockProducer.ExpectSendMessageAndSucceed()
+
+	_, offset, err := producer.ProduceEvent(context.Background(), createTestEvent())
+
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), offset)
+	assert.True(t, producer.Transactional())
+}
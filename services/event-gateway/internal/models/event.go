@@ -1,11 +1,30 @@
 package models
 
 import (
+	"regexp"
 	"time"
 
 	"github.com/google/uuid"
 )
 
+// eventIDPattern admits UUIDs, ULIDs and similar opaque IDs, optionally
+// namespaced with dots or colons, up to 128 characters
+var eventIDPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._:-]{0,127}$`)
+
+// ValidEventID reports whether id may be used as an event ID
+func ValidEventID(id string) bool {
+	return eventIDPattern.MatchString(id)
+}
+
+// Event metadata keys the API handlers fill in from the request. They are
+// carried in the event so that spooled and dead-lettered events keep them.
+const (
+	MetadataRequestID      = "request_id"
+	MetadataTraceParent    = "traceparent"
+	MetadataTraceState     = "tracestate"
+	MetadataIdempotencyKey = "idempotency_key"
+)
+
 // Event represents an incoming event
 type Event struct {
 	ID            string                 `json:"id" validate:"required"`
@@ -17,46 +36,90 @@ type Event struct {
 	Timestamp     time.Time              `json:"timestamp"`
 	Version       string                 `json:"version,omitempty"`
 	SchemaVersion string                 `json:"schema_version,omitempty"`
+	DataSchema    string                 `json:"data_schema,omitempty"`
 	Metadata      map[string]string      `json:"metadata,omitempty"`
 	CorrelationID string                 `json:"correlation_id,omitempty"`
 	Priority      int                    `json:"priority,omitempty"`
 }
 
-// EventRequest represents the request payload for event ingestion
+// SetDefaults generates the ID and uses the current time when they are unset
+func (e *Event) SetDefaults() {
+	if e.ID == "" {
+		e.ID = uuid.New().String()
+	}
+	if e.Timestamp.IsZero() {
+		e.Timestamp = time.Now().UTC()
+	}
+}
+
+// EventRequest represents the request payload for event ingestion. ID and
+// Timestamp are optional; the gateway fills them in when they are left out.
 type EventRequest struct {
-	Type     string                 `json:"type" validate:"required"`
-	Source   string                 `json:"source" validate:"required"`
-	Subject  string                 `json:"subject,omitempty"`
-	Data     map[string]interface{} `json:"data" validate:"required"`
-	Version  string                 `json:"version,omitempty"`
-	Metadata map[string]string      `json:"metadata,omitempty"`
+	ID            string                 `json:"id,omitempty"`
+	Type          string                 `json:"type"`
+	Source        string                 `json:"source"`
+	Subject       string                 `json:"subject,omitempty"`
+	TenantID      string                 `json:"tenant_id,omitempty"`
+	Data          map[string]interface{} `json:"data"`
+	Timestamp     *time.Time             `json:"timestamp,omitempty"`
+	Version       string                 `json:"version,omitempty"`
+	SchemaVersion string                 `json:"schema_version,omitempty"`
+	DataSchema    string                 `json:"data_schema,omitempty"`
+	Metadata      map[string]string      `json:"metadata,omitempty"`
+	CorrelationID string                 `json:"correlation_id,omitempty"`
+	Priority      int                    `json:"priority,omitempty"`
 }
 
-// ToEvent converts EventRequest to Event with generated fields
+// ToEvent converts EventRequest to Event, generating the ID and using the
+// current time when the client did not supply them
 func (er *EventRequest) ToEvent() *Event {
+	event := er.AsEvent()
+	event.SetDefaults()
+	return event
+}
+
+// AsEvent converts EventRequest to Event as the client sent it, leaving the
+// ID and timestamp unset when they were left out
+func (er *EventRequest) AsEvent() *Event {
+	var timestamp time.Time
+	if er.Timestamp != nil {
+		timestamp = er.Timestamp.UTC()
+	}
+
 	return &Event{
-		ID:        uuid.New().String(),
-		Type:      er.Type,
-		Source:    er.Source,
-		Subject:   er.Subject,
-		Data:      er.Data,
-		Timestamp: time.Now().UTC(),
-		Version:   er.Version,
-		Metadata:  er.Metadata,
+		ID:            er.ID,
+		Type:          er.Type,
+		Source:        er.Source,
+		Subject:       er.Subject,
+		TenantID:      er.TenantID,
+		Data:          er.Data,
+		Timestamp:     timestamp,
+		Version:       er.Version,
+		SchemaVersion: er.SchemaVersion,
+		DataSchema:    er.DataSchema,
+		Metadata:      er.Metadata,
+		CorrelationID: er.CorrelationID,
+		Priority:      er.Priority,
 	}
 }
 
 // EventResponse represents the response after successful event ingestion
 type EventResponse struct {
-	EventID   string    `json:"event_id"`
-	Status    string    `json:"status"`
-	Timestamp time.Time `json:"timestamp"`
-	Message   string    `json:"message,omitempty"`
+	EventID     string    `json:"event_id"`
+	Status      string    `json:"status"`
+	Timestamp   time.Time `json:"timestamp"`
+	Message     string    `json:"message,omitempty"`
+	Topic       string    `json:"topic,omitempty"`
+	RoutingRule string    `json:"routing_rule,omitempty"`
+	Cluster     string    `json:"cluster,omitempty"`
+	Partition   *int32    `json:"partition,omitempty"`
+	Offset      *int64    `json:"offset,omitempty"`
+	Duplicate   bool      `json:"duplicate,omitempty"`
 }
 
 // BatchEventRequest represents multiple events in a single request
 type BatchEventRequest struct {
-	Events []EventRequest `json:"events" validate:"required,min=1,max=100,dive"`
+	Events []EventRequest `json:"events"`
 }
 
 // BatchEventResponse represents response for batch event ingestion
@@ -67,11 +130,116 @@ type BatchEventResponse struct {
 	Errors         []string           `json:"errors,omitempty"`
 }
 
-// BatchEventResult represents individual event result in batch
+// BatchEventResult represents individual event result in batch.
+// Partition and offset are only set once Kafka has acknowledged the event.
 type BatchEventResult struct {
-	EventID string `json:"event_id"`
-	Status  string `json:"status"`
-	Error   string `json:"error,omitempty"`
+	EventID     string `json:"event_id"`
+	Status      string `json:"status"`
+	Topic       string `json:"topic,omitempty"`
+	RoutingRule string `json:"routing_rule,omitempty"`
+	Cluster     string `json:"cluster,omitempty"`
+	Partition   *int32 `json:"partition,omitempty"`
+	Offset      *int64 `json:"offset,omitempty"`
+	Duplicate   bool   `json:"duplicate,omitempty"`
+	Error       string `json:"error,omitempty"`
+
+	// Errors lists every violation when the event failed validation
+	Errors []ValidationError `json:"errors,omitempty"`
+}
+
+// StreamEventResponse summarizes the ingestion of an NDJSON stream. LineCount
+// counts the non-blank lines read and Errors lists the lines that failed, up
+// to a limit. Error is set when the stream could not be read to its end.
+type StreamEventResponse struct {
+	LineCount       int               `json:"line_count"`
+	ProcessedCount  int               `json:"processed_count"`
+	FailedCount     int               `json:"failed_count"`
+	Errors          []StreamLineError `json:"errors"`
+	ErrorsTruncated bool              `json:"errors_truncated,omitempty"`
+	Error           string            `json:"error,omitempty"`
+}
+
+// StreamLineError is a line of an NDJSON stream that was not ingested. Line
+// numbers start at 1 and count blank lines.
+type StreamLineError struct {
+	Line    int    `json:"line"`
+	EventID string `json:"event_id,omitempty"`
+	Error   string `json:"error"`
+
+	// Errors lists every violation when the event failed validation
+	Errors []ValidationError `json:"errors,omitempty"`
+}
+
+// WebSocketRequest is a frame sent to the WebSocket endpoint. Like the gRPC
+// StreamEvents request, it carries exactly one of an event, a ping or a
+// configuration.
+type WebSocketRequest struct {
+	Event  *EventRequest          `json:"event,omitempty"`
+	Ping   *WebSocketPing         `json:"ping,omitempty"`
+	Config *WebSocketStreamConfig `json:"config,omitempty"`
+}
+
+// WebSocketPing is a keep-alive ping, answered with a pong
+type WebSocketPing struct {
+	Timestamp *time.Time `json:"timestamp,omitempty"`
+}
+
+// WebSocketStreamConfig configures a WebSocket connection. Compression only
+// applies when the client negotiated the permessage-deflate extension.
+type WebSocketStreamConfig struct {
+	EnableCompression bool `json:"enable_compression"`
+	BatchSize         int  `json:"batch_size,omitempty"`
+	FlushIntervalMs   int  `json:"flush_interval_ms,omitempty"`
+}
+
+// WebSocketResponse is a frame sent by the WebSocket endpoint, carrying
+// exactly one of an ack, a pong or a status
+type WebSocketResponse struct {
+	Ack    *EventAck        `json:"ack,omitempty"`
+	Pong   *WebSocketPong   `json:"pong,omitempty"`
+	Status *WebSocketStatus `json:"status,omitempty"`
+}
+
+// EventAck acknowledges an event once Kafka has accepted it, or once it was
+// queued or dropped
+type EventAck struct {
+	EventID     string    `json:"event_id"`
+	RequestID   string    `json:"request_id"`
+	AcceptedAt  time.Time `json:"accepted_at"`
+	Status      string    `json:"status"`
+	Topic       string    `json:"topic,omitempty"`
+	RoutingRule string    `json:"routing_rule,omitempty"`
+	Cluster     string    `json:"cluster,omitempty"`
+	Partition   int32     `json:"partition"`
+	Offset      int64     `json:"offset"`
+	Duplicate   bool      `json:"duplicate,omitempty"`
+}
+
+// WebSocketPong answers a ping
+type WebSocketPong struct {
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// WebSocketStatus reports a frame or event that failed. Code is ERROR and
+// Error a stable identifier such as validation_failed; EventID is set when
+// the failure concerns an event the client named.
+type WebSocketStatus struct {
+	Code       string            `json:"code"`
+	Error      string            `json:"error,omitempty"`
+	Message    string            `json:"message"`
+	EventID    string            `json:"event_id,omitempty"`
+	Errors     []ValidationError `json:"errors,omitempty"`
+	RetryAfter int               `json:"retry_after,omitempty"`
+	Timestamp  time.Time         `json:"timestamp"`
+}
+
+// ValidationError is one way in which an event breaks the ingestion rules.
+// Field is the path of the offending field and Code a stable identifier such
+// as REQUIRED_FIELD.
+type ValidationError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
 }
 
 // HealthCheck represents health check response
@@ -1,10 +1,12 @@
 package models
 
 import (
+	"encoding/json"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestEventRequest_ToEvent(t *testing.T) {
@@ -67,6 +69,33 @@ func TestEventRequest_ToEvent_GeneratesUniqueIDs(t *testing.T) {
 	assert.NotEqual(t, event1.ID, event2.ID, "Each event should have a unique ID")
 }
 
+func TestEventRequest_ToEvent_ClientFields(t *testing.T) {
+	eventTime := time.Date(2024, 5, 1, 12, 0, 0, 0, time.FixedZone("CEST", 2*60*60))
+	req := &EventRequest{
+		ID:            "order-42",
+		Type:          "order.placed",
+		Source:        "order-service",
+		TenantID:      "tenant-1",
+		Data:          map[string]interface{}{"amount": 10},
+		Timestamp:     &eventTime,
+		SchemaVersion: "1.0.0",
+		DataSchema:    "https://example.com/order.json",
+		CorrelationID: "corr-1",
+		Priority:      7,
+	}
+
+	event := req.ToEvent()
+
+	assert.Equal(t, "order-42", event.ID)
+	assert.Equal(t, "tenant-1", event.TenantID)
+	assert.True(t, event.Timestamp.Equal(eventTime))
+	assert.Equal(t, time.UTC, event.Timestamp.Location())
+	assert.Equal(t, "1.0.0", event.SchemaVersion)
+	assert.Equal(t, "https://example.com/order.json", event.DataSchema)
+	assert.Equal(t, "corr-1", event.CorrelationID)
+	assert.Equal(t, 7, event.Priority)
+}
+
 func TestEvent_AllFields(t *testing.T) {
 	now := time.Now().UTC()
 	event := Event{
@@ -175,6 +204,24 @@ func TestBatchEventResult(t *testing.T) {
 		assert.Equal(t, "failed", result.Status)
 		assert.Equal(t, "validation failed: missing required field", result.Error)
 	})
+
+	t.Run("delivery position serialization", func(t *testing.T) {
+		partition, offset := int32(0), int64(42)
+		accepted, err := json.Marshal(BatchEventResult{
+			EventID:     "event-123",
+			Status:      "accepted",
+			Topic:       "events",
+			RoutingRule: "default",
+			Partition:   &partition,
+			Offset:      &offset,
+		})
+		require.NoError(t, err)
+		assert.JSONEq(t, `{"event_id":"event-123","status":"accepted","topic":"events","routing_rule":"default","partition":0,"offset":42}`, string(accepted))
+
+		failed, err := json.Marshal(BatchEventResult{EventID: "event-456", Status: "failed", Error: "broker unavailable"})
+		require.NoError(t, err)
+		assert.JSONEq(t, `{"event_id":"event-456","status":"failed","error":"broker unavailable"}`, string(failed))
+	})
 }
 
 func TestHealthCheck(t *testing.T) {
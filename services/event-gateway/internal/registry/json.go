@@ -0,0 +1,216 @@
+package registry
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/santhosh-tekuri/jsonschema/v6"
+)
+
+// CompileJSON compiles a JSON Schema definition
+func CompileJSON(definition string) (*jsonschema.Schema, error) {
+	doc, err := jsonschema.UnmarshalJSON(strings.NewReader(definition))
+	if err != nil {
+		return nil, err
+	}
+
+	const url = "registry:///schema.json"
+	compiler := jsonschema.NewCompiler()
+	if err := compiler.AddResource(url, doc); err != nil {
+		return nil, err
+	}
+	return compiler.Compile(url)
+}
+
+// Keywords whose value may only be raised or only be lowered by a reader
+var (
+	lowerBounds = []string{"minimum", "exclusiveMinimum", "minLength", "minItems", "minProperties"}
+	upperBounds = []string{"maximum", "exclusiveMaximum", "maxLength", "maxItems", "maxProperties"}
+)
+
+// Keywords that are compared as a whole, since their effect cannot be worked
+// out structurally
+var opaqueKeywords = []string{"$ref", "allOf", "anyOf", "oneOf", "not", "if", "then", "else", "pattern", "format", "const"}
+
+// jsonReads lists why instances valid against the writer schema may be
+// invalid against the reader schema. Types, required properties, closed
+// objects, enums and bounds are compared property by property; properties
+// the writer does not know about may be added freely.
+func jsonReads(reader, writer string) []string {
+	var readerDoc, writerDoc interface{}
+	if err := json.Unmarshal([]byte(reader), &readerDoc); err != nil {
+		return []string{err.Error()}
+	}
+	if err := json.Unmarshal([]byte(writer), &writerDoc); err != nil {
+		return []string{err.Error()}
+	}
+
+	var reasons []string
+	compareJSON(readerDoc, writerDoc, "", &reasons)
+	return reasons
+}
+
+func compareJSON(reader, writer interface{}, path string, reasons *[]string) {
+	add := func(format string, args ...interface{}) {
+		*reasons = append(*reasons, describePath(path)+": "+fmt.Sprintf(format, args...))
+	}
+
+	// true and {} accept everything, false nothing
+	if accepts, ok := writer.(bool); ok && !accepts {
+		return
+	}
+	if accepts, ok := reader.(bool); ok {
+		if !accepts {
+			add("no value is allowed anymore")
+		}
+		return
+	}
+	readerSchema, _ := reader.(map[string]interface{})
+	writerSchema, _ := writer.(map[string]interface{})
+	if readerSchema == nil {
+		return
+	}
+	if writerSchema == nil {
+		writerSchema = map[string]interface{}{}
+	}
+
+	for _, keyword := range opaqueKeywords {
+		if !reflect.DeepEqual(readerSchema[keyword], writerSchema[keyword]) {
+			add("%s changed", keyword)
+		}
+	}
+
+	readerTypes, writerTypes := jsonTypes(readerSchema), jsonTypes(writerSchema)
+	if readerTypes != nil {
+		if writerTypes == nil {
+			add("type is now restricted to %s", strings.Join(sortedKeys(readerTypes), ", "))
+		}
+		for _, writerType := range sortedKeys(writerTypes) {
+			if !readerTypes[writerType] && !(writerType == "integer" && readerTypes["number"]) {
+				add("type %s is no longer allowed", writerType)
+			}
+		}
+	}
+
+	if readerEnum, ok := readerSchema["enum"].([]interface{}); ok {
+		writerEnum, ok := writerSchema["enum"].([]interface{})
+		if !ok {
+			add("values are now restricted to an enum")
+		}
+		for _, value := range writerEnum {
+			if !containsValue(readerEnum, value) {
+				add("enum value %v is no longer allowed", value)
+			}
+		}
+	}
+
+	for _, keyword := range lowerBounds {
+		if bound, ok := readerSchema[keyword].(float64); ok {
+			if previous, ok := writerSchema[keyword].(float64); !ok || previous < bound {
+				add("%s raised to %v", keyword, bound)
+			}
+		}
+	}
+	for _, keyword := range upperBounds {
+		if bound, ok := readerSchema[keyword].(float64); ok {
+			if previous, ok := writerSchema[keyword].(float64); !ok || previous > bound {
+				add("%s lowered to %v", keyword, bound)
+			}
+		}
+	}
+
+	compareObjects(readerSchema, writerSchema, path, reasons, add)
+
+	if readerItems, ok := readerSchema["items"]; ok {
+		compareJSON(readerItems, writerSchema["items"], path+"[]", reasons)
+	}
+}
+
+func compareObjects(reader, writer map[string]interface{}, path string, reasons *[]string, add func(string, ...interface{})) {
+	writerRequired := stringSet(writer["required"])
+	for _, name := range sortedKeys(stringSet(reader["required"])) {
+		if !writerRequired[name] {
+			add("property %s is now required", name)
+		}
+	}
+
+	readerProperties, _ := reader["properties"].(map[string]interface{})
+	writerProperties, _ := writer["properties"].(map[string]interface{})
+	readerAdditional, hasReaderAdditional := reader["additionalProperties"]
+
+	for _, name := range sortedKeys(writerProperties) {
+		property := joinPath(path, name)
+		if readerProperty, ok := readerProperties[name]; ok {
+			compareJSON(readerProperty, writerProperties[name], property, reasons)
+		} else if hasReaderAdditional {
+			compareJSON(readerAdditional, writerProperties[name], property, reasons)
+		}
+	}
+
+	// Properties the writer does not name are only constrained by the reader
+	// when the writer allowed them
+	writerAdditional, hasWriterAdditional := writer["additionalProperties"]
+	if hasReaderAdditional {
+		if !hasWriterAdditional {
+			writerAdditional = true
+		}
+		compareJSON(readerAdditional, writerAdditional, joinPath(path, "*"), reasons)
+	}
+}
+
+// jsonTypes returns the types a schema allows, or nil if it does not say
+func jsonTypes(schema map[string]interface{}) map[string]bool {
+	switch t := schema["type"].(type) {
+	case string:
+		return map[string]bool{t: true}
+	case []interface{}:
+		return stringSet(t)
+	}
+	return nil
+}
+
+func stringSet(value interface{}) map[string]bool {
+	set := make(map[string]bool)
+	items, _ := value.([]interface{})
+	for _, item := range items {
+		if s, ok := item.(string); ok {
+			set[s] = true
+		}
+	}
+	return set
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func containsValue(values []interface{}, value interface{}) bool {
+	for _, candidate := range values {
+		if reflect.DeepEqual(candidate, value) {
+			return true
+		}
+	}
+	return false
+}
+
+func joinPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+func describePath(path string) string {
+	if path == "" {
+		return "schema"
+	}
+	return "property " + path
+}
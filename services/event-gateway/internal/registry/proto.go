@@ -0,0 +1,159 @@
+package registry
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/bufbuild/protocompile"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// protoFile is the name a protobuf definition is compiled under
+const protoFile = "schema.proto"
+
+// compileProto compiles a protobuf definition, which may import the
+// well-known types
+func compileProto(definition string) (protoreflect.FileDescriptor, error) {
+	compiler := protocompile.Compiler{
+		Resolver: protocompile.WithStandardImports(&protocompile.SourceResolver{
+			Accessor: protocompile.SourceAccessorFromMap(map[string]string{protoFile: definition}),
+		}),
+	}
+	files, err := compiler.Compile(context.Background(), protoFile)
+	if err != nil {
+		return nil, err
+	}
+	return files[0], nil
+}
+
+// wireTypes groups the scalar kinds whose values can be read as one another
+var wireTypes = map[protoreflect.Kind]string{
+	protoreflect.Int32Kind:    "varint",
+	protoreflect.Int64Kind:    "varint",
+	protoreflect.Uint32Kind:   "varint",
+	protoreflect.Uint64Kind:   "varint",
+	protoreflect.BoolKind:     "varint",
+	protoreflect.Sint32Kind:   "zigzag",
+	protoreflect.Sint64Kind:   "zigzag",
+	protoreflect.Fixed32Kind:  "fixed32",
+	protoreflect.Sfixed32Kind: "fixed32",
+	protoreflect.Fixed64Kind:  "fixed64",
+	protoreflect.Sfixed64Kind: "fixed64",
+	protoreflect.FloatKind:    "float",
+	protoreflect.DoubleKind:   "double",
+	protoreflect.StringKind:   "bytes",
+	protoreflect.BytesKind:    "bytes",
+}
+
+// protoReads lists why messages written with the writer definition may not
+// be read with the reader definition. Fields are matched by number: a field
+// may be renamed or dropped, but not change to a type read differently on
+// the wire, and messages may not be removed.
+func protoReads(reader, writer string) []string {
+	readerFile, err := compileProto(reader)
+	if err != nil {
+		return []string{err.Error()}
+	}
+	writerFile, err := compileProto(writer)
+	if err != nil {
+		return []string{err.Error()}
+	}
+
+	readerMessages := make(map[protoreflect.FullName]protoreflect.MessageDescriptor)
+	collectMessages(readerFile.Messages(), readerMessages)
+	writerMessages := make(map[protoreflect.FullName]protoreflect.MessageDescriptor)
+	collectMessages(writerFile.Messages(), writerMessages)
+
+	var reasons []string
+	for _, name := range sortedKeys(stringKeys(writerMessages)) {
+		writerMessage := writerMessages[protoreflect.FullName(name)]
+		readerMessage, ok := readerMessages[protoreflect.FullName(name)]
+		if !ok {
+			reasons = append(reasons, fmt.Sprintf("message %s was removed", name))
+			continue
+		}
+		reasons = append(reasons, compareMessages(readerMessage, writerMessage)...)
+	}
+	return reasons
+}
+
+func compareMessages(reader, writer protoreflect.MessageDescriptor) []string {
+	var reasons []string
+	add := func(field protoreflect.FieldDescriptor, format string, args ...interface{}) {
+		reasons = append(reasons, fmt.Sprintf("field %s.%s (%d): ", reader.FullName(), field.Name(), field.Number())+
+			fmt.Sprintf(format, args...))
+	}
+
+	writerFields := writer.Fields()
+	for i := 0; i < writerFields.Len(); i++ {
+		writerField := writerFields.Get(i)
+		readerField := reader.Fields().ByNumber(writerField.Number())
+		if readerField == nil {
+			if writerField.Cardinality() == protoreflect.Required {
+				add(writerField, "required field was removed")
+			}
+			continue
+		}
+
+		if readerField.IsList() != writerField.IsList() || readerField.IsMap() != writerField.IsMap() {
+			add(readerField, "changed between singular, repeated and map")
+			continue
+		}
+		if wireType(readerField) != wireType(writerField) {
+			add(readerField, "type changed from %s to %s", typeName(writerField), typeName(readerField))
+		}
+		if readerField.ContainingOneof() != nil && writerField.ContainingOneof() == nil {
+			add(readerField, "moved into oneof %s", readerField.ContainingOneof().Name())
+		}
+	}
+
+	readerFields := reader.Fields()
+	for i := 0; i < readerFields.Len(); i++ {
+		readerField := readerFields.Get(i)
+		if readerField.Cardinality() == protoreflect.Required && writer.Fields().ByNumber(readerField.Number()) == nil {
+			add(readerField, "required field was added")
+		}
+	}
+	return reasons
+}
+
+// wireType identifies how a field is read: by its message or enum for those,
+// or by the group of scalar kinds it can be read as
+func wireType(field protoreflect.FieldDescriptor) string {
+	switch {
+	case field.Message() != nil:
+		return "message " + string(field.Message().FullName())
+	case field.Enum() != nil:
+		return "enum " + string(field.Enum().FullName())
+	}
+	return wireTypes[field.Kind()]
+}
+
+func typeName(field protoreflect.FieldDescriptor) string {
+	switch {
+	case field.Message() != nil:
+		return string(field.Message().FullName())
+	case field.Enum() != nil:
+		return string(field.Enum().FullName())
+	}
+	return field.Kind().String()
+}
+
+func collectMessages(messages protoreflect.MessageDescriptors, into map[protoreflect.FullName]protoreflect.MessageDescriptor) {
+	for i := 0; i < messages.Len(); i++ {
+		message := messages.Get(i)
+		if message.IsMapEntry() {
+			continue
+		}
+		into[message.FullName()] = message
+		collectMessages(message.Messages(), into)
+	}
+}
+
+func stringKeys(messages map[protoreflect.FullName]protoreflect.MessageDescriptor) map[string]bool {
+	keys := make(map[string]bool, len(messages))
+	for name := range messages {
+		keys[string(name)] = true
+	}
+	return keys
+}
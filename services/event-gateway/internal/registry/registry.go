@@ -0,0 +1,408 @@
+package registry
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/distributed-event-processor/services/event-gateway/internal/config"
+)
+
+// Schema types
+const (
+	SchemaTypeJSON     = "JSON"
+	SchemaTypeProtobuf = "PROTOBUF"
+)
+
+// Compatibility levels. A new version of a subject is checked against the
+// latest one: BACKWARD means readers using the new schema can read data
+// written with the latest, FORWARD the reverse, and FULL both.
+const (
+	CompatibilityNone     = "NONE"
+	CompatibilityBackward = "BACKWARD"
+	CompatibilityForward  = "FORWARD"
+	CompatibilityFull     = "FULL"
+)
+
+// LatestVersion asks Get for the latest version of a subject
+const LatestVersion = -1
+
+var (
+	// ErrSubjectNotFound is returned for a subject without any schema
+	ErrSubjectNotFound = errors.New("subject not found")
+
+	// ErrVersionNotFound is returned for a version a subject does not have
+	ErrVersionNotFound = errors.New("version not found")
+)
+
+// InvalidSchemaError is returned for a schema that does not parse, or whose
+// type or compatibility level is unknown
+type InvalidSchemaError struct {
+	Err error
+}
+
+func (e *InvalidSchemaError) Error() string {
+	return "invalid schema: " + e.Err.Error()
+}
+
+func (e *InvalidSchemaError) Unwrap() error {
+	return e.Err
+}
+
+// IncompatibleError is returned for a schema that breaks the compatibility
+// level of its subject, with every reason found
+type IncompatibleError struct {
+	Compatibility string
+	Version       int
+	Reasons       []string
+}
+
+func (e *IncompatibleError) Error() string {
+	return fmt.Sprintf("schema is not %s compatible with version %d: %s",
+		e.Compatibility, e.Version, strings.Join(e.Reasons, "; "))
+}
+
+// Schema is one registered version of a subject. The same definition
+// registered under several subjects shares one ID.
+type Schema struct {
+	Subject    string    `json:"subject"`
+	Version    int       `json:"version"`
+	ID         int       `json:"id"`
+	SchemaType string    `json:"schema_type"`
+	Schema     string    `json:"schema"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+type subject struct {
+	Compatibility string   `json:"compatibility,omitempty"`
+	Versions      []Schema `json:"versions"`
+}
+
+// state is everything the registry stores, written to its file as a whole
+type state struct {
+	NextID   int                 `json:"next_id"`
+	Subjects map[string]*subject `json:"subjects"`
+}
+
+// Registry keeps versioned schemas by subject and refuses new versions that
+// break the subject's compatibility level. The gateway uses event types as
+// subjects. Schemas are kept in a JSON file, or only in memory without one.
+type Registry struct {
+	path          string
+	compatibility string
+
+	mu    sync.RWMutex
+	state state
+}
+
+// New opens the registry stored in cfg.File, creating it on the first
+// registration if it does not exist yet
+func New(cfg config.RegistryConfig) (*Registry, error) {
+	compatibility := cfg.Compatibility
+	if compatibility == "" {
+		compatibility = CompatibilityBackward
+	}
+	if err := checkCompatibility(compatibility); err != nil {
+		return nil, err
+	}
+
+	r := &Registry{
+		path:          cfg.File,
+		compatibility: compatibility,
+		state:         state{NextID: 1, Subjects: make(map[string]*subject)},
+	}
+
+	if r.path == "" {
+		return r, nil
+	}
+	data, err := os.ReadFile(r.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return r, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read schema registry: %w", err)
+	}
+	if err := json.Unmarshal(data, &r.state); err != nil {
+		return nil, fmt.Errorf("corrupt schema registry %s: %w", r.path, err)
+	}
+	if r.state.Subjects == nil {
+		r.state.Subjects = make(map[string]*subject)
+	}
+	return r, nil
+}
+
+func checkCompatibility(level string) error {
+	switch level {
+	case CompatibilityNone, CompatibilityBackward, CompatibilityForward, CompatibilityFull:
+		return nil
+	default:
+		return fmt.Errorf("unknown compatibility level: %s (expected: %s, %s, %s or %s)",
+			level, CompatibilityNone, CompatibilityBackward, CompatibilityForward, CompatibilityFull)
+	}
+}
+
+// Register adds a schema as the next version of a subject. Registering the
+// latest definition again returns the existing version instead.
+func (r *Registry) Register(subjectName, schemaType, definition string) (Schema, bool, error) {
+	if subjectName == "" {
+		return Schema{}, false, &InvalidSchemaError{Err: errors.New("subject is required")}
+	}
+	if schemaType == "" {
+		schemaType = SchemaTypeJSON
+	}
+	if err := parse(schemaType, definition); err != nil {
+		return Schema{}, false, &InvalidSchemaError{Err: err}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	s := r.state.Subjects[subjectName]
+	if s != nil && len(s.Versions) > 0 {
+		latest := s.Versions[len(s.Versions)-1]
+		if latest.SchemaType == schemaType && latest.Schema == definition {
+			return latest, false, nil
+		}
+		if err := r.check(s, latest, schemaType, definition); err != nil {
+			return Schema{}, false, err
+		}
+	}
+
+	registered := Schema{
+		Subject:    subjectName,
+		Version:    1,
+		ID:         r.idOf(schemaType, definition),
+		SchemaType: schemaType,
+		Schema:     definition,
+		CreatedAt:  time.Now().UTC(),
+	}
+	if s == nil {
+		s = &subject{}
+		r.state.Subjects[subjectName] = s
+	} else if len(s.Versions) > 0 {
+		registered.Version = s.Versions[len(s.Versions)-1].Version + 1
+	}
+
+	nextID := r.state.NextID
+	s.Versions = append(s.Versions, registered)
+	if registered.ID == r.state.NextID {
+		r.state.NextID++
+	}
+	if err := r.save(); err != nil {
+		s.Versions = s.Versions[:len(s.Versions)-1]
+		if len(s.Versions) == 0 && s.Compatibility == "" {
+			delete(r.state.Subjects, subjectName)
+		}
+		r.state.NextID = nextID
+		return Schema{}, false, err
+	}
+	return registered, true, nil
+}
+
+// idOf returns the ID of a definition registered under any subject, or the
+// next free ID. Callers hold mu.
+func (r *Registry) idOf(schemaType, definition string) int {
+	for _, s := range r.state.Subjects {
+		for _, version := range s.Versions {
+			if version.SchemaType == schemaType && version.Schema == definition {
+				return version.ID
+			}
+		}
+	}
+	return r.state.NextID
+}
+
+// check returns an *IncompatibleError if definition breaks the compatibility
+// level of s against its latest version. Callers hold mu.
+func (r *Registry) check(s *subject, latest Schema, schemaType, definition string) error {
+	level := r.compatibilityOf(s)
+	if level == CompatibilityNone {
+		return nil
+	}
+	if latest.SchemaType != schemaType {
+		return &IncompatibleError{
+			Compatibility: level,
+			Version:       latest.Version,
+			Reasons:       []string{fmt.Sprintf("schema type changed from %s to %s", latest.SchemaType, schemaType)},
+		}
+	}
+
+	var reasons []string
+	if level == CompatibilityBackward || level == CompatibilityFull {
+		reasons = append(reasons, reads(schemaType, definition, latest.Schema)...)
+	}
+	if level == CompatibilityForward || level == CompatibilityFull {
+		reasons = append(reasons, reads(schemaType, latest.Schema, definition)...)
+	}
+	if len(reasons) > 0 {
+		return &IncompatibleError{Compatibility: level, Version: latest.Version, Reasons: reasons}
+	}
+	return nil
+}
+
+// CheckCompatibility reports whether definition could be registered as the
+// next version of a subject. Any subject accepts its first schema.
+func (r *Registry) CheckCompatibility(subjectName, schemaType, definition string) error {
+	if schemaType == "" {
+		schemaType = SchemaTypeJSON
+	}
+	if err := parse(schemaType, definition); err != nil {
+		return &InvalidSchemaError{Err: err}
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	s := r.state.Subjects[subjectName]
+	if s == nil || len(s.Versions) == 0 {
+		return nil
+	}
+	return r.check(s, s.Versions[len(s.Versions)-1], schemaType, definition)
+}
+
+// Subjects lists the subjects with at least one schema, in order
+func (r *Registry) Subjects() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	subjects := make([]string, 0, len(r.state.Subjects))
+	for name, s := range r.state.Subjects {
+		if len(s.Versions) > 0 {
+			subjects = append(subjects, name)
+		}
+	}
+	sort.Strings(subjects)
+	return subjects
+}
+
+// Versions lists the versions of a subject, oldest first
+func (r *Registry) Versions(subjectName string) ([]int, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	s := r.state.Subjects[subjectName]
+	if s == nil || len(s.Versions) == 0 {
+		return nil, ErrSubjectNotFound
+	}
+	versions := make([]int, len(s.Versions))
+	for i, version := range s.Versions {
+		versions[i] = version.Version
+	}
+	return versions, nil
+}
+
+// Get returns a version of a subject, or its latest with LatestVersion
+func (r *Registry) Get(subjectName string, version int) (Schema, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	s := r.state.Subjects[subjectName]
+	if s == nil || len(s.Versions) == 0 {
+		return Schema{}, ErrSubjectNotFound
+	}
+	if version == LatestVersion {
+		return s.Versions[len(s.Versions)-1], nil
+	}
+	for _, registered := range s.Versions {
+		if registered.Version == version {
+			return registered, nil
+		}
+	}
+	return Schema{}, ErrVersionNotFound
+}
+
+// Compatibility returns the compatibility level of a subject
+func (r *Registry) Compatibility(subjectName string) string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.compatibilityOf(r.state.Subjects[subjectName])
+}
+
+// compatibilityOf returns the level set for s, or the registry's default.
+// Callers hold mu.
+func (r *Registry) compatibilityOf(s *subject) string {
+	if s != nil && s.Compatibility != "" {
+		return s.Compatibility
+	}
+	return r.compatibility
+}
+
+// SetCompatibility sets the compatibility level of a subject, which applies
+// to the versions registered from then on
+func (r *Registry) SetCompatibility(subjectName, level string) error {
+	if err := checkCompatibility(level); err != nil {
+		return &InvalidSchemaError{Err: err}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	s := r.state.Subjects[subjectName]
+	if s == nil {
+		s = &subject{}
+		r.state.Subjects[subjectName] = s
+	}
+	previous := s.Compatibility
+	s.Compatibility = level
+	if err := r.save(); err != nil {
+		s.Compatibility = previous
+		return err
+	}
+	return nil
+}
+
+// save writes the state to the registry file through a temporary file, so a
+// crash never leaves it half written. Callers hold mu.
+func (r *Registry) save() error {
+	if r.path == "" {
+		return nil
+	}
+
+	data, err := json.MarshalIndent(r.state, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(r.path), 0o755); err != nil {
+		return fmt.Errorf("failed to create schema registry directory: %w", err)
+	}
+	tmp := r.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write schema registry: %w", err)
+	}
+	if err := os.Rename(tmp, r.path); err != nil {
+		return fmt.Errorf("failed to write schema registry: %w", err)
+	}
+	return nil
+}
+
+// parse checks that a definition is a valid schema of its type
+func parse(schemaType, definition string) error {
+	switch schemaType {
+	case SchemaTypeJSON:
+		_, err := CompileJSON(definition)
+		return err
+	case SchemaTypeProtobuf:
+		_, err := compileProto(definition)
+		return err
+	default:
+		return fmt.Errorf("unknown schema type: %s (expected: %s or %s)", schemaType, SchemaTypeJSON, SchemaTypeProtobuf)
+	}
+}
+
+// reads lists why readers using the reader definition may fail on data
+// written with the writer definition
+func reads(schemaType, reader, writer string) []string {
+	switch schemaType {
+	case SchemaTypeJSON:
+		return jsonReads(reader, writer)
+	case SchemaTypeProtobuf:
+		return protoReads(reader, writer)
+	}
+	return nil
+}
@@ -0,0 +1,260 @@
+package registry
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/distributed-event-processor/services/event-gateway/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const userV1 = `{
+	"type": "object",
+	"required": ["user_id"],
+	"properties": {"user_id": {"type": "string"}, "age": {"type": "integer", "minimum": 0}}
+}`
+
+func newTestRegistry(t *testing.T, compatibility string) *Registry {
+	t.Helper()
+	r, err := New(config.RegistryConfig{
+		File:          filepath.Join(t.TempDir(), "schemas.json"),
+		Compatibility: compatibility,
+	})
+	require.NoError(t, err)
+	return r
+}
+
+func incompatibleReasons(t *testing.T, err error) []string {
+	t.Helper()
+	var incompatible *IncompatibleError
+	require.True(t, errors.As(err, &incompatible), "expected an IncompatibleError, got %v", err)
+	return incompatible.Reasons
+}
+
+func TestRegister(t *testing.T) {
+	r := newTestRegistry(t, CompatibilityBackward)
+
+	first, created, err := r.Register("user.created", "", userV1)
+	require.NoError(t, err)
+	assert.True(t, created)
+	assert.Equal(t, 1, first.Version)
+	assert.Equal(t, 1, first.ID)
+	assert.Equal(t, SchemaTypeJSON, first.SchemaType)
+
+	// Registering the latest definition again is a no-op
+	again, created, err := r.Register("user.created", SchemaTypeJSON, userV1)
+	require.NoError(t, err)
+	assert.False(t, created)
+	assert.Equal(t, first, again)
+
+	// An optional property can be added
+	second, created, err := r.Register("user.created", SchemaTypeJSON,
+		`{"type": "object", "required": ["user_id"], "properties": {"user_id": {"type": "string"}, "age": {"type": "integer", "minimum": 0}, "email": {"type": "string"}}}`)
+	require.NoError(t, err)
+	assert.True(t, created)
+	assert.Equal(t, 2, second.Version)
+	assert.Equal(t, 2, second.ID)
+
+	// The same definition shares its ID across subjects
+	other, _, err := r.Register("user.updated", SchemaTypeJSON, userV1)
+	require.NoError(t, err)
+	assert.Equal(t, 1, other.Version)
+	assert.Equal(t, first.ID, other.ID)
+
+	assert.Equal(t, []string{"user.created", "user.updated"}, r.Subjects())
+	versions, err := r.Versions("user.created")
+	require.NoError(t, err)
+	assert.Equal(t, []int{1, 2}, versions)
+
+	latest, err := r.Get("user.created", LatestVersion)
+	require.NoError(t, err)
+	assert.Equal(t, second, latest)
+	got, err := r.Get("user.created", 1)
+	require.NoError(t, err)
+	assert.Equal(t, first, got)
+
+	_, err = r.Get("user.created", 3)
+	assert.ErrorIs(t, err, ErrVersionNotFound)
+	_, err = r.Get("order.created", LatestVersion)
+	assert.ErrorIs(t, err, ErrSubjectNotFound)
+	_, err = r.Versions("order.created")
+	assert.ErrorIs(t, err, ErrSubjectNotFound)
+}
+
+func TestRegister_Invalid(t *testing.T) {
+	r := newTestRegistry(t, CompatibilityBackward)
+	var invalid *InvalidSchemaError
+
+	_, _, err := r.Register("user.created", SchemaTypeJSON, `{"type": 42}`)
+	assert.True(t, errors.As(err, &invalid))
+
+	_, _, err = r.Register("user.created", "AVRO", `{}`)
+	assert.True(t, errors.As(err, &invalid))
+
+	_, _, err = r.Register("user.created", SchemaTypeProtobuf, `message {`)
+	assert.True(t, errors.As(err, &invalid))
+
+	_, _, err = r.Register("", SchemaTypeJSON, `{}`)
+	assert.True(t, errors.As(err, &invalid))
+
+	assert.Empty(t, r.Subjects())
+}
+
+func TestPersistence(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "registry", "schemas.json")
+	r, err := New(config.RegistryConfig{File: file})
+	require.NoError(t, err)
+
+	registered, _, err := r.Register("user.created", SchemaTypeJSON, userV1)
+	require.NoError(t, err)
+	require.NoError(t, r.SetCompatibility("user.created", CompatibilityNone))
+
+	reopened, err := New(config.RegistryConfig{File: file})
+	require.NoError(t, err)
+	got, err := reopened.Get("user.created", 1)
+	require.NoError(t, err)
+	assert.Equal(t, registered.Schema, got.Schema)
+	assert.Equal(t, registered.ID, got.ID)
+	assert.Equal(t, CompatibilityNone, reopened.Compatibility("user.created"))
+
+	// IDs continue where the file left off
+	next, _, err := reopened.Register("order.created", SchemaTypeJSON, `{"type": "object"}`)
+	require.NoError(t, err)
+	assert.Equal(t, 2, next.ID)
+}
+
+func TestNew_InvalidConfig(t *testing.T) {
+	_, err := New(config.RegistryConfig{Compatibility: "TRANSITIVE"})
+	assert.Error(t, err)
+
+	// Without a file, schemas are only kept in memory
+	r, err := New(config.RegistryConfig{})
+	require.NoError(t, err)
+	assert.Equal(t, CompatibilityBackward, r.Compatibility("user.created"))
+	_, _, err = r.Register("user.created", SchemaTypeJSON, userV1)
+	assert.NoError(t, err)
+}
+
+func TestJSONCompatibility_Backward(t *testing.T) {
+	r := newTestRegistry(t, CompatibilityBackward)
+	_, _, err := r.Register("user.created", SchemaTypeJSON, userV1)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name   string
+		schema string
+		reason string
+	}{
+		{
+			name:   "new required property",
+			schema: `{"type": "object", "required": ["user_id", "email"], "properties": {"user_id": {"type": "string"}, "age": {"type": "integer", "minimum": 0}, "email": {"type": "string"}}}`,
+			reason: "schema: property email is now required",
+		},
+		{
+			name:   "narrowed type",
+			schema: `{"type": "object", "required": ["user_id"], "properties": {"user_id": {"type": "integer"}, "age": {"type": "integer", "minimum": 0}}}`,
+			reason: "property user_id: type string is no longer allowed",
+		},
+		{
+			name:   "raised minimum",
+			schema: `{"type": "object", "required": ["user_id"], "properties": {"user_id": {"type": "string"}, "age": {"type": "integer", "minimum": 18}}}`,
+			reason: "property age: minimum raised to 18",
+		},
+		{
+			name:   "closed object",
+			schema: `{"type": "object", "required": ["user_id"], "additionalProperties": false, "properties": {"user_id": {"type": "string"}}}`,
+			reason: "property age: no value is allowed anymore",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := r.CheckCompatibility("user.created", SchemaTypeJSON, tt.schema)
+			assert.Contains(t, incompatibleReasons(t, err), tt.reason)
+
+			_, _, err = r.Register("user.created", SchemaTypeJSON, tt.schema)
+			assert.Error(t, err)
+		})
+	}
+
+	// Widening is fine: integer to number, dropping a requirement, lowering a bound
+	err = r.CheckCompatibility("user.created", SchemaTypeJSON,
+		`{"type": "object", "properties": {"user_id": {"type": "string"}, "age": {"type": "number", "minimum": -1}}}`)
+	assert.NoError(t, err)
+}
+
+func TestJSONCompatibility_ForwardAndFull(t *testing.T) {
+	r := newTestRegistry(t, CompatibilityForward)
+	_, _, err := r.Register("user.created", SchemaTypeJSON, userV1)
+	require.NoError(t, err)
+
+	// Dropping a required property breaks readers of the old schema
+	dropped := `{"type": "object", "properties": {"user_id": {"type": "string"}, "age": {"type": "integer", "minimum": 0}}}`
+	reasons := incompatibleReasons(t, r.CheckCompatibility("user.created", SchemaTypeJSON, dropped))
+	assert.Contains(t, reasons, "schema: property user_id is now required")
+
+	// Adding a required property is forward compatible, but not backward
+	added := `{"type": "object", "required": ["user_id", "email"], "properties": {"user_id": {"type": "string"}, "age": {"type": "integer", "minimum": 0}, "email": {"type": "string"}}}`
+	assert.NoError(t, r.CheckCompatibility("user.created", SchemaTypeJSON, added))
+
+	require.NoError(t, r.SetCompatibility("user.created", CompatibilityFull))
+	assert.Error(t, r.CheckCompatibility("user.created", SchemaTypeJSON, added))
+
+	// Schema types cannot change
+	reasons = incompatibleReasons(t, r.CheckCompatibility("user.created", SchemaTypeProtobuf, `syntax = "proto3"; message User {}`))
+	assert.Contains(t, reasons[0], "schema type changed")
+
+	// NONE accepts anything
+	require.NoError(t, r.SetCompatibility("user.created", CompatibilityNone))
+	assert.NoError(t, r.CheckCompatibility("user.created", SchemaTypeJSON, `{"type": "string"}`))
+
+	var invalid *InvalidSchemaError
+	assert.True(t, errors.As(r.SetCompatibility("user.created", "SOMETIMES"), &invalid))
+}
+
+func TestProtobufCompatibility(t *testing.T) {
+	r := newTestRegistry(t, CompatibilityFull)
+	_, _, err := r.Register("order.created", SchemaTypeProtobuf, `syntax = "proto3";
+package orders;
+import "google/protobuf/timestamp.proto";
+message Order {
+	string order_id = 1;
+	int32 quantity = 2;
+	repeated string skus = 3;
+	google.protobuf.Timestamp placed_at = 4;
+}`)
+	require.NoError(t, err)
+
+	// Renaming fields, widening varints and adding fields are compatible
+	_, created, err := r.Register("order.created", SchemaTypeProtobuf, `syntax = "proto3";
+package orders;
+import "google/protobuf/timestamp.proto";
+message Order {
+	string id = 1;
+	int64 quantity = 2;
+	repeated string skus = 3;
+	google.protobuf.Timestamp placed_at = 4;
+	string note = 5;
+}`)
+	require.NoError(t, err)
+	assert.True(t, created)
+
+	reasons := incompatibleReasons(t, r.CheckCompatibility("order.created", SchemaTypeProtobuf, `syntax = "proto3";
+package orders;
+message Order {
+	string id = 1;
+	double quantity = 2;
+	string skus = 3;
+	string note = 5;
+}
+message Item {}`))
+	assert.Contains(t, reasons, "field orders.Order.quantity (2): type changed from int64 to double")
+	assert.Contains(t, reasons, "field orders.Order.skus (3): changed between singular, repeated and map")
+
+	reasons = incompatibleReasons(t, r.CheckCompatibility("order.created", SchemaTypeProtobuf, `syntax = "proto3";
+package billing;
+message Invoice {}`))
+	assert.Contains(t, reasons, "message orders.Order was removed")
+}
@@ -0,0 +1,45 @@
+package schema
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Outcomes of checking event data against a schema
+const (
+	OutcomeValid   = "valid"
+	OutcomeInvalid = "invalid"
+	OutcomeMissing = "missing"
+)
+
+// Results of reloading the schemas
+const (
+	ReloadSuccess = "success"
+	ReloadFailure = "failure"
+)
+
+// Prometheus metrics
+var (
+	schemaValidations = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "schema_validations_total",
+			Help: "Total number of events checked against a schema, by outcome (valid, invalid or missing)",
+		},
+		[]string{"outcome"},
+	)
+
+	schemaReloads = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "schema_reloads_total",
+			Help: "Total number of times the schemas were loaded, by result (success or failure)",
+		},
+		[]string{"result"},
+	)
+
+	schemasLoaded = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "schemas_loaded",
+			Help: "Number of schemas in use",
+		},
+	)
+)
@@ -0,0 +1,424 @@
+package schema
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/distributed-event-processor/services/event-gateway/internal/config"
+	"github.com/distributed-event-processor/services/event-gateway/internal/models"
+	"github.com/distributed-event-processor/services/event-gateway/internal/registry"
+	"github.com/santhosh-tekuri/jsonschema/v6"
+	"go.uber.org/zap"
+)
+
+// Validation modes
+const (
+	ModeEnforce = "enforce"
+	ModeWarn    = "warn"
+	ModeOff     = "off"
+)
+
+// Violation codes
+const (
+	CodeSchemaViolation = "SCHEMA_VIOLATION"
+	CodeSchemaNotFound  = "SCHEMA_NOT_FOUND"
+)
+
+// DefaultVersion names the schema used for events without a schema_version
+const DefaultVersion = "default"
+
+// Validator checks event data against the JSON Schema for the event's type and
+// schema_version. The schemas are files named <type>/<schema_version>.json in
+// a directory, which is scanned again periodically so that schemas can be
+// added or changed without a restart. Types without files there are looked up
+// in the schema registry, with the event type as subject.
+type Validator struct {
+	dir         string
+	defaultMode string
+	modes       map[string]string
+	interval    time.Duration
+	registry    *registry.Registry
+	logger      *zap.Logger
+
+	mu         sync.RWMutex
+	schemas    map[string]map[string]*jsonschema.Schema // by type, then version
+	digest     string
+	registered map[int]*jsonschema.Schema // registry schemas by ID
+
+	stop chan struct{}
+	wg   sync.WaitGroup
+}
+
+// New loads the schemas of cfg.Directory and starts reloading them every
+// cfg.ReloadIntervalMs. It fails if any schema cannot be compiled. Without a
+// directory, only the schemas of the registry are used; schemas may be nil
+// when there is no registry.
+func New(cfg config.SchemasConfig, schemas *registry.Registry, logger *zap.Logger) (*Validator, error) {
+	if cfg.Directory == "" && schemas == nil {
+		return nil, errors.New("schemas directory is required without a schema registry")
+	}
+	if err := checkMode(cfg.Mode); err != nil {
+		return nil, err
+	}
+
+	modes := make(map[string]string)
+	for _, override := range cfg.Overrides {
+		if err := checkMode(override.Mode); err != nil {
+			return nil, err
+		}
+		for _, eventType := range override.EventTypes {
+			modes[eventType] = override.Mode
+		}
+	}
+
+	v := &Validator{
+		dir:         cfg.Directory,
+		defaultMode: cfg.Mode,
+		modes:       modes,
+		interval:    time.Duration(cfg.ReloadIntervalMs) * time.Millisecond,
+		registry:    schemas,
+		logger:      logger,
+		registered:  make(map[int]*jsonschema.Schema),
+		stop:        make(chan struct{}),
+	}
+
+	if _, err := v.Reload(); err != nil {
+		return nil, err
+	}
+	if v.interval > 0 && v.dir != "" {
+		v.start()
+	}
+
+	logger.Info("Schema validation enabled",
+		zap.String("directory", cfg.Directory),
+		zap.String("mode", cfg.Mode),
+		zap.Int("schemas", v.Len()),
+	)
+	return v, nil
+}
+
+func checkMode(mode string) error {
+	switch mode {
+	case ModeEnforce, ModeWarn, ModeOff:
+		return nil
+	default:
+		return fmt.Errorf("unknown schema validation mode: %s (expected: %s, %s or %s)", mode, ModeEnforce, ModeWarn, ModeOff)
+	}
+}
+
+// Mode returns the validation mode of an event type
+func (v *Validator) Mode(eventType string) string {
+	if mode, ok := v.modes[eventType]; ok {
+		return mode
+	}
+	return v.defaultMode
+}
+
+// Validate checks the data of an event against its schema. Violations are
+// returned as errors when the type's mode is enforce or when enforce is set,
+// and as warnings in warn mode. Types without any schema are not checked, but
+// an event whose schema_version has no schema of its type is reported. In the
+// registry, events without a schema_version use the latest version.
+func (v *Validator) Validate(event *models.Event, enforce bool) ([]models.ValidationError, []string) {
+	mode := v.Mode(event.Type)
+	if enforce {
+		mode = ModeEnforce
+	}
+	if mode == ModeOff {
+		return nil, nil
+	}
+
+	version := event.SchemaVersion
+	if version == "" {
+		version = DefaultVersion
+	}
+
+	v.mu.RLock()
+	versions, known := v.schemas[event.Type]
+	compiled := versions[version]
+	v.mu.RUnlock()
+
+	if !known && v.registry != nil {
+		compiled, known = v.lookup(event.Type, event.SchemaVersion)
+		if event.SchemaVersion != "" {
+			version = event.SchemaVersion
+		}
+	}
+	if !known {
+		return nil, nil
+	}
+
+	var violations []models.ValidationError
+	if compiled == nil {
+		schemaValidations.WithLabelValues(OutcomeMissing).Inc()
+		violations = []models.ValidationError{{
+			Field:   "schema_version",
+			Code:    CodeSchemaNotFound,
+			Message: fmt.Sprintf("no schema for event type %s version %s", event.Type, version),
+		}}
+	} else {
+		violations = dataViolations(compiled.Validate(event.Data))
+		if len(violations) == 0 {
+			schemaValidations.WithLabelValues(OutcomeValid).Inc()
+			return nil, nil
+		}
+		schemaValidations.WithLabelValues(OutcomeInvalid).Inc()
+	}
+
+	if mode == ModeEnforce {
+		return violations, nil
+	}
+	warnings := make([]string, len(violations))
+	for i, violation := range violations {
+		warnings[i] = violation.Field + ": " + violation.Message
+	}
+	return nil, warnings
+}
+
+// lookup returns the JSON Schema registered for an event type at version, a
+// version number or empty for the latest. known is false when the type has no
+// JSON Schemas in the registry, and the schema nil when the version is missing.
+func (v *Validator) lookup(eventType, version string) (*jsonschema.Schema, bool) {
+	number := registry.LatestVersion
+	if version != "" {
+		parsed, err := strconv.Atoi(version)
+		if err != nil {
+			_, err = v.registry.Versions(eventType)
+			return nil, err == nil
+		}
+		number = parsed
+	}
+
+	registered, err := v.registry.Get(eventType, number)
+	if errors.Is(err, registry.ErrSubjectNotFound) {
+		return nil, false
+	}
+	if err != nil {
+		return nil, true
+	}
+	if registered.SchemaType != registry.SchemaTypeJSON {
+		return nil, false
+	}
+
+	v.mu.RLock()
+	compiled, ok := v.registered[registered.ID]
+	v.mu.RUnlock()
+	if ok {
+		return compiled, true
+	}
+
+	compiled, err = registry.CompileJSON(registered.Schema)
+	if err != nil {
+		v.logger.Error("Failed to compile registered schema",
+			zap.String("subject", eventType),
+			zap.Int("version", registered.Version),
+			zap.Error(err),
+		)
+		return nil, false
+	}
+
+	v.mu.Lock()
+	v.registered[registered.ID] = compiled
+	v.mu.Unlock()
+	return compiled, true
+}
+
+// dataViolations lists the leaf errors of a failed validation, naming each
+// field by its path below data
+func dataViolations(err error) []models.ValidationError {
+	if err == nil {
+		return nil
+	}
+
+	var validationErr *jsonschema.ValidationError
+	if !errors.As(err, &validationErr) {
+		return []models.ValidationError{{Field: "data", Code: CodeSchemaViolation, Message: err.Error()}}
+	}
+
+	var violations []models.ValidationError
+	var collect func(unit *jsonschema.OutputUnit)
+	collect = func(unit *jsonschema.OutputUnit) {
+		if len(unit.Errors) == 0 && unit.Error != nil {
+			violations = append(violations, models.ValidationError{
+				Field:   fieldPath(unit.InstanceLocation),
+				Code:    CodeSchemaViolation,
+				Message: unit.Error.String(),
+			})
+		}
+		for i := range unit.Errors {
+			collect(&unit.Errors[i])
+		}
+	}
+	collect(validationErr.DetailedOutput())
+	return violations
+}
+
+// fieldPath turns a JSON pointer into the instance into a dotted path below
+// data, such as data.items.0.sku
+func fieldPath(pointer string) string {
+	if pointer == "" {
+		return "data"
+	}
+	tokens := strings.Split(strings.TrimPrefix(pointer, "/"), "/")
+	for i, token := range tokens {
+		tokens[i] = strings.NewReplacer("~1", "/", "~0", "~").Replace(token)
+	}
+	return "data." + strings.Join(tokens, ".")
+}
+
+// Len returns the number of schemas loaded
+func (v *Validator) Len() int {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+
+	count := 0
+	for _, versions := range v.schemas {
+		count += len(versions)
+	}
+	return count
+}
+
+// Reload compiles the schemas again if any file in the directory changed since
+// the last load, and reports whether it did. The schemas in use are kept when
+// any of the new ones fails to compile.
+func (v *Validator) Reload() (bool, error) {
+	if v.dir == "" {
+		return false, nil
+	}
+
+	files, digest, err := scan(v.dir)
+	if err != nil {
+		schemaReloads.WithLabelValues(ReloadFailure).Inc()
+		return false, err
+	}
+
+	v.mu.RLock()
+	unchanged := digest == v.digest
+	v.mu.RUnlock()
+	if unchanged {
+		return false, nil
+	}
+
+	compiler := jsonschema.NewCompiler()
+	schemas := make(map[string]map[string]*jsonschema.Schema)
+	for _, file := range files {
+		compiled, err := compiler.Compile(file.path)
+		if err != nil {
+			// Remember the digest so the same files are not compiled again
+			// until one of them changes
+			v.mu.Lock()
+			v.digest = digest
+			v.mu.Unlock()
+
+			schemaReloads.WithLabelValues(ReloadFailure).Inc()
+			return false, fmt.Errorf("failed to compile schema %s: %w", file.path, err)
+		}
+		if schemas[file.eventType] == nil {
+			schemas[file.eventType] = make(map[string]*jsonschema.Schema)
+		}
+		schemas[file.eventType][file.version] = compiled
+	}
+
+	v.mu.Lock()
+	v.schemas = schemas
+	v.digest = digest
+	v.mu.Unlock()
+
+	schemaReloads.WithLabelValues(ReloadSuccess).Inc()
+	schemasLoaded.Set(float64(len(files)))
+	return true, nil
+}
+
+type schemaFile struct {
+	path      string
+	eventType string
+	version   string
+}
+
+// scan lists the schema files of dir, with a digest of their names, sizes and
+// modification times that changes whenever one of them does
+func scan(dir string) ([]schemaFile, string, error) {
+	typeDirs, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read schemas directory: %w", err)
+	}
+
+	var files []schemaFile
+	hash := sha256.New()
+	for _, typeDir := range typeDirs {
+		if !typeDir.IsDir() {
+			continue
+		}
+		entries, err := os.ReadDir(filepath.Join(dir, typeDir.Name()))
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to read schemas of %s: %w", typeDir.Name(), err)
+		}
+		for _, entry := range entries {
+			if entry.IsDir() || filepath.Ext(entry.Name()) != ".json" {
+				continue
+			}
+			info, err := entry.Info()
+			if err != nil {
+				if errors.Is(err, fs.ErrNotExist) {
+					continue
+				}
+				return nil, "", err
+			}
+
+			path, err := filepath.Abs(filepath.Join(dir, typeDir.Name(), entry.Name()))
+			if err != nil {
+				return nil, "", err
+			}
+			files = append(files, schemaFile{
+				path:      path,
+				eventType: typeDir.Name(),
+				version:   strings.TrimSuffix(entry.Name(), ".json"),
+			})
+			fmt.Fprintf(hash, "%s %d %d\n", path, info.Size(), info.ModTime().UnixNano())
+		}
+	}
+
+	sort.Slice(files, func(i, j int) bool { return files[i].path < files[j].path })
+	return files, hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// start rescans the directory on every interval until Close
+func (v *Validator) start() {
+	v.wg.Add(1)
+	go func() {
+		defer v.wg.Done()
+
+		ticker := time.NewTicker(v.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-v.stop:
+				return
+			case <-ticker.C:
+				reloaded, err := v.Reload()
+				if err != nil {
+					v.logger.Error("Failed to reload schemas, keeping the previous ones", zap.Error(err))
+				} else if reloaded {
+					v.logger.Info("Reloaded schemas", zap.Int("schemas", v.Len()))
+				}
+			}
+		}
+	}()
+}
+
+// Close stops reloading the schemas
+func (v *Validator) Close() {
+	close(v.stop)
+	v.wg.Wait()
+}
@@ -0,0 +1,243 @@
+package schema
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/distributed-event-processor/services/event-gateway/internal/config"
+	"github.com/distributed-event-processor/services/event-gateway/internal/models"
+	"github.com/distributed-event-processor/services/event-gateway/internal/registry"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+const orderSchema = `{
+	"type": "object",
+	"required": ["order_id", "items"],
+	"properties": {
+		"order_id": {"type": "string"},
+		"items": {
+			"type": "array",
+			"items": {
+				"type": "object",
+				"required": ["sku"],
+				"properties": {"sku": {"type": "string"}, "quantity": {"type": "integer", "minimum": 1}}
+			}
+		}
+	}
+}`
+
+func writeSchema(t *testing.T, dir, eventType, version, schema string) {
+	t.Helper()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, eventType), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, eventType, version+".json"), []byte(schema), 0o644))
+}
+
+func newTestValidator(t *testing.T, cfg config.SchemasConfig) *Validator {
+	t.Helper()
+	if cfg.Directory == "" {
+		cfg.Directory = t.TempDir()
+		writeSchema(t, cfg.Directory, "order.created", "1", orderSchema)
+	}
+	if cfg.Mode == "" {
+		cfg.Mode = ModeEnforce
+	}
+	v, err := New(cfg, nil, zap.NewNop())
+	require.NoError(t, err)
+	t.Cleanup(v.Close)
+	return v
+}
+
+func orderEvent(data map[string]interface{}) *models.Event {
+	return &models.Event{Type: "order.created", SchemaVersion: "1", Data: data}
+}
+
+func TestValidate_Valid(t *testing.T) {
+	v := newTestValidator(t, config.SchemasConfig{})
+
+	errs, warnings := v.Validate(orderEvent(map[string]interface{}{
+		"order_id": "o-1",
+		"items":    []interface{}{map[string]interface{}{"sku": "A1", "quantity": float64(2)}},
+	}), false)
+
+	assert.Empty(t, errs)
+	assert.Empty(t, warnings)
+}
+
+func TestValidate_Violations(t *testing.T) {
+	v := newTestValidator(t, config.SchemasConfig{})
+
+	errs, warnings := v.Validate(orderEvent(map[string]interface{}{
+		"items": []interface{}{map[string]interface{}{"quantity": float64(0)}},
+	}), false)
+
+	assert.Empty(t, warnings)
+	fields := make([]string, len(errs))
+	for i, violation := range errs {
+		fields[i] = violation.Field
+		assert.Equal(t, CodeSchemaViolation, violation.Code)
+		assert.NotEmpty(t, violation.Message)
+	}
+	assert.ElementsMatch(t, []string{"data", "data.items.0", "data.items.0.quantity"}, fields)
+}
+
+func TestValidate_Modes(t *testing.T) {
+	v := newTestValidator(t, config.SchemasConfig{
+		Mode: ModeWarn,
+		Overrides: []config.SchemaModeOverride{
+			{EventTypes: []string{"order.created"}, Mode: ModeOff},
+		},
+	})
+	invalid := orderEvent(map[string]interface{}{})
+
+	// Off for this type
+	errs, warnings := v.Validate(invalid, false)
+	assert.Empty(t, errs)
+	assert.Empty(t, warnings)
+
+	// A strict check enforces the schema anyway
+	errs, _ = v.Validate(invalid, true)
+	assert.NotEmpty(t, errs)
+
+	v.modes = map[string]string{}
+	errs, warnings = v.Validate(invalid, false)
+	assert.Empty(t, errs)
+	require.NotEmpty(t, warnings)
+	assert.Contains(t, warnings[0], "data: ")
+}
+
+func TestValidate_UnknownVersion(t *testing.T) {
+	v := newTestValidator(t, config.SchemasConfig{})
+
+	event := orderEvent(map[string]interface{}{})
+	event.SchemaVersion = "2"
+	errs, _ := v.Validate(event, false)
+	require.Len(t, errs, 1)
+	assert.Equal(t, "schema_version", errs[0].Field)
+	assert.Equal(t, CodeSchemaNotFound, errs[0].Code)
+
+	// Without a schema_version, the type's default schema is used
+	event.SchemaVersion = ""
+	errs, _ = v.Validate(event, false)
+	require.Len(t, errs, 1)
+	assert.Contains(t, errs[0].Message, "version default")
+
+	// Types without schemas are not checked
+	errs, warnings := v.Validate(&models.Event{Type: "user.created", Data: map[string]interface{}{}}, false)
+	assert.Empty(t, errs)
+	assert.Empty(t, warnings)
+}
+
+func TestReload(t *testing.T) {
+	dir := t.TempDir()
+	writeSchema(t, dir, "order.created", "1", orderSchema)
+	v := newTestValidator(t, config.SchemasConfig{Directory: dir})
+	assert.Equal(t, 1, v.Len())
+
+	reloaded, err := v.Reload()
+	require.NoError(t, err)
+	assert.False(t, reloaded)
+
+	writeSchema(t, dir, "order.created", "2", `{"type": "object"}`)
+	reloaded, err = v.Reload()
+	require.NoError(t, err)
+	assert.True(t, reloaded)
+	assert.Equal(t, 2, v.Len())
+
+	// A schema that does not compile leaves the loaded ones in place
+	writeSchema(t, dir, "order.created", "3", `{"type": 42}`)
+	_, err = v.Reload()
+	assert.Error(t, err)
+	assert.Equal(t, 2, v.Len())
+
+	// The same broken files are not compiled again
+	reloaded, err = v.Reload()
+	assert.NoError(t, err)
+	assert.False(t, reloaded)
+}
+
+func TestReload_Periodic(t *testing.T) {
+	dir := t.TempDir()
+	writeSchema(t, dir, "order.created", "1", orderSchema)
+	v := newTestValidator(t, config.SchemasConfig{Directory: dir, ReloadIntervalMs: 10})
+
+	writeSchema(t, dir, "user.created", "1", `{"type": "object"}`)
+	assert.Eventually(t, func() bool { return v.Len() == 2 }, time.Second, 10*time.Millisecond)
+}
+
+func TestValidate_Registry(t *testing.T) {
+	schemas, err := registry.New(config.RegistryConfig{Compatibility: registry.CompatibilityNone})
+	require.NoError(t, err)
+	_, _, err = schemas.Register("user.created", registry.SchemaTypeJSON, `{"type": "object", "required": ["user_id"]}`)
+	require.NoError(t, err)
+	_, _, err = schemas.Register("user.created", registry.SchemaTypeJSON, `{"type": "object", "required": ["user_id", "email"]}`)
+	require.NoError(t, err)
+	_, _, err = schemas.Register("order.created", registry.SchemaTypeJSON, `{"type": "object", "required": ["total"]}`)
+	require.NoError(t, err)
+
+	// The directory takes precedence for the types it has
+	dir := t.TempDir()
+	writeSchema(t, dir, "order.created", "1", orderSchema)
+	v, err := New(config.SchemasConfig{Directory: dir, Mode: ModeEnforce}, schemas, zap.NewNop())
+	require.NoError(t, err)
+	t.Cleanup(v.Close)
+
+	event := &models.Event{Type: "user.created", Data: map[string]interface{}{"user_id": "u-1"}}
+
+	// Without a schema_version, the latest version applies
+	errs, _ := v.Validate(event, false)
+	require.Len(t, errs, 1)
+	assert.Equal(t, CodeSchemaViolation, errs[0].Code)
+
+	event.SchemaVersion = "1"
+	errs, _ = v.Validate(event, false)
+	assert.Empty(t, errs)
+
+	for _, version := range []string{"3", "v1"} {
+		event.SchemaVersion = version
+		errs, _ = v.Validate(event, false)
+		require.Len(t, errs, 1)
+		assert.Equal(t, CodeSchemaNotFound, errs[0].Code)
+	}
+
+	errs, _ = v.Validate(orderEvent(map[string]interface{}{"order_id": "o-1", "items": []interface{}{}}), false)
+	assert.Empty(t, errs)
+
+	// Subjects the registry does not have are not checked
+	errs, _ = v.Validate(&models.Event{Type: "user.deleted", Data: map[string]interface{}{}}, false)
+	assert.Empty(t, errs)
+
+	// The registry alone is enough
+	v, err = New(config.SchemasConfig{Mode: ModeEnforce}, schemas, zap.NewNop())
+	require.NoError(t, err)
+	t.Cleanup(v.Close)
+	event.SchemaVersion = "2"
+	errs, _ = v.Validate(event, false)
+	assert.Len(t, errs, 1)
+}
+
+func TestNew_InvalidConfig(t *testing.T) {
+	dir := t.TempDir()
+
+	_, err := New(config.SchemasConfig{Directory: dir, Mode: "strict"}, nil, zap.NewNop())
+	assert.Error(t, err)
+
+	_, err = New(config.SchemasConfig{Mode: ModeEnforce}, nil, zap.NewNop())
+	assert.Error(t, err)
+
+	_, err = New(config.SchemasConfig{Directory: filepath.Join(dir, "missing"), Mode: ModeEnforce}, nil, zap.NewNop())
+	assert.Error(t, err)
+
+	writeSchema(t, dir, "order.created", "1", `{"type": 42}`)
+	_, err = New(config.SchemasConfig{Directory: dir, Mode: ModeEnforce}, nil, zap.NewNop())
+	assert.Error(t, err)
+}
+
+func TestFieldPath(t *testing.T) {
+	assert.Equal(t, "data", fieldPath(""))
+	assert.Equal(t, "data.items.0.sku", fieldPath("/items/0/sku"))
+	assert.Equal(t, "data.a/b", fieldPath("/a~1b"))
+}
@@ -0,0 +1,39 @@
+package spool
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Prometheus metrics
+var (
+	spoolPending = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "spool_pending_events",
+			Help: "Number of spooled events waiting to be replayed to Kafka, by spool directory",
+		},
+		[]string{"dir"},
+	)
+
+	spoolSize = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "spool_size_bytes",
+			Help: "Disk space used by the spool, by spool directory",
+		},
+		[]string{"dir"},
+	)
+
+	spoolAppended = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "spool_appended_total",
+			Help: "Total number of events written to the spool",
+		},
+	)
+
+	spoolRejected = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "spool_rejected_total",
+			Help: "Total number of events refused because the spool was full",
+		},
+	)
+)
@@ -0,0 +1,605 @@
+package spool
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/distributed-event-processor/services/event-gateway/internal/config"
+	"go.uber.org/zap"
+)
+
+// Fsync policies supported by Open
+const (
+	FsyncAlways   = "always"
+	FsyncInterval = "interval"
+	FsyncNever    = "never"
+)
+
+var (
+	// ErrFull is returned by Append when the record would exceed the configured size limit
+	ErrFull = errors.New("spool is full")
+
+	// ErrClosed is returned when the spool is used after Close
+	ErrClosed = errors.New("spool is closed")
+)
+
+const (
+	segmentSuffix  = ".seg"
+	checkpointFile = "checkpoint"
+
+	// Every record is prefixed with its payload length and a CRC32 of the payload
+	recordHeaderSize = 8
+)
+
+type segment struct {
+	id   uint64
+	size int64
+}
+
+// Spool is a segment-based write-ahead log of opaque records on local disk.
+// Records are appended to the newest segment and consumed in order from the
+// oldest; a checkpoint file remembers the read position across restarts, and
+// segments are deleted once every record in them has been committed.
+type Spool struct {
+	dir          string
+	maxBytes     int64
+	segmentBytes int64
+	fsync        string
+	logger       *zap.Logger
+
+	mu       sync.Mutex
+	segments []segment
+	active   *os.File
+	// headOffset is the byte offset of the next unread record in segments[0]
+	headOffset int64
+	pending    int
+	size       int64
+	dirty      bool
+	closed     bool
+
+	stop chan struct{}
+	wg   sync.WaitGroup
+}
+
+// Open opens the spool in cfg.Dir, creating it if needed, and recovers any
+// records left over from a previous run. A torn record at the end of a
+// segment, left by a crash mid-write, is truncated away.
+func Open(cfg config.SpoolConfig, logger *zap.Logger) (*Spool, error) {
+	if cfg.Dir == "" {
+		return nil, errors.New("spool directory is required")
+	}
+	if cfg.MaxBytes <= 0 || cfg.SegmentBytes <= 0 {
+		return nil, errors.New("spool max_bytes and segment_bytes must be positive")
+	}
+	if cfg.SegmentBytes > cfg.MaxBytes {
+		return nil, fmt.Errorf("spool segment_bytes (%d) cannot exceed max_bytes (%d)", cfg.SegmentBytes, cfg.MaxBytes)
+	}
+
+	switch cfg.FsyncPolicy {
+	case FsyncAlways, FsyncNever:
+	case FsyncInterval:
+		if cfg.FsyncIntervalMs <= 0 {
+			return nil, errors.New("spool fsync_interval_ms must be positive with the interval fsync policy")
+		}
+	default:
+		return nil, fmt.Errorf("unknown spool fsync policy: %s (expected: %s, %s or %s)", cfg.FsyncPolicy, FsyncAlways, FsyncInterval, FsyncNever)
+	}
+
+	if err := os.MkdirAll(cfg.Dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create spool directory: %w", err)
+	}
+
+	s := &Spool{
+		dir:          cfg.Dir,
+		maxBytes:     cfg.MaxBytes,
+		segmentBytes: cfg.SegmentBytes,
+		fsync:        cfg.FsyncPolicy,
+		logger:       logger,
+		stop:         make(chan struct{}),
+	}
+
+	if err := s.recover(); err != nil {
+		return nil, err
+	}
+
+	if s.fsync == FsyncInterval {
+		s.wg.Add(1)
+		go s.syncLoop(time.Duration(cfg.FsyncIntervalMs) * time.Millisecond)
+	}
+
+	s.updateGauges()
+	return s, nil
+}
+
+// recover rebuilds the in-memory index from the segments and checkpoint on disk
+func (s *Spool) recover() error {
+	ids, err := s.listSegments()
+	if err != nil {
+		return err
+	}
+
+	headID, headOffset, err := s.readCheckpoint()
+	if err != nil {
+		return err
+	}
+
+	for i, id := range ids {
+		// Segments before the checkpoint were fully committed but not yet removed
+		if id < headID {
+			if err := os.Remove(s.segmentPath(id)); err != nil {
+				return fmt.Errorf("failed to remove committed spool segment: %w", err)
+			}
+			continue
+		}
+
+		size, records, err := s.scanSegment(id, i == len(ids)-1)
+		if err != nil {
+			return err
+		}
+
+		if id == headID {
+			skipped, err := s.countRecords(id, headOffset)
+			if err != nil {
+				return err
+			}
+			records -= skipped
+			s.headOffset = headOffset
+		}
+
+		s.segments = append(s.segments, segment{id: id, size: size})
+		s.size += size
+		s.pending += records
+	}
+
+	// The checkpoint pointed at a segment that no longer exists, so start
+	// reading from the beginning of whatever is left
+	if len(s.segments) > 0 && s.segments[0].id != headID {
+		s.headOffset = 0
+	}
+
+	if len(s.segments) == 0 {
+		next := headID
+		if next == 0 {
+			next = 1
+		}
+		if len(ids) > 0 && ids[len(ids)-1] >= next {
+			next = ids[len(ids)-1] + 1
+		}
+		s.headOffset = 0
+		s.segments = append(s.segments, segment{id: next})
+	}
+
+	tail := s.segments[len(s.segments)-1]
+	active, err := os.OpenFile(s.segmentPath(tail.id), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open spool segment: %w", err)
+	}
+	s.active = active
+
+	if s.pending > 0 {
+		s.logger.Info("Recovered spooled events",
+			zap.String("dir", s.dir),
+			zap.Int("pending", s.pending),
+			zap.Int64("size_bytes", s.size))
+	}
+
+	return s.writeCheckpoint()
+}
+
+// Append durably adds a record to the end of the spool, subject to the fsync policy
+func (s *Spool) Append(data []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return ErrClosed
+	}
+
+	recordSize := int64(recordHeaderSize + len(data))
+	if s.size+recordSize > s.maxBytes {
+		spoolRejected.Inc()
+		return ErrFull
+	}
+
+	tail := &s.segments[len(s.segments)-1]
+	if tail.size > 0 && tail.size+recordSize > s.segmentBytes {
+		if err := s.roll(); err != nil {
+			return err
+		}
+		tail = &s.segments[len(s.segments)-1]
+	}
+
+	record := make([]byte, recordSize)
+	binary.BigEndian.PutUint32(record[0:4], uint32(len(data)))
+	binary.BigEndian.PutUint32(record[4:8], crc32.ChecksumIEEE(data))
+	copy(record[recordHeaderSize:], data)
+
+	if _, err := s.active.Write(record); err != nil {
+		return fmt.Errorf("failed to write spool record: %w", err)
+	}
+
+	if s.fsync == FsyncAlways {
+		if err := s.active.Sync(); err != nil {
+			return fmt.Errorf("failed to sync spool segment: %w", err)
+		}
+	} else {
+		s.dirty = true
+	}
+
+	tail.size += recordSize
+	s.size += recordSize
+	s.pending++
+	spoolAppended.Inc()
+	s.updateGauges()
+	return nil
+}
+
+// Peek returns up to n of the oldest uncommitted records without consuming them
+func (s *Spool) Peek(n int) ([][]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return nil, ErrClosed
+	}
+
+	records := make([][]byte, 0, min(n, s.pending))
+	offset := s.headOffset
+	for _, seg := range s.segments {
+		if len(records) == n {
+			break
+		}
+
+		f, err := os.Open(s.segmentPath(seg.id))
+		if err != nil {
+			return nil, fmt.Errorf("failed to open spool segment: %w", err)
+		}
+
+		for offset < seg.size && len(records) < n {
+			data, next, err := readRecord(f, offset)
+			if err != nil {
+				f.Close()
+				return nil, fmt.Errorf("failed to read spool record: %w", err)
+			}
+			records = append(records, data)
+			offset = next
+		}
+		f.Close()
+		offset = 0
+	}
+
+	return records, nil
+}
+
+// Commit marks the n oldest records as consumed, deleting any segment that no
+// longer holds uncommitted records
+func (s *Spool) Commit(n int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return ErrClosed
+	}
+	if n > s.pending {
+		return fmt.Errorf("cannot commit %d spool records, only %d pending", n, s.pending)
+	}
+
+	for n > 0 {
+		head := s.segments[0]
+		f, err := os.Open(s.segmentPath(head.id))
+		if err != nil {
+			return fmt.Errorf("failed to open spool segment: %w", err)
+		}
+		for n > 0 && s.headOffset < head.size {
+			next, err := skipRecord(f, s.headOffset)
+			if err != nil {
+				f.Close()
+				return fmt.Errorf("failed to read spool record: %w", err)
+			}
+			s.headOffset = next
+			s.pending--
+			n--
+		}
+		f.Close()
+
+		if s.headOffset >= head.size && len(s.segments) > 1 {
+			if err := s.dropHead(); err != nil {
+				return err
+			}
+		}
+	}
+
+	// Once everything is consumed start a fresh segment, so that a drained
+	// spool does not keep its old records on disk
+	if s.pending == 0 && s.segments[0].size > 0 {
+		if err := s.roll(); err != nil {
+			return err
+		}
+		if err := s.dropHead(); err != nil {
+			return err
+		}
+	}
+
+	if err := s.writeCheckpoint(); err != nil {
+		return err
+	}
+
+	s.updateGauges()
+	return nil
+}
+
+// Len returns the number of uncommitted records
+func (s *Spool) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.pending
+}
+
+// Size returns the number of bytes the spool occupies on disk
+func (s *Spool) Size() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.size
+}
+
+// Close flushes the active segment to disk and releases it
+func (s *Spool) Close() error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil
+	}
+	s.closed = true
+	s.mu.Unlock()
+
+	close(s.stop)
+	s.wg.Wait()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.active.Sync(); err != nil {
+		s.active.Close()
+		return fmt.Errorf("failed to sync spool segment: %w", err)
+	}
+	return s.active.Close()
+}
+
+// syncLoop flushes appended records to disk on a fixed interval
+func (s *Spool) syncLoop(interval time.Duration) {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+			s.mu.Lock()
+			if s.dirty && !s.closed {
+				if err := s.active.Sync(); err != nil {
+					s.logger.Error("Failed to sync spool segment", zap.Error(err))
+				} else {
+					s.dirty = false
+				}
+			}
+			s.mu.Unlock()
+		}
+	}
+}
+
+// roll closes the active segment and starts appending to a new one
+func (s *Spool) roll() error {
+	if err := s.active.Sync(); err != nil {
+		return fmt.Errorf("failed to sync spool segment: %w", err)
+	}
+	if err := s.active.Close(); err != nil {
+		return fmt.Errorf("failed to close spool segment: %w", err)
+	}
+
+	id := s.segments[len(s.segments)-1].id + 1
+	active, err := os.OpenFile(s.segmentPath(id), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to create spool segment: %w", err)
+	}
+
+	s.active = active
+	s.dirty = false
+	s.segments = append(s.segments, segment{id: id})
+	return nil
+}
+
+// dropHead deletes the oldest segment and moves the read position to the next one
+func (s *Spool) dropHead() error {
+	head := s.segments[0]
+	s.segments = s.segments[1:]
+	s.headOffset = 0
+	s.size -= head.size
+
+	// Checkpoint before removing the file so that a crash in between can never
+	// make a restart read the next segment from a stale offset
+	if err := s.writeCheckpoint(); err != nil {
+		return err
+	}
+	if err := os.Remove(s.segmentPath(head.id)); err != nil {
+		return fmt.Errorf("failed to remove spool segment: %w", err)
+	}
+	return nil
+}
+
+// scanSegment validates every record in a segment and returns its size and
+// record count. Corrupt or incomplete trailing data is truncated.
+func (s *Spool) scanSegment(id uint64, tail bool) (int64, int, error) {
+	path := s.segmentPath(id)
+	f, err := os.OpenFile(path, os.O_RDWR, 0o644)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to open spool segment: %w", err)
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to stat spool segment: %w", err)
+	}
+
+	var offset int64
+	records := 0
+	for offset < info.Size() {
+		_, next, err := readRecord(f, offset)
+		if err != nil {
+			s.logger.Warn("Truncating corrupt spool segment",
+				zap.String("segment", path),
+				zap.Int64("offset", offset),
+				zap.Bool("tail", tail),
+				zap.Error(err))
+			if err := f.Truncate(offset); err != nil {
+				return 0, 0, fmt.Errorf("failed to truncate spool segment: %w", err)
+			}
+			break
+		}
+		offset = next
+		records++
+	}
+
+	return offset, records, nil
+}
+
+// countRecords counts the records stored before offset in a segment
+func (s *Spool) countRecords(id uint64, offset int64) (int, error) {
+	f, err := os.Open(s.segmentPath(id))
+	if err != nil {
+		return 0, fmt.Errorf("failed to open spool segment: %w", err)
+	}
+	defer f.Close()
+
+	var pos int64
+	count := 0
+	for pos < offset {
+		next, err := skipRecord(f, pos)
+		if err != nil {
+			return 0, fmt.Errorf("spool checkpoint does not match segment contents: %w", err)
+		}
+		pos = next
+		count++
+	}
+	return count, nil
+}
+
+func (s *Spool) listSegments() ([]uint64, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read spool directory: %w", err)
+	}
+
+	var ids []uint64
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, segmentSuffix) {
+			continue
+		}
+		id, err := strconv.ParseUint(strings.TrimSuffix(name, segmentSuffix), 10, 64)
+		if err != nil {
+			continue
+		}
+		ids = append(ids, id)
+	}
+
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids, nil
+}
+
+// readCheckpoint returns the segment and offset of the next unread record
+func (s *Spool) readCheckpoint() (uint64, int64, error) {
+	data, err := os.ReadFile(filepath.Join(s.dir, checkpointFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, 0, nil
+	}
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to read spool checkpoint: %w", err)
+	}
+	if len(data) != 16 {
+		return 0, 0, fmt.Errorf("invalid spool checkpoint: expected 16 bytes, got %d", len(data))
+	}
+	return binary.BigEndian.Uint64(data[0:8]), int64(binary.BigEndian.Uint64(data[8:16])), nil
+}
+
+// writeCheckpoint atomically replaces the checkpoint with the current read position
+func (s *Spool) writeCheckpoint() error {
+	data := make([]byte, 16)
+	binary.BigEndian.PutUint64(data[0:8], s.segments[0].id)
+	binary.BigEndian.PutUint64(data[8:16], uint64(s.headOffset))
+
+	path := filepath.Join(s.dir, checkpointFile)
+	tmp := path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to write spool checkpoint: %w", err)
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write spool checkpoint: %w", err)
+	}
+	if s.fsync == FsyncAlways {
+		if err := f.Sync(); err != nil {
+			f.Close()
+			return fmt.Errorf("failed to sync spool checkpoint: %w", err)
+		}
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to write spool checkpoint: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("failed to write spool checkpoint: %w", err)
+	}
+	return nil
+}
+
+func (s *Spool) segmentPath(id uint64) string {
+	return filepath.Join(s.dir, fmt.Sprintf("%020d%s", id, segmentSuffix))
+}
+
+func (s *Spool) updateGauges() {
+	spoolPending.WithLabelValues(s.dir).Set(float64(s.pending))
+	spoolSize.WithLabelValues(s.dir).Set(float64(s.size))
+}
+
+// readRecord reads and verifies the record at offset and returns its payload
+// along with the offset of the following record
+func readRecord(r io.ReaderAt, offset int64) ([]byte, int64, error) {
+	header := make([]byte, recordHeaderSize)
+	if _, err := r.ReadAt(header, offset); err != nil {
+		return nil, 0, fmt.Errorf("incomplete record header: %w", err)
+	}
+
+	length := binary.BigEndian.Uint32(header[0:4])
+	data := make([]byte, length)
+	if _, err := r.ReadAt(data, offset+recordHeaderSize); err != nil {
+		return nil, 0, fmt.Errorf("incomplete record: %w", err)
+	}
+	if crc32.ChecksumIEEE(data) != binary.BigEndian.Uint32(header[4:8]) {
+		return nil, 0, errors.New("record checksum mismatch")
+	}
+
+	return data, offset + recordHeaderSize + int64(length), nil
+}
+
+// skipRecord returns the offset of the record following the one at offset
+func skipRecord(r io.ReaderAt, offset int64) (int64, error) {
+	header := make([]byte, recordHeaderSize)
+	if _, err := r.ReadAt(header, offset); err != nil {
+		return 0, fmt.Errorf("incomplete record header: %w", err)
+	}
+	return offset + recordHeaderSize + int64(binary.BigEndian.Uint32(header[0:4])), nil
+}
@@ -0,0 +1,195 @@
+package spool
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/distributed-event-processor/services/event-gateway/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func testSpoolConfig(t *testing.T) config.SpoolConfig {
+	return config.SpoolConfig{
+		Enabled:      true,
+		Dir:          t.TempDir(),
+		MaxBytes:     1 << 20,
+		SegmentBytes: 64,
+		FsyncPolicy:  FsyncAlways,
+	}
+}
+
+func openTestSpool(t *testing.T, cfg config.SpoolConfig) *Spool {
+	s, err := Open(cfg, zap.NewNop())
+	require.NoError(t, err)
+	return s
+}
+
+func appendRecords(t *testing.T, s *Spool, from, to int) {
+	for i := from; i < to; i++ {
+		require.NoError(t, s.Append([]byte(fmt.Sprintf("record-%02d", i))))
+	}
+}
+
+func TestSpool_AppendPeekCommit(t *testing.T) {
+	s := openTestSpool(t, testSpoolConfig(t))
+	defer s.Close()
+
+	appendRecords(t, s, 0, 10)
+	assert.Equal(t, 10, s.Len())
+
+	records, err := s.Peek(3)
+	require.NoError(t, err)
+	require.Len(t, records, 3)
+	assert.Equal(t, "record-00", string(records[0]))
+	assert.Equal(t, "record-02", string(records[2]))
+
+	// Peeking does not consume
+	again, err := s.Peek(1)
+	require.NoError(t, err)
+	assert.Equal(t, "record-00", string(again[0]))
+
+	require.NoError(t, s.Commit(4))
+	assert.Equal(t, 6, s.Len())
+
+	records, err = s.Peek(100)
+	require.NoError(t, err)
+	require.Len(t, records, 6)
+	assert.Equal(t, "record-04", string(records[0]))
+	assert.Equal(t, "record-09", string(records[5]))
+
+	assert.Error(t, s.Commit(7))
+}
+
+func TestSpool_RollsAndRemovesSegments(t *testing.T) {
+	cfg := testSpoolConfig(t)
+	s := openTestSpool(t, cfg)
+	defer s.Close()
+
+	// Each record is 17 bytes, so a 64 byte segment holds three of them
+	appendRecords(t, s, 0, 10)
+	segments, err := filepath.Glob(filepath.Join(cfg.Dir, "*"+segmentSuffix))
+	require.NoError(t, err)
+	assert.Len(t, segments, 4)
+
+	require.NoError(t, s.Commit(7))
+	segments, err = filepath.Glob(filepath.Join(cfg.Dir, "*"+segmentSuffix))
+	require.NoError(t, err)
+	assert.Len(t, segments, 2)
+
+	// A fully drained spool keeps only an empty active segment
+	require.NoError(t, s.Commit(3))
+	assert.Zero(t, s.Len())
+	assert.Zero(t, s.Size())
+	segments, err = filepath.Glob(filepath.Join(cfg.Dir, "*"+segmentSuffix))
+	require.NoError(t, err)
+	assert.Len(t, segments, 1)
+
+	appendRecords(t, s, 10, 11)
+	records, err := s.Peek(10)
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+	assert.Equal(t, "record-10", string(records[0]))
+}
+
+func TestSpool_RecoversAfterRestart(t *testing.T) {
+	cfg := testSpoolConfig(t)
+	s := openTestSpool(t, cfg)
+	appendRecords(t, s, 0, 8)
+	require.NoError(t, s.Commit(5))
+	require.NoError(t, s.Close())
+
+	reopened := openTestSpool(t, cfg)
+	defer reopened.Close()
+
+	assert.Equal(t, 3, reopened.Len())
+	records, err := reopened.Peek(10)
+	require.NoError(t, err)
+	require.Len(t, records, 3)
+	assert.Equal(t, "record-05", string(records[0]))
+
+	appendRecords(t, reopened, 8, 9)
+	records, err = reopened.Peek(10)
+	require.NoError(t, err)
+	assert.Equal(t, "record-08", string(records[3]))
+}
+
+func TestSpool_TruncatesTornRecord(t *testing.T) {
+	cfg := testSpoolConfig(t)
+	cfg.SegmentBytes = 1024
+	s := openTestSpool(t, cfg)
+	appendRecords(t, s, 0, 2)
+	require.NoError(t, s.Close())
+
+	// Simulate a crash part way through writing a third record
+	segments, err := filepath.Glob(filepath.Join(cfg.Dir, "*"+segmentSuffix))
+	require.NoError(t, err)
+	require.Len(t, segments, 1)
+	f, err := os.OpenFile(segments[0], os.O_WRONLY|os.O_APPEND, 0o644)
+	require.NoError(t, err)
+	_, err = f.Write([]byte{0, 0, 0, 9, 1, 2})
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	reopened := openTestSpool(t, cfg)
+	defer reopened.Close()
+
+	assert.Equal(t, 2, reopened.Len())
+	appendRecords(t, reopened, 2, 3)
+	records, err := reopened.Peek(10)
+	require.NoError(t, err)
+	require.Len(t, records, 3)
+	assert.Equal(t, "record-02", string(records[2]))
+}
+
+func TestSpool_Full(t *testing.T) {
+	cfg := testSpoolConfig(t)
+	cfg.MaxBytes = 64
+	s := openTestSpool(t, cfg)
+	defer s.Close()
+
+	appendRecords(t, s, 0, 3)
+	assert.ErrorIs(t, s.Append([]byte("record-03")), ErrFull)
+
+	// Committed space is reclaimed once its segment is removed
+	require.NoError(t, s.Commit(3))
+	assert.NoError(t, s.Append([]byte("record-03")))
+}
+
+func TestSpool_Closed(t *testing.T) {
+	s := openTestSpool(t, testSpoolConfig(t))
+	require.NoError(t, s.Close())
+	require.NoError(t, s.Close())
+
+	assert.ErrorIs(t, s.Append([]byte("late")), ErrClosed)
+	_, err := s.Peek(1)
+	assert.ErrorIs(t, err, ErrClosed)
+}
+
+func TestOpen_InvalidConfig(t *testing.T) {
+	tests := []struct {
+		name   string
+		mutate func(cfg *config.SpoolConfig)
+	}{
+		{"missing directory", func(cfg *config.SpoolConfig) { cfg.Dir = "" }},
+		{"non-positive size", func(cfg *config.SpoolConfig) { cfg.MaxBytes = 0 }},
+		{"segment larger than spool", func(cfg *config.SpoolConfig) { cfg.SegmentBytes = cfg.MaxBytes + 1 }},
+		{"unknown fsync policy", func(cfg *config.SpoolConfig) { cfg.FsyncPolicy = "sometimes" }},
+		{"interval without period", func(cfg *config.SpoolConfig) {
+			cfg.FsyncPolicy = FsyncInterval
+			cfg.FsyncIntervalMs = 0
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := testSpoolConfig(t)
+			tt.mutate(&cfg)
+			_, err := Open(cfg, zap.NewNop())
+			assert.Error(t, err)
+		})
+	}
+}
@@ -0,0 +1,177 @@
+package validation
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/distributed-event-processor/services/event-gateway/internal/config"
+	"github.com/distributed-event-processor/services/event-gateway/internal/models"
+	"github.com/distributed-event-processor/services/event-gateway/internal/schema"
+)
+
+// Violation codes
+const (
+	CodeRequired      = "REQUIRED_FIELD"
+	CodeInvalidFormat = "INVALID_FORMAT"
+	CodeOutOfRange    = "OUT_OF_RANGE"
+)
+
+// MaxBatchSize is the most events a single batch request may carry
+const MaxBatchSize = 100
+
+// DefaultMaxClockSkew is how far ahead of the gateway's clock an event
+// timestamp may be when no skew is configured
+const DefaultMaxClockSkew = 5 * time.Minute
+
+// reservedMetadata are metadata keys the gateway sets itself, so values sent
+// by clients may be replaced
+var reservedMetadata = []string{
+	models.MetadataRequestID,
+	models.MetadataTraceParent,
+	models.MetadataTraceState,
+	models.MetadataIdempotencyKey,
+}
+
+// Result lists every violation that makes an event invalid, and warnings
+// about things that do not stop it from being ingested
+type Result struct {
+	Errors   []models.ValidationError
+	Warnings []string
+}
+
+// Valid reports whether the event has no violations
+func (r Result) Valid() bool {
+	return len(r.Errors) == 0
+}
+
+// Err returns the violations as an *Error, or nil if there are none
+func (r Result) Err() error {
+	if r.Valid() {
+		return nil
+	}
+	return &Error{Violations: r.Errors}
+}
+
+func (r *Result) addError(field, code, message string) {
+	r.Errors = append(r.Errors, models.ValidationError{Field: field, Code: code, Message: message})
+}
+
+// Error reports every violation of an invalid event
+type Error struct {
+	Violations []models.ValidationError
+}
+
+func (e *Error) Error() string {
+	messages := make([]string, len(e.Violations))
+	for i, violation := range e.Violations {
+		messages[i] = violation.Message
+	}
+	return strings.Join(messages, "; ")
+}
+
+// Validator checks events against the ingestion rules. Both transports use
+// it, so an event is accepted or rejected the same way over HTTP and gRPC.
+type Validator struct {
+	maxSkew time.Duration
+	maxAge  time.Duration
+	schemas *schema.Validator
+	now     func() time.Time
+}
+
+// New creates a validator with the timestamp limits of cfg. Event data is
+// checked against schemas too, unless schemas is nil.
+func New(cfg config.IngestConfig, schemas *schema.Validator) *Validator {
+	maxSkew := time.Duration(cfg.MaxClockSkewMs) * time.Millisecond
+	if maxSkew <= 0 {
+		maxSkew = DefaultMaxClockSkew
+	}
+
+	return &Validator{
+		maxSkew: maxSkew,
+		maxAge:  time.Duration(cfg.MaxEventAgeMs) * time.Millisecond,
+		schemas: schemas,
+		now:     time.Now,
+	}
+}
+
+// Validate checks an event as the client sent it, before the gateway fills
+// anything in: an empty ID, a zero timestamp and nil data were left out
+func (v *Validator) Validate(event *models.Event) Result {
+	return v.validate(event, false)
+}
+
+// ValidateStrict is Validate, except that data not matching its schema is an
+// error whatever the schema validation mode of the event's type
+func (v *Validator) ValidateStrict(event *models.Event) Result {
+	return v.validate(event, true)
+}
+
+func (v *Validator) validate(event *models.Event, strictSchema bool) Result {
+	var result Result
+	if event == nil {
+		result.addError("event", CodeRequired, "event cannot be nil")
+		return result
+	}
+
+	if event.Type == "" {
+		result.addError("type", CodeRequired, "event type is required")
+	}
+	if event.Source == "" {
+		result.addError("source", CodeRequired, "event source is required")
+	}
+	if event.Data == nil {
+		result.addError("data", CodeRequired, "event data is required")
+	} else if len(event.Data) == 0 {
+		result.Warnings = append(result.Warnings, "event data is empty")
+	}
+
+	if event.ID != "" && !models.ValidEventID(event.ID) {
+		result.addError("id", CodeInvalidFormat,
+			"event id must be 1-128 letters, digits, '.', '_', ':' or '-', starting with a letter or digit")
+	}
+
+	if !event.Timestamp.IsZero() {
+		now := v.now()
+		if event.Timestamp.After(now.Add(v.maxSkew)) {
+			result.addError("timestamp", CodeOutOfRange,
+				fmt.Sprintf("event timestamp is more than %s in the future", v.maxSkew))
+		}
+		if v.maxAge > 0 && event.Timestamp.Before(now.Add(-v.maxAge)) {
+			result.addError("timestamp", CodeOutOfRange,
+				fmt.Sprintf("event timestamp is more than %s in the past", v.maxAge))
+		}
+	}
+
+	if event.Priority < 0 || event.Priority > 10 {
+		result.addError("priority", CodeOutOfRange, "event priority must be between 0 and 10")
+	}
+
+	if v.schemas != nil && event.Data != nil {
+		violations, warnings := v.schemas.Validate(event, strictSchema)
+		result.Errors = append(result.Errors, violations...)
+		result.Warnings = append(result.Warnings, warnings...)
+	}
+
+	for _, key := range reservedMetadata {
+		if _, ok := event.Metadata[key]; ok {
+			result.Warnings = append(result.Warnings,
+				fmt.Sprintf("metadata key %q is reserved for the gateway and may be overwritten", key))
+		}
+	}
+
+	return result
+}
+
+// ValidateBatchSize checks that a batch has between 1 and MaxBatchSize events
+func ValidateBatchSize(size int) Result {
+	var result Result
+	switch {
+	case size == 0:
+		result.addError("events", CodeRequired, "batch cannot be empty")
+	case size > MaxBatchSize:
+		result.addError("events", CodeOutOfRange,
+			fmt.Sprintf("batch cannot have more than %d events", MaxBatchSize))
+	}
+	return result
+}
@@ -0,0 +1,123 @@
+package validation
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/distributed-event-processor/services/event-gateway/internal/config"
+	"github.com/distributed-event-processor/services/event-gateway/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestValidator(cfg config.IngestConfig, now time.Time) *Validator {
+	validator := New(cfg, nil)
+	validator.now = func() time.Time { return now }
+	return validator
+}
+
+func testEvent() *models.Event {
+	return &models.Event{
+		Type:   "user.created",
+		Source: "user-service",
+		Data:   map[string]interface{}{"user_id": "123"},
+	}
+}
+
+func TestValidate_Valid(t *testing.T) {
+	result := New(config.IngestConfig{}, nil).Validate(testEvent())
+
+	assert.True(t, result.Valid())
+	assert.NoError(t, result.Err())
+	assert.Empty(t, result.Warnings)
+}
+
+func TestValidate_NilEvent(t *testing.T) {
+	result := New(config.IngestConfig{}, nil).Validate(nil)
+
+	require.Len(t, result.Errors, 1)
+	assert.Equal(t, "event", result.Errors[0].Field)
+	assert.Contains(t, result.Err().Error(), "cannot be nil")
+}
+
+func TestValidate_ReportsEveryViolation(t *testing.T) {
+	result := New(config.IngestConfig{}, nil).Validate(&models.Event{ID: "-42", Priority: -1})
+
+	assert.Equal(t, []models.ValidationError{
+		{Field: "type", Code: CodeRequired, Message: "event type is required"},
+		{Field: "source", Code: CodeRequired, Message: "event source is required"},
+		{Field: "data", Code: CodeRequired, Message: "event data is required"},
+		{Field: "id", Code: CodeInvalidFormat, Message: result.Errors[3].Message},
+		{Field: "priority", Code: CodeOutOfRange, Message: "event priority must be between 0 and 10"},
+	}, result.Errors)
+	assert.True(t, strings.HasPrefix(result.Err().Error(), "event type is required; event source is required; "))
+}
+
+func TestValidate_InvalidFields(t *testing.T) {
+	now := time.Now()
+	validator := newTestValidator(config.IngestConfig{MaxClockSkewMs: 60000, MaxEventAgeMs: 86400000}, now)
+
+	tests := []struct {
+		name   string
+		field  string
+		modify func(event *models.Event)
+	}{
+		{"id with spaces", "id", func(event *models.Event) { event.ID = "order 42" }},
+		{"id starting with a dash", "id", func(event *models.Event) { event.ID = "-42" }},
+		{"id too long", "id", func(event *models.Event) { event.ID = strings.Repeat("a", 129) }},
+		{"timestamp beyond the clock skew", "timestamp", func(event *models.Event) { event.Timestamp = now.Add(2 * time.Minute) }},
+		{"timestamp older than the max age", "timestamp", func(event *models.Event) { event.Timestamp = now.Add(-48 * time.Hour) }},
+		{"negative priority", "priority", func(event *models.Event) { event.Priority = -1 }},
+		{"priority above 10", "priority", func(event *models.Event) { event.Priority = 11 }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			event := testEvent()
+			tt.modify(event)
+			result := validator.Validate(event)
+			require.Len(t, result.Errors, 1)
+			assert.Equal(t, tt.field, result.Errors[0].Field)
+		})
+	}
+}
+
+func TestValidate_DefaultClockSkew(t *testing.T) {
+	now := time.Now()
+	validator := newTestValidator(config.IngestConfig{}, now)
+
+	event := testEvent()
+	event.Timestamp = now.Add(DefaultMaxClockSkew - time.Second)
+	assert.True(t, validator.Validate(event).Valid())
+
+	// Without a max age, events of any age are accepted
+	event.Timestamp = now.Add(-365 * 24 * time.Hour)
+	assert.True(t, validator.Validate(event).Valid())
+}
+
+func TestValidate_Warnings(t *testing.T) {
+	event := testEvent()
+	event.Data = map[string]interface{}{}
+	event.Metadata = map[string]string{"traceparent": "client-value", "env": "test"}
+
+	result := New(config.IngestConfig{}, nil).Validate(event)
+
+	assert.True(t, result.Valid())
+	require.Len(t, result.Warnings, 2)
+	assert.Equal(t, "event data is empty", result.Warnings[0])
+	assert.Contains(t, result.Warnings[1], `"traceparent"`)
+}
+
+func TestValidateBatchSize(t *testing.T) {
+	assert.True(t, ValidateBatchSize(1).Valid())
+	assert.True(t, ValidateBatchSize(MaxBatchSize).Valid())
+
+	empty := ValidateBatchSize(0)
+	require.Len(t, empty.Errors, 1)
+	assert.Equal(t, "batch cannot be empty", empty.Errors[0].Message)
+
+	tooLarge := ValidateBatchSize(MaxBatchSize + 1)
+	require.Len(t, tooLarge.Errors, 1)
+	assert.Equal(t, CodeOutOfRange, tooLarge.Errors[0].Code)
+}
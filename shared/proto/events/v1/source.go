@@ -0,0 +1,9 @@
+package eventsv1
+
+import _ "embed"
+
+// EventsProto is the source of events.proto, for registering the event schema
+// with a schema registry
+//
+//go:embed events.proto
+var EventsProto string